@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jsfour/assist-tee/internal/database"
@@ -30,6 +32,52 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Fail fast on a malformed execution proxy rather than on the first networked execution
+	if err := executor.ValidateProxy(executor.ServerProxy()); err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: invalid EXEC_*_PROXY setting: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// Fail fast on unknown gVisor options rather than on the first setup
+	if err := executor.ValidateRunscConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: invalid RUNSC_* setting: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// Fail fast on a malformed CPU pinning rather than on every execution
+	if err := executor.ValidateCpusetConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: invalid EXEC_CPUSET_CPUS setting: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// Fail fast on an unknown or malformed lint rule rather than on every setup
+	if err := executor.ValidateLintConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: invalid MODULE_LINT_RULES setting: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	// Refuse to run unsandboxed and unauthenticated unless explicitly acknowledged
+	if executor.IsGVisorDisabled() && middleware.IsAuthDisabled() {
+		if os.Getenv("I_KNOW_THIS_IS_INSECURE") != "true" {
+			logger.Log.Error("refusing to start with gVisor and authentication both disabled",
+				slog.String("security", "insecure"),
+				slog.String("risk", "any client that can reach the server can run unsandboxed code against the host kernel"),
+			)
+			fmt.Fprintln(os.Stderr, "FATAL: DISABLE_GVISOR and DISABLE_BEARER_TOKEN are both set; anyone who can reach this server could run unsandboxed code on the host. Set I_KNOW_THIS_IS_INSECURE=true to allow this for local development.")
+			os.Exit(1)
+		}
+		logger.Log.Warn("running with gVisor and authentication both disabled",
+			slog.String("security", "insecure"),
+		)
+	}
+
+	// Churn is measured from the host cgroup, which can't see inside gVisor
+	if executor.ProcessChurnLimit() > 0 && !executor.IsGVisorDisabled() {
+		logger.Log.Warn("EXEC_PROCESS_CHURN_LIMIT has no effect: process churn can't be measured under gVisor",
+			slog.Int("limit", executor.ProcessChurnLimit()),
+		)
+	}
+
 	// Print startup banner to stdout (not through logger for visual clarity)
 	fmt.Println("=" + strings.Repeat("=", 78))
 	fmt.Println("  TEE API Server - Trusted Execution Environment")
@@ -80,6 +128,21 @@ func main() {
 		)
 	}
 
+	// Verify the docker daemon (local or DOCKER_HOST) is reachable
+	logger.Log.Info("verifying docker daemon",
+		slog.String("docker_host", executor.DockerHost()),
+	)
+	verifyCtx, cancelVerify := context.WithTimeout(context.Background(), 10*time.Second)
+	err := executor.VerifyDockerHost(verifyCtx)
+	cancelVerify()
+	if err != nil {
+		logger.Log.Error("failed to reach docker daemon",
+			slog.String("docker_host", executor.DockerHost()),
+			slog.String("error", err.Error()),
+		)
+		os.Exit(1)
+	}
+
 	// Connect to database
 	logger.Log.Info("connecting to database")
 	if err := database.Connect(); err != nil {
@@ -89,6 +152,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Optional read replica for list, get and stats queries
+	database.ConnectReplica()
+
 	// Initialize schema
 	if err := database.InitSchema(); err != nil {
 		logger.Log.Error("failed to initialize database schema",
@@ -108,25 +174,67 @@ func main() {
 	// Start background reaper
 	reaper.StartReaper()
 
+	// Pull runtime images up front; /health/ready fails until they are all present
+	if executor.PrepullEnabled() {
+		go func() {
+			if err := executor.PrepullImages(context.Background()); err != nil {
+				logger.Log.Error("failed to pre-pull images",
+					slog.String("error", err.Error()),
+				)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Create executor and server
-	exec := executor.NewDockerExecutor()
+	var exec executor.Executor = executor.NewDockerExecutor()
+	faultInjection := executor.FaultInjectionEnabled()
+	if faultInjection {
+		// Test-only: lets clients force failures with the X-Fault-Inject header
+		logger.Log.Warn("fault injection is ENABLED - do not use in production",
+			slog.String("security", "degraded"),
+		)
+		exec = executor.NewFaultInjector(exec)
+	}
 	server := handlers.NewServer(exec)
+	server.Audit = database.AuditLog{}
 
 	// Setup routes
 	r := mux.NewRouter()
 
 	// API routes
-	r.HandleFunc("/environments/setup", server.HandleSetup).Methods("POST")
-	r.HandleFunc("/environments/{id}/execute", server.HandleExecute).Methods("POST")
-	r.HandleFunc("/environments/{id}", server.HandleDelete).Methods("DELETE")
+	r.HandleFunc("/environments/setup", server.Audited("setup", server.HandleSetup)).Methods("POST")
+	r.HandleFunc("/environments/import", server.Audited("import", server.HandleImport)).Methods("POST")
+	r.HandleFunc("/environments/{id}/execute", server.Audited("execute", server.HandleExecute)).Methods("POST")
+	r.HandleFunc("/environments/{id}/batch", server.Audited("batch_execute", server.HandleBatchExecute)).Methods("POST")
+	r.HandleFunc("/environments/{id}/executions/latest", server.HandleLatestExecution).Methods("GET")
+	r.HandleFunc("/environments/{id}/executions/{execId}", server.Audited("cancel_execution", server.HandleCancelExecution)).Methods("DELETE")
+	r.HandleFunc("/environments/{id}/executions", server.HandleListExecutions).Methods("GET")
+	r.HandleFunc("/environments/{id}/stats", server.HandleStats).Methods("GET")
+	r.HandleFunc("/environments/{id}/export", server.HandleExport).Methods("GET")
+	r.HandleFunc("/environments/{id}/ready", server.HandleEnvironmentReady).Methods("GET")
+	r.HandleFunc("/environments/{id}", server.HandleGet).Methods("GET", "HEAD")
+	r.HandleFunc("/environments/{id}", server.Audited("update", server.HandlePatch)).Methods("PATCH")
+	r.HandleFunc("/environments/{id}", server.Audited("delete", server.HandleDelete)).Methods("DELETE")
 	r.HandleFunc("/environments", server.HandleList).Methods("GET")
+	r.HandleFunc("/groups/{groupId}", server.Audited("update_group", server.HandlePatchGroup)).Methods("PATCH")
+	r.HandleFunc("/templates", server.Audited("create_template", server.HandleCreateTemplate)).Methods("POST")
+	r.HandleFunc("/admin/audit", server.HandleListAudit).Methods("GET")
+	r.HandleFunc("/admin/health", server.HandleAdminHealth).Methods("GET")
+	r.HandleFunc("/admin/maintenance", server.HandleGetMaintenance).Methods("GET")
+	r.HandleFunc("/admin/maintenance", server.Audited("maintenance", server.HandleSetMaintenance)).Methods("PUT")
+	r.HandleFunc("/health/ready", server.HandleReady).Methods("GET")
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	}).Methods("GET")
 
-	// Apply middleware (order matters: recovery -> logging -> auth -> routes)
-	handler := middleware.Recovery(middleware.RequestLogging(middleware.BearerAuth(r)))
+	// Apply middleware (order matters: recovery -> logging -> compression -> CORS -> auth -> [fault injection] -> routes)
+	var routes http.Handler = r
+	if faultInjection {
+		routes = middleware.FaultInjection(routes)
+	}
+	handler := middleware.Recovery(middleware.RequestLogging(middleware.Compress(middleware.CORS(middleware.BearerAuth(routes)))))
 
 	// Start server
 	port := getEnv("PORT", "8080")
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// AuditLog stores audit entries in the audit_log table
+type AuditLog struct{}
+
+// RecordAudit appends an entry to the audit log
+func (AuditLog) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return DB.QueryRowContext(ctx, `
+		INSERT INTO audit_log (actor, action, environment_id, outcome, status_code, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, entry.Actor, entry.Action, entry.EnvironmentID, entry.Outcome, entry.StatusCode,
+		sql.NullString{String: entry.RequestID, Valid: entry.RequestID != ""},
+	).Scan(&entry.ID, &entry.CreatedAt)
+}
+
+// AuditFilter narrows ListAudit results; zero values are ignored
+type AuditFilter struct {
+	Actor         string
+	Action        string
+	EnvironmentID *uuid.UUID
+	Since         time.Time
+	Until         time.Time
+	Limit         int
+}
+
+// ListAudit returns audit entries matching filter, newest first
+func ListAudit(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	addCondition := func(clause string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.EnvironmentID != nil {
+		addCondition("environment_id = $%d", *filter.EnvironmentID)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at < $%d", filter.Until)
+	}
+
+	query := `SELECT id, actor, action, environment_id, outcome, status_code, request_id, created_at FROM audit_log`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	var rows *sql.Rows
+	err := WithRetry(ctx, func() error {
+		var err error
+		rows, err = DB.QueryContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var requestID sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.EnvironmentID,
+			&entry.Outcome, &entry.StatusCode, &requestID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.RequestID = requestID.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
@@ -1,10 +1,12 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -20,6 +22,14 @@ func Connect() error {
 	password := getEnv("DB_PASSWORD", "tee")
 	dbname := getEnv("DB_NAME", "tee")
 
+	pool, err := poolConfigFromEnv()
+	if err != nil {
+		logger.Log.Error("invalid database pool configuration",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+
 	logger.Log.Info("connecting to database",
 		slog.String("host", host),
 		slog.String("port", port),
@@ -31,8 +41,12 @@ func Connect() error {
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname,
 	)
+	if timeout := statementTimeout(); timeout > 0 {
+		// Applied server-side to every pooled connection so a runaway query
+		// cannot hold a connection indefinitely
+		connStr += fmt.Sprintf(" options='-c statement_timeout=%d'", timeout.Milliseconds())
+	}
 
-	var err error
 	DB, err = sql.Open("postgres", connStr)
 	if err != nil {
 		logger.Log.Error("failed to open database connection",
@@ -42,9 +56,14 @@ func Connect() error {
 	}
 
 	// Configure connection pool
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(5)
-	DB.SetConnMaxLifetime(5 * time.Minute)
+	DB.SetMaxOpenConns(pool.maxOpen)
+	DB.SetMaxIdleConns(pool.maxIdle)
+	DB.SetConnMaxLifetime(pool.maxLifetime)
+	logger.Log.Info("database connection pool settings",
+		slog.Int("max_open_conns", pool.maxOpen),
+		slog.Int("max_idle_conns", min(pool.maxIdle, pool.maxOpen)),
+		slog.Duration("conn_max_lifetime", pool.maxLifetime),
+	)
 
 	// Test connection with retries
 	logger.Log.Debug("testing database connection with retries",
@@ -107,6 +126,20 @@ func InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_environments_last_executed_at ON environments(last_executed_at);
 	CREATE INDEX IF NOT EXISTS idx_environments_status ON environments(status);
 
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS warmed_up BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS idle_timeout_seconds INTEGER;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS keep_alive_on_activity BOOLEAN;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS ref_count INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX IF NOT EXISTS idx_environments_content_hash ON environments(content_hash);
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS disk_usage_bytes BIGINT;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS group_id VARCHAR(64);
+	CREATE INDEX IF NOT EXISTS idx_environments_group_id ON environments(group_id);
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS created_by VARCHAR(255);
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS updating_since TIMESTAMP;
+	ALTER TABLE environments ADD COLUMN IF NOT EXISTS disk_usage_failed_at TIMESTAMP;
+
 	CREATE TABLE IF NOT EXISTS executions (
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 		environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
@@ -120,6 +153,41 @@ func InitSchema() error {
 
 	CREATE INDEX IF NOT EXISTS idx_executions_environment_id ON executions(environment_id);
 	CREATE INDEX IF NOT EXISTS idx_executions_started_at ON executions(started_at);
+
+	ALTER TABLE executions ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'completed';
+	ALTER TABLE executions ADD COLUMN IF NOT EXISTS outputs JSONB;
+	CREATE INDEX IF NOT EXISTS idx_executions_environment_started_at ON executions(environment_id, started_at);
+	ALTER TABLE executions ADD COLUMN IF NOT EXISTS labels JSONB;
+	CREATE INDEX IF NOT EXISTS idx_executions_labels ON executions USING GIN (labels);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(50) NOT NULL,
+		environment_id UUID,
+		outcome VARCHAR(20) NOT NULL,
+		status_code INTEGER NOT NULL,
+		request_id VARCHAR(255),
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_environment_id ON audit_log(environment_id);
+
+	CREATE TABLE IF NOT EXISTS templates (
+		name VARCHAR(64) PRIMARY KEY,
+		dependencies JSONB,
+		permissions JSONB,
+		ttl_seconds INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS environment_groups (
+		id VARCHAR(64) PRIMARY KEY,
+		limits JSONB,
+		env JSONB,
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);
 	`
 
 	_, err := DB.Exec(schema)
@@ -152,6 +220,79 @@ func LogStats() {
 	)
 }
 
+// poolConfig holds the connection pool settings
+type poolConfig struct {
+	maxOpen     int
+	maxIdle     int
+	maxLifetime time.Duration
+}
+
+// poolConfigFromEnv reads the pool settings from DB_MAX_OPEN_CONNS (default 25),
+// DB_MAX_IDLE_CONNS (default 5) and DB_CONN_MAX_LIFETIME_SEC (default 300).
+// Values that are set must be positive integers.
+func poolConfigFromEnv() (poolConfig, error) {
+	maxOpen, err := positiveEnvInt("DB_MAX_OPEN_CONNS", 25)
+	if err != nil {
+		return poolConfig{}, err
+	}
+	maxIdle, err := positiveEnvInt("DB_MAX_IDLE_CONNS", 5)
+	if err != nil {
+		return poolConfig{}, err
+	}
+	lifetimeSec, err := positiveEnvInt("DB_CONN_MAX_LIFETIME_SEC", 300)
+	if err != nil {
+		return poolConfig{}, err
+	}
+	return poolConfig{
+		maxOpen:     maxOpen,
+		maxIdle:     maxIdle,
+		maxLifetime: time.Duration(lifetimeSec) * time.Second,
+	}, nil
+}
+
+// positiveEnvInt returns the value of an environment variable, or defaultValue
+// when it is unset. A set value that is not a positive integer is an error.
+func positiveEnvInt(key string, defaultValue int) (int, error) {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, raw)
+	}
+	return value, nil
+}
+
+// statementTimeout returns the Postgres statement_timeout applied to pooled
+// connections, from DB_STATEMENT_TIMEOUT_MS (default 30s, 0 disables it)
+func statementTimeout() time.Duration {
+	ms, err := strconv.Atoi(os.Getenv("DB_STATEMENT_TIMEOUT_MS"))
+	if err != nil || ms < 0 {
+		ms = 30000
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// LongRunningConn returns a dedicated connection with the statement timeout
+// lifted, for intentional long-running work such as the reaper's scan. Call
+// release when done; it restores the timeout and returns the connection to the pool.
+func LongRunningConn(ctx context.Context) (conn *sql.Conn, release func(), err error) {
+	conn, err = DB.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	release = func() {
+		conn.ExecContext(context.WithoutCancel(ctx), "RESET statement_timeout")
+		conn.Close()
+	}
+	return conn, release, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -159,3 +300,11 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
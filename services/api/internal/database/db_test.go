@@ -0,0 +1,49 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatementTimeout(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      30 * time.Second,
+		"5000":  5 * time.Second,
+		"0":     0,
+		"-1":    30 * time.Second,
+		"bogus": 30 * time.Second,
+	}
+	for value, want := range cases {
+		t.Setenv("DB_STATEMENT_TIMEOUT_MS", value)
+		if got := statementTimeout(); got != want {
+			t.Errorf("DB_STATEMENT_TIMEOUT_MS=%q: expected %v, got %v", value, want, got)
+		}
+	}
+}
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	pool, err := poolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pool != (poolConfig{maxOpen: 25, maxIdle: 5, maxLifetime: 5 * time.Minute}) {
+		t.Errorf("expected the default pool settings, got %+v", pool)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "80")
+	t.Setenv("DB_CONN_MAX_LIFETIME_SEC", "60")
+	pool, err = poolConfigFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pool.maxOpen != 80 || pool.maxIdle != 5 || pool.maxLifetime != time.Minute {
+		t.Errorf("expected configured pool settings, got %+v", pool)
+	}
+
+	for _, value := range []string{"0", "-3", "many"} {
+		t.Setenv("DB_MAX_IDLE_CONNS", value)
+		if _, err := poolConfigFromEnv(); err == nil {
+			t.Errorf("DB_MAX_IDLE_CONNS=%q: expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// EnvironmentColumns is the column list scanned by ScanEnvironment
+const EnvironmentColumns = `id, volume_name, main_module, created_at, last_executed_at,
+	execution_count, status, metadata, ttl_seconds, warmed_up, version,
+	idle_timeout_seconds, keep_alive_on_activity, ref_count, disk_usage_bytes,
+	group_id, created_by`
+
+// Scanner is implemented by *sql.Row and *sql.Rows
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// ScanEnvironment scans a row selected with EnvironmentColumns into env
+func ScanEnvironment(row Scanner, env *models.Environment) error {
+	var metadataJSON []byte
+	var groupID, createdBy sql.NullString
+	err := row.Scan(
+		&env.ID, &env.VolumeName, &env.MainModule, &env.CreatedAt,
+		&env.LastExecutedAt, &env.ExecutionCount, &env.Status,
+		&metadataJSON, &env.TTLSeconds, &env.WarmedUp, &env.Version,
+		&env.IdleTimeoutSeconds, &env.KeepAliveOnActivity, &env.RefCount,
+		&env.DiskUsageBytes, &groupID, &createdBy,
+	)
+	if err != nil {
+		return err
+	}
+	env.GroupID = groupID.String
+	env.CreatedBy = createdBy.String
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &env.Metadata); err != nil {
+			// Leave Metadata nil, so readers can tell a corrupt record from
+			// one without metadata, which is upgraded to an empty map
+			logger.Log.Error("environment metadata is malformed",
+				slog.String("environment_id", env.ID.String()),
+				slog.String("error", err.Error()),
+			)
+			env.Metadata = nil
+			return nil
+		}
+	}
+	env.Metadata = UpgradeMetadata(env.Metadata)
+	return nil
+}
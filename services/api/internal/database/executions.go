@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// ErrNoExecutions is returned when an environment has no stored executions.
+var ErrNoExecutions = errors.New("no executions found")
+
+// executionColumns are the executions columns read by scanExecution
+const executionColumns = "id, status, exit_code, duration_ms, started_at, completed_at, labels"
+
+// ListExecutions returns an environment's stored executions, newest first, at
+// most limit of them. With labels, only executions carrying every one of those
+// labels are returned.
+func ListExecutions(ctx context.Context, envID uuid.UUID, labels map[string]string, limit int) ([]models.ExecutionRecord, error) {
+	var labelsJSON []byte
+	if len(labels) > 0 {
+		labelsJSON, _ = json.Marshal(labels)
+	}
+
+	records := []models.ExecutionRecord{}
+	err := WithReader(ctx, envID, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+executionColumns+`
+			FROM executions
+			WHERE environment_id = $1
+			  AND ($2::jsonb IS NULL OR labels @> $2::jsonb)
+			ORDER BY started_at DESC
+			LIMIT $3
+		`, envID, labelsJSON, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		records = records[:0]
+		for rows.Next() {
+			var record models.ExecutionRecord
+			if err := scanExecution(rows, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// LatestExecution returns an environment's most recently started execution,
+// including its output, or ErrNoExecutions when it has none. The lookup is
+// served by the (environment_id, started_at) index.
+func LatestExecution(ctx context.Context, envID uuid.UUID) (*models.ExecutionRecord, error) {
+	var record models.ExecutionRecord
+	err := WithReader(ctx, envID, func(db *sql.DB) error {
+		var stdout, stderr sql.NullString
+		var outputs []byte
+		err := scanExecution(db.QueryRowContext(ctx, `
+			SELECT `+executionColumns+`, stdout, stderr, outputs
+			FROM executions
+			WHERE environment_id = $1
+			ORDER BY started_at DESC
+			LIMIT 1
+		`, envID), &record, &stdout, &stderr, &outputs)
+		if err != nil {
+			return err
+		}
+		record.Stdout, record.Stderr = stdout.String, stderr.String
+		if outputs != nil {
+			json.Unmarshal(outputs, &record.Outputs)
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoExecutions
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ExecutionExists reports whether an execution with the given ID has been
+// stored, in any environment. It reads the primary, since the ID is about to
+// be inserted there.
+func ExecutionExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM executions WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
+
+// scanExecution scans executionColumns, followed by any extra columns, into record
+func scanExecution(row interface{ Scan(...any) error }, record *models.ExecutionRecord, extra ...any) error {
+	var exitCode sql.NullInt64
+	var durationMs sql.NullInt64
+	var completedAt sql.NullTime
+	var labels []byte
+	dest := append([]any{&record.ID, &record.Status, &exitCode, &durationMs,
+		&record.StartedAt, &completedAt, &labels}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		record.ExitCode = &code
+	}
+	if durationMs.Valid {
+		record.DurationMs = &durationMs.Int64
+	}
+	if completedAt.Valid {
+		record.CompletedAt = &completedAt.Time
+	}
+	if labels != nil {
+		json.Unmarshal(labels, &record.Labels)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/jsfour/assist-tee/internal/models"
+	"github.com/lib/pq"
+)
+
+// UpdateGroupDefaults applies a patch to a group's defaults, creating the group
+// if it has none yet. Limits, when set, replace the stored limits. Env vars are
+// merged into the stored ones, and an empty value removes the var. Returns the
+// updated group with its member count.
+func UpdateGroupDefaults(ctx context.Context, groupID string, patch *models.GroupDefaults) (*models.EnvironmentGroup, error) {
+	var limitsJSON []byte
+	if patch.Limits != nil {
+		var err error
+		if limitsJSON, err = json.Marshal(patch.Limits); err != nil {
+			return nil, err
+		}
+	}
+	setEnv := map[string]string{}
+	var removeEnv []string
+	for name, value := range patch.Env {
+		if value == "" {
+			removeEnv = append(removeEnv, name)
+		} else {
+			setEnv[name] = value
+		}
+	}
+	sort.Strings(removeEnv)
+	setEnvJSON, err := json.Marshal(setEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.EnvironmentGroup{ID: groupID}
+	var storedLimits, storedEnv []byte
+	err = WithRetry(ctx, func() error {
+		return DB.QueryRowContext(ctx, `
+			INSERT INTO environment_groups (id, limits, env)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (id) DO UPDATE SET
+				limits = COALESCE(EXCLUDED.limits, environment_groups.limits),
+				env = (COALESCE(environment_groups.env, '{}'::jsonb) || EXCLUDED.env) - $4::text[],
+				updated_at = NOW()
+			RETURNING limits, env, updated_at,
+				(SELECT COUNT(*) FROM environments WHERE group_id = $1)
+		`, groupID, limitsJSON, setEnvJSON, pq.Array(removeEnv)).Scan(&storedLimits, &storedEnv, &group.UpdatedAt, &group.MemberCount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := DecodeGroupDefaults(storedLimits, storedEnv)
+	if err != nil {
+		return nil, err
+	}
+	group.Defaults = *defaults
+	return group, nil
+}
+
+// DecodeGroupDefaults decodes a group's stored limits and env columns. Either
+// may be NULL.
+func DecodeGroupDefaults(limitsJSON, envJSON []byte) (*models.GroupDefaults, error) {
+	defaults := &models.GroupDefaults{}
+	if limitsJSON != nil {
+		if err := json.Unmarshal(limitsJSON, &defaults.Limits); err != nil {
+			return nil, err
+		}
+	}
+	if envJSON != nil {
+		if err := json.Unmarshal(envJSON, &defaults.Env); err != nil {
+			return nil, err
+		}
+	}
+	if len(defaults.Env) == 0 {
+		defaults.Env = nil
+	}
+	return defaults, nil
+}
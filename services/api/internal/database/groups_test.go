@@ -0,0 +1,22 @@
+package database
+
+import "testing"
+
+func TestDecodeGroupDefaults(t *testing.T) {
+	defaults, err := DecodeGroupDefaults(nil, nil)
+	if err != nil || defaults.Limits != nil || defaults.Env != nil {
+		t.Errorf("expected empty defaults for a missing group, got %+v, %v", defaults, err)
+	}
+
+	defaults, err = DecodeGroupDefaults([]byte(`{"timeoutMs":10000,"memoryMb":256}`), []byte(`{"API_TOKEN":"t"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if defaults.Limits.MemoryMb != 256 || defaults.Env["API_TOKEN"] != "t" {
+		t.Errorf("unexpected defaults: %+v", defaults)
+	}
+
+	if _, err := DecodeGroupDefaults([]byte(`{"timeoutMs":`), nil); err == nil {
+		t.Error("expected malformed limits to fail")
+	}
+}
@@ -0,0 +1,55 @@
+package database
+
+// MetadataVersion is the environment metadata format setup writes, stamped
+// into the stored metadata as metadataVersion. Metadata without one predates
+// versioning and is version 0.
+const MetadataVersion = 1
+
+// metadataMigrations[v] upgrades metadata from version v to v+1 in place.
+// Values are written as JSON decodes them (numbers as float64), since that is
+// how stored metadata is read back.
+var metadataMigrations = []func(metadata map[string]interface{}){
+	// 0 -> 1: environments from before the runtime was recorded are deno
+	// environments. Module and dependency counts that were never recorded are
+	// unknown, so they are left absent rather than reported as zero.
+	func(metadata map[string]interface{}) {
+		setMetadataDefault(metadata, "runtime", "deno")
+		setMetadataDefault(metadata, "hasDependencies", false)
+	},
+}
+
+// UpgradeMetadata brings stored environment metadata up to MetadataVersion so
+// readers can rely on the current shape. Missing metadata becomes an empty,
+// current map. Metadata from a newer release is returned unchanged.
+func UpgradeMetadata(metadata map[string]interface{}) map[string]interface{} {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	version := StoredMetadataVersion(metadata)
+	if version >= MetadataVersion {
+		return metadata
+	}
+	for ; version < MetadataVersion; version++ {
+		metadataMigrations[version](metadata)
+	}
+	metadata["metadataVersion"] = float64(MetadataVersion)
+	return metadata
+}
+
+// StoredMetadataVersion returns the metadataVersion recorded in metadata, or 0
+// when it has none.
+func StoredMetadataVersion(metadata map[string]interface{}) int {
+	switch version := metadata["metadataVersion"].(type) {
+	case float64:
+		return int(version)
+	case int:
+		return version
+	}
+	return 0
+}
+
+func setMetadataDefault(metadata map[string]interface{}, key string, value interface{}) {
+	if current, ok := metadata[key]; !ok || current == nil {
+		metadata[key] = value
+	}
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUpgradeMetadata_Unversioned(t *testing.T) {
+	var metadata map[string]interface{}
+	json.Unmarshal([]byte(`{"permissions":null,"moduleCount":2}`), &metadata)
+
+	upgraded := UpgradeMetadata(metadata)
+
+	if StoredMetadataVersion(upgraded) != MetadataVersion {
+		t.Errorf("expected version %d, got %v", MetadataVersion, upgraded["metadataVersion"])
+	}
+	if upgraded["runtime"] != "deno" {
+		t.Errorf("expected runtime to default to deno, got %v", upgraded["runtime"])
+	}
+	if upgraded["moduleCount"] != float64(2) {
+		t.Errorf("expected the stored moduleCount to be kept, got %v", upgraded["moduleCount"])
+	}
+	if _, ok := upgraded["dependencyCount"]; ok {
+		t.Errorf("expected the unknown dependencyCount to stay absent, got %v", upgraded["dependencyCount"])
+	}
+	if upgraded["hasDependencies"] != false {
+		t.Errorf("expected hasDependencies to default, got %v", upgraded["hasDependencies"])
+	}
+}
+
+func TestUpgradeMetadata_Nil(t *testing.T) {
+	upgraded := UpgradeMetadata(nil)
+	if upgraded == nil || StoredMetadataVersion(upgraded) != MetadataVersion {
+		t.Errorf("expected an empty current map, got %v", upgraded)
+	}
+	upgraded["template"] = "writable"
+}
+
+func TestUpgradeMetadata_NewerVersion(t *testing.T) {
+	metadata := map[string]interface{}{"metadataVersion": float64(MetadataVersion + 1)}
+	upgraded := UpgradeMetadata(metadata)
+	if _, ok := upgraded["runtime"]; ok {
+		t.Errorf("expected metadata from a newer release to be left alone, got %v", upgraded)
+	}
+}
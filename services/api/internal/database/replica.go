@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// Replica is the read replica from DB_REPLICA_URL, used for read-only queries
+// through WithReader. Nil when none is configured or it was unreachable at
+// startup, in which case every query goes to the primary.
+var Replica *sql.DB
+
+// ConnectReplica opens the read replica named by DB_REPLICA_URL, if set. A
+// replica that cannot be reached is logged and skipped rather than failing
+// startup, since the primary can serve reads on its own.
+func ConnectReplica() {
+	dsn := os.Getenv("DB_REPLICA_URL")
+	if dsn == "" {
+		return
+	}
+
+	pool, err := poolConfigFromEnv()
+	if err != nil {
+		logger.Log.Warn("invalid database pool configuration, not using the read replica",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+
+	replica, err := sql.Open("postgres", dsn)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = replica.PingContext(ctx)
+		cancel()
+	}
+	if err != nil {
+		logger.Log.Warn("read replica unreachable, serving reads from the primary",
+			slog.String("error", err.Error()),
+		)
+		if replica != nil {
+			replica.Close()
+		}
+		return
+	}
+
+	replica.SetMaxOpenConns(pool.maxOpen)
+	replica.SetMaxIdleConns(pool.maxIdle)
+	replica.SetConnMaxLifetime(pool.maxLifetime)
+	Replica = replica
+	logger.Log.Info("read replica connection established",
+		slog.Duration("lag_window", replicaLagWindow()),
+	)
+}
+
+// replicaLagWindow returns how long reads of a just-written environment keep
+// going to the primary, from DB_REPLICA_LAG_WINDOW_MS (default 5000)
+func replicaLagWindow() time.Duration {
+	return time.Duration(getEnvInt("DB_REPLICA_LAG_WINDOW_MS", 5000)) * time.Millisecond
+}
+
+// writeTracker remembers when environments were last written, so reads of them
+// go to the primary until the replica has had time to catch up
+type writeTracker struct {
+	mu      sync.Mutex
+	written map[uuid.UUID]time.Time
+	last    time.Time // the most recent write of any environment
+}
+
+var recentWrites = &writeTracker{written: map[uuid.UUID]time.Time{}}
+
+// maxTrackedWrites bounds the tracker; expired entries are pruned beyond it
+const maxTrackedWrites = 1024
+
+func (t *writeTracker) mark(envID uuid.UUID, now time.Time, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.written) >= maxTrackedWrites {
+		for id, at := range t.written {
+			if now.Sub(at) >= window {
+				delete(t.written, id)
+			}
+		}
+	}
+	t.written[envID] = now
+	t.last = now
+}
+
+// recent reports whether envID was written within window. uuid.Nil asks about
+// any environment, for queries that span all of them.
+func (t *writeTracker) recent(envID uuid.UUID, now time.Time, window time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	at := t.last
+	if envID != uuid.Nil {
+		at = t.written[envID]
+	}
+	return !at.IsZero() && now.Sub(at) < window
+}
+
+// MarkWritten records that an environment was just created, changed or deleted,
+// so WithReader sends reads of it to the primary for DB_REPLICA_LAG_WINDOW_MS.
+func MarkWritten(envID uuid.UUID) {
+	if Replica != nil {
+		recentWrites.mark(envID, time.Now(), replicaLagWindow())
+	}
+}
+
+// WithReader runs a read-only query against the replica, falling back to the
+// primary when there is no replica, when envID (or, for uuid.Nil, any
+// environment) was written within the lag window, or when the replica query
+// fails, including with sql.ErrNoRows, which may only mean the replica is
+// behind. Each attempt is retried like WithRetry.
+func WithReader(ctx context.Context, envID uuid.UUID, query func(db *sql.DB) error) error {
+	if Replica != nil && !recentWrites.recent(envID, time.Now(), replicaLagWindow()) {
+		err := WithRetry(ctx, func() error { return query(Replica) })
+		if err == nil {
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			logger.FromContext(ctx).Warn("read replica query failed, falling back to the primary",
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	return WithRetry(ctx, func() error { return query(DB) })
+}
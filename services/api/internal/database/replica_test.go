@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWriteTracker(t *testing.T) {
+	tracker := &writeTracker{written: map[uuid.UUID]time.Time{}}
+	window := 5 * time.Second
+	now := time.Now()
+	written, other := uuid.New(), uuid.New()
+
+	if tracker.recent(uuid.Nil, now, window) {
+		t.Error("expected no recent writes before any were marked")
+	}
+
+	tracker.mark(written, now, window)
+
+	if !tracker.recent(written, now.Add(time.Second), window) {
+		t.Error("expected a just-written environment to be recent")
+	}
+	if tracker.recent(other, now.Add(time.Second), window) {
+		t.Error("expected an unwritten environment not to be recent")
+	}
+	if !tracker.recent(uuid.Nil, now.Add(time.Second), window) {
+		t.Error("expected uuid.Nil to see the recent write of any environment")
+	}
+	if tracker.recent(written, now.Add(window), window) || tracker.recent(uuid.Nil, now.Add(window), window) {
+		t.Error("expected writes to stop being recent once the lag window passes")
+	}
+}
+
+func TestWriteTracker_PrunesExpired(t *testing.T) {
+	tracker := &writeTracker{written: map[uuid.UUID]time.Time{}}
+	window := time.Second
+	start := time.Now()
+	for i := 0; i < maxTrackedWrites; i++ {
+		tracker.mark(uuid.New(), start, window)
+	}
+
+	fresh := uuid.New()
+	tracker.mark(fresh, start.Add(2*window), window)
+
+	if len(tracker.written) != 1 {
+		t.Errorf("expected expired writes to be pruned, %d remain", len(tracker.written))
+	}
+	if !tracker.recent(fresh, start.Add(2*window), window) {
+		t.Error("expected the fresh write to be kept")
+	}
+}
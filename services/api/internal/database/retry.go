@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/lib/pq"
+)
+
+// RetryConfig controls how transient database errors are retried at runtime
+type RetryConfig struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// retryConfig is loaded from DB_RETRY_ATTEMPTS and DB_RETRY_BACKOFF_MS on first use
+var (
+	retryConfig     *RetryConfig
+	retryConfigOnce sync.Once
+)
+
+func getRetryConfig() *RetryConfig {
+	retryConfigOnce.Do(func() {
+		if retryConfig == nil {
+			retryConfig = &RetryConfig{
+				MaxAttempts: getEnvInt("DB_RETRY_ATTEMPTS", 3),
+				Backoff:     time.Duration(getEnvInt("DB_RETRY_BACKOFF_MS", 100)) * time.Millisecond,
+			}
+		}
+	})
+	return retryConfig
+}
+
+// WithRetry runs op, retrying it when it fails with a transient connection error
+// (e.g. Postgres restarting or failing over). Retries stop when the attempt budget
+// is exhausted or when the next backoff would exceed the context deadline.
+//
+// A lost connection can hide a statement that did commit, so op must be
+// idempotent: a read, or a write that has the same effect run twice. Inserts
+// of fresh rows, counter increments and conditional state transitions must not
+// be wrapped.
+func WithRetry(ctx context.Context, op func() error) error {
+	cfg := getRetryConfig()
+	backoff := cfg.Backoff
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = op()
+		if err == nil || !IsTransient(err) || attempt >= cfg.MaxAttempts {
+			return err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return err
+		}
+
+		logger.FromContext(ctx).Warn("transient database error, retrying",
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// IsTransient reports whether err looks like a lost or refused database connection
+// rather than a query error, so the operation is safe to retry.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exception; 57P01-57P03 are shutdown/unavailable
+		return pqErr.Code.Class() == "08" ||
+			pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+	}
+
+	// A timeout may have fired after the server received the statement
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return !netErr.Timeout()
+	}
+
+	return strings.Contains(err.Error(), "connection refused") ||
+		strings.Contains(err.Error(), "connection reset")
+}
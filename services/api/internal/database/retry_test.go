@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/lib/pq"
+)
+
+func init() {
+	logger.Init(nil)
+}
+
+// setRetryConfig overrides the retry config for the duration of a test
+func setRetryConfig(t *testing.T, cfg *RetryConfig) {
+	previous := getRetryConfig()
+	retryConfig = cfg
+	t.Cleanup(func() { retryConfig = previous })
+}
+
+// timeoutError is a net.Error reporting a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"no rows", sql.ErrNoRows, false},
+		{"connection exception", &pq.Error{Code: "08006"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"refused message", errors.New("dial tcp: connection refused"), true},
+		{"dial error", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"timeout", &net.OpError{Op: "read", Err: timeoutError{}}, false},
+	}
+
+	for _, tc := range cases {
+		if got := IsTransient(tc.err); got != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestWithRetry_RetriesTransientErrors(t *testing.T) {
+	setRetryConfig(t, &RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	calls := 0
+	err := WithRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Errorf("expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestWithRetry_DoesNotRetryQueryErrors(t *testing.T) {
+	setRetryConfig(t, &RetryConfig{MaxAttempts: 3, Backoff: time.Millisecond})
+
+	calls := 0
+	err := WithRetry(context.Background(), func() error {
+		calls++
+		return sql.ErrNoRows
+	})
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected sql.ErrNoRows, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestWithRetry_RespectsDeadline(t *testing.T) {
+	setRetryConfig(t, &RetryConfig{MaxAttempts: 5, Backoff: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := WithRetry(ctx, func() error {
+		calls++
+		return driver.ErrBadConn
+	})
+
+	if err == nil {
+		t.Error("expected error when deadline is shorter than backoff")
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
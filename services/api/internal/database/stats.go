@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// ExecutionStats aggregates the stored executions of an environment started in
+// [since, until); zero times leave that side of the window open
+func ExecutionStats(ctx context.Context, envID uuid.UUID, since, until time.Time) (*models.ExecutionStats, error) {
+	stats := &models.ExecutionStats{
+		EnvironmentID: envID,
+		ExitCodes:     map[string]int{},
+	}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+	if !until.IsZero() {
+		stats.Until = &until
+	}
+
+	sinceArg := sql.NullTime{Time: since, Valid: !since.IsZero()}
+	untilArg := sql.NullTime{Time: until, Valid: !until.IsZero()}
+	const window = `environment_id = $1
+		AND ($2::timestamp IS NULL OR started_at >= $2)
+		AND ($3::timestamp IS NULL OR started_at < $3)`
+
+	err := WithReader(ctx, envID, func(db *sql.DB) error {
+		var successes int
+		var p50, p95, p99 sql.NullFloat64
+		var last sql.NullTime
+		err := db.QueryRowContext(ctx, `
+			SELECT
+				COUNT(*),
+				COUNT(*) FILTER (WHERE exit_code = 0),
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms),
+				percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms),
+				percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms),
+				MAX(started_at)
+			FROM executions
+			WHERE `+window, envID, sinceArg, untilArg,
+		).Scan(&stats.Count, &successes, &p50, &p95, &p99, &last)
+		if err != nil {
+			return err
+		}
+
+		if stats.Count > 0 {
+			stats.SuccessRate = float64(successes) / float64(stats.Count)
+		}
+		stats.DurationMs = models.DurationQuantiles{P50: p50.Float64, P95: p95.Float64, P99: p99.Float64}
+		if last.Valid {
+			stats.LastExecutedAt = &last.Time
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *sql.Rows
+	err = WithReader(ctx, envID, func(db *sql.DB) error {
+		var err error
+		rows, err = db.QueryContext(ctx, `
+			SELECT COALESCE(exit_code, -1), COUNT(*)
+			FROM executions
+			WHERE `+window+`
+			GROUP BY 1
+		`, envID, sinceArg, untilArg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var exitCode, count int
+		if err := rows.Scan(&exitCode, &count); err != nil {
+			return nil, err
+		}
+		stats.ExitCodes[strconv.Itoa(exitCode)] = count
+	}
+	return stats, rows.Err()
+}
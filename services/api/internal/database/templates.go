@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// ErrTemplateNotFound is returned when no template has the requested name
+var ErrTemplateNotFound = errors.New("template not found")
+
+// ErrTemplateExists is returned when registering a name that is already taken
+var ErrTemplateExists = errors.New("template already exists")
+
+// CreateTemplate stores a new template and sets its CreatedAt
+func CreateTemplate(ctx context.Context, tmpl *models.Template) error {
+	depsJSON, err := json.Marshal(tmpl.Dependencies)
+	if err != nil {
+		return err
+	}
+	permsJSON, err := json.Marshal(tmpl.Permissions)
+	if err != nil {
+		return err
+	}
+
+	err = DB.QueryRowContext(ctx, `
+		INSERT INTO templates (name, dependencies, permissions, ttl_seconds)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING created_at
+	`, tmpl.Name, depsJSON, permsJSON, tmpl.TTLSeconds).Scan(&tmpl.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrTemplateExists
+	}
+	return err
+}
+
+// GetTemplate loads a template by name
+func GetTemplate(ctx context.Context, name string) (*models.Template, error) {
+	tmpl := &models.Template{Name: name}
+	var depsJSON, permsJSON []byte
+
+	err := WithRetry(ctx, func() error {
+		return DB.QueryRowContext(ctx, `
+			SELECT dependencies, permissions, ttl_seconds, created_at
+			FROM templates
+			WHERE name = $1
+		`, name).Scan(&depsJSON, &permsJSON, &tmpl.TTLSeconds, &tmpl.CreatedAt)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if depsJSON != nil {
+		if err := json.Unmarshal(depsJSON, &tmpl.Dependencies); err != nil {
+			return nil, err
+		}
+	}
+	if permsJSON != nil {
+		if err := json.Unmarshal(permsJSON, &tmpl.Permissions); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
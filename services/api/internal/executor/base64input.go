@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// decodeBase64Input decodes base64 execute data for a raw stdin module. The
+// decoded length is checked before decoding, so oversized input is rejected
+// without allocating it. Standard and URL-safe alphabets, padded or not, are
+// accepted, and line breaks (as in MIME-wrapped base64) are ignored.
+func decodeBase64Input(data string, limit int64) ([]byte, error) {
+	data = strings.NewReplacer("\r", "", "\n", "").Replace(data)
+	if int64(base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(data, "=")))) > limit {
+		return nil, &Error{
+			Code:    "input_too_large",
+			Message: fmt.Sprintf("decoded input exceeds maximum size of %d bytes", limit),
+		}
+	}
+
+	encoding := base64.StdEncoding
+	if strings.ContainsAny(data, "-_") {
+		encoding = base64.URLEncoding
+	}
+	if !strings.HasSuffix(data, "=") && len(data)%4 != 0 {
+		encoding = encoding.WithPadding(base64.NoPadding)
+	}
+	decoded, err := encoding.DecodeString(data)
+	if err != nil {
+		return nil, &Error{Code: "validation_error", Message: "data is not valid base64: " + err.Error()}
+	}
+	return decoded, nil
+}
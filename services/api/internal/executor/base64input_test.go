@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeBase64Input(t *testing.T) {
+	for _, data := range []string{"AAEC/w==", "AAEC_w", "AAEC/w", "AAEC\n/w=="} {
+		decoded, err := decodeBase64Input(data, 1024)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", data, err)
+			continue
+		}
+		if string(decoded) != "\x00\x01\x02\xff" {
+			t.Errorf("%q: unexpected bytes %v", data, decoded)
+		}
+	}
+
+	var execErr *Error
+	if _, err := decodeBase64Input("not base64!", 1024); !errors.As(err, &execErr) || execErr.Code != "validation_error" {
+		t.Errorf("expected validation_error, got %v", err)
+	}
+	if _, err := decodeBase64Input("AAECAw==", 3); !errors.As(err, &execErr) || execErr.Code != "input_too_large" {
+		t.Errorf("expected input_too_large for 4 decoded bytes over a 3 byte limit, got %v", err)
+	}
+	if _, err := decodeBase64Input("AAEC", 3); err != nil {
+		t.Errorf("expected exactly the limit to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// CallbackRequireHTTPS reports whether callback URLs must use https, from
+// CALLBACK_REQUIRE_HTTPS (default true). Turn it off only for local development.
+func CallbackRequireHTTPS() bool {
+	return getEnvBool("CALLBACK_REQUIRE_HTTPS", true)
+}
+
+// CallbackTimeout returns how long delivering a callback may take, from
+// CALLBACK_TIMEOUT_SECONDS (default 10).
+func CallbackTimeout() time.Duration {
+	return time.Duration(getEnvInt("CALLBACK_TIMEOUT_SECONDS", 10)) * time.Second
+}
+
+// CallbackAllowedHosts returns the hosts callbacks may be delivered to, from the
+// comma-separated CALLBACK_ALLOWED_HOSTS. Entries match like GLOBAL_NET_ALLOWLIST
+// entries. Empty allows any public host.
+func CallbackAllowedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("CALLBACK_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// lookupCallbackHost resolves a callback host; tests replace it.
+var lookupCallbackHost = net.DefaultResolver.LookupNetIP
+
+// callbackBlockedPrefixes are the ranges beyond loopback, private, link-local
+// and multicast that a callback must never reach.
+var callbackBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can embed private IPv4
+}
+
+// callbackAddrAllowed reports whether a callback may connect to addr. Loopback,
+// private, link-local (which covers cloud metadata endpoints) and other
+// non-public addresses are refused.
+func callbackAddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range callbackBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateCallbackURL checks that a callback URL is an absolute https URL
+// (unless CALLBACK_REQUIRE_HTTPS is off), on an allowed host, and that every
+// address the host resolves to is public. Resolution can change before
+// delivery, so deliveries must also go through CallbackHTTPClient, which checks
+// the address it actually connects to.
+func ValidateCallbackURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || u.Hostname() == "" {
+		return fmt.Errorf("callback URL must be an absolute URL with a host")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && !CallbackRequireHTTPS():
+	default:
+		return fmt.Errorf("callback URL must use https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("callback URL must not contain credentials")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if allowed := CallbackAllowedHosts(); len(allowed) > 0 {
+		entry := host
+		if port := u.Port(); port != "" {
+			entry = net.JoinHostPort(host, port)
+		}
+		if !NetHostAllowed(entry, allowed) {
+			return fmt.Errorf("callback host %s is not in CALLBACK_ALLOWED_HOSTS", host)
+		}
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !callbackAddrAllowed(addr) {
+			return fmt.Errorf("callback URL must not target a private or internal address")
+		}
+		return nil
+	}
+	addrs, err := lookupCallbackHost(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve callback host %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !callbackAddrAllowed(addr) {
+			return fmt.Errorf("callback host %s resolves to a private or internal address", host)
+		}
+	}
+	return nil
+}
+
+// CallbackHTTPClient returns a client for delivering callbacks. Its dialer
+// refuses non-public addresses at connect time, after DNS resolution, so a host
+// that rebinds to an internal address between validation and delivery is still
+// blocked. Redirects are validated like the original URL, and proxies are
+// bypassed so the check applies to the real destination.
+func CallbackHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("callback dial to %s refused: %w", address, err)
+			}
+			if !callbackAddrAllowed(addrPort.Addr()) {
+				return fmt.Errorf("callback dial to %s refused: private or internal address", address)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("callback stopped after %d redirects", len(via))
+			}
+			return ValidateCallbackURL(req.Context(), req.URL.String())
+		},
+	}
+}
+
+// DeliverCallback POSTs payload as JSON to a callback URL that has passed
+// ValidateCallbackURL, through CallbackHTTPClient. A response outside 2xx is
+// an error; delivery is not retried.
+func DeliverCallback(ctx context.Context, rawURL string, payload *models.CallbackPayload) error {
+	return postCallback(ctx, CallbackHTTPClient(CallbackTimeout()), rawURL, payload)
+}
+
+// postCallback POSTs payload to rawURL with client.
+func postCallback(ctx context.Context, client *http.Client, rawURL string, payload *models.CallbackPayload) error {
+	log := logger.FromContext(ctx)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal callback: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Execution-Id", payload.ExecutionID.String())
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("callback delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback delivery failed: %s returned %d", req.URL.Host, resp.StatusCode)
+	}
+
+	log.Info("callback delivered",
+		slog.String("execution_id", payload.ExecutionID.String()),
+		slog.String("callback_host", req.URL.Host),
+		slog.Int("status", resp.StatusCode),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+	)
+	return nil
+}
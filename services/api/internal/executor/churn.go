@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// errProcessChurn is the cancellation cause for executions stopped for creating
+// and reaping processes too rapidly.
+var errProcessChurn = errors.New("process churn limit exceeded")
+
+// churnCgroupLookups is how many times the watcher looks for a container's
+// cgroup before giving up, allowing for the container still starting.
+const churnCgroupLookups = 10
+
+// pidsSample is one reading of a container's pids cgroup.
+type pidsSample struct {
+	current   int64 // pids.current: processes and threads alive
+	limitHits int64 // the "max" count in pids.events: forks refused at the pids limit
+}
+
+// readPidsSample reads the pids controller files in a cgroup directory.
+func readPidsSample(dir string) (pidsSample, error) {
+	var sample pidsSample
+
+	current, err := os.ReadFile(filepath.Join(dir, "pids.current"))
+	if err != nil {
+		return sample, err
+	}
+	sample.current, err = strconv.ParseInt(strings.TrimSpace(string(current)), 10, 64)
+	if err != nil {
+		return sample, fmt.Errorf("invalid pids.current: %w", err)
+	}
+
+	events, err := os.ReadFile(filepath.Join(dir, "pids.events"))
+	if err != nil {
+		return sample, err
+	}
+	for _, line := range strings.Split(string(events), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), " "); ok && key == "max" {
+			sample.limitHits, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return sample, fmt.Errorf("invalid pids.events: %w", err)
+			}
+		}
+	}
+	return sample, nil
+}
+
+// churnEvent is the churn seen between two samples.
+type churnEvent struct {
+	at    time.Time
+	count int64
+}
+
+// churnMeter sums process churn over a sliding window. Churn between two samples
+// is the change in live processes plus forks refused at the pids limit, so a
+// handler that keeps spawning and killing processes registers even while it
+// stays under the cap. Sampling misses processes that live and die between
+// samples, making this a lower bound.
+type churnMeter struct {
+	window  time.Duration
+	prev    pidsSample
+	sampled bool
+	events  []churnEvent
+	total   int64
+}
+
+// observe folds in a sample and returns the churn within the window ending now.
+func (m *churnMeter) observe(sample pidsSample, now time.Time) int64 {
+	if m.sampled {
+		count := sample.current - m.prev.current
+		if count < 0 {
+			count = -count
+		}
+		if hits := sample.limitHits - m.prev.limitHits; hits > 0 {
+			count += hits
+		}
+		if count > 0 {
+			m.events = append(m.events, churnEvent{at: now, count: count})
+			m.total += count
+		}
+	}
+	m.prev, m.sampled = sample, true
+
+	cutoff := now.Add(-m.window)
+	for len(m.events) > 0 && !m.events[0].at.After(cutoff) {
+		m.total -= m.events[0].count
+		m.events = m.events[1:]
+	}
+	return m.total
+}
+
+// findContainerCgroup locates a running container's cgroup directory; a
+// variable so tests can point it at a fake cgroup.
+var findContainerCgroup = containerCgroupDir
+
+// containerCgroupDir finds the cgroup v2 directory of the named container under
+// either the systemd or the cgroupfs docker cgroup driver layout.
+func containerCgroupDir(ctx context.Context, name string) (string, error) {
+	output, err := DockerCommand(ctx, "inspect", "--format", "{{.Id}}", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect container %s: %w", name, err)
+	}
+	id := strings.TrimSpace(string(output))
+
+	root := CgroupRoot()
+	for _, dir := range []string{
+		filepath.Join(root, "system.slice", "docker-"+id+".scope"),
+		filepath.Join(root, "docker", id),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, "pids.events")); err == nil {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no pids cgroup found for container %s under %s", name, root)
+}
+
+// watchProcessChurn calls stop with errProcessChurn once the named container's
+// process churn within window exceeds limit. It returns when ctx is done, or
+// early when the container's cgroup can't be found or read, for instance
+// because the host cgroup hierarchy isn't mounted at CGROUP_ROOT.
+//
+// Only containers run by runc can be watched. Under gVisor the handler's
+// processes live inside the sandbox kernel, so the host pids cgroup holds just
+// the sandbox's own processes and never sees the handler fork.
+func watchProcessChurn(ctx context.Context, name string, limit int, window time.Duration, stop context.CancelCauseFunc) {
+	interval := window / 10
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var dir string
+	lookups := 0
+	meter := &churnMeter{window: window}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if dir == "" {
+				// The container may not have started yet
+				found, err := findContainerCgroup(ctx, name)
+				if err != nil {
+					if lookups++; lookups >= churnCgroupLookups && ctx.Err() == nil {
+						logger.FromContext(ctx).Warn("process churn detection unavailable",
+							slog.String("container", name),
+							slog.String("error", err.Error()),
+						)
+						return
+					}
+					continue
+				}
+				dir = found
+			}
+
+			sample, err := readPidsSample(dir)
+			if err != nil {
+				// The container has exited
+				return
+			}
+			if meter.observe(sample, now) > int64(limit) {
+				stop(errProcessChurn)
+				return
+			}
+		}
+	}
+}
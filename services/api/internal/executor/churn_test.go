@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// writePids writes a fake pids cgroup into dir. Each file is replaced whole so
+// a watcher never reads one half-written.
+func writePids(t *testing.T, dir string, current, limitHits int) {
+	t.Helper()
+	for name, content := range map[string]string{
+		"pids.current": strconv.Itoa(current) + "\n",
+		"pids.events":  "max " + strconv.Itoa(limitHits) + "\n",
+	} {
+		tmp := filepath.Join(dir, name+".tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadPidsSample(t *testing.T) {
+	dir := t.TempDir()
+	writePids(t, dir, 12, 3)
+
+	sample, err := readPidsSample(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sample.current != 12 || sample.limitHits != 3 {
+		t.Errorf("expected 12 processes and 3 limit hits, got %+v", sample)
+	}
+
+	if _, err := readPidsSample(t.TempDir()); err == nil {
+		t.Error("expected an error for a missing cgroup")
+	}
+}
+
+func TestChurnMeter(t *testing.T) {
+	start := time.Now()
+	m := &churnMeter{window: time.Second}
+
+	if got := m.observe(pidsSample{current: 1}, start); got != 0 {
+		t.Errorf("expected no churn from the first sample, got %d", got)
+	}
+	m.observe(pidsSample{current: 40}, start.Add(100*time.Millisecond))
+	if got := m.observe(pidsSample{current: 5, limitHits: 10}, start.Add(200*time.Millisecond)); got != 84 {
+		t.Errorf("expected churn of 39+35+10, got %d", got)
+	}
+	if got := m.observe(pidsSample{current: 5, limitHits: 10}, start.Add(1150*time.Millisecond)); got != 45 {
+		t.Errorf("expected churn older than the window dropped, got %d", got)
+	}
+	if got := m.observe(pidsSample{current: 5, limitHits: 10}, start.Add(1250*time.Millisecond)); got != 0 {
+		t.Errorf("expected no churn once the window passed, got %d", got)
+	}
+}
+
+// startChurnWatcher runs watchProcessChurn against the cgroup lookup find,
+// returning the execution's context and a channel closed when the watcher
+// returns. The watcher is stopped and the lookup
+// restored when the test ends.
+func startChurnWatcher(t *testing.T, find func(context.Context, string) (string, error), limit int, window time.Duration) (context.Context, <-chan struct{}) {
+	t.Helper()
+	saved := findContainerCgroup
+	findContainerCgroup = find
+
+	ctx, stop := context.WithCancelCause(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watchProcessChurn(ctx, "tee-exec-test", limit, window, stop)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		stop(nil)
+		<-done
+		findContainerCgroup = saved
+	})
+	return ctx, done
+}
+
+// fakeCgroup returns a cgroup lookup that always finds dir
+func fakeCgroup(dir string) func(context.Context, string) (string, error) {
+	return func(context.Context, string) (string, error) { return dir, nil }
+}
+
+func TestWatchProcessChurn_StopsChurningContainer(t *testing.T) {
+	dir := t.TempDir()
+	writePids(t, dir, 1, 0)
+	ctx, _ := startChurnWatcher(t, fakeCgroup(dir), 20, 500*time.Millisecond)
+
+	for i := 0; ctx.Err() == nil && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		writePids(t, dir, 1+(i%2)*30, 0)
+	}
+	if cause := context.Cause(ctx); cause != errProcessChurn {
+		t.Errorf("expected cause %v, got %v", errProcessChurn, cause)
+	}
+}
+
+func TestWatchProcessChurn_SteadyContainerKeepsRunning(t *testing.T) {
+	dir := t.TempDir()
+	writePids(t, dir, 8, 0)
+	ctx, _ := startChurnWatcher(t, fakeCgroup(dir), 20, 100*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("expected a steady container to keep running, got %v", context.Cause(ctx))
+	}
+}
+
+func TestWatchProcessChurn_GivesUpWithoutCgroup(t *testing.T) {
+	logger.Init(nil)
+	ctx, done := startChurnWatcher(t, func(context.Context, string) (string, error) {
+		return "", errors.New("no cgroup")
+	}, 20, 100*time.Millisecond)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the watcher to give up without a cgroup")
+	}
+	if ctx.Err() != nil {
+		t.Errorf("expected the execution left running, got %v", context.Cause(ctx))
+	}
+}
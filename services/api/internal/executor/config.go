@@ -0,0 +1,217 @@
+package executor
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getEnvInt returns the positive integer value of an environment variable, or defaultValue
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool returns the boolean value of an environment variable, or defaultValue
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// MaxStreamInputBytes returns the maximum size of streamed execution input
+func MaxStreamInputBytes() int64 {
+	return int64(getEnvInt("MAX_STREAM_INPUT_BYTES", 64<<20))
+}
+
+// ContainerStopTimeout returns the grace period given to a cancelled execution's
+// container before it is killed
+func ContainerStopTimeout() time.Duration {
+	return time.Duration(getEnvInt("CONTAINER_STOP_TIMEOUT_SECONDS", 2)) * time.Second
+}
+
+// DepInstallTimeout returns how long installing an environment's dependencies
+// may take before setup or update fails, from DEP_INSTALL_TIMEOUT_SECONDS
+// (default 600).
+func DepInstallTimeout() time.Duration {
+	return time.Duration(getEnvInt("DEP_INSTALL_TIMEOUT_SECONDS", 600)) * time.Second
+}
+
+// OutputStreamingDisabled reports whether execution output is left out of the
+// server log, from DISABLE_OUTPUT_STREAMING. Output is still captured for the
+// result; only the per-line logging is skipped, to cut log volume and overhead.
+func OutputStreamingDisabled() bool {
+	return getEnvBool("DISABLE_OUTPUT_STREAMING", false)
+}
+
+// StreamMaxLineBytes returns the longest output line logged whole while streaming
+// container output, from STREAM_MAX_LINE_BYTES (default 64 KiB). Longer lines are
+// cut short with a truncation marker.
+func StreamMaxLineBytes() int {
+	return getEnvInt("STREAM_MAX_LINE_BYTES", 64<<10)
+}
+
+// RuntimeDefaultLimits returns the timeout and memory limits applied to a runtime's
+// executions when the request does not set them. DEFAULT_TIMEOUT_MS_<RUNTIME> and
+// DEFAULT_MEMORY_MB_<RUNTIME> (e.g. DEFAULT_MEMORY_MB_DENO) override the global defaults.
+func RuntimeDefaultLimits(runtime string) (timeoutMs, memoryMb int) {
+	suffix := strings.ToUpper(runtime)
+	timeoutMs = getEnvInt("DEFAULT_TIMEOUT_MS_"+suffix, defaultTimeoutMs)
+	memoryMb = getEnvInt("DEFAULT_MEMORY_MB_"+suffix, defaultMemoryMb)
+	return timeoutMs, memoryMb
+}
+
+// OutputEncoding returns how execution output that is not valid UTF-8 is made safe:
+// OutputEncodingEscape (default) or OutputEncodingBase64
+func OutputEncoding() string {
+	if os.Getenv("OUTPUT_ENCODING") == OutputEncodingBase64 {
+		return OutputEncodingBase64
+	}
+	return OutputEncodingEscape
+}
+
+// RuntimeImagePullEnabled reports whether setup may pull a missing runtime image
+func RuntimeImagePullEnabled() bool {
+	return getEnvBool("RUNTIME_IMAGE_PULL", true)
+}
+
+// EnvironmentReuseEnabled reports whether setup requests that opt in with reuse
+// may be served by an existing environment with identical content
+func EnvironmentReuseEnabled() bool {
+	return getEnvBool("ENVIRONMENT_REUSE", false)
+}
+
+// DrainTimeout returns how long deletes and in-place updates wait for running
+// executions to finish before giving up on them
+func DrainTimeout() time.Duration {
+	return time.Duration(getEnvInt("DRAIN_TIMEOUT_SECONDS", 30)) * time.Second
+}
+
+// StuckUpdateTimeout returns how long an environment may stay 'updating' before
+// the reaper assumes the server running the update died and marks it 'failed':
+// comfortably longer than a drain plus a dependency install.
+func StuckUpdateTimeout() time.Duration {
+	return DrainTimeout() + DepInstallTimeout() + 5*time.Minute
+}
+
+// DenoAllowedHosts returns the hosts deno dependencies may be fetched from, from
+// the comma-separated DENO_ALLOWED_HOSTS. Empty means any host is allowed.
+func DenoAllowedHosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("DENO_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ExecEnvDeniedPrefixes returns the env var name prefixes that are never passed to
+// executions even when allowEnv lists them, from the comma-separated
+// EXEC_ENV_DENIED_PREFIXES. They keep handlers from redirecting the runtime
+// itself (DENO_DIR, LD_PRELOAD and the like).
+func ExecEnvDeniedPrefixes() []string {
+	value, ok := os.LookupEnv("EXEC_ENV_DENIED_PREFIXES")
+	if !ok {
+		value = "DENO_,LD_,NODE_,BUN_"
+	}
+	var prefixes []string
+	for _, prefix := range strings.Split(value, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// ResultContentTypes returns the media types handlers may declare for their
+// result with context.setContentType, from the comma-separated
+// RESULT_CONTENT_TYPES.
+func ResultContentTypes() []string {
+	value, ok := os.LookupEnv("RESULT_CONTENT_TYPES")
+	if !ok {
+		value = "application/json,text/plain,text/html,text/csv,text/markdown,application/xml,text/xml"
+	}
+	var types []string
+	for _, mediaType := range strings.Split(value, ",") {
+		if mediaType = strings.ToLower(strings.TrimSpace(mediaType)); mediaType != "" {
+			types = append(types, mediaType)
+		}
+	}
+	return types
+}
+
+// VolumePrefix returns the prefix for environment volume names, so deployments
+// sharing a docker host can tell their volumes apart
+func VolumePrefix() string {
+	if prefix := os.Getenv("VOLUME_PREFIX"); prefix != "" {
+		return prefix
+	}
+	return "tee-env-"
+}
+
+// ExecQueueWait returns how long an execution waits for a free slot before the
+// request is rejected as busy
+func ExecQueueWait() time.Duration {
+	return time.Duration(getEnvInt("EXEC_QUEUE_WAIT_MS", 2000)) * time.Millisecond
+}
+
+// PriorityAging returns how far ahead of normal-priority executions waiting
+// high-priority ones start, from EXEC_PRIORITY_AGING_MS. A normal execution
+// that has waited this long is served ahead of newer high-priority ones.
+func PriorityAging() time.Duration {
+	return time.Duration(getEnvInt("EXEC_PRIORITY_AGING_MS", 1000)) * time.Millisecond
+}
+
+// ProcessChurnLimit returns how much process churn an execution may cause within
+// ProcessChurnWindow before it is killed, from EXEC_PROCESS_CHURN_LIMIT. 0 (the
+// default) disables churn detection. It only applies with gVisor disabled; see
+// watchProcessChurn.
+func ProcessChurnLimit() int {
+	return getEnvInt("EXEC_PROCESS_CHURN_LIMIT", 0)
+}
+
+// ProcessChurnWindow returns the sliding window process churn is measured over,
+// from EXEC_PROCESS_CHURN_WINDOW_MS.
+func ProcessChurnWindow() time.Duration {
+	return time.Duration(getEnvInt("EXEC_PROCESS_CHURN_WINDOW_MS", 1000)) * time.Millisecond
+}
+
+// CgroupRoot returns where the host's cgroup v2 hierarchy is mounted, from
+// CGROUP_ROOT.
+func CgroupRoot() string {
+	if root := os.Getenv("CGROUP_ROOT"); root != "" {
+		return root
+	}
+	return "/sys/fs/cgroup"
+}
+
+// RuntimeVersions returns the runtime image tags environments may pin at setup,
+// from the comma-separated RUNTIME_VERSIONS. Empty means pinning is unavailable.
+func RuntimeVersions() []string {
+	var versions []string
+	for _, version := range strings.Split(os.Getenv("RUNTIME_VERSIONS"), ",") {
+		if version = strings.TrimSpace(version); version != "" {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// GlobalNetAllowlist returns the server-wide ceiling on the hosts any environment
+// may reach, from the comma-separated GLOBAL_NET_ALLOWLIST. Empty means no ceiling.
+func GlobalNetAllowlist() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("GLOBAL_NET_ALLOWLIST"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+)
+
+// resultContentType checks a content type declared by a handler against
+// RESULT_CONTENT_TYPES and returns it re-serialized, so nothing but a parsed
+// media type and charset ever reaches a response header.
+func resultContentType(declared string) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return "", fmt.Errorf("invalid content type %q", declared)
+	}
+	if !slices.Contains(ResultContentTypes(), mediaType) {
+		return "", fmt.Errorf("content type %q is not allowed", mediaType)
+	}
+
+	kept := map[string]string{}
+	for name, value := range params {
+		if name != "charset" {
+			return "", fmt.Errorf("content type parameter %q is not allowed", name)
+		}
+		kept[name] = strings.ToLower(value)
+	}
+	formatted := mime.FormatMediaType(mediaType, kept)
+	if formatted == "" {
+		return "", fmt.Errorf("invalid content type %q", declared)
+	}
+	return formatted, nil
+}
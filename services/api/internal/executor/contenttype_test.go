@@ -0,0 +1,61 @@
+package executor
+
+import "testing"
+
+func TestResultContentType(t *testing.T) {
+	allowed := map[string]string{
+		"text/html":                    "text/html",
+		"Text/CSV; charset=UTF-8":      "text/csv; charset=utf-8",
+		"application/json":             "application/json",
+		"text/plain;charset=\"utf-8\"": "text/plain; charset=utf-8",
+	}
+	for declared, want := range allowed {
+		got, err := resultContentType(declared)
+		if err != nil {
+			t.Errorf("resultContentType(%q) returned error: %v", declared, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("resultContentType(%q) = %q, want %q", declared, got, want)
+		}
+	}
+
+	for _, declared := range []string{
+		"application/javascript",
+		"text/html\r\nSet-Cookie: a=b",
+		"text/html; boundary=x",
+		"not a type",
+		"",
+	} {
+		if got, err := resultContentType(declared); err == nil {
+			t.Errorf("expected resultContentType(%q) to be refused, got %q", declared, got)
+		}
+	}
+}
+
+func TestResultContentType_Configured(t *testing.T) {
+	t.Setenv("RESULT_CONTENT_TYPES", "image/svg+xml")
+
+	if _, err := resultContentType("image/svg+xml"); err != nil {
+		t.Errorf("expected a configured type to be allowed, got %v", err)
+	}
+	if _, err := resultContentType("text/html"); err == nil {
+		t.Error("expected a type missing from RESULT_CONTENT_TYPES to be refused")
+	}
+}
+
+func TestParseRunnerContentType(t *testing.T) {
+	got, err := parseRunnerContentType(`{"teeEnvelope":1,"success":true,"result":"<p>","contentType":"text/html"}`)
+	if err != nil || got != "text/html" {
+		t.Errorf("expected text/html, got %q (%v)", got, err)
+	}
+	if got, err := parseRunnerContentType(`{"teeEnvelope":1,"success":true,"result":1}`); got != "" || err != nil {
+		t.Errorf("expected no content type when none was declared, got %q (%v)", got, err)
+	}
+	if _, err := parseRunnerContentType(`{"teeEnvelope":1,"success":true,"contentType":"text/javascript"}`); err == nil {
+		t.Error("expected a disallowed content type to be reported")
+	}
+	if got, _ := parseRunnerContentType(`{"contentType":"text/html"}`); got != "" {
+		t.Errorf("expected handler output that is not the envelope to be ignored, got %q", got)
+	}
+}
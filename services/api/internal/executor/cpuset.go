@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ExecCpusetCpus returns the CPUs execution containers are pinned to, from
+// EXEC_CPUSET_CPUS in docker's --cpuset-cpus format (e.g. "2-7" or "2,4-6").
+// Empty means executions may run on any CPU.
+func ExecCpusetCpus() string {
+	return strings.TrimSpace(os.Getenv("EXEC_CPUSET_CPUS"))
+}
+
+// ValidateCpusetConfig checks EXEC_CPUSET_CPUS, so a malformed value fails at
+// startup rather than on every execution.
+func ValidateCpusetConfig() error {
+	return ValidateCpuset(ExecCpusetCpus())
+}
+
+// ValidateCpuset checks a --cpuset-cpus value: a comma-separated list of CPU
+// numbers and ascending ranges. Empty is valid.
+func ValidateCpuset(cpuset string) error {
+	if cpuset == "" {
+		return nil
+	}
+	for _, part := range strings.Split(cpuset, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := parseCPU(first)
+		if err != nil {
+			return fmt.Errorf("invalid cpuset %q: %w", cpuset, err)
+		}
+		if !isRange {
+			continue
+		}
+		end, err := parseCPU(last)
+		if err != nil {
+			return fmt.Errorf("invalid cpuset %q: %w", cpuset, err)
+		}
+		if end < start {
+			return fmt.Errorf("invalid cpuset %q: range %s is descending", cpuset, part)
+		}
+	}
+	return nil
+}
+
+// parseCPU parses one CPU number of a cpuset
+func parseCPU(value string) (int, error) {
+	cpu, err := strconv.Atoi(value)
+	if err != nil || cpu < 0 || strings.HasPrefix(value, "+") {
+		return 0, fmt.Errorf("%q is not a CPU number", value)
+	}
+	return cpu, nil
+}
+
+// cpusetArgs returns the docker run flag pinning an execution to
+// EXEC_CPUSET_CPUS, if set.
+func cpusetArgs() []string {
+	cpuset := ExecCpusetCpus()
+	if cpuset == "" {
+		return nil
+	}
+	return []string{"--cpuset-cpus=" + cpuset}
+}
@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateCpuset(t *testing.T) {
+	for _, cpuset := range []string{"", "0", "2-7", "0,2,4-6", "3-3"} {
+		if err := ValidateCpuset(cpuset); err != nil {
+			t.Errorf("expected %q to be valid, got %v", cpuset, err)
+		}
+	}
+	for _, cpuset := range []string{"a", "-1", "7-2", "1,,2", "2-", "1-2-3", " 1", "+1"} {
+		if err := ValidateCpuset(cpuset); err == nil {
+			t.Errorf("expected %q to be rejected", cpuset)
+		}
+	}
+}
+
+func TestCpusetArgs(t *testing.T) {
+	if args := cpusetArgs(); args != nil {
+		t.Errorf("expected no flag by default, got %v", args)
+	}
+
+	t.Setenv("EXEC_CPUSET_CPUS", " 2-7 ")
+	if args := cpusetArgs(); !reflect.DeepEqual(args, []string{"--cpuset-cpus=2-7"}) {
+		t.Errorf("expected --cpuset-cpus=2-7, got %v", args)
+	}
+}
@@ -0,0 +1,56 @@
+package executor
+
+import "github.com/jsfour/assist-tee/internal/models"
+
+// executionData returns the data an execution receives: the request's data, or
+// the environment's defaultData when the request has none. An explicit null is
+// data, so it does not get the default. With mergeDefaults, object data is
+// deep-merged over the default instead of replacing it. Streamed requests
+// always use the stream.
+func executionData(metadata map[string]interface{}, req *models.ExecuteRequest) interface{} {
+	if req.DataStream != nil {
+		return req.Data
+	}
+	defaultData, ok := metadata["defaultData"]
+	if !ok {
+		return req.Data
+	}
+	if !dataPresent(req) {
+		return defaultData
+	}
+	if req.MergeDefaults {
+		return mergeData(defaultData, req.Data)
+	}
+	return req.Data
+}
+
+// dataPresent reports whether the request carries its own data: a "data" field
+// (even null), data set in code, or a stream.
+func dataPresent(req *models.ExecuteRequest) bool {
+	return req.DataPresent || req.Data != nil || req.DataStream != nil
+}
+
+// mergeData deep-merges override into base. Objects are merged key by key;
+// any other value in override replaces the one in base.
+func mergeData(base, override interface{}) interface{} {
+	baseMap, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	overrideMap, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		if existing, ok := merged[key]; ok {
+			merged[key] = mergeData(existing, value)
+		} else {
+			merged[key] = value
+		}
+	}
+	return merged
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestExecutionData(t *testing.T) {
+	metadata := map[string]interface{}{
+		"defaultData": map[string]interface{}{
+			"region":  "eu",
+			"options": map[string]interface{}{"verbose": false, "limit": 10.0},
+		},
+	}
+
+	if got := executionData(metadata, &models.ExecuteRequest{}); !reflect.DeepEqual(got, metadata["defaultData"]) {
+		t.Errorf("expected the default data without request data, got %v", got)
+	}
+
+	override := map[string]interface{}{"options": map[string]interface{}{"verbose": true}}
+	if got := executionData(metadata, &models.ExecuteRequest{Data: override}); !reflect.DeepEqual(got, override) {
+		t.Errorf("expected request data to replace the default, got %v", got)
+	}
+
+	want := map[string]interface{}{
+		"region":  "eu",
+		"options": map[string]interface{}{"verbose": true, "limit": 10.0},
+	}
+	got := executionData(metadata, &models.ExecuteRequest{Data: override, MergeDefaults: true})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got := executionData(nil, &models.ExecuteRequest{Data: "x"}); got != "x" {
+		t.Errorf("expected request data without a default, got %v", got)
+	}
+}
+
+func TestExecutionData_NullVersusAbsent(t *testing.T) {
+	metadata := map[string]interface{}{"defaultData": "fallback"}
+
+	for body, want := range map[string]interface{}{
+		`{}`:             "fallback",
+		`{"data": null}`: nil,
+		`{"data": 0}`:    0.0,
+	} {
+		var req models.ExecuteRequest
+		if err := json.Unmarshal([]byte(body), &req); err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if got := executionData(metadata, &req); got != want {
+			t.Errorf("%s: expected data %v, got %v", body, want, got)
+		}
+
+		input, err := buildExecutionInput(uuid.New(), uuid.New(), "main.ts", executionData(metadata, &req), nil,
+			map[string]interface{}{"dataPresent": dataPresent(&req)})
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		var decoded struct {
+			Context struct {
+				DataPresent bool `json:"dataPresent"`
+			} `json:"context"`
+		}
+		json.Unmarshal(input, &decoded)
+		if decoded.Context.DataPresent != (body != `{}`) {
+			t.Errorf("%s: expected context.dataPresent=%v", body, body != `{}`)
+		}
+	}
+}
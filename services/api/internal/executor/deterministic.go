@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// deterministicExecutionID derives an execution ID from the environment and the
+// request's input. Maps are marshalled with sorted keys, so requests with the
+// same input always hash to the same ID, and the environment ID namespaces it
+// so two environments never share one.
+func deterministicExecutionID(envID uuid.UUID, req *models.ExecuteRequest) (uuid.UUID, error) {
+	if req.DataStream != nil {
+		return uuid.Nil, fmt.Errorf("deterministicId is not supported for streamed input")
+	}
+	input, err := json.Marshal(struct {
+		Data        interface{}       `json:"data"`
+		DataPresent bool              `json:"dataPresent"`
+		Env         map[string]string `json:"env,omitempty"`
+		Args        []string          `json:"args,omitempty"`
+		WorkingDir  string            `json:"workingDir,omitempty"`
+		Handler     string            `json:"handler,omitempty"`
+	}{
+		Data:        req.Data,
+		DataPresent: dataPresent(req),
+		Env:         req.Env,
+		Args:        req.Args,
+		WorkingDir:  req.WorkingDir,
+		Handler:     req.Handler,
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to hash execution input: %w", err)
+	}
+	return uuid.NewSHA1(envID, input), nil
+}
@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestDeterministicExecutionID(t *testing.T) {
+	envID := uuid.New()
+	id := func(req *models.ExecuteRequest) uuid.UUID {
+		t.Helper()
+		execID, err := deterministicExecutionID(envID, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return execID
+	}
+
+	first := id(&models.ExecuteRequest{Data: map[string]interface{}{"a": 1.0, "b": 2.0}, DataPresent: true})
+	same := id(&models.ExecuteRequest{Data: map[string]interface{}{"b": 2.0, "a": 1.0}, DataPresent: true})
+	if first != same {
+		t.Errorf("expected identical input to give the same ID, got %s and %s", first, same)
+	}
+
+	for name, req := range map[string]*models.ExecuteRequest{
+		"different data": {Data: map[string]interface{}{"a": 2.0, "b": 2.0}, DataPresent: true},
+		"different env":  {Data: map[string]interface{}{"a": 1.0, "b": 2.0}, DataPresent: true, Env: map[string]string{"MODE": "x"}},
+		"different args": {Data: map[string]interface{}{"a": 1.0, "b": 2.0}, DataPresent: true, Args: []string{"--v"}},
+	} {
+		if id(req) == first {
+			t.Errorf("%s: expected a different ID", name)
+		}
+	}
+
+	other, _ := deterministicExecutionID(uuid.New(), &models.ExecuteRequest{Data: map[string]interface{}{"a": 1.0, "b": 2.0}, DataPresent: true})
+	if other == first {
+		t.Error("expected different environments to give different IDs")
+	}
+
+	if _, err := deterministicExecutionID(envID, &models.ExecuteRequest{DataStream: strings.NewReader("x")}); err == nil {
+		t.Error("expected streamed input to be rejected")
+	}
+}
@@ -0,0 +1,124 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// volumeDiskUsage measures the bytes used by an environment's volume, which
+// holds both its modules and its dependency cache.
+func volumeDiskUsage(ctx context.Context, volumeName string) (int64, error) {
+	cmd := DockerCommand(ctx, "run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace:ro", volumeName),
+		"busybox:latest",
+		"du", "-sk", "/workspace",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("failed to measure volume: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseDuKilobytes(stdout.String())
+}
+
+// parseDuKilobytes converts the output of "du -sk" to bytes.
+func parseDuKilobytes(output string) (int64, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected du output %q", output)
+	}
+	kb, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected du output %q", output)
+	}
+	return kb * 1024, nil
+}
+
+// measureDiskUsage returns the volume's disk usage for storing with the
+// environment, or a null value when it could not be measured. Failing to
+// measure never fails the operation that asked.
+func measureDiskUsage(ctx context.Context, envID uuid.UUID, volumeName string) sql.NullInt64 {
+	usage, err := volumeDiskUsage(ctx, volumeName)
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to measure environment disk usage",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: usage, Valid: true}
+}
+
+// diskUsageBackfillBatch caps how many environments one backfill pass measures,
+// since each measurement starts a container.
+const diskUsageBackfillBatch = 20
+
+// BackfillDiskUsage measures and stores the disk usage of ready environments
+// that predate disk usage tracking, a batch at a time. It runs from the reaper
+// so reads never start containers. A failed measurement is recorded and not
+// retried for a day, so an unreadable volume isn't measured every pass.
+func BackfillDiskUsage(ctx context.Context) {
+	log := logger.FromContext(ctx)
+
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT id, volume_name FROM environments
+		WHERE disk_usage_bytes IS NULL AND status = 'ready'
+		  AND (disk_usage_failed_at IS NULL OR disk_usage_failed_at < NOW() - INTERVAL '1 day')
+		ORDER BY created_at
+		LIMIT $1
+	`, diskUsageBackfillBatch)
+	if err != nil {
+		log.Warn("failed to find environments missing disk usage",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	type pending struct {
+		id         uuid.UUID
+		volumeName string
+	}
+	var envs []pending
+	for rows.Next() {
+		var env pending
+		if err := rows.Scan(&env.id, &env.volumeName); err == nil {
+			envs = append(envs, env)
+		}
+	}
+	rows.Close()
+
+	for _, env := range envs {
+		usage := measureDiskUsage(ctx, env.id, env.volumeName)
+		if usage.Valid {
+			_, err = database.DB.ExecContext(ctx, `
+				UPDATE environments SET disk_usage_bytes = $2, disk_usage_failed_at = NULL WHERE id = $1
+			`, env.id, usage.Int64)
+		} else {
+			_, err = database.DB.ExecContext(ctx, `
+				UPDATE environments SET disk_usage_failed_at = NOW() WHERE id = $1
+			`, env.id)
+		}
+		if err != nil {
+			log.Warn("failed to store environment disk usage",
+				slog.String("environment_id", env.id.String()),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func nullInt64Ptr(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
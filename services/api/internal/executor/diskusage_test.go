@@ -0,0 +1,17 @@
+package executor
+
+import "testing"
+
+func TestParseDuKilobytes(t *testing.T) {
+	got, err := parseDuKilobytes("2048\t/workspace\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != 2048*1024 {
+		t.Errorf("expected %d bytes, got %d", 2048*1024, got)
+	}
+
+	if _, err := parseDuKilobytes("du: /workspace: No such file or directory"); err == nil {
+		t.Error("expected an error for unexpected output")
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,7 +21,26 @@ import (
 	"github.com/jsfour/assist-tee/internal/models"
 )
 
-var execSemaphore = make(chan struct{}, 50) // Max 50 concurrent executions
+// Max 50 concurrent executions, EXEC_HIGH_PRIORITY_RESERVED_PERCENT of them
+// kept for high priority
+var execSlots = newExecScheduler(50, getEnvInt("EXEC_HIGH_PRIORITY_RESERVED_PERCENT", 0))
+
+// Setups are limited separately from executions, and code-only setups separately
+// from dependency installs so fast setups don't queue behind slow installs.
+var (
+	setupSemaphore     = make(chan struct{}, getEnvInt("SETUP_CONCURRENCY", 20))
+	setupDepsSemaphore = make(chan struct{}, getEnvInt("SETUP_DEPS_CONCURRENCY", 5))
+)
+
+// Default resource limits applied when neither the request nor the runtime's
+// DEFAULT_*_<RUNTIME> settings specify their own
+const (
+	defaultTimeoutMs = 5000
+	defaultMemoryMb  = 128
+)
+
+// defaultRuntime is the runtime used by environments that do not record one
+const defaultRuntime = "deno"
 
 // RuntimeImage returns the Docker image to use for code execution
 func RuntimeImage() string {
@@ -29,6 +50,23 @@ func RuntimeImage() string {
 	return "octaviusdeployment/assist-tee-rt-deno:latest"
 }
 
+// RuntimeImageForVersion returns the runtime image pinned to version, which
+// replaces the tag of RuntimeImage. An empty version uses RuntimeImage as is.
+func RuntimeImageForVersion(version string) string {
+	image := RuntimeImage()
+	if version == "" {
+		return image
+	}
+	if at := strings.Index(image, "@"); at >= 0 {
+		image = image[:at]
+	}
+	// A colon after the last slash starts the tag; earlier ones belong to a registry port
+	if colon := strings.LastIndex(image, ":"); colon > strings.LastIndex(image, "/") {
+		image = image[:colon]
+	}
+	return image + ":" + version
+}
+
 // IsGVisorDisabled checks if gVisor is disabled via environment variable
 func IsGVisorDisabled() bool {
 	return os.Getenv("DISABLE_GVISOR") == "true" || os.Getenv("DISABLE_GVISOR") == "1"
@@ -46,9 +84,85 @@ func isGVisorRuntimeError(err error, stderr string) bool {
 
 func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
 	envID := uuid.New()
-	volumeName := fmt.Sprintf("tee-env-%s", envID.String())
+	volumeName := environmentVolumeName(envID)
+	image := RuntimeImageForVersion(req.RuntimeVersion)
 	log := logger.FromContext(ctx)
 
+	var warnings []string
+	if req.Reuse && !EnvironmentReuseEnabled() {
+		warnings = append(warnings, "reuse ignored: ENVIRONMENT_REUSE is disabled on this server")
+	}
+	if req.Proxy != nil && (req.Permissions == nil || len(req.Permissions.AllowNet) == 0) {
+		warnings = append(warnings, "proxy has no effect: the environment has no network access (permissions.allowNet is empty)")
+	}
+	if len(req.Runsc) > 0 && IsGVisorDisabled() {
+		warnings = append(warnings, "runsc options have no effect: gVisor is disabled on this server")
+	}
+
+	// Serve opted-in requests from an identical existing environment when allowed
+	var contentHash string
+	if req.Reuse && EnvironmentReuseEnabled() {
+		contentHash = setupContentHash(req)
+		env, err := reuseEnvironment(ctx, contentHash)
+		if err != nil {
+			log.Error("failed to look up reusable environment",
+				slog.String("content_hash", contentHash),
+				slog.String("error", err.Error()),
+			)
+			return nil, err
+		}
+		if env != nil {
+			log.Info("reusing existing environment",
+				slog.String("environment_id", env.ID.String()),
+				slog.String("content_hash", contentHash),
+				slog.Int("ref_count", env.RefCount),
+			)
+			env.Warnings = warnings
+			return env, nil
+		}
+	}
+
+	// Time each step of the setup for the response
+	setupStart := time.Now()
+	stepStart := setupStart
+	steps := &models.SetupSteps{}
+	stepDone := func(ms *int64) {
+		now := time.Now()
+		*ms = now.Sub(stepStart).Milliseconds()
+		stepStart = now
+	}
+
+	// Acquire the setup semaphore for this kind of setup
+	hasDeps := req.Dependencies.Count() > 0
+	sem := setupSemaphore
+	if hasDeps {
+		sem = setupDepsSemaphore
+	}
+	log.Debug("acquiring setup semaphore",
+		slog.Bool("has_dependencies", hasDeps),
+	)
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		log.Warn("context cancelled while waiting for setup semaphore",
+			slog.Bool("has_dependencies", hasDeps),
+		)
+		return nil, ctx.Err()
+	}
+	stepDone(&steps.QueueMs)
+
+	// A tuned sandbox needs its runtime registered with the docker daemon
+	if runtime := runscRuntime(resolveRunscOptions(req.Runsc)); runtime != defaultRunscRuntime && !IsGVisorDisabled() {
+		if err := checkRuntimeRegistered(ctx, runtime); err != nil {
+			log.Warn("gVisor runtime unavailable",
+				slog.String("runtime", runtime),
+				slog.String("error", err.Error()),
+			)
+			return nil, err
+		}
+	}
+
 	log.Debug("starting environment setup",
 		slog.String("environment_id", envID.String()),
 		slog.String("volume_name", volumeName),
@@ -56,11 +170,19 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 		slog.Int("module_count", len(req.Modules)),
 	)
 
-	// 1. Create Docker volume
+	// 1. Create Docker volume. "volume create" silently returns an existing
+	// volume, so refuse to reuse one that is already there.
+	if err := checkVolumeAbsent(ctx, volumeName); err != nil {
+		log.Error("refusing to create docker volume",
+			slog.String("volume_name", volumeName),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
 	log.Debug("creating docker volume",
 		slog.String("volume_name", volumeName),
 	)
-	cmd := exec.CommandContext(ctx, "docker", "volume", "create", volumeName)
+	cmd := DockerCommand(ctx, "volume", "create", volumeName)
 	if err := cmd.Run(); err != nil {
 		log.Error("failed to create docker volume",
 			slog.String("volume_name", volumeName),
@@ -68,80 +190,80 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 		)
 		return nil, fmt.Errorf("failed to create volume: %w", err)
 	}
+	stepDone(&steps.VolumeMs)
+	reportProgress(req.Progress, models.SetupProgress{Stage: models.SetupStageVolumeCreated})
 
 	// 2. Write modules to volume
-	// The deno user in the container has UID 1000, so we need to set ownership
-	for filename, content := range req.Modules {
-		log.Debug("writing module to volume",
-			slog.String("filename", filename),
-			slog.Int("content_length", len(content)),
-		)
-
-		// Escape single quotes in content
-		escapedContent := strings.ReplaceAll(content, "'", "'\\''")
-
-		writeCmd := fmt.Sprintf("cat > /workspace/%s <<'EOF'\n%s\nEOF", filename, escapedContent)
-		cmd := exec.CommandContext(ctx, "docker", "run", "--rm",
-			"-v", fmt.Sprintf("%s:/workspace", volumeName),
-			"busybox:latest",
-			"sh", "-c", writeCmd,
-		)
-
-		if err := cmd.Run(); err != nil {
-			log.Error("failed to write module",
-				slog.String("filename", filename),
-				slog.String("error", err.Error()),
-			)
-			// Cleanup volume on failure
-			exec.Command("docker", "volume", "rm", "-f", volumeName).Run()
-			return nil, fmt.Errorf("failed to write %s: %w", filename, err)
-		}
-	}
-
-	// 2b. Fix ownership for deno user (UID 1000 in the deno image)
-	log.Debug("setting volume ownership for deno user")
-	chownCmd := exec.CommandContext(ctx, "docker", "run", "--rm",
-		"-v", fmt.Sprintf("%s:/workspace", volumeName),
-		"busybox:latest",
-		"sh", "-c", "chown -R 1000:1000 /workspace",
-	)
-	if err := chownCmd.Run(); err != nil {
-		log.Warn("failed to set volume ownership",
-			slog.String("error", err.Error()),
-		)
-		// Don't fail - it might still work if deps aren't needed
+	if err := writeModules(ctx, volumeName, "/workspace", req.Modules, req.Progress); err != nil {
+		// Cleanup volume on failure
+		DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run()
+		return nil, err
 	}
-
-	log.Debug("all modules written successfully",
-		slog.Int("module_count", len(req.Modules)),
-	)
+	stepDone(&steps.ModulesMs)
 
 	// 3. Install dependencies (if specified)
-	if req.Dependencies != nil && (len(req.Dependencies.NPM) > 0 || len(req.Dependencies.Deno) > 0) {
-		depCount := len(req.Dependencies.NPM) + len(req.Dependencies.Deno)
+	if hasDeps {
+		depCount := req.Dependencies.Count()
 		log.Info("installing dependencies",
 			slog.String("environment_id", envID.String()),
 			slog.Int("npm_count", len(req.Dependencies.NPM)),
+			slog.Int("jsr_count", len(req.Dependencies.JSR)),
 			slog.Int("deno_count", len(req.Dependencies.Deno)),
 			slog.Int("total_count", depCount),
 		)
 
-		if err := installDependencies(ctx, volumeName, req.Dependencies); err != nil {
+		if err := installDependencies(ctx, volumeName, image, installStrategyFor(defaultRuntime, req.RuntimeVersion), resolveProxy(req.Proxy), req.Dependencies, req.Progress); err != nil {
 			log.Error("dependency installation failed",
 				slog.String("environment_id", envID.String()),
 				slog.String("error", err.Error()),
 			)
 			// Cleanup volume on failure
-			exec.Command("docker", "volume", "rm", "-f", volumeName).Run()
+			DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run()
 			return nil, fmt.Errorf("failed to install dependencies: %w", err)
 		}
 
 		log.Info("dependencies installed successfully",
 			slog.String("environment_id", envID.String()),
 		)
+		stepDone(&steps.DependenciesMs)
+	}
+
+	// 4. Make sure the runtime image is available so the first execute does not pull it
+	if err := ensureRuntimeImage(ctx, envID, image); err != nil {
+		log.Error("runtime image unavailable",
+			slog.String("environment_id", envID.String()),
+			slog.String("image", image),
+			slog.String("error", err.Error()),
+		)
+		// Cleanup volume on failure
+		DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run()
+		return nil, err
+	}
+	stepDone(&steps.ImageMs)
+	reportProgress(req.Progress, models.SetupProgress{Stage: models.SetupStageImageReady})
+
+	// 5. Warm up the handler (if requested)
+	warmedUp := false
+	var warmupShape *models.ResultShape
+	if req.Warmup != nil {
+		shape, err := warmupEnvironment(ctx, envID, volumeName, image, req)
+		if err != nil {
+			log.Error("environment warmup failed",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			// Cleanup volume on failure
+			DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run()
+			return nil, err
+		}
+		warmedUp = true
+		warmupShape = shape
+		stepDone(&steps.WarmupMs)
+		reportProgress(req.Progress, models.SetupProgress{Stage: models.SetupStageWarmedUp})
 	}
 
-	// 4. Store metadata
+	// 6. Measure the volume and store metadata
+	diskUsage := measureDiskUsage(ctx, envID, volumeName)
 	ttl := req.TTLSeconds
 	if ttl == 0 {
 		ttl = 3600 // Default 1 hour
@@ -149,15 +271,68 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 
 	depCount := 0
 	if req.Dependencies != nil {
-		depCount = len(req.Dependencies.NPM) + len(req.Dependencies.Deno)
+		depCount = req.Dependencies.Count()
 	}
 
 	metadata := map[string]interface{}{
+		"metadataVersion": database.MetadataVersion,
+		"runtime":         defaultRuntime,
 		"permissions":     req.Permissions,
+		"modules":         moduleNames(req.Modules),
 		"moduleCount":     len(req.Modules),
+		"dependencies":    req.Dependencies,
 		"dependencyCount": depCount,
 		"hasDependencies": depCount > 0,
 	}
+	if req.PersistResults != nil {
+		metadata["persistResults"] = *req.PersistResults
+	}
+	if len(req.RequiredEnv) > 0 {
+		metadata["requiredEnv"] = req.RequiredEnv
+	}
+	if req.Template != "" {
+		metadata["template"] = req.Template
+	}
+	if req.MaxExecutionsPerMinute > 0 {
+		metadata["maxExecutionsPerMinute"] = req.MaxExecutionsPerMinute
+	}
+	if req.Proxy != nil {
+		metadata["proxy"] = req.Proxy
+	}
+	if req.Timezone != "" {
+		metadata["timezone"] = req.Timezone
+	}
+	if req.Locale != "" {
+		metadata["locale"] = req.Locale
+	}
+	if req.DefaultData != nil {
+		metadata["defaultData"] = req.DefaultData
+	}
+	if warmupShape != nil {
+		metadata["resultShape"] = warmupShape
+	}
+	if req.RuntimeVersion != "" {
+		metadata["runtimeVersion"] = req.RuntimeVersion
+		metadata["runtimeImage"] = image
+	}
+	if len(req.Runsc) > 0 {
+		metadata["runsc"] = req.Runsc
+	}
+	if req.HealthCheck != nil {
+		metadata["healthCheck"] = req.HealthCheck
+	}
+	if req.PreHook != "" {
+		metadata["preHook"] = req.PreHook
+	}
+	if req.PostHook != "" {
+		metadata["postHook"] = req.PostHook
+	}
+	if req.HandlerName != "" {
+		metadata["handlerName"] = req.HandlerName
+	}
+	if len(req.Handlers) > 0 {
+		metadata["handlers"] = req.Handlers
+	}
 	metadataJSON, _ := json.Marshal(metadata)
 
 	log.Debug("storing environment metadata",
@@ -166,9 +341,13 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 	)
 
 	_, err := database.DB.ExecContext(ctx, `
-		INSERT INTO environments (id, volume_name, main_module, metadata, ttl_seconds)
-		VALUES ($1, $2, $3, $4, $5)
-	`, envID, volumeName, req.MainModule, metadataJSON, ttl)
+		INSERT INTO environments
+		(id, volume_name, main_module, metadata, ttl_seconds, warmed_up, idle_timeout_seconds, keep_alive_on_activity, content_hash, disk_usage_bytes, group_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, envID, volumeName, req.MainModule, metadataJSON, ttl, warmedUp, req.IdleTimeoutSeconds, req.KeepAliveOnActivity,
+		sql.NullString{String: contentHash, Valid: contentHash != ""}, diskUsage,
+		sql.NullString{String: req.GroupID, Valid: req.GroupID != ""},
+		sql.NullString{String: req.CreatedBy, Valid: req.CreatedBy != ""})
 
 	if err != nil {
 		log.Error("failed to store environment in database",
@@ -176,9 +355,12 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 			slog.String("error", err.Error()),
 		)
 		// Cleanup volume on DB failure
-		exec.Command("docker", "volume", "rm", "-f", volumeName).Run()
+		DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run()
 		return nil, fmt.Errorf("failed to store environment: %w", err)
 	}
+	database.MarkWritten(envID)
+	stepDone(&steps.StoreMs)
+	setupDuration := time.Since(setupStart)
 
 	log.Info("environment setup completed",
 		slog.String("environment_id", envID.String()),
@@ -187,6 +369,8 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 		slog.Int("module_count", len(req.Modules)),
 		slog.Int("dependency_count", depCount),
 		slog.Int("ttl_seconds", ttl),
+		slog.Int64("duration_ms", setupDuration.Milliseconds()),
+		slog.Int64("dependencies_ms", steps.DependenciesMs),
 	)
 
 	return &models.Environment{
@@ -198,186 +382,1186 @@ func (e *DockerExecutor) SetupEnvironment(ctx context.Context, req *models.Setup
 		Status:         "ready",
 		Metadata:       metadata,
 		TTLSeconds:     ttl,
+		WarmedUp:       warmedUp,
+		Version:        1,
+		RefCount:       1,
+		DiskUsageBytes: nullInt64Ptr(diskUsage),
+		GroupID:        req.GroupID,
+		CreatedBy:      req.CreatedBy,
+		Warnings:       warnings,
+
+		SetupDurationMs: setupDuration.Milliseconds(),
+		SetupSteps:      steps,
+
+		IdleTimeoutSeconds:  req.IdleTimeoutSeconds,
+		KeepAliveOnActivity: req.KeepAliveOnActivity,
 	}, nil
 }
 
-func (e *DockerExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
-	log := logger.FromContext(ctx)
-
-	// Acquire semaphore
-	log.Debug("acquiring execution semaphore",
-		slog.String("environment_id", envID.String()),
-	)
-	select {
-	case execSemaphore <- struct{}{}:
-		defer func() { <-execSemaphore }()
-	case <-ctx.Done():
-		log.Warn("context cancelled while waiting for semaphore",
-			slog.String("environment_id", envID.String()),
-		)
-		return nil, ctx.Err()
+// metadataDefault returns value, or the environment's default for key when value
+// is empty.
+func metadataDefault(metadata map[string]interface{}, key, value string) string {
+	if value != "" {
+		return value
 	}
+	str, _ := metadata[key].(string)
+	return str
+}
 
-	// 1. Look up environment
-	var volumeName, mainModule string
-	var metadataJSON []byte
-	err := database.DB.QueryRowContext(ctx, `
-		SELECT volume_name, main_module, metadata
-		FROM environments
-		WHERE id = $1 AND status = 'ready'
-	`, envID).Scan(&volumeName, &mainModule, &metadataJSON)
+// metadataStrings reads a string list stored in environment metadata.
+func metadataStrings(metadata map[string]interface{}, key string) []string {
+	values, ok := metadata[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		if str, ok := value.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
 
-	if err == sql.ErrNoRows {
-		log.Warn("environment not found or not ready",
-			slog.String("environment_id", envID.String()),
-		)
-		return nil, fmt.Errorf("environment not found or not ready")
-	} else if err != nil {
-		log.Error("database query failed",
-			slog.String("environment_id", envID.String()),
-			slog.String("error", err.Error()),
-		)
-		return nil, err
+// missingEnv returns the required env var names absent from env.
+func missingEnv(required []string, env map[string]string) []string {
+	var missing []string
+	for _, key := range required {
+		if _, ok := env[key]; !ok {
+			missing = append(missing, key)
+		}
 	}
+	return missing
+}
 
-	// Parse metadata for permissions
-	var metadata map[string]interface{}
-	if metadataJSON != nil {
-		json.Unmarshal(metadataJSON, &metadata)
+// disallowedEnv returns the sorted env var names in env that allowed does not
+// list or that an ExecEnvDeniedPrefixes prefix matches: the ones executionEnv
+// would drop.
+func disallowedEnv(allowed []string, env map[string]string) []string {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, key := range allowed {
+		allowedSet[key] = true
 	}
+	denied := ExecEnvDeniedPrefixes()
+	var disallowed []string
+	for key := range env {
+		if !allowedSet[key] || hasAnyPrefix(key, denied) {
+			disallowed = append(disallowed, key)
+		}
+	}
+	sort.Strings(disallowed)
+	return disallowed
+}
 
-	// Extract permissions from metadata
-	var permissions *models.Permissions
-	if metadata != nil {
-		if permData, ok := metadata["permissions"]; ok && permData != nil {
-			permJSON, _ := json.Marshal(permData)
-			permissions = &models.Permissions{}
-			json.Unmarshal(permJSON, permissions)
+// executionEnv returns the env vars an execution may see: those in env that
+// allowEnv lists and no ExecEnvDeniedPrefixes prefix matches. Every runtime gets
+// exactly this set, both as container variables and as event.env; nothing from
+// the host is ever passed through.
+func executionEnv(permissions *models.Permissions, env map[string]string) map[string]string {
+	if permissions == nil || len(permissions.AllowEnv) == 0 || len(env) == 0 {
+		return nil
+	}
+	denied := ExecEnvDeniedPrefixes()
+	filtered := make(map[string]string)
+	for key, value := range env {
+		if !containsString(permissions.AllowEnv, key) || hasAnyPrefix(key, denied) {
+			continue
 		}
+		filtered[key] = value
 	}
+	return filtered
+}
 
-	// 2. Apply limits
-	timeoutMs := 5000
-	memoryMb := 128
-	if req.Limits != nil {
-		if req.Limits.TimeoutMs > 0 {
-			timeoutMs = req.Limits.TimeoutMs
+// droppedEnv returns the sorted env var names in requested that filtered left out.
+func droppedEnv(requested, filtered map[string]string) []string {
+	var dropped []string
+	for key := range requested {
+		if _, ok := filtered[key]; !ok {
+			dropped = append(dropped, key)
 		}
-		if req.Limits.MemoryMb > 0 {
-			memoryMb = req.Limits.MemoryMb
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
 		}
 	}
+	return false
+}
 
-	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
-	defer cancel()
+// moduleNames returns the sorted file names of a modules map.
+func moduleNames(modules map[string]string) []string {
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
-	// 3. Build execution input
-	execID := uuid.New()
-	executionInput := map[string]interface{}{
-		"event": map[string]interface{}{
-			"data": req.Data,
-			"env":  req.Env,
-		},
-		"context": map[string]interface{}{
-			"executionId":   execID.String(),
-			"environmentId": envID.String(),
-			"requestId":     execID.String(),
-		},
-		"mainModule": mainModule,
+// environmentRuntime returns the runtime recorded in environment metadata.
+func environmentRuntime(metadata map[string]interface{}) string {
+	if runtime, ok := metadata["runtime"].(string); ok && runtime != "" {
+		return runtime
+	}
+	return defaultRuntime
+}
+
+// parseEnvironmentMetadata decodes an environment's metadata and the permissions
+// stored in it. If either is malformed it returns an error along with deny-all
+// permissions (and no metadata when the blob itself is unreadable), so a corrupt
+// record can never widen what an execution is allowed to do. Readable metadata
+// is upgraded to the current MetadataVersion.
+func parseEnvironmentMetadata(metadataJSON []byte) (map[string]interface{}, *models.Permissions, error) {
+	var metadata map[string]interface{}
+	if metadataJSON == nil {
+		return database.UpgradeMetadata(nil), nil, nil
+	}
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		return nil, &models.Permissions{}, fmt.Errorf("invalid metadata: %w", err)
 	}
+	metadata = database.UpgradeMetadata(metadata)
 
-	inputJSON, err := json.Marshal(executionInput)
+	permData, ok := metadata["permissions"]
+	if !ok || permData == nil {
+		return metadata, nil, nil
+	}
+	permJSON, err := json.Marshal(permData)
 	if err != nil {
-		log.Error("failed to marshal execution input",
-			slog.String("environment_id", envID.String()),
-			slog.String("error", err.Error()),
-		)
-		return nil, err
+		return metadata, &models.Permissions{}, fmt.Errorf("invalid permissions: %w", err)
 	}
+	permissions := &models.Permissions{}
+	if err := json.Unmarshal(permJSON, permissions); err != nil {
+		return metadata, &models.Permissions{}, fmt.Errorf("invalid permissions: %w", err)
+	}
+	return metadata, permissions, nil
+}
 
-	log.Debug("starting container execution",
-		slog.String("environment_id", envID.String()),
-		slog.String("execution_id", execID.String()),
-		slog.String("volume_name", volumeName),
-		slog.String("main_module", mainModule),
-		slog.Int("timeout_ms", timeoutMs),
-		slog.Int("memory_mb", memoryMb),
-	)
+// environmentImage returns the runtime image an environment was pinned to at
+// setup, or the current RuntimeImage for unpinned environments.
+func environmentImage(metadata map[string]interface{}) string {
+	if image, ok := metadata["runtimeImage"].(string); ok && image != "" {
+		return image
+	}
+	return RuntimeImage()
+}
 
-	// 4. Build docker run command
-	args := []string{
-		"run",
-		"--rm",
-		"-i",
+// checkReferencedModules rejects an update whose module set drops a module the
+// environment's hooks or health check still name.
+func checkReferencedModules(metadata map[string]interface{}, modules []string) error {
+	for _, hook := range []string{"preHook", "postHook"} {
+		if module := metadataDefault(metadata, hook, ""); module != "" && !containsString(modules, module) {
+			return &Error{Code: "validation_error", Message: fmt.Sprintf("modules must keep the %s module %s", hook, module)}
+		}
 	}
+	if check := environmentHealthCheck(metadata); check != nil && check.Module != "" && !containsString(modules, check.Module) {
+		return &Error{Code: "validation_error", Message: "modules must keep the healthCheck module " + check.Module}
+	}
+	return nil
+}
 
-	// Add gVisor runtime if not disabled
-	if !IsGVisorDisabled() {
-		args = append(args, "--runtime=runsc")
-	} else {
-		log.Warn("gVisor is disabled - execution is not sandboxed",
-			slog.String("environment_id", envID.String()),
-			slog.String("execution_id", execID.String()),
-		)
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
 	}
+	return false
+}
 
-	// Determine network mode based on permissions
-	networkMode := "none"
-	if permissions != nil && len(permissions.AllowNet) > 0 {
-		networkMode = "bridge"
-		log.Info("network access enabled with whitelist",
-			slog.String("environment_id", envID.String()),
-			slog.Any("allowed_domains", permissions.AllowNet),
+// writeModules writes module files under dir, a path in the volume mounted at
+// /workspace, in name order and fixes their ownership, reporting each written
+// module to progress.
+func writeModules(ctx context.Context, volumeName, dir string, modules map[string]string, progress func(models.SetupProgress)) error {
+	log := logger.FromContext(ctx)
+
+	// The deno user in the container has UID 1000, so we need to set ownership
+	names := moduleNames(modules)
+	for i, filename := range names {
+		content := modules[filename]
+		log.Debug("writing module to volume",
+			slog.String("filename", filename),
+			slog.Int("content_length", len(content)),
 		)
-	}
 
-	// Continue with other args
-	args = append(args,
-		fmt.Sprintf("--network=%s", networkMode),
-		"--read-only",
-		fmt.Sprintf("--memory=%dm", memoryMb),
-		"--cpus=0.5",
-		"--pids-limit=100",
-		"-v", fmt.Sprintf("%s:/workspace:ro", volumeName),
-		"-v", fmt.Sprintf("%s:/deno-dir:ro", volumeName), // Mount cached dependencies
-		"-e", "DENO_DIR=/deno-dir",                       // Tell Deno where to find cache
-	)
+		// Escape single quotes in content
+		escapedContent := strings.ReplaceAll(content, "'", "'\\''")
 
-	// Build env var whitelist set for quick lookup
-	allowedEnvVars := make(map[string]bool)
-	if permissions != nil {
-		for _, envVar := range permissions.AllowEnv {
-			allowedEnvVars[envVar] = true
-		}
-	}
+		target := path.Join(dir, filename)
+		writeCmd := fmt.Sprintf("mkdir -p %s && cat > %s <<'EOF'\n%s\nEOF", path.Dir(target), target, escapedContent)
+		cmd := DockerCommand(ctx, "run", "--rm",
+			"-v", fmt.Sprintf("%s:/workspace", volumeName),
+			"busybox:latest",
+			"sh", "-c", writeCmd,
+		)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
 
-	// Pass whitelisted environment variables to container
-	if req.Env != nil && len(allowedEnvVars) > 0 {
-		for key, value := range req.Env {
-			if allowedEnvVars[key] {
-				args = append(args, "-e", fmt.Sprintf("%s=%s", key, value))
-				log.Debug("passing whitelisted env var",
-					slog.String("key", key),
-				)
-			} else {
-				log.Debug("env var not in whitelist, skipping",
-					slog.String("key", key),
-				)
+		if err := cmd.Run(); err != nil {
+			log.Error("failed to write module",
+				slog.String("filename", filename),
+				slog.String("error", err.Error()),
+			)
+			if isImagePullFailure(stderr.String()) {
+				return imagePullError("busybox:latest", stderr.String())
 			}
+			return fmt.Errorf("failed to write %s: %w", filename, err)
 		}
+		reportProgress(progress, models.SetupProgress{
+			Stage:     models.SetupStageModuleWritten,
+			Module:    filename,
+			Completed: i + 1,
+			Total:     len(names),
+		})
 	}
 
-	// Build Deno permission flags
-	denoPermissions := "--allow-read=/workspace,/runtime,/deno-dir --allow-env"
-	if permissions != nil && len(permissions.AllowNet) > 0 {
-		// Add network permission with domain whitelist
-		denoPermissions += fmt.Sprintf(" --allow-net=%s", strings.Join(permissions.AllowNet, ","))
+	// Fix ownership for deno user (UID 1000 in the deno image)
+	log.Debug("setting volume ownership for deno user")
+	chownCmd := DockerCommand(ctx, "run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace", volumeName),
+		"busybox:latest",
+		"sh", "-c", "chown -R 1000:1000 /workspace",
+	)
+	if err := chownCmd.Run(); err != nil {
+		log.Warn("failed to set volume ownership",
+			slog.String("error", err.Error()),
+		)
+		// Don't fail - it might still work if deps aren't needed
 	}
 
-	// Override entrypoint to pass custom Deno permissions
+	log.Debug("all modules written successfully",
+		slog.Int("module_count", len(modules)),
+	)
+	return nil
+}
+
+// moduleStagingDir is where an in-place update writes the new modules before
+// swapping them in.
+const moduleStagingDir = "/workspace/.tee-staging"
+
+// stageModules writes modules into moduleStagingDir, clearing out anything an
+// earlier, interrupted update left there.
+func stageModules(ctx context.Context, volumeName string, modules map[string]string) error {
+	clearCmd := DockerCommand(ctx, "run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace", volumeName),
+		"busybox:latest",
+		"rm", "-rf", moduleStagingDir,
+	)
+	if err := clearCmd.Run(); err != nil {
+		return fmt.Errorf("failed to clear staged modules: %w", err)
+	}
+	return writeModules(ctx, volumeName, moduleStagingDir, modules, nil)
+}
+
+// discardStagedModules removes moduleStagingDir after an abandoned update.
+func discardStagedModules(volumeName string) {
+	DockerCommand(context.Background(), "run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace", volumeName),
+		"busybox:latest",
+		"rm", "-rf", moduleStagingDir,
+	).Run()
+}
+
+// swapInModules replaces the live modules with the staged ones, removing the
+// stale modules (quoted /workspace paths) in the same container so the window
+// in which the volume is half-updated is as short as possible.
+func swapInModules(ctx context.Context, volumeName string, stale []string) error {
+	script := fmt.Sprintf("cp -a %s/. /workspace/ && rm -rf %s", moduleStagingDir, moduleStagingDir)
+	if len(stale) > 0 {
+		script = "rm -f " + strings.Join(stale, " ") + " && " + script
+	}
+	cmd := DockerCommand(ctx, "run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace", volumeName),
+		"busybox:latest",
+		"sh", "-c", script,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ensureRuntimeImage checks that the runtime image is present locally, pulling it
+// when RUNTIME_IMAGE_PULL allows. Pull progress is streamed to the logs.
+func ensureRuntimeImage(ctx context.Context, envID uuid.UUID, image string) error {
+	log := logger.FromContext(ctx)
+
+	if err := DockerCommand(ctx, "image", "inspect", "--format", "{{.Id}}", image).Run(); err == nil {
+		return nil
+	}
+	if !RuntimeImagePullEnabled() {
+		return fmt.Errorf("runtime image %s is not present and RUNTIME_IMAGE_PULL is disabled", image)
+	}
+
+	log.Info("pulling runtime image",
+		slog.String("environment_id", envID.String()),
+		slog.String("image", image),
+	)
+
+	stdoutWriter := &streamingWriter{
+		log:    log,
+		stream: "stdout",
+		prefix: "image pull",
+		envID:  envID.String(),
+	}
+	stderrWriter := &streamingWriter{
+		log:    log,
+		stream: "stderr",
+		prefix: "image pull",
+		envID:  envID.String(),
+	}
+	var stderr bytes.Buffer
+
+	cmd := DockerCommand(ctx, "pull", image)
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = io.MultiWriter(stderrWriter, &stderr)
+	err := cmd.Run()
+	stdoutWriter.Flush()
+	stderrWriter.Flush()
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to pull runtime image %s: %w", image, ctx.Err())
+		}
+		return imagePullError(image, stderr.String())
+	}
+
+	log.Info("runtime image pulled",
+		slog.String("environment_id", envID.String()),
+		slog.String("image", image),
+	)
+	return nil
+}
+
+// warmupEnvironment runs the handler once so the module graph is resolved and any
+// top-level initialization runs before the environment is marked ready. It
+// returns the shape of the handler's result.
+func warmupEnvironment(ctx context.Context, envID uuid.UUID, volumeName, image string, req *models.SetupRequest) (*models.ResultShape, error) {
+	log := logger.FromContext(ctx)
+	execID := uuid.New()
+
+	log.Info("warming up environment",
+		slog.String("environment_id", envID.String()),
+		slog.String("execution_id", execID.String()),
+	)
+
+	data := req.Warmup.Data
+	if data == nil {
+		data = req.DefaultData
+	}
+	extraContext := map[string]interface{}{"warmup": true, "dataPresent": req.Warmup.Data != nil}
+	addHookContext(extraContext, req.PreHook, req.PostHook)
+	addHandlerNameContext(extraContext, req.HandlerName)
+	inputJSON, err := buildExecutionInput(envID, execID, req.MainModule, data, nil, extraContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build warmup input: %w", err)
+	}
+
+	timeoutMs, memoryMb := RuntimeDefaultLimits(defaultRuntime)
+	result, err := runContainer(ctx, &containerRun{
+		envID:       envID,
+		execID:      execID,
+		volumeName:  volumeName,
+		image:       image,
+		mainModule:  req.MainModule,
+		permissions: req.Permissions,
+		proxy:       resolveProxy(req.Proxy),
+		timezone:    req.Timezone,
+		locale:      req.Locale,
+		runtime:     runscRuntime(resolveRunscOptions(req.Runsc)),
+		input:       inputJSON,
+		timeoutMs:   timeoutMs,
+		memoryMb:    memoryMb,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("warmup failed: %w", err)
+	}
+	if result.timedOut {
+		return nil, fmt.Errorf("warmup failed: execution timeout exceeded")
+	}
+	if result.stalled {
+		return nil, fmt.Errorf("warmup failed: execution stalled without output")
+	}
+
+	resultJSON, stderr, exitCode, _ := parseRunnerOutput(result.stdout, result.stderr, result.exitCode)
+	if exitCode != 0 {
+		return nil, fmt.Errorf("warmup failed with exit code %d: %s", exitCode, stderr)
+	}
+
+	log.Info("environment warmup completed",
+		slog.String("environment_id", envID.String()),
+		slog.Int64("duration_ms", result.duration.Milliseconds()),
+	)
+	return resultShape(resultJSON), nil
+}
+
+func (e *DockerExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+	// Every log line for the execution carries the client's labels
+	if len(req.Labels) > 0 {
+		ctx = logger.WithAttrs(ctx, slog.Any("labels", req.Labels))
+	}
+	log := logger.FromContext(ctx)
+
+	// Acquire semaphore, giving up after a short wait so clients can back off
+	log.Debug("acquiring execution semaphore",
+		slog.String("environment_id", envID.String()),
+	)
+	release, err := acquireExecSlot(ctx, req.Priority == models.PriorityHigh)
+	if err != nil {
+		log.Warn("no execution slot available",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+	defer release()
+
+	// Register as in-flight before the lookup so in-place updates can't race us
+	done := e.inFlight.add(envID)
+	defer done()
+
+	// 1. Look up environment, along with its group's defaults
+	var volumeName, mainModule string
+	var metadataJSON, groupLimitsJSON, groupEnvJSON []byte
+	var version int
+	var status string
+	err = database.WithRetry(ctx, func() error {
+		return database.DB.QueryRowContext(ctx, `
+			SELECT e.volume_name, e.main_module, e.metadata, e.version, e.status, g.limits, g.env
+			FROM environments e
+			LEFT JOIN environment_groups g ON g.id = e.group_id
+			WHERE e.id = $1
+		`, envID).Scan(&volumeName, &mainModule, &metadataJSON, &version, &status, &groupLimitsJSON, &groupEnvJSON)
+	})
+
+	if err == nil && status == "draining" {
+		log.Warn("execution rejected: environment is draining",
+			slog.String("environment_id", envID.String()),
+		)
+		return nil, ErrEnvironmentDraining
+	}
+	if err == sql.ErrNoRows || (err == nil && status != "ready") {
+		log.Warn("environment not found or not ready",
+			slog.String("environment_id", envID.String()),
+		)
+		return nil, fmt.Errorf("environment not found or not ready")
+	} else if err != nil {
+		log.Error("database query failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+
+	// Parse metadata and permissions, failing closed if either is corrupt
+	metadata, permissions, err := parseEnvironmentMetadata(metadataJSON)
+	if err != nil {
+		log.Error("environment metadata is malformed, denying all permissions",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	// Executions inherit the group's limits and env vars unless they set their
+	// own. Strict mode judges only the vars the caller sent, since the group's
+	// are operator-set and dropped like any other var outside allowEnv.
+	requestEnv := req.Env
+	if groupDefaults, err := database.DecodeGroupDefaults(groupLimitsJSON, groupEnvJSON); err != nil {
+		log.Error("environment group defaults are malformed, ignoring them",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+	} else {
+		req = withGroupDefaults(req, groupDefaults)
+	}
+
+	// Resolve whether to persist this execution (request overrides environment default)
+	persist := true
+	if defaultPersist, ok := metadata["persistResults"].(bool); ok {
+		persist = defaultPersist
+	}
+	if req.Persist != nil {
+		persist = *req.Persist
+	}
+
+	// Fail fast if the request is missing env vars the environment requires
+	if missing := missingEnv(metadataStrings(metadata, "requiredEnv"), req.Env); len(missing) > 0 {
+		log.Warn("execution rejected: missing required env vars",
+			slog.String("environment_id", envID.String()),
+			slog.Any("missing", missing),
+		)
+		return nil, &Error{
+			Code:    "validation_error",
+			Message: "missing required env vars: " + strings.Join(missing, ", "),
+		}
+	}
+
+	// In strict mode, env vars that would be dropped are an error instead
+	if permissions != nil && permissions.StrictEnv {
+		if disallowed := disallowedEnv(permissions.AllowEnv, requestEnv); len(disallowed) > 0 {
+			log.Warn("execution rejected: env vars not allowed",
+				slog.String("environment_id", envID.String()),
+				slog.Any("disallowed", disallowed),
+			)
+			return nil, &Error{
+				Code:    "validation_error",
+				Message: "env vars not in allowEnv or denied by EXEC_ENV_DENIED_PREFIXES: " + strings.Join(disallowed, ", "),
+			}
+		}
+	}
+
+	// Only handlers the environment declared at setup may be selected
+	handlerName, err := selectHandler(metadata, req.Handler)
+	if err != nil {
+		log.Warn("execution rejected: handler not allowed",
+			slog.String("environment_id", envID.String()),
+			slog.String("handler", req.Handler),
+		)
+		return nil, &Error{Code: "validation_error", Message: err.Error()}
+	}
+
+	// Raw stdin mode pipes the data as is, so it must already be text or bytes
+	if req.RawStdin {
+		if err := validateRawStdin(req); err != nil {
+			log.Warn("execution rejected: invalid raw stdin request",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			return nil, &Error{Code: "validation_error", Message: err.Error()}
+		}
+	}
+
+	// Enforce the environment's own execution rate limit, if it set one
+	if limit := maxExecutionsPerMinute(metadata); limit > 0 {
+		if ok, wait := e.rateLimits.allow(envID, limit, time.Now()); !ok {
+			log.Warn("execution rejected: environment rate limit exceeded",
+				slog.String("environment_id", envID.String()),
+				slog.Int("max_executions_per_minute", limit),
+			)
+			return nil, &Error{
+				Code:       "environment_rate_limited",
+				Message:    fmt.Sprintf("environment allows at most %d executions per minute", limit),
+				RetryAfter: wait,
+			}
+		}
+	}
+
+	// 2. Apply limits, falling back to the runtime's defaults
+	timeoutMs, memoryMb := RuntimeDefaultLimits(environmentRuntime(metadata))
+	if req.Limits != nil {
+		if req.Limits.TimeoutMs > 0 {
+			timeoutMs = req.Limits.TimeoutMs
+		}
+		if req.Limits.MemoryMb > 0 {
+			memoryMb = req.Limits.MemoryMb
+		}
+	}
+	memorySwapMb := memoryMb
+	if req.Limits != nil && req.Limits.MemorySwapMb > 0 {
+		if req.Limits.MemorySwapMb < memoryMb {
+			return nil, &Error{
+				Code:    "validation_error",
+				Message: fmt.Sprintf("limits.memorySwapMb (%d) must be at least the memory limit (%d)", req.Limits.MemorySwapMb, memoryMb),
+			}
+		}
+		memorySwapMb = req.Limits.MemorySwapMb
+	}
+
+	// 3. Register the execution so it can be cancelled while it runs
+	execID := uuid.New()
+	if req.ExecutionID != nil {
+		execID = *req.ExecutionID
+		// A stored execution would make the record insert fail after the run
+		exists, err := database.ExecutionExists(ctx, execID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check execution ID: %w", err)
+		}
+		if exists {
+			return nil, &Error{Code: "conflict", Message: "execution " + execID.String() + " already exists"}
+		}
+	} else if req.DeterministicID {
+		if execID, err = deterministicExecutionID(envID, req); err != nil {
+			return nil, &Error{Code: "validation_error", Message: err.Error()}
+		}
+	}
+	execCtx, cancelExec := context.WithCancelCause(ctx)
+	defer cancelExec(nil)
+	untrack, ok := e.inFlight.track(envID, execID, cancelExec)
+	if !ok {
+		return nil, &Error{Code: "conflict", Message: "execution " + execID.String() + " is already running"}
+	}
+	defer untrack()
+
+	// 4. Build execution input. Streamed data follows the JSON header on stdin.
+	extraContext := map[string]interface{}{"dataPresent": dataPresent(req)}
+	var stream *limitedInput
+	if req.DataStream != nil {
+		extraContext["streamedData"] = true
+		stream = &limitedInput{r: req.DataStream, limit: MaxStreamInputBytes()}
+	}
+	if req.Records != nil {
+		extraContext["streamRecords"] = true
+	}
+	if len(req.Args) > 0 {
+		extraContext["args"] = req.Args
+	}
+	if req.WorkingDir != "" {
+		extraContext["workingDir"] = req.WorkingDir
+	}
+	addHookContext(extraContext, metadataDefault(metadata, "preHook", ""), metadataDefault(metadata, "postHook", ""))
+	addHandlerNameContext(extraContext, handlerName)
+	env := executionEnv(permissions, req.Env)
+	var warnings []string
+	if dropped := droppedEnv(req.Env, env); len(dropped) > 0 {
+		warnings = append(warnings, "env vars not passed (not in allowEnv or denied by EXEC_ENV_DENIED_PREFIXES): "+strings.Join(dropped, ", "))
+	}
+	data := executionData(metadata, req)
+	logExecutionPayload(ctx, "execution input", execID, "data", data, req.Env)
+	inputJSON, err := buildExecutionInput(envID, execID, mainModule, data, env, extraContext)
+	if err != nil {
+		log.Error("failed to marshal execution input",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+	if req.RawStdin {
+		// Raw stdin filters get the data itself, without the runner's envelope
+		rawData, _ := data.(string)
+		inputJSON = []byte(rawData)
+		if req.InputEncoding == models.InputEncodingBase64 {
+			if inputJSON, err = decodeBase64Input(rawData, MaxStreamInputBytes()); err != nil {
+				log.Warn("execution rejected: invalid base64 input",
+					slog.String("environment_id", envID.String()),
+					slog.String("error", err.Error()),
+				)
+				return nil, err
+			}
+		}
+	}
+
+	// 5. Run the container
+	run := &containerRun{
+		envID:        envID,
+		execID:       execID,
+		volumeName:   volumeName,
+		image:        environmentImage(metadata),
+		mainModule:   mainModule,
+		permissions:  permissions,
+		env:          env,
+		args:         req.Args,
+		workingDir:   req.WorkingDir,
+		rawStdin:     req.RawStdin,
+		proxy:        resolveProxy(environmentProxy(metadata)),
+		timezone:     metadataDefault(metadata, "timezone", req.Timezone),
+		locale:       metadataDefault(metadata, "locale", req.Locale),
+		runtime:      runscRuntime(resolveRunscOptions(environmentRunscOptions(metadata))),
+		input:        inputJSON,
+		collectStats: req.IncludeStats,
+		records:      req.Records,
+		timeoutMs:    timeoutMs,
+		memoryMb:     memoryMb,
+		memorySwapMb: memorySwapMb,
+		reproducible: req.Reproducible,
+		sourceEpoch:  req.SourceDateEpoch,
+	}
+	result, err := runContainer(execCtx, run, stream)
+	if stream != nil && stream.exceeded {
+		log.Warn("streamed input exceeded maximum size",
+			slog.String("environment_id", envID.String()),
+			slog.Int64("max_bytes", stream.limit),
+		)
+		return nil, &Error{
+			Code:    "input_too_large",
+			Message: fmt.Sprintf("streamed input exceeds maximum size of %d bytes", stream.limit),
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Re-run once with more memory if the handler ran out of it and the caller opted in
+	var attempts, attemptMemoryMb int
+	if req.RetryWithMoreMemory {
+		attempts, attemptMemoryMb = 1, memoryMb
+	}
+	if req.RetryWithMoreMemory && result.exitCode == oomExitCode && !result.timedOut && !result.stalled && !result.cancelled {
+		retryMemoryMb, retrySwapMb, ok := oomRetryMemory(memoryMb, memorySwapMb)
+		switch {
+		case stream != nil || req.Records != nil:
+			warnings = append(warnings, "not retried with more memory: streamed executions cannot be re-run")
+		case !ok:
+			warnings = append(warnings, fmt.Sprintf("not retried with more memory: the limit is already MAX_MEMORY_MB (%d MB)", MaxMemoryMb()))
+		default:
+			log.Info("retrying execution with more memory",
+				slog.String("environment_id", envID.String()),
+				slog.String("execution_id", execID.String()),
+				slog.Int("memory_mb", memoryMb),
+				slog.Int("retry_memory_mb", retryMemoryMb),
+			)
+			recordExecutionDuration(result.duration)
+			run.memoryMb, run.memorySwapMb = retryMemoryMb, retrySwapMb
+			result, err = runContainer(execCtx, run, nil)
+			if err != nil {
+				return nil, err
+			}
+			warnings = append(warnings, fmt.Sprintf("retried with %d MB after running out of memory at %d MB", retryMemoryMb, memoryMb))
+			memoryMb = retryMemoryMb
+			attempts, attemptMemoryMb = 2, retryMemoryMb
+		}
+	}
+	recordExecutionDuration(result.duration)
+	if result.timedOut {
+		// Return whatever the handler wrote before the timeout to help debugging
+		stdout, stderr, encoding := encodeOutput(OutputEncoding(), result.stdout,
+			withPartialOutput("Execution timeout exceeded", result.stderr))
+		return &models.ExecutionResponse{
+			ID:            execID,
+			ExitCode:      124,
+			Stdout:        stdout,
+			Stderr:        stderr,
+			DurationMs:    result.duration.Milliseconds(),
+			Signal:        result.signal,
+			Reason:        fmt.Sprintf("killed by timeout after %d ms", timeoutMs),
+			Encoding:      encoding,
+			ResourceUsage: result.usage,
+			Warnings:      warnings,
+			Attempts:      attempts,
+			MemoryMb:      attemptMemoryMb,
+		}, nil
+	}
+	if result.stalled {
+		stdout, stderr, encoding := encodeOutput(OutputEncoding(), result.stdout,
+			withPartialOutput("Execution stalled", result.stderr))
+		return &models.ExecutionResponse{
+			ID:            execID,
+			ExitCode:      124,
+			Stdout:        stdout,
+			Stderr:        stderr,
+			DurationMs:    result.duration.Milliseconds(),
+			Signal:        result.signal,
+			Reason:        fmt.Sprintf("stalled: killed after %d ms without output", ExecStallTimeout().Milliseconds()),
+			Encoding:      encoding,
+			ResourceUsage: result.usage,
+			Warnings:      warnings,
+			Attempts:      attempts,
+			MemoryMb:      attemptMemoryMb,
+		}, nil
+	}
+	if result.cancelled {
+		log.Info("execution cancelled",
+			slog.String("environment_id", envID.String()),
+			slog.String("execution_id", execID.String()),
+			slog.Int64("duration_ms", result.duration.Milliseconds()),
+		)
+		if persist && context.Cause(execCtx) == errExecutionCancelled {
+			storeExecution(ctx, envID, execID, req.DeterministicID, "cancelled", result.exitCode, "", "Execution cancelled", nil, req.Labels, result.duration)
+		}
+		return &models.ExecutionResponse{
+			ID:            execID,
+			ExitCode:      result.exitCode,
+			Stderr:        "Execution cancelled",
+			DurationMs:    result.duration.Milliseconds(),
+			Signal:        result.signal,
+			Reason:        "killed by cancellation",
+			ResourceUsage: result.usage,
+			Warnings:      warnings,
+			Attempts:      attempts,
+			MemoryMb:      attemptMemoryMb,
+		}, nil
+	}
+
+	// 6. Parse structured output from stdout. Raw stdin filters have no runner
+	// envelope, so their stdout is the result as is.
+	resultJSON, stderrStr, exitCode, success := result.stdout, result.stderr, result.exitCode, false
+	var outputs map[string]json.RawMessage
+	var contentType string
+	if !req.RawStdin {
+		resultJSON, stderrStr, exitCode, success = parseRunnerOutput(result.stdout, result.stderr, result.exitCode)
+		outputs = parseRunnerOutputs(result.stdout)
+
+		var err error
+		if contentType, err = parseRunnerContentType(result.stdout); err != nil {
+			warnings = append(warnings, "contentType ignored: "+err.Error())
+		}
+	}
+
+	signal, reason := describeExit(exitCode, memoryMb)
+
+	// Raw output may not be valid UTF-8; make it safe to store and JSON-encode
+	resultJSON, stderrStr, encoding := encodeOutput(OutputEncoding(), resultJSON, stderrStr)
+	if encoding != "" {
+		log.Warn("execution output was not valid UTF-8",
+			slog.String("execution_id", execID.String()),
+			slog.String("encoding", encoding),
+		)
+	}
+
+	log.Debug("execution output parsed",
+		slog.String("execution_id", execID.String()),
+		slog.Bool("success", success),
+		slog.Int("stdout_length", len(result.stdout)),
+		slog.Int("stderr_length", len(stderrStr)),
+	)
+	logExecutionPayload(ctx, "execution result", execID, "result", decodedResult(resultJSON), req.Env)
+
+	// 7. Store execution record and update stats (skipped when persistence is disabled)
+	if persist {
+		if err := storeExecution(ctx, envID, execID, req.DeterministicID, "completed", exitCode, resultJSON, stderrStr, outputs, req.Labels, result.duration); err != nil {
+			warnings = append(warnings, "the execution record could not be stored")
+		}
+		if success && !req.RawStdin && encoding == "" {
+			recordResultShape(ctx, envID, metadata, resultShape(resultJSON))
+		}
+	} else {
+		log.Debug("execution persistence disabled, skipping record",
+			slog.String("execution_id", execID.String()),
+		)
+	}
+
+	log.Info("execution completed",
+		slog.String("environment_id", envID.String()),
+		slog.String("execution_id", execID.String()),
+		slog.Int("exit_code", exitCode),
+		slog.Int64("duration_ms", result.duration.Milliseconds()),
+		slog.Bool("success", exitCode == 0),
+	)
+
+	// 8. Project the result when the caller only wants part of it
+	if req.Select != "" && success && !req.RawStdin && encoding == "" {
+		projected, err := selectResult(req.Select, resultJSON)
+		if err != nil {
+			warnings = append(warnings, "select ignored: "+err.Error())
+		} else {
+			resultJSON = projected
+		}
+	}
+
+	// 9. Wrap the result with server-side metadata when the full envelope is requested
+	if req.Envelope == models.EnvelopeFull && encoding != "" {
+		warnings = append(warnings, "envelope=full ignored: output is "+encoding+"-encoded")
+	}
+	if req.Envelope == models.EnvelopeFull && encoding == "" {
+		wrapped, err := wrapResult(resultJSON, success, resultMeta{
+			ExecutionID:        execID,
+			EnvironmentID:      envID,
+			EnvironmentVersion: version,
+			Runtime:            environmentRuntime(metadata),
+			ExitCode:           exitCode,
+			DurationMs:         result.duration.Milliseconds(),
+			CompletedAt:        time.Now().UTC(),
+		})
+		if err != nil {
+			log.Warn("failed to build result envelope, returning bare result",
+				slog.String("execution_id", execID.String()),
+				slog.String("error", err.Error()),
+			)
+			warnings = append(warnings, "envelope=full ignored: "+err.Error())
+		} else {
+			resultJSON = wrapped
+		}
+	}
+
+	return &models.ExecutionResponse{
+		ID:            execID,
+		ExitCode:      exitCode,
+		Stdout:        resultJSON,
+		Stderr:        stderrStr,
+		DurationMs:    result.duration.Milliseconds(),
+		Signal:        signal,
+		Reason:        reason,
+		Outputs:       outputs,
+		ContentType:   contentType,
+		Encoding:      encoding,
+		ResourceUsage: result.usage,
+		Warnings:      warnings,
+		Attempts:      attempts,
+		MemoryMb:      attemptMemoryMb,
+	}, nil
+}
+
+// withPartialOutput prefixes captured output with a status message, so the message
+// stays first for clients that match on it.
+func withPartialOutput(message, output string) string {
+	if output == "" {
+		return message
+	}
+	return message + "\n" + output
+}
+
+// storeExecution records the execution and bumps the environment's usage stats.
+// With overwrite, set for deterministic executions, re-running an execution ID
+// replaces its record; otherwise an existing record is a conflict. Failures are
+// logged but do not fail the execution; the error from storing the record is
+// returned so it can be reported as a warning.
+func storeExecution(ctx context.Context, envID, execID uuid.UUID, overwrite bool, status string, exitCode int, stdout, stderr string, outputs map[string]json.RawMessage, labels map[string]string, duration time.Duration) error {
+	log := logger.FromContext(ctx)
+
+	var outputsJSON, labelsJSON []byte
+	if len(outputs) > 0 {
+		outputsJSON, _ = json.Marshal(outputs)
+	}
+	if len(labels) > 0 {
+		labelsJSON, _ = json.Marshal(labels)
+	}
+
+	query := `
+		INSERT INTO executions
+		(id, environment_id, status, exit_code, stdout, stderr, outputs, duration_ms, labels, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())`
+	if overwrite {
+		query += `
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			started_at = EXCLUDED.started_at,
+			exit_code = EXCLUDED.exit_code,
+			stdout = EXCLUDED.stdout,
+			stderr = EXCLUDED.stderr,
+			outputs = EXCLUDED.outputs,
+			duration_ms = EXCLUDED.duration_ms,
+			labels = EXCLUDED.labels,
+			completed_at = EXCLUDED.completed_at
+		WHERE executions.environment_id = EXCLUDED.environment_id`
+	}
+	insert := func() error {
+		_, err := database.DB.ExecContext(ctx, query,
+			execID, envID, status, exitCode, stdout, stderr, outputsJSON, duration.Milliseconds(), labelsJSON)
+		return err
+	}
+
+	// Overwriting is idempotent, so only then is it safe to retry
+	var dbErr error
+	if overwrite {
+		dbErr = database.WithRetry(ctx, insert)
+	} else {
+		dbErr = insert()
+	}
+
+	storeErr := dbErr
+	if dbErr != nil {
+		log.Warn("failed to store execution record",
+			slog.String("execution_id", execID.String()),
+			slog.String("error", dbErr.Error()),
+		)
+	}
+
+	_, dbErr = database.DB.ExecContext(ctx, `
+		UPDATE environments
+		SET execution_count = execution_count + 1,
+			last_executed_at = NOW()
+		WHERE id = $1
+	`, envID)
+	// Keep this environment's execution reads on the primary until the replica
+	// has the new record
+	database.MarkWritten(envID)
+
+	if dbErr != nil {
+		log.Warn("failed to update environment stats",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", dbErr.Error()),
+		)
+	}
+	return storeErr
+}
+
+// buildExecutionInput marshals the JSON document the runner reads from stdin.
+// Extra context fields (e.g. the warmup flag) are merged into the context object.
+func buildExecutionInput(envID, execID uuid.UUID, mainModule string, data interface{}, env map[string]string, extraContext map[string]interface{}) ([]byte, error) {
+	execContext := map[string]interface{}{
+		"executionId":   execID.String(),
+		"environmentId": envID.String(),
+		"requestId":     execID.String(),
+	}
+	for key, value := range extraContext {
+		execContext[key] = value
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"event": map[string]interface{}{
+			"data": data,
+			"env":  env,
+		},
+		"context":    execContext,
+		"mainModule": mainModule,
+	})
+}
+
+// containerRun describes a single invocation of the runtime container.
+type containerRun struct {
+	envID        uuid.UUID
+	execID       uuid.UUID
+	volumeName   string
+	image        string // runtime image to run
+	mainModule   string
+	permissions  *models.Permissions
+	env          map[string]string   // requested env vars, already filtered by executionEnv
+	args         []string            // command-line args appended after the runner script
+	workingDir   string              // directory within /workspace for raw stdin runs
+	rawStdin     bool                // run the main module directly instead of the runner
+	proxy        *models.ProxyConfig // outbound proxy, applied only when network access is allowed
+	timezone     string              // TZ for the container; empty means UTC
+	locale       string              // LANG for the container; empty keeps the image default
+	runtime      string              // docker runtime for gVisor; empty means runsc
+	input        []byte              // JSON piped to the runner on stdin, or raw data
+	timeoutMs    int
+	memoryMb     int
+	memorySwapMb int       // memory plus swap; 0 means equal to memoryMb (no swap)
+	collectStats bool      // sample docker stats while the container runs
+	records      io.Writer // receives record lines from streaming handlers
+	reproducible bool      // fix the clock and random seed at sourceEpoch
+	sourceEpoch  int64     // SOURCE_DATE_EPOCH for reproducible runs, in seconds
+}
+
+// containerResult holds the raw outcome of a container invocation.
+type containerResult struct {
+	exitCode  int
+	stdout    string
+	stderr    string
+	duration  time.Duration
+	timedOut  bool
+	cancelled bool
+	stalled   bool                  // killed by stall detection after no output for ExecStallTimeout
+	signal    string                // signal that stopped a timed-out, stalled or cancelled container
+	usage     *models.ResourceUsage // nil unless stats were collected
+}
+
+// runContainer starts a sandboxed runtime container for the given run and waits for it to exit.
+// When stream is set it is piped to stdin after the JSON input and a newline separator.
+// A non-zero exit code is not an error; an error is only returned if the container could not be run.
+func runContainer(ctx context.Context, run *containerRun, stream io.Reader) (*containerResult, error) {
+	log := logger.FromContext(ctx)
+	envID := run.envID
+	execID := run.execID
+
+	execCtx, cancel := context.WithTimeout(ctx, time.Duration(run.timeoutMs)*time.Millisecond)
+	defer cancel()
+
+	log.Debug("starting container execution",
+		slog.String("environment_id", envID.String()),
+		slog.String("execution_id", execID.String()),
+		slog.String("volume_name", run.volumeName),
+		slog.String("main_module", run.mainModule),
+		slog.Int("timeout_ms", run.timeoutMs),
+		slog.Int("memory_mb", run.memoryMb),
+	)
+
+	// Build docker run command
+	// Name the container so it can be stopped if the docker CLI is killed on cancellation
+	name := containerName(execID)
+	args := []string{
+		"run",
+		"--rm",
+		"-i",
+		"--name", name,
+	}
+
+	// The runner changes directory itself; raw stdin filters are started in place
+	if run.rawStdin {
+		workDir := "/workspace"
+		if run.workingDir != "" {
+			workDir += "/" + run.workingDir
+		}
+		args = append(args, "-w", workDir)
+	}
+
+	// Add gVisor runtime if not disabled
+	if !IsGVisorDisabled() {
+		runtime := run.runtime
+		if runtime == "" {
+			runtime = defaultRunscRuntime
+		}
+		args = append(args, "--runtime="+runtime)
+	} else {
+		log.Warn("gVisor is disabled - execution is not sandboxed",
+			slog.String("environment_id", envID.String()),
+			slog.String("execution_id", execID.String()),
+		)
+	}
+
+	// Determine network mode based on permissions, capped by the server-wide allowlist
+	permissions := restrictAllowNet(run.permissions)
+	networkMode := "none"
+	if permissions != nil && len(permissions.AllowNet) > 0 {
+		networkMode = "bridge"
+		log.Info("network access enabled with whitelist",
+			slog.String("environment_id", envID.String()),
+			slog.Any("allowed_domains", permissions.AllowNet),
+		)
+	}
+
+	// Without --memory-swap docker allows as much swap again as memory
+	memorySwapMb := run.memorySwapMb
+	if memorySwapMb < run.memoryMb {
+		memorySwapMb = run.memoryMb
+	}
+
+	// Continue with other args
+	args = append(args,
+		fmt.Sprintf("--network=%s", networkMode),
+		"--read-only",
+		fmt.Sprintf("--memory=%dm", run.memoryMb),
+		fmt.Sprintf("--memory-swap=%dm", memorySwapMb),
+		"--cpus=0.5",
+		"--pids-limit=100",
+		"-v", fmt.Sprintf("%s:/workspace:ro", run.volumeName),
+		"-v", fmt.Sprintf("%s:/deno-dir:ro", run.volumeName), // Mount cached dependencies
+		"-e", "DENO_DIR=/deno-dir",                           // Tell Deno where to find cache
+	)
+	args = append(args, cpusetArgs()...)
+
+	// Pass the execution's env vars explicitly. docker run never inherits the
+	// API server's environment, so these (and the settings below) are all the
+	// container sees besides the image defaults.
+	envKeys := make([]string, 0, len(run.env))
+	for key := range run.env {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+	for _, key := range envKeys {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", key, run.env[key]))
+		log.Debug("passing whitelisted env var",
+			slog.String("key", key),
+		)
+	}
+
+	// Route allowed egress through the configured proxy. Deno checks --allow-net
+	// against the target host, so the allowlist still applies behind the proxy.
+	if networkMode == "bridge" {
+		args = append(args, proxyEnvArgs(run.proxy)...)
+	}
+
+	// Set the handler's timezone and locale, defaulting to UTC
+	timezone := run.timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	args = append(args, "-e", "TZ="+timezone)
+	if run.locale != "" {
+		args = append(args, "-e", "LANG="+run.locale)
+	}
+
+	// Fix the timestamp and clock for reproducible runs. Set after the
+	// requested env vars so they cannot override it.
+	if run.reproducible {
+		args = append(args, reproducibleEnvArgs(run.sourceEpoch)...)
+	}
+
+	// Build Deno permission flags
+	denoPermissions := "--allow-read=/workspace,/runtime,/deno-dir --allow-env"
+	if permissions != nil && len(permissions.AllowNet) > 0 {
+		// Add network permission with domain whitelist
+		denoPermissions += fmt.Sprintf(" --allow-net=%s", strings.Join(permissions.AllowNet, ","))
+	}
+
+	// Override entrypoint to pass custom Deno permissions
 	args = append(args,
 		"--entrypoint", "deno",
-		RuntimeImage(),
+		run.image,
 		"run",
 	)
 	// Add Deno permission flags
@@ -386,13 +1570,31 @@ func (e *DockerExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UU
 			args = append(args, perm)
 		}
 	}
-	// Add the runner script path
-	args = append(args, "/runtime/runner.ts")
+	if run.reproducible {
+		args = append(args, reproducibleDenoFlags(run.sourceEpoch)...)
+	}
+	// Add the runner script path (or the main module itself for raw stdin filters),
+	// followed by the handler's args (exposed as Deno.args)
+	if run.rawStdin {
+		args = append(args, "/workspace/"+run.mainModule)
+	} else {
+		args = append(args, "/runtime/runner.ts")
+	}
+	args = append(args, run.args...)
 
-	// 5. Execute with stdin
+	// Execute with stdin. runCtx is also cancelled by stall detection.
 	startTime := time.Now()
-	cmd := exec.CommandContext(execCtx, "docker", args...)
-	cmd.Stdin = bytes.NewReader(inputJSON)
+	runCtx, stopRun := context.WithCancelCause(execCtx)
+	defer stopRun(nil)
+	cmd := DockerCommand(runCtx, args...)
+	switch {
+	case stream != nil && run.rawStdin:
+		cmd.Stdin = stream
+	case stream != nil:
+		cmd.Stdin = io.MultiReader(bytes.NewReader(run.input), strings.NewReader("\n"), stream)
+	default:
+		cmd.Stdin = bytes.NewReader(run.input)
+	}
 
 	// Create streaming writers that log output in real-time
 	stdoutWriter := &streamingWriter{
@@ -412,38 +1614,140 @@ func (e *DockerExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UU
 
 	// Also capture full output for parsing the result
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = io.MultiWriter(stdoutWriter, &stdout)
-	cmd.Stderr = io.MultiWriter(stderrWriter, &stderr)
+	cmd.Stdout = outputWriter(stdoutWriter, &stdout)
+	cmd.Stderr = outputWriter(stderrWriter, &stderr)
+
+	// Streaming handlers interleave record lines with the final result; forward
+	// the records and keep only the result for parsing
+	var records *recordSplitter
+	if run.records != nil {
+		records = &recordSplitter{out: run.records, rest: &stdout}
+		cmd.Stdout = outputWriter(stdoutWriter, records)
+	}
+
+	// Kill containers that go silent for longer than the stall timeout
+	stallTimeout := ExecStallTimeout()
+	if stallTimeout > 0 {
+		activity := newActivityWriter(startTime)
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, activity)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, activity)
+		go watchStall(runCtx, activity, stallTimeout, stopRun)
+	}
+
+	// Kill containers that churn through processes fast enough to thrash the
+	// host while staying under --pids-limit. The host cgroup only sees a
+	// container's processes when it isn't sandboxed by gVisor.
+	churnLimit, churnWindow := ProcessChurnLimit(), ProcessChurnWindow()
+	if churnLimit > 0 && IsGVisorDisabled() {
+		go watchProcessChurn(runCtx, name, churnLimit, churnWindow, stopRun)
+	}
 
-	err = cmd.Run()
+	statsCtx, stopSampling := context.WithCancel(execCtx)
+	defer stopSampling()
+	var sampler *statsSampler
+	if run.collectStats {
+		sampler = startStatsSampler(statsCtx, name)
+	}
+
+	err := cmd.Run()
 
 	// Flush any remaining buffered output
 	stdoutWriter.Flush()
 	stderrWriter.Flush()
+	if records != nil {
+		records.Flush()
+	}
 	duration := time.Since(startTime)
 
-	// 6. Handle exit
+	var usage *models.ResourceUsage
+	if sampler != nil {
+		stopSampling()
+		usage = sampler.stop()
+	}
+
+	// Killing the docker CLI does not stop the container itself
+	var signal string
+	if err != nil && runCtx.Err() != nil {
+		signal = stopContainer(ctx, name, execID)
+	}
+
+	// Handle exit
 	exitCode := 0
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-			log.Debug("execution completed with non-zero exit",
+		if execCtx.Err() == nil && context.Cause(runCtx) == errProcessChurn {
+			log.Warn("execution killed for process churn",
+				slog.String("environment_id", envID.String()),
+				slog.String("execution_id", execID.String()),
+				slog.Int("churn_limit", churnLimit),
+				slog.Int64("churn_window_ms", churnWindow.Milliseconds()),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+			)
+			return nil, &Error{
+				Code:    "resource_abuse",
+				Message: fmt.Sprintf("execution killed: created or reaped processes more than %d times within %d ms", churnLimit, churnWindow.Milliseconds()),
+			}
+		} else if execCtx.Err() == nil && context.Cause(runCtx) == errExecutionStalled {
+			log.Warn("execution stalled",
+				slog.String("environment_id", envID.String()),
 				slog.String("execution_id", execID.String()),
-				slog.Int("exit_code", exitCode),
+				slog.Int64("stall_timeout_ms", stallTimeout.Milliseconds()),
+				slog.Int64("duration_ms", duration.Milliseconds()),
 			)
+			return &containerResult{
+				exitCode: 124,
+				stdout:   stdout.String(),
+				stderr:   stderr.String(),
+				duration: duration,
+				stalled:  true,
+				signal:   signal,
+				usage:    usage,
+			}, nil
 		} else if execCtx.Err() == context.DeadlineExceeded {
 			log.Warn("execution timeout exceeded",
 				slog.String("environment_id", envID.String()),
 				slog.String("execution_id", execID.String()),
-				slog.Int("timeout_ms", timeoutMs),
+				slog.Int("timeout_ms", run.timeoutMs),
+				slog.Int64("duration_ms", duration.Milliseconds()),
+			)
+			return &containerResult{
+				exitCode: 124,
+				stdout:   stdout.String(),
+				stderr:   stderr.String(),
+				duration: duration,
+				timedOut: true,
+				signal:   signal,
+				usage:    usage,
+			}, nil
+		} else if execCtx.Err() == context.Canceled {
+			log.Warn("execution cancelled",
+				slog.String("environment_id", envID.String()),
+				slog.String("execution_id", execID.String()),
 				slog.Int64("duration_ms", duration.Milliseconds()),
 			)
-			return &models.ExecutionResponse{
-				ID:         execID,
-				ExitCode:   124,
-				Stderr:     "Execution timeout exceeded",
-				DurationMs: duration.Milliseconds(),
+			return &containerResult{
+				exitCode:  130,
+				stdout:    stdout.String(),
+				stderr:    stderr.String(),
+				duration:  duration,
+				cancelled: true,
+				signal:    signal,
+				usage:     usage,
 			}, nil
+		} else if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+			// Exit code 125 means docker itself failed before the container started
+			if exitCode == 125 && isImagePullFailure(stderr.String()) {
+				log.Error("runtime image pull failed",
+					slog.String("environment_id", envID.String()),
+					slog.String("execution_id", execID.String()),
+					slog.String("image", run.image),
+				)
+				return nil, imagePullError(run.image, stderr.String())
+			}
+			log.Debug("execution completed with non-zero exit",
+				slog.String("execution_id", execID.String()),
+				slog.Int("exit_code", exitCode),
+			)
 		} else {
 			log.Error("execution failed",
 				slog.String("environment_id", envID.String()),
@@ -454,92 +1758,366 @@ func (e *DockerExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UU
 		}
 	}
 
-	// 7. Parse structured output from stdout
+	return &containerResult{
+		exitCode: exitCode,
+		stdout:   stdout.String(),
+		stderr:   stderr.String(),
+		duration: duration,
+		usage:    usage,
+	}, nil
+}
+
+// validateRawStdin checks that a raw stdin request has data that can be piped
+// as is: a string, a streamed body, or nothing at all
+func validateRawStdin(req *models.ExecuteRequest) error {
+	if req.Records != nil {
+		return fmt.Errorf("rawStdin cannot be combined with NDJSON record streaming")
+	}
+	if req.DataStream == nil && req.Data != nil {
+		if _, ok := req.Data.(string); !ok {
+			return fmt.Errorf("rawStdin requires data to be a string or an application/octet-stream body")
+		}
+	}
+	if req.InputEncoding == models.InputEncodingBase64 && req.DataStream != nil {
+		return fmt.Errorf("inputEncoding cannot be combined with an application/octet-stream body")
+	}
+	return nil
+}
+
+// runnerEnvelopeMarker is set on every envelope the runner writes, so handler
+// output that merely parses as JSON is not mistaken for it.
+const runnerEnvelopeMarker = "teeEnvelope"
+
+// legacyEnvelopeKeys are the fields of the envelope written by runtime images
+// from before runnerEnvelopeMarker
+var legacyEnvelopeKeys = map[string]bool{
+	"success": true, "result": true, "outputs": true, "error": true,
+	"stack": true, "logs": true, "timing": true,
+}
+
+// runnerEnvelope returns the fields of stdout when it is the runner's structured
+// envelope: a JSON object carrying runnerEnvelopeMarker, or, for older runtime
+// images, one with a boolean success and only envelope fields. Any other
+// stdout, including JSON such as a bare array or an arbitrary object, is not.
+func runnerEnvelope(stdout string) (map[string]json.RawMessage, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stdout), &fields); err != nil || fields == nil {
+		return nil, false
+	}
+	if _, ok := fields[runnerEnvelopeMarker]; ok {
+		return fields, true
+	}
+	var success bool
+	if err := json.Unmarshal(fields["success"], &success); err != nil {
+		return nil, false
+	}
+	for key := range fields {
+		if !legacyEnvelopeKeys[key] {
+			return nil, false
+		}
+	}
+	return fields, true
+}
+
+// parseRunnerOutput interprets the runner's stdout. When stdout holds the runner's
+// structured envelope, the result is re-marshaled and failures are moved to stderr;
+// otherwise stdout is returned as raw output.
+func parseRunnerOutput(stdout, stderr string, exitCode int) (result string, errOutput string, code int, success bool) {
+	fields, ok := runnerEnvelope(stdout)
+	if !ok {
+		// Fallback: treat stdout as raw output
+		return stdout, stderr, exitCode, false
+	}
+
 	var output struct {
 		Success bool        `json:"success"`
 		Result  interface{} `json:"result"`
 		Error   string      `json:"error"`
 	}
+	envelopeJSON, _ := json.Marshal(fields)
+	if err := json.Unmarshal(envelopeJSON, &output); err != nil {
+		return stdout, stderr, exitCode, false
+	}
 
-	stdoutStr := stdout.String()
-	stderrStr := stderr.String()
-	resultJSON := ""
+	if !output.Success {
+		if exitCode == 0 {
+			exitCode = 1
+		}
+		return "", output.Error, exitCode, false
+	}
 
-	// Try to parse stdout as structured JSON
-	if err := json.Unmarshal([]byte(stdoutStr), &output); err == nil {
-		if output.Success {
-			resultBytes, _ := json.Marshal(output.Result)
-			resultJSON = string(resultBytes)
-		} else {
-			stderrStr = output.Error
-			if exitCode == 0 {
-				exitCode = 1
-			}
+	resultBytes, _ := json.Marshal(output.Result)
+	return string(resultBytes), stderr, exitCode, true
+}
+
+// parseRunnerContentType returns the content type the handler declared for its
+// result, from the runner's stdout, normalized by resultContentType. It returns
+// "" when none was declared or stdout is not the runner's envelope.
+func parseRunnerContentType(stdout string) (string, error) {
+	fields, ok := runnerEnvelope(stdout)
+	if !ok {
+		return "", nil
+	}
+	var declared string
+	if err := json.Unmarshal(fields["contentType"], &declared); err != nil || declared == "" {
+		return "", nil
+	}
+	return resultContentType(declared)
+}
+
+// parseRunnerOutputs returns the named outputs from the runner's stdout, or nil
+// when there are none or stdout is not the runner's envelope
+func parseRunnerOutputs(stdout string) map[string]json.RawMessage {
+	fields, ok := runnerEnvelope(stdout)
+	if !ok {
+		return nil
+	}
+	var outputs map[string]json.RawMessage
+	if err := json.Unmarshal(fields["outputs"], &outputs); err != nil || len(outputs) == 0 {
+		return nil
+	}
+	return outputs
+}
+
+func (e *DockerExecutor) UpdateEnvironment(ctx context.Context, envID uuid.UUID, req *models.UpdateRequest) (*models.Environment, error) {
+	log := logger.FromContext(ctx)
+
+	// 1. Claim the environment by moving it out of 'ready' so no new executions start
+	var volumeName, mainModule string
+	var metadataJSON []byte
+	err := database.DB.QueryRowContext(ctx, `
+		UPDATE environments SET status = 'updating', updating_since = NOW()
+		WHERE id = $1 AND status = 'ready'
+		RETURNING volume_name, main_module, metadata
+	`, envID).Scan(&volumeName, &mainModule, &metadataJSON)
+	if err == sql.ErrNoRows {
+		log.Warn("environment not found or not ready for update",
+			slog.String("environment_id", envID.String()),
+		)
+		return nil, &Error{Code: "conflict", Message: "environment not found or not ready"}
+	} else if err != nil {
+		log.Error("failed to claim environment for update",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+
+	// restoreReady puts the environment back into service if the update is
+	// abandoned before its volume was touched
+	restoreReady := func() {
+		database.DB.ExecContext(context.Background(),
+			"UPDATE environments SET status = 'ready' WHERE id = $1", envID)
+	}
+	// markFailed takes the environment out of service for good once its volume
+	// may hold a mix of old and new modules
+	markFailed := func(cause error) {
+		log.Error("environment left failed by a partial update",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", cause.Error()),
+		)
+		database.DB.ExecContext(context.Background(),
+			"UPDATE environments SET status = 'failed' WHERE id = $1", envID)
+	}
+
+	// 2. Let running executions finish before rewriting the volume underneath them.
+	// New ones are already refused since the environment is no longer 'ready'.
+	if !e.drain(ctx, envID) {
+		n := e.inFlight.count(envID)
+		restoreReady()
+		log.Warn("update rejected: executions still in flight after drain timeout",
+			slog.String("environment_id", envID.String()),
+			slog.Int("in_flight", n),
+		)
+		return nil, &Error{Code: "conflict", Message: fmt.Sprintf("environment has %d execution(s) in flight", n)}
+	}
+
+	var metadata map[string]interface{}
+	if metadataJSON != nil {
+		if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+			// Rebuilt metadata carries no permissions, so the environment fails closed
+			log.Error("environment metadata is malformed, rebuilding it without permissions",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			metadata = nil
 		}
-	} else {
-		// Fallback: treat stdout as raw output
-		resultJSON = stdoutStr
 	}
+	metadata = database.UpgradeMetadata(metadata)
 
-	log.Debug("execution output parsed",
-		slog.String("execution_id", execID.String()),
-		slog.Bool("success", output.Success),
-		slog.Int("stdout_length", len(stdoutStr)),
-		slog.Int("stderr_length", len(stderrStr)),
+	// Resolve the resulting module set to validate the entry point
+	existingModules := metadataStrings(metadata, "modules")
+	resultingModules := existingModules
+	if req.Modules != nil {
+		resultingModules = moduleNames(req.Modules)
+	}
+	if req.MainModule != "" {
+		mainModule = req.MainModule
+	}
+	if resultingModules != nil && !containsString(resultingModules, mainModule) {
+		restoreReady()
+		return nil, &Error{Code: "validation_error", Message: "mainModule must exist in modules map"}
+	}
+	if resultingModules != nil {
+		if err := checkReferencedModules(metadata, resultingModules); err != nil {
+			restoreReady()
+			return nil, err
+		}
+	}
+
+	log.Info("updating environment",
+		slog.String("environment_id", envID.String()),
+		slog.String("volume_name", volumeName),
+		slog.Int("module_count", len(req.Modules)),
 	)
 
-	// 8. Store execution record
-	_, dbErr := database.DB.ExecContext(ctx, `
-		INSERT INTO executions
-		(id, environment_id, exit_code, stdout, stderr, duration_ms, completed_at)
-		VALUES ($1, $2, $3, $4, $5, $6, NOW())
-	`, execID, envID, exitCode, resultJSON, stderrStr, duration.Milliseconds())
+	// 3. Stage the new modules beside the live ones, leaving the environment
+	// untouched if writing them fails
+	var stale []string
+	if req.Modules != nil {
+		for _, name := range existingModules {
+			if _, ok := req.Modules[name]; !ok {
+				stale = append(stale, "'/workspace/"+name+"'")
+			}
+		}
+		if err := stageModules(ctx, volumeName, req.Modules); err != nil {
+			discardStagedModules(volumeName)
+			restoreReady()
+			return nil, err
+		}
+		metadata["modules"] = resultingModules
+		metadata["moduleCount"] = len(req.Modules)
+		// The new code may return something else; the next execution records it
+		delete(metadata, "resultShape")
+	}
 
-	if dbErr != nil {
-		log.Warn("failed to store execution record",
-			slog.String("execution_id", execID.String()),
-			slog.String("error", dbErr.Error()),
-		)
+	// 4. Re-install dependencies
+	if req.Dependencies != nil {
+		if err := installDependencies(ctx, volumeName, environmentImage(metadata), environmentInstallStrategy(metadata), resolveProxy(environmentProxy(metadata)), req.Dependencies, nil); err != nil {
+			log.Error("dependency installation failed during update",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			if req.Modules != nil {
+				discardStagedModules(volumeName)
+			}
+			restoreReady()
+			return nil, fmt.Errorf("failed to install dependencies: %w", err)
+		}
+		depCount := req.Dependencies.Count()
+		metadata["dependencies"] = req.Dependencies
+		metadata["dependencyCount"] = depCount
+		metadata["hasDependencies"] = depCount > 0
 	}
 
-	// 9. Update stats
-	_, dbErr = database.DB.ExecContext(ctx, `
+	// From here on the volume changes, so the update finishes even if the client
+	// goes away, and a failure leaves the environment 'failed' rather than
+	// serving a mix of old and new modules
+	ctx = context.WithoutCancel(ctx)
+	if req.Modules != nil {
+		if err := swapInModules(ctx, volumeName, stale); err != nil {
+			markFailed(err)
+			return nil, fmt.Errorf("failed to swap in modules: %w", err)
+		}
+	}
+
+	// 5. Store the new metadata and disk usage, bump the version and put the environment
+	// back in service. Its content no longer matches the setup it came from, so it is
+	// not reused again.
+	newMetadataJSON, _ := json.Marshal(metadata)
+	diskUsage := measureDiskUsage(ctx, envID, volumeName)
+	var env models.Environment
+	row := database.DB.QueryRowContext(ctx, `
 		UPDATE environments
-		SET execution_count = execution_count + 1,
-			last_executed_at = NOW()
+		SET main_module = $2, metadata = $3, version = version + 1, status = 'ready', content_hash = NULL,
+		    disk_usage_bytes = COALESCE($4, disk_usage_bytes)
 		WHERE id = $1
-	`, envID)
+		RETURNING `+database.EnvironmentColumns,
+		envID, mainModule, newMetadataJSON, diskUsage)
+	err = database.ScanEnvironment(row, &env)
+	if err != nil {
+		log.Error("failed to store updated environment",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		markFailed(err)
+		return nil, fmt.Errorf("failed to store environment: %w", err)
+	}
+	database.MarkWritten(envID)
 
-	if dbErr != nil {
-		log.Warn("failed to update environment stats",
+	log.Info("environment updated",
+		slog.String("environment_id", envID.String()),
+		slog.Int("version", env.Version),
+	)
+
+	return &env, nil
+}
+
+func (e *DockerExecutor) GetEnvironment(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+	log := logger.FromContext(ctx)
+
+	var env models.Environment
+	err := database.WithReader(ctx, envID, func(db *sql.DB) error {
+		row := db.QueryRowContext(ctx, `
+			SELECT `+database.EnvironmentColumns+`
+			FROM environments
+			WHERE id = $1
+		`, envID)
+		return database.ScanEnvironment(row, &env)
+	})
+
+	if err == sql.ErrNoRows {
+		return nil, ErrEnvironmentNotFound
+	} else if err != nil {
+		log.Error("failed to query environment",
 			slog.String("environment_id", envID.String()),
-			slog.String("error", dbErr.Error()),
+			slog.String("error", err.Error()),
 		)
+		return nil, err
 	}
 
-	log.Info("execution completed",
+	return &env, nil
+}
+
+func (e *DockerExecutor) CancelExecution(ctx context.Context, envID, execID uuid.UUID) error {
+	if !e.inFlight.cancel(envID, execID) {
+		return ErrExecutionNotRunning
+	}
+
+	logger.FromContext(ctx).Info("execution cancellation requested",
 		slog.String("environment_id", envID.String()),
 		slog.String("execution_id", execID.String()),
-		slog.Int("exit_code", exitCode),
-		slog.Int64("duration_ms", duration.Milliseconds()),
-		slog.Bool("success", exitCode == 0),
 	)
-
-	return &models.ExecutionResponse{
-		ID:         execID,
-		ExitCode:   exitCode,
-		Stdout:     resultJSON,
-		Stderr:     stderrStr,
-		DurationMs: duration.Milliseconds(),
-	}, nil
+	return nil
 }
 
 func (e *DockerExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID) error {
+	// Once started, a delete runs to completion even if the client goes away:
+	// stopping between removing the volume and deleting the row would leave a
+	// 'draining' environment with nothing behind it
+	ctx = context.WithoutCancel(ctx)
 	log := logger.FromContext(ctx)
 
-	// Get volume name
+	// A shared environment is only removed once its last reference is released
+	shared, err := releaseSharedEnvironment(ctx, envID)
+	if err != nil {
+		log.Error("failed to release environment reference",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	if shared {
+		return nil
+	}
+
+	// Mark the environment draining so it accepts no new executions
 	var volumeName string
-	err := database.DB.QueryRowContext(ctx, "SELECT volume_name FROM environments WHERE id = $1", envID).Scan(&volumeName)
+	err = database.WithRetry(ctx, func() error {
+		return database.DB.QueryRowContext(ctx,
+			"UPDATE environments SET status = 'draining' WHERE id = $1 RETURNING volume_name", envID).Scan(&volumeName)
+	})
+	database.MarkWritten(envID)
 	if err != nil {
 		log.Error("failed to find environment for deletion",
 			slog.String("environment_id", envID.String()),
@@ -548,13 +2126,25 @@ func (e *DockerExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID)
 		return err
 	}
 
+	// Let running executions finish, cancelling any that outlast DRAIN_TIMEOUT_SECONDS
+	if !e.drain(ctx, envID) {
+		cancelled := e.inFlight.cancelAll(envID)
+		log.Warn("drain timed out, cancelling running executions",
+			slog.String("environment_id", envID.String()),
+			slog.Int("cancelled", cancelled),
+		)
+		stopCtx, cancel := context.WithTimeout(ctx, ContainerStopTimeout()+10*time.Second)
+		e.inFlight.wait(stopCtx, envID)
+		cancel()
+	}
+
 	log.Debug("deleting environment",
 		slog.String("environment_id", envID.String()),
 		slog.String("volume_name", volumeName),
 	)
 
 	// Remove volume
-	if err := exec.Command("docker", "volume", "rm", "-f", volumeName).Run(); err != nil {
+	if err := DockerCommand(context.Background(), "volume", "rm", "-f", volumeName).Run(); err != nil {
 		log.Warn("failed to remove docker volume",
 			slog.String("volume_name", volumeName),
 			slog.String("error", err.Error()),
@@ -562,7 +2152,10 @@ func (e *DockerExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID)
 	}
 
 	// Delete from DB (cascades to executions)
-	_, err = database.DB.ExecContext(ctx, "DELETE FROM environments WHERE id = $1", envID)
+	err = database.WithRetry(ctx, func() error {
+		_, err := database.DB.ExecContext(ctx, "DELETE FROM environments WHERE id = $1", envID)
+		return err
+	})
 	if err != nil {
 		log.Error("failed to delete environment from database",
 			slog.String("environment_id", envID.String()),
@@ -570,6 +2163,7 @@ func (e *DockerExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID)
 		)
 		return err
 	}
+	e.rateLimits.forget(envID)
 
 	log.Info("environment deleted",
 		slog.String("environment_id", envID.String()),
@@ -579,101 +2173,247 @@ func (e *DockerExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID)
 	return nil
 }
 
-// streamingWriter wraps a logger to stream output line by line
+// drain waits up to DrainTimeout for the environment's running executions to
+// finish, reporting whether they all did.
+func (e *DockerExecutor) drain(ctx context.Context, envID uuid.UUID) bool {
+	drainCtx, cancel := context.WithTimeout(ctx, DrainTimeout())
+	defer cancel()
+	return e.inFlight.wait(drainCtx, envID)
+}
+
+// environmentVolumeName returns the docker volume name for an environment
+func environmentVolumeName(envID uuid.UUID) string {
+	return VolumePrefix() + envID.String()
+}
+
+// checkVolumeAbsent returns an infra_error when a volume with the name already
+// exists, since creating it would hand another environment's files to this one.
+func checkVolumeAbsent(ctx context.Context, volumeName string) error {
+	cmd := DockerCommand(ctx, "volume", "inspect", volumeName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return &Error{Code: "infra_error", Message: "volume " + volumeName + " already exists"}
+	}
+	if !isNoSuchVolume(stderr.String()) {
+		return fmt.Errorf("failed to inspect volume: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// isNoSuchVolume reports whether docker's stderr says the volume does not exist
+func isNoSuchVolume(stderr string) bool {
+	return strings.Contains(strings.ToLower(stderr), "no such volume")
+}
+
+// IsEnvironmentVolume reports whether a docker volume belongs to this deployment:
+// VOLUME_PREFIX followed by nothing but an environment ID. Requiring the ID
+// keeps "tee-env-" from claiming another deployment's "tee-env-staging-" volumes.
+func IsEnvironmentVolume(volumeName string) bool {
+	id, ok := strings.CutPrefix(volumeName, VolumePrefix())
+	if !ok {
+		return false
+	}
+	_, err := uuid.Parse(id)
+	return err == nil && len(id) == 36
+}
+
+// containerName returns the docker container name used for an execution
+func containerName(execID uuid.UUID) string {
+	return "tee-exec-" + execID.String()
+}
+
+// stopContainer stops a container left behind by a cancelled execution, giving it
+// ContainerStopTimeout to exit before it is killed. It uses a fresh context because
+// the execution context is already done.
+//
+// It returns the signal that ended the container: SIGTERM when it exited within
+// the grace period, SIGKILL when docker stop had to escalate or the container
+// was killed, and "" when it had already exited on its own.
+func stopContainer(ctx context.Context, name string, execID uuid.UUID) string {
+	log := logger.FromContext(ctx)
+	grace := ContainerStopTimeout()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), grace+10*time.Second)
+	defer cancel()
+
+	log.Warn("forcing stop of cancelled execution container",
+		slog.String("execution_id", execID.String()),
+		slog.String("container", name),
+		slog.Int("grace_seconds", int(grace.Seconds())),
+	)
+
+	stopCmd := DockerCommand(stopCtx, "stop", fmt.Sprintf("--time=%d", int(grace.Seconds())), name)
+	started := time.Now()
+	output, err := stopCmd.CombinedOutput()
+	if err == nil {
+		// docker stop returns once the container exits, sending SIGKILL if it
+		// is still running when the grace period runs out
+		if time.Since(started) >= grace {
+			return "SIGKILL"
+		}
+		return "SIGTERM"
+	}
+	if strings.Contains(string(output), "No such container") {
+		return ""
+	}
+
+	log.Warn("docker stop failed, killing container",
+		slog.String("execution_id", execID.String()),
+		slog.String("container", name),
+		slog.String("output", strings.TrimSpace(string(output))),
+	)
+
+	killCmd := DockerCommand(stopCtx, "kill", name)
+	if output, err := killCmd.CombinedOutput(); err != nil && !strings.Contains(string(output), "No such container") {
+		log.Error("failed to kill execution container",
+			slog.String("execution_id", execID.String()),
+			slog.String("container", name),
+			slog.String("error", err.Error()),
+			slog.String("output", strings.TrimSpace(string(output))),
+		)
+	}
+	return "SIGKILL"
+}
+
+// streamingWriter wraps a logger to stream output line by line. A line longer
+// than maxLineBytes is logged cut short with truncatedLineMarker, and the rest of
+// it is dropped, so output without newlines cannot grow the buffer unboundedly.
 type streamingWriter struct {
-	log     *slog.Logger
-	stream  string // "stdout" or "stderr"
-	prefix  string // log message prefix (e.g., "dependency install", "execution")
-	envID   string // optional environment ID for context
-	execID  string // optional execution ID for context
-	buffer  []byte
+	log          *slog.Logger
+	stream       string            // "stdout" or "stderr"
+	prefix       string            // log message prefix (e.g., "dependency install", "execution")
+	envID        string            // optional environment ID for context
+	execID       string            // optional execution ID for context
+	onLine       func(line string) // optional hook called with each complete line
+	maxLineBytes int               // line length cap; 0 uses StreamMaxLineBytes()
+	buffer       []byte
+	discarding   bool // dropping the rest of a truncated line
 }
 
+// outputWriter returns where an execution's output stream goes: capture, teed
+// to the line logger unless DISABLE_OUTPUT_STREAMING is set.
+func outputWriter(logWriter *streamingWriter, capture io.Writer) io.Writer {
+	if OutputStreamingDisabled() {
+		return capture
+	}
+	return io.MultiWriter(logWriter, capture)
+}
+
+// truncatedLineMarker ends a logged line that exceeded the line length cap
+const truncatedLineMarker = "...[truncated]"
+
 func (w *streamingWriter) Write(p []byte) (n int, err error) {
-	w.buffer = append(w.buffer, p...)
+	n = len(p)
+	limit := w.maxLineBytes
+	if limit <= 0 {
+		limit = StreamMaxLineBytes()
+	}
+
+	for len(p) > 0 {
+		idx := bytes.IndexByte(p, '\n')
+		chunk := p
+		if idx != -1 {
+			chunk = p[:idx]
+		}
 
-	// Process complete lines
-	for {
-		idx := bytes.IndexByte(w.buffer, '\n')
+		if !w.discarding {
+			if room := limit - len(w.buffer); len(chunk) > room {
+				w.buffer = append(w.buffer, chunk[:room]...)
+				w.logLine(string(w.buffer) + truncatedLineMarker)
+				w.buffer = w.buffer[:0]
+				w.discarding = true
+			} else {
+				w.buffer = append(w.buffer, chunk...)
+			}
+		}
 		if idx == -1 {
 			break
 		}
 
-		line := string(w.buffer[:idx])
-		w.buffer = w.buffer[idx+1:]
-
-		if line != "" {
-			attrs := []any{
-				slog.String("stream", w.stream),
-				slog.String("output", line),
-			}
-			if w.envID != "" {
-				attrs = append(attrs, slog.String("env_id", w.envID))
+		// Process the complete line
+		if w.discarding {
+			w.discarding = false
+		} else {
+			line := string(w.buffer)
+			if w.onLine != nil {
+				w.onLine(line)
 			}
-			if w.execID != "" {
-				attrs = append(attrs, slog.String("exec_id", w.execID))
+			if line != "" {
+				w.logLine(line)
 			}
-			w.log.Info(w.prefix, attrs...)
 		}
+		w.buffer = w.buffer[:0]
+		p = p[idx+1:]
 	}
 
-	return len(p), nil
+	return n, nil
+}
+
+func (w *streamingWriter) logLine(line string) {
+	attrs := []any{
+		slog.String("stream", w.stream),
+		slog.String("output", line),
+	}
+	if w.envID != "" {
+		attrs = append(attrs, slog.String("env_id", w.envID))
+	}
+	if w.execID != "" {
+		attrs = append(attrs, slog.String("exec_id", w.execID))
+	}
+	w.log.Info(w.prefix, attrs...)
 }
 
 func (w *streamingWriter) Flush() {
 	// Flush any remaining content
 	if len(w.buffer) > 0 {
-		attrs := []any{
-			slog.String("stream", w.stream),
-			slog.String("output", string(w.buffer)),
-		}
-		if w.envID != "" {
-			attrs = append(attrs, slog.String("env_id", w.envID))
-		}
-		if w.execID != "" {
-			attrs = append(attrs, slog.String("exec_id", w.execID))
-		}
-		w.log.Info(w.prefix, attrs...)
-		w.buffer = nil
+		w.logLine(string(w.buffer))
 	}
+	w.buffer = nil
+	w.discarding = false
 }
 
-// installDependencies caches dependencies in the volume with network access
-func installDependencies(ctx context.Context, volumeName string, deps *models.Dependencies) error {
+// installDependencies caches dependencies in the volume with network access, or
+// from the offline cache and mirror when OFFLINE_DEPS is set, using the cache
+// commands of the runtime's install strategy. Each cached dependency is
+// reported to progress.
+func installDependencies(ctx context.Context, volumeName, image string, strategy installStrategy, proxy *models.ProxyConfig, deps *models.Dependencies, progress func(models.SetupProgress)) error {
 	if deps == nil {
 		return nil
 	}
 
 	log := logger.FromContext(ctx)
+	offline := OfflineDepsConfig()
 
-	// Build deno cache commands
-	var cacheCommands []string
-
-	// Cache npm dependencies
 	if len(deps.NPM) > 0 {
 		log.Info("preparing npm dependencies",
 			slog.Any("packages", deps.NPM),
 		)
-		for _, pkg := range deps.NPM {
-			cacheCommands = append(cacheCommands, fmt.Sprintf("deno cache --node-modules-dir npm:%s", pkg))
-		}
 	}
-
-	// Cache deno dependencies
+	if len(deps.JSR) > 0 {
+		log.Info("preparing jsr dependencies",
+			slog.Any("packages", deps.JSR),
+		)
+	}
 	if len(deps.Deno) > 0 {
 		log.Info("preparing deno dependencies",
 			slog.Any("modules", deps.Deno),
 		)
-		for _, url := range deps.Deno {
-			cacheCommands = append(cacheCommands, fmt.Sprintf("deno cache %s", url))
-		}
 	}
-
+	cacheCommands := strategy.cacheCommands(deps, offline != nil && offline.cachedOnly())
 	if len(cacheCommands) == 0 {
 		log.Debug("no dependencies to install")
 		return nil
 	}
 
+	cacheCommands = withProgressMarkers(cacheCommands)
+
+	// Offline installs start from the pre-populated cache, if there is one
+	if offline != nil && offline.CacheVolume != "" {
+		cacheCommands = append([]string{"cp -a /offline-cache/. /deno-dir/"}, cacheCommands...)
+	}
+
 	// Join commands with && for sequential execution
 	cacheScript := strings.Join(cacheCommands, " && ")
 
@@ -683,26 +2423,39 @@ func installDependencies(ctx context.Context, volumeName string, deps *models.De
 		slog.String("script", cacheScript),
 	)
 
-	// Build docker command
+	// Build docker command, named so it can be stopped if the install times out
 	// Note: Must override entrypoint since the image defaults to running runner.ts
+	installID := uuid.New()
+	name := "tee-install-" + installID.String()
 	dockerArgs := []string{
 		"run", "--rm",
+		"--name", name,
 		"--entrypoint", "sh", // Override entrypoint to run shell commands
-		"--network=bridge",   // Network ENABLED for dependency download
 		"-v", fmt.Sprintf("%s:/workspace", volumeName),
 		"-v", fmt.Sprintf("%s:/deno-dir", volumeName), // Cache in volume
 		"-e", "DENO_DIR=/deno-dir",
 		"-w", "/workspace",
-		RuntimeImage(),
-		"-c", cacheScript,
 	}
+	if offline != nil {
+		// Offline installs only reach the internal mirror network, if any
+		dockerArgs = append(dockerArgs, offline.dockerArgs()...)
+	} else {
+		dockerArgs = append(dockerArgs, "--network=bridge") // Network ENABLED for dependency download
+		dockerArgs = append(dockerArgs, proxyEnvArgs(proxy)...)
+	}
+	dockerArgs = append(dockerArgs, image, "-c", cacheScript)
 
-	// Run dependency installation with streaming output
+	// Run dependency installation with streaming output, bounded by its own
+	// timeout so a hung install cannot hold a setup slot indefinitely
+	timeout := DepInstallTimeout()
+	installCtx, cancelInstall := context.WithTimeout(ctx, timeout)
+	defer cancelInstall()
 	startTime := time.Now()
-	cmd := exec.CommandContext(ctx, "docker", dockerArgs...)
+	cmd := DockerCommand(installCtx, dockerArgs...)
 
 	// Create streaming writers that log output in real-time
-	stdoutWriter := &streamingWriter{log: log, stream: "stdout", prefix: "dependency install"}
+	stdoutWriter := &streamingWriter{log: log, stream: "stdout", prefix: "dependency install",
+		onLine: dependencyProgress(progress, dependencySpecs(deps))}
 	stderrWriter := &streamingWriter{log: log, stream: "stderr", prefix: "dependency install"}
 
 	// Also capture full output for error reporting
@@ -718,12 +2471,30 @@ func installDependencies(ctx context.Context, volumeName string, deps *models.De
 
 	duration := time.Since(startTime)
 
+	// Killing the docker CLI does not stop the container itself
+	if err != nil && installCtx.Err() != nil {
+		stopContainer(ctx, name, installID)
+	}
+	if err != nil && ctx.Err() == nil && installCtx.Err() == context.DeadlineExceeded {
+		log.Error("dependency installation timed out",
+			slog.String("volume_name", volumeName),
+			slog.Int64("timeout_ms", timeout.Milliseconds()),
+		)
+		return &Error{
+			Code:    "dependency_install_timeout",
+			Message: fmt.Sprintf("dependency installation did not finish within %s (DEP_INSTALL_TIMEOUT_SECONDS)", timeout),
+		}
+	}
+
 	if err != nil {
 		log.Error("dependency installation failed",
 			slog.String("volume_name", volumeName),
 			slog.String("error", err.Error()),
 			slog.Int64("duration_ms", duration.Milliseconds()),
 		)
+		if isImagePullFailure(stderrBuf.String()) {
+			return imagePullError(image, stderrBuf.String())
+		}
 		// Include both stdout and stderr in error for debugging
 		combinedOutput := stderrBuf.String()
 		if combinedOutput == "" {
@@ -0,0 +1,494 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestMissingEnv(t *testing.T) {
+	required := []string{"API_KEY", "REGION", "DEBUG"}
+	env := map[string]string{"API_KEY": "secret", "DEBUG": ""}
+
+	missing := missingEnv(required, env)
+
+	if !reflect.DeepEqual(missing, []string{"REGION"}) {
+		t.Errorf("expected [REGION], got %v", missing)
+	}
+}
+
+func TestDisallowedEnv(t *testing.T) {
+	allowed := []string{"API_KEY", "REGION"}
+	env := map[string]string{"API_KEY": "secret", "REGOIN": "us-east-1", "DEBUG": "1"}
+
+	if got := disallowedEnv(allowed, env); !reflect.DeepEqual(got, []string{"DEBUG", "REGOIN"}) {
+		t.Errorf("expected [DEBUG REGOIN], got %v", got)
+	}
+	if got := disallowedEnv(allowed, map[string]string{"REGION": "eu"}); got != nil {
+		t.Errorf("expected no disallowed keys, got %v", got)
+	}
+
+	// Denied prefixes are disallowed even when allowEnv lists them
+	allowed = append(allowed, "LD_PRELOAD")
+	if got := disallowedEnv(allowed, map[string]string{"API_KEY": "secret", "LD_PRELOAD": "/x.so"}); !reflect.DeepEqual(got, []string{"LD_PRELOAD"}) {
+		t.Errorf("expected [LD_PRELOAD], got %v", got)
+	}
+}
+
+func TestExecutionEnv(t *testing.T) {
+	permissions := &models.Permissions{AllowEnv: []string{"API_KEY", "DENO_DIR", "LD_PRELOAD"}}
+	env := map[string]string{"API_KEY": "secret", "DENO_DIR": "/tmp", "LD_PRELOAD": "/x.so", "HOME": "/root"}
+
+	if got := executionEnv(permissions, env); !reflect.DeepEqual(got, map[string]string{"API_KEY": "secret"}) {
+		t.Errorf("expected only API_KEY to pass, got %v", got)
+	}
+	if got := executionEnv(nil, env); got != nil {
+		t.Errorf("expected no env without allowEnv, got %v", got)
+	}
+
+	t.Setenv("EXEC_ENV_DENIED_PREFIXES", "")
+	if got := executionEnv(permissions, env); len(got) != 3 {
+		t.Errorf("expected every allowed var to pass with no denied prefixes, got %v", got)
+	}
+}
+
+func TestMetadataStrings(t *testing.T) {
+	metadata := map[string]interface{}{
+		"requiredEnv": []interface{}{"A", "B", 3},
+		"other":       "not a list",
+	}
+
+	if got := metadataStrings(metadata, "requiredEnv"); !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Errorf("expected [A B], got %v", got)
+	}
+
+	if got := metadataStrings(metadata, "other"); got != nil {
+		t.Errorf("expected nil for non-list value, got %v", got)
+	}
+
+	if got := metadataStrings(nil, "missing"); got != nil {
+		t.Errorf("expected nil for missing key, got %v", got)
+	}
+}
+
+func TestRuntimeDefaultLimits(t *testing.T) {
+	timeoutMs, memoryMb := RuntimeDefaultLimits("deno")
+	if timeoutMs != defaultTimeoutMs || memoryMb != defaultMemoryMb {
+		t.Errorf("expected global defaults, got %d/%d", timeoutMs, memoryMb)
+	}
+
+	t.Setenv("DEFAULT_MEMORY_MB_DENO", "256")
+	t.Setenv("DEFAULT_TIMEOUT_MS_DENO", "10000")
+	timeoutMs, memoryMb = RuntimeDefaultLimits(environmentRuntime(map[string]interface{}{}))
+	if timeoutMs != 10000 || memoryMb != 256 {
+		t.Errorf("expected deno overrides 10000/256, got %d/%d", timeoutMs, memoryMb)
+	}
+}
+
+func TestDockerEnv_OnlyPassesDockerSettings(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "tcp://exec-node:2376")
+	t.Setenv("DOCKER_TLS_VERIFY", "1")
+	t.Setenv("DB_PASSWORD", "secret")
+
+	env := dockerEnv()
+
+	for _, want := range []string{"DOCKER_HOST=tcp://exec-node:2376", "DOCKER_TLS_VERIFY=1"} {
+		if !containsString(env, want) {
+			t.Errorf("expected %s in docker env, got %v", want, env)
+		}
+	}
+	if containsString(env, "DB_PASSWORD=secret") {
+		t.Error("unrelated variables must not be passed to the docker CLI")
+	}
+}
+
+func TestWithPartialOutput(t *testing.T) {
+	if got := withPartialOutput("Execution timeout exceeded", ""); got != "Execution timeout exceeded" {
+		t.Errorf("expected bare message, got %q", got)
+	}
+	if got := withPartialOutput("Execution timeout exceeded", "step 3 of 5\n"); got != "Execution timeout exceeded\nstep 3 of 5\n" {
+		t.Errorf("expected message followed by partial output, got %q", got)
+	}
+}
+
+func TestRuntimeImageForVersion(t *testing.T) {
+	cases := []struct {
+		image, version, want string
+	}{
+		{"", "", "octaviusdeployment/assist-tee-rt-deno:latest"},
+		{"", "1.41", "octaviusdeployment/assist-tee-rt-deno:1.41"},
+		{"registry.local:5000/rt-deno", "1.40", "registry.local:5000/rt-deno:1.40"},
+		{"registry.local:5000/rt-deno:latest", "1.40", "registry.local:5000/rt-deno:1.40"},
+		{"rt-deno@sha256:abc", "1.40", "rt-deno:1.40"},
+	}
+	for _, c := range cases {
+		t.Setenv("RUNTIME_IMAGE", c.image)
+		if got := RuntimeImageForVersion(c.version); got != c.want {
+			t.Errorf("RUNTIME_IMAGE=%q version %q: expected %q, got %q", c.image, c.version, c.want, got)
+		}
+	}
+}
+
+func TestEnvironmentImage(t *testing.T) {
+	if got := environmentImage(map[string]interface{}{"runtimeImage": "rt-deno:1.40"}); got != "rt-deno:1.40" {
+		t.Errorf("expected pinned image, got %q", got)
+	}
+	if got := environmentImage(nil); got != RuntimeImage() {
+		t.Errorf("expected default image for unpinned environments, got %q", got)
+	}
+}
+
+func TestParseEnvironmentMetadata(t *testing.T) {
+	metadata, permissions, err := parseEnvironmentMetadata([]byte(`{"runtime":"deno","permissions":{"allowNet":["api.example.com"]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata["runtime"] != "deno" || permissions == nil || len(permissions.AllowNet) != 1 {
+		t.Errorf("unexpected parse result %v / %+v", metadata, permissions)
+	}
+
+	for name, blob := range map[string]string{
+		"truncated":    `{"permissions":{"allowNet":["api.exa`,
+		"bad allowNet": `{"permissions":{"allowNet":"*"}}`,
+	} {
+		_, permissions, err := parseEnvironmentMetadata([]byte(blob))
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if permissions == nil || len(permissions.AllowNet) != 0 || len(permissions.AllowEnv) != 0 {
+			t.Errorf("%s: expected deny-all permissions, got %+v", name, permissions)
+		}
+	}
+}
+
+func TestValidateRawStdin(t *testing.T) {
+	valid := []*models.ExecuteRequest{
+		{RawStdin: true},
+		{RawStdin: true, Data: "line 1\nline 2\n"},
+		{RawStdin: true, DataStream: strings.NewReader("bytes")},
+	}
+	for _, req := range valid {
+		if err := validateRawStdin(req); err != nil {
+			t.Errorf("expected %+v to be valid, got %v", req, err)
+		}
+	}
+
+	invalid := []*models.ExecuteRequest{
+		{RawStdin: true, Data: map[string]interface{}{"a": 1}},
+		{RawStdin: true, Data: "x", Records: &bytes.Buffer{}},
+	}
+	for _, req := range invalid {
+		if err := validateRawStdin(req); err == nil {
+			t.Errorf("expected %+v to be rejected", req)
+		}
+	}
+}
+
+func TestIsEnvironmentVolume(t *testing.T) {
+	envID := uuid.New()
+	if !IsEnvironmentVolume("tee-env-" + envID.String()) {
+		t.Error("expected default-prefixed volume to match")
+	}
+
+	t.Setenv("VOLUME_PREFIX", "tee-staging-")
+	if got := environmentVolumeName(envID); got != "tee-staging-"+envID.String() {
+		t.Errorf("expected prefixed volume name, got %q", got)
+	}
+	for _, name := range []string{
+		"tee-env-" + envID.String(),
+		"tee-staging-not-a-uuid",
+		"tee-staging-{" + envID.String() + "}",
+	} {
+		if IsEnvironmentVolume(name) {
+			t.Errorf("expected %q not to belong to this deployment", name)
+		}
+	}
+
+	t.Setenv("VOLUME_PREFIX", "")
+	if IsEnvironmentVolume("tee-env-staging-" + envID.String()) {
+		t.Error("the default prefix must not claim another deployment's volumes")
+	}
+}
+
+func TestMetadataDefault(t *testing.T) {
+	metadata := map[string]interface{}{"timezone": "Europe/Berlin"}
+	if got := metadataDefault(metadata, "timezone", ""); got != "Europe/Berlin" {
+		t.Errorf("expected environment default, got %q", got)
+	}
+	if got := metadataDefault(metadata, "timezone", "Asia/Tokyo"); got != "Asia/Tokyo" {
+		t.Errorf("expected request value to win, got %q", got)
+	}
+	if got := metadataDefault(nil, "locale", ""); got != "" {
+		t.Errorf("expected empty value, got %q", got)
+	}
+}
+
+func TestIsNoSuchVolume(t *testing.T) {
+	if !isNoSuchVolume("Error response from daemon: get tee-env-x: no such volume\n") {
+		t.Error("expected a missing volume to be recognized")
+	}
+	if isNoSuchVolume("Cannot connect to the Docker daemon at unix:///var/run/docker.sock") {
+		t.Error("expected a daemon error not to count as a missing volume")
+	}
+}
+
+func TestDroppedEnv(t *testing.T) {
+	requested := map[string]string{"API_KEY": "secret", "DEBUG": "1", "DENO_DIR": "/tmp"}
+	filtered := map[string]string{"API_KEY": "secret"}
+	if got := droppedEnv(requested, filtered); !reflect.DeepEqual(got, []string{"DEBUG", "DENO_DIR"}) {
+		t.Errorf("expected [DEBUG DENO_DIR], got %v", got)
+	}
+}
+
+func TestStreamingWriter_TruncatesLongLines(t *testing.T) {
+	var logs bytes.Buffer
+	var lines []string
+	w := &streamingWriter{
+		log:          slog.New(slog.NewJSONHandler(&logs, nil)),
+		stream:       "stdout",
+		prefix:       "execution",
+		maxLineBytes: 16,
+		onLine:       func(line string) { lines = append(lines, line) },
+	}
+
+	// A huge write with no newline, then the rest of that line and a normal one
+	huge := bytes.Repeat([]byte("x"), 4<<20)
+	if n, err := w.Write(huge); n != len(huge) || err != nil {
+		t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(huge))
+	}
+	if cap(w.buffer) > 64 {
+		t.Errorf("expected the buffer to stay bounded, got capacity %d", cap(w.buffer))
+	}
+	w.Write([]byte("yyyy\nshort line\nexactly 16 bytes\ntail"))
+	w.Flush()
+
+	var outputs []string
+	for _, record := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry struct {
+			Output string `json:"output"`
+		}
+		if err := json.Unmarshal([]byte(record), &entry); err != nil {
+			t.Fatalf("invalid log record %q: %v", record, err)
+		}
+		outputs = append(outputs, entry.Output)
+	}
+	want := []string{strings.Repeat("x", 16) + truncatedLineMarker, "short line", "exactly 16 bytes", "tail"}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("logged %q, want %q", outputs, want)
+	}
+	// Only complete, untruncated lines reach the line hook
+	if !reflect.DeepEqual(lines, []string{"short line", "exactly 16 bytes"}) {
+		t.Errorf("onLine saw %q", lines)
+	}
+}
+
+func TestOutputWriter(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		t.Setenv("DISABLE_OUTPUT_STREAMING", strconv.FormatBool(disabled))
+		var logs, capture bytes.Buffer
+		logWriter := &streamingWriter{log: slog.New(slog.NewJSONHandler(&logs, nil)), stream: "stdout", prefix: "execution output"}
+
+		io.WriteString(outputWriter(logWriter, &capture), "first\nsecond\n")
+		logWriter.Flush()
+
+		if capture.String() != "first\nsecond\n" {
+			t.Errorf("disabled=%v: expected the full output captured, got %q", disabled, capture.String())
+		}
+		if logged := strings.Count(logs.String(), "\n"); disabled && logged != 0 || !disabled && logged != 2 {
+			t.Errorf("disabled=%v: unexpected log lines: %q", disabled, logs.String())
+		}
+	}
+}
+
+// fakeVolumeDocker puts a docker stand-in on PATH that runs busybox commands on
+// the host with the volume's /workspace mapped to a temp dir, which it returns.
+func fakeVolumeDocker(t *testing.T) string {
+	bin, workspace := t.TempDir(), t.TempDir()
+	// DockerCommand passes the child only docker settings, so the workspace is
+	// baked into the script
+	script := fmt.Sprintf(`#!/bin/sh
+# docker run --rm -v VOLUME:/workspace busybox:latest CMD...
+shift 5
+for arg in "$@"; do
+	set -- "$@" "$(printf '%%s' "$arg" | sed "s#/workspace#%s#g")"
+	shift
+done
+exec "$@"
+`, workspace)
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return workspace
+}
+
+func TestStageAndSwapInModules(t *testing.T) {
+	logger.Init(nil)
+	workspace := fakeVolumeDocker(t)
+	ctx := context.Background()
+	for name, content := range map[string]string{"main.ts": "old main", "old.ts": "stale"} {
+		os.WriteFile(filepath.Join(workspace, name), []byte(content), 0o644)
+	}
+
+	modules := map[string]string{"main.ts": "new main", "lib/util.ts": "util"}
+	if err := stageModules(ctx, "vol", modules); err != nil {
+		t.Fatalf("stageModules: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(workspace, "main.ts")); string(got) != "old main" {
+		t.Errorf("expected staging to leave the live modules alone, got %q", got)
+	}
+
+	if err := swapInModules(ctx, "vol", []string{"'/workspace/old.ts'"}); err != nil {
+		t.Fatalf("swapInModules: %v", err)
+	}
+	for name, want := range modules {
+		if got, _ := os.ReadFile(filepath.Join(workspace, name)); strings.TrimSpace(string(got)) != want {
+			t.Errorf("expected %s to hold %q, got %q", name, want, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "old.ts")); !os.IsNotExist(err) {
+		t.Error("expected the stale module to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, ".tee-staging")); !os.IsNotExist(err) {
+		t.Error("expected the staging directory to be removed")
+	}
+}
+
+func TestStopContainer_ReportsSignal(t *testing.T) {
+	logger.Init(nil)
+	t.Setenv("CONTAINER_STOP_TIMEOUT_SECONDS", "1")
+
+	tests := []struct {
+		name string
+		stop string // what the fake docker stop does
+		want string
+	}{
+		{"exits within grace", "exit 0", "SIGTERM"},
+		{"escalated by docker stop", "sleep 1", "SIGKILL"},
+		{"stop fails then killed", "echo 'Error response from daemon' >&2; exit 1", "SIGKILL"},
+		{"already exited", "echo 'Error: No such container: x' >&2; exit 1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeDocker(t, "if [ \"$1\" = stop ]; then "+tt.stop+"; fi")
+
+			if got := stopContainer(context.Background(), "tee-exec-x", uuid.New()); got != tt.want {
+				t.Errorf("expected signal %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// fakeDocker puts a docker script running body first on PATH
+func fakeDocker(t *testing.T, body string) {
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestWarmupEnvironment(t *testing.T) {
+	logger.Init(nil)
+	input := filepath.Join(t.TempDir(), "input.json")
+	req := &models.SetupRequest{
+		MainModule:  "main.ts",
+		DefaultData: map[string]interface{}{"ping": true},
+		Warmup:      &models.WarmupConfig{},
+	}
+
+	// The runner reads the warmup input on stdin and reports the handler's result
+	fakeDocker(t, fmt.Sprintf(`cat > %s
+echo '{"success": true, "result": {"pong": true}}'`, input))
+	shape, err := warmupEnvironment(context.Background(), uuid.New(), "vol", "runtime:test", req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shape == nil {
+		t.Error("expected the warmup result's shape to be returned")
+	}
+
+	var sent struct {
+		Event   struct{ Data map[string]interface{} } `json:"event"`
+		Context map[string]interface{}                `json:"context"`
+	}
+	raw, _ := os.ReadFile(input)
+	if err := json.Unmarshal(bytes.TrimSpace(raw), &sent); err != nil {
+		t.Fatalf("warmup input is not JSON: %v (%s)", err, raw)
+	}
+	if sent.Context["warmup"] != true || sent.Context["dataPresent"] != false {
+		t.Errorf("expected a warmup context without data, got %v", sent.Context)
+	}
+	if sent.Event.Data["ping"] != true {
+		t.Errorf("expected the default data to be sent, got %v", sent.Event.Data)
+	}
+
+	// A handler that throws fails the warmup with its error
+	fakeDocker(t, `cat > /dev/null
+echo '{"success": false, "error": "connection refused"}'
+exit 1`)
+	if _, err := warmupEnvironment(context.Background(), uuid.New(), "vol", "runtime:test", req); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the handler error to fail the warmup, got %v", err)
+	}
+}
+
+func TestRunContainer_PassesArgs(t *testing.T) {
+	logger.Init(nil)
+	dir := t.TempDir()
+	argv, input := filepath.Join(dir, "argv"), filepath.Join(dir, "input.json")
+	fakeDocker(t, fmt.Sprintf(`printf '%%s\n' "$@" > %s
+cat > %s
+echo '{"success": true, "result": null}'`, argv, input))
+
+	envID, execID := uuid.New(), uuid.New()
+	args := []string{"--verbose", "two words"}
+	inputJSON, err := buildExecutionInput(envID, execID, "main.ts", nil, nil, map[string]interface{}{"args": args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runContainer(context.Background(), &containerRun{
+		envID:      envID,
+		execID:     execID,
+		volumeName: "vol",
+		image:      "runtime:test",
+		mainModule: "main.ts",
+		args:       args,
+		input:      inputJSON,
+		timeoutMs:  5000,
+		memoryMb:   128,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The handler sees the args as Deno.args, after the runner script
+	raw, _ := os.ReadFile(argv)
+	lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	if len(lines) < 3 || !reflect.DeepEqual(lines[len(lines)-3:], []string{"/runtime/runner.ts", "--verbose", "two words"}) {
+		t.Errorf("expected the args after the runner script, got %q", lines)
+	}
+
+	// and as context.args
+	var sent struct {
+		Context struct {
+			Args []string `json:"args"`
+		} `json:"context"`
+	}
+	raw, _ = os.ReadFile(input)
+	if err := json.Unmarshal(bytes.TrimSpace(raw), &sent); err != nil || !reflect.DeepEqual(sent.Context.Args, args) {
+		t.Errorf("expected context.args %v, got %s", args, raw)
+	}
+}
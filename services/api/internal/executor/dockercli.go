@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// dockerEnvVars are the process environment variables passed to the docker CLI.
+// DOCKER_HOST and the TLS settings select a remote daemon; everything else is
+// withheld so secrets like BEARER_TOKEN and DB_PASSWORD never reach it.
+var dockerEnvVars = []string{
+	"PATH",
+	"HOME",
+	"DOCKER_HOST",
+	"DOCKER_CONTEXT",
+	"DOCKER_CONFIG",
+	"DOCKER_TLS_VERIFY",
+	"DOCKER_CERT_PATH",
+	"DOCKER_API_VERSION",
+}
+
+// DockerCommand returns a docker CLI command that talks to the configured daemon
+func DockerCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Env = dockerEnv()
+	return cmd
+}
+
+func dockerEnv() []string {
+	env := make([]string, 0, len(dockerEnvVars))
+	for _, key := range dockerEnvVars {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// DockerHost returns the daemon address used for docker commands
+func DockerHost() string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	return "local"
+}
+
+// VerifyDockerHost checks that the configured docker daemon is reachable
+func VerifyDockerHost(ctx context.Context) error {
+	cmd := DockerCommand(ctx, "version", "--format", "{{.Server.Version}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker daemon %s unreachable: %w: %s", DockerHost(), err, strings.TrimSpace(string(output)))
+	}
+
+	logger.FromContext(ctx).Info("docker daemon reachable",
+		slog.String("docker_host", DockerHost()),
+		slog.Bool("tls_verify", os.Getenv("DOCKER_TLS_VERIFY") != ""),
+		slog.String("server_version", strings.TrimSpace(string(output))),
+	)
+	return nil
+}
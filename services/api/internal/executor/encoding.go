@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Output encodings for execution stdout/stderr that are not valid UTF-8
+const (
+	// OutputEncodingEscape replaces each invalid byte with a \xNN escape
+	OutputEncodingEscape = "escape"
+	// OutputEncodingBase64 base64-encodes stdout and stderr and flags the response
+	OutputEncodingBase64 = "base64"
+)
+
+// needsEncoding reports whether s cannot be stored in a Postgres TEXT column or
+// JSON-encoded as is. NUL is valid UTF-8 but rejected by Postgres.
+func needsEncoding(s string) bool {
+	return !utf8.ValidString(s) || strings.IndexByte(s, 0) >= 0
+}
+
+// encodeOutput makes captured output safe to store and return. Output that is
+// already valid is returned unchanged with an empty encoding; otherwise it is
+// escaped and reported as "escape" or, in base64 mode, encoded and reported as
+// "base64".
+func encodeOutput(mode, stdout, stderr string) (string, string, string) {
+	if !needsEncoding(stdout) && !needsEncoding(stderr) {
+		return stdout, stderr, ""
+	}
+	if mode == OutputEncodingBase64 {
+		return base64.StdEncoding.EncodeToString([]byte(stdout)),
+			base64.StdEncoding.EncodeToString([]byte(stderr)),
+			OutputEncodingBase64
+	}
+	return escapeInvalidUTF8(stdout), escapeInvalidUTF8(stderr), OutputEncodingEscape
+}
+
+// escapeInvalidUTF8 replaces invalid UTF-8 bytes and NULs with \xNN escapes,
+// doubling any backslashes so a literal "\xNN" in the output stays distinct
+func escapeInvalidUTF8(s string) string {
+	if !needsEncoding(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || r == 0 {
+			fmt.Fprintf(&b, "\\x%02x", s[i])
+		} else if r == '\\' {
+			b.WriteString(`\\`)
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package executor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEncodeOutput_ValidUnchanged(t *testing.T) {
+	stdout, stderr, encoding := encodeOutput(OutputEncodingEscape, "héllo", "")
+	if stdout != "héllo" || stderr != "" || encoding != "" {
+		t.Errorf("expected valid output unchanged, got %q %q %q", stdout, stderr, encoding)
+	}
+}
+
+func TestEncodeOutput_InvalidUTF8ThroughParse(t *testing.T) {
+	raw := "ok \xff\xfe done\x00"
+
+	// Raw (non-envelope) stdout falls through parseRunnerOutput unchanged
+	result, errOutput, _, _ := parseRunnerOutput(raw, "bad \xc3", 0)
+	if utf8.ValidString(result) {
+		t.Fatal("expected raw stdout to still contain invalid bytes")
+	}
+
+	stdout, stderr, encoding := encodeOutput(OutputEncodingEscape, result, errOutput)
+	if encoding != OutputEncodingEscape {
+		t.Errorf("expected escape encoding flag, got %q", encoding)
+	}
+	if stdout != `ok \xff\xfe done\x00` {
+		t.Errorf("unexpected escaped stdout: %q", stdout)
+	}
+	if stderr != `bad \xc3` {
+		t.Errorf("unexpected escaped stderr: %q", stderr)
+	}
+
+	encoded, err := json.Marshal(stdout)
+	if err != nil {
+		t.Fatalf("escaped output should JSON-encode: %v", err)
+	}
+	var decoded string
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded != stdout {
+		t.Errorf("escaped output did not survive a JSON round trip: %q", decoded)
+	}
+}
+
+func TestEscapeInvalidUTF8_EscapesBackslashes(t *testing.T) {
+	// A literal \xff must not read back the same as an escaped 0xff byte
+	got := escapeInvalidUTF8("\xff" + `\xff`)
+	if got != `\xff\\xff` {
+		t.Errorf("unexpected escaped output: %q", got)
+	}
+
+	// Valid output is left alone, backslashes included
+	if got := escapeInvalidUTF8(`C:\tmp`); got != `C:\tmp` {
+		t.Errorf("expected valid output unchanged, got %q", got)
+	}
+}
+
+func TestEncodeOutput_Base64(t *testing.T) {
+	raw := "\xff\x00binary"
+
+	stdout, stderr, encoding := encodeOutput(OutputEncodingBase64, raw, "")
+	if encoding != OutputEncodingBase64 {
+		t.Fatalf("expected base64 encoding flag, got %q", encoding)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(stdout)
+	if err != nil || string(decoded) != raw {
+		t.Errorf("expected base64 of raw stdout, got %q", stdout)
+	}
+	if stderr != "" {
+		t.Errorf("expected empty stderr to stay empty, got %q", stderr)
+	}
+}
+
+func TestParseRunnerOutputs(t *testing.T) {
+	stdout := `{"success":true,"result":1,"outputs":{"summary":{"rows":2},"blob":{"encoding":"base64","data":"AAE="}}}`
+
+	outputs := parseRunnerOutputs(stdout)
+	if len(outputs) != 2 || string(outputs["summary"]) != `{"rows":2}` {
+		t.Errorf("unexpected outputs: %v", outputs)
+	}
+	if outputs := parseRunnerOutputs(`{"success":true,"result":1}`); outputs != nil {
+		t.Errorf("expected nil outputs, got %v", outputs)
+	}
+	if outputs := parseRunnerOutputs("not json"); outputs != nil {
+		t.Errorf("expected nil outputs for raw stdout, got %v", outputs)
+	}
+}
+
+func TestParseRunnerOutput_EnvelopeDetection(t *testing.T) {
+	cases := []struct {
+		name        string
+		stdout      string
+		exitCode    int
+		wantResult  string
+		wantSuccess bool
+		wantCode    int
+	}{
+		{"marked envelope", `{"teeEnvelope":1,"success":true,"result":[1,2]}`, 0, `[1,2]`, true, 0},
+		{"marked failure", `{"teeEnvelope":1,"success":false,"error":"boom"}`, 0, "", false, 1},
+		{"legacy envelope", `{"success":true,"result":{"a":1},"logs":[]}`, 0, `{"a":1}`, true, 0},
+		{"bare JSON array", `[{"id":1},{"id":2}]`, 0, `[{"id":1},{"id":2}]`, false, 0},
+		{"arbitrary JSON object", `{"items":[1],"total":1}`, 0, `{"items":[1],"total":1}`, false, 0},
+		{"object with a non-boolean success", `{"success":"yes","result":1}`, 0, `{"success":"yes","result":1}`, false, 0},
+		{"object with success and other fields", `{"success":true,"user":"a"}`, 0, `{"success":true,"user":"a"}`, false, 0},
+		{"JSON null", `null`, 0, `null`, false, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			result, _, code, success := parseRunnerOutput(c.stdout, "", c.exitCode)
+			if result != c.wantResult || success != c.wantSuccess || code != c.wantCode {
+				t.Errorf("got result %q success %v code %d, want %q %v %d", result, success, code, c.wantResult, c.wantSuccess, c.wantCode)
+			}
+		})
+	}
+}
+
+func TestEncodeOutput_EscapeFlagsEitherStream(t *testing.T) {
+	tests := []struct {
+		name           string
+		stdout, stderr string
+	}{
+		{"stdout", "a\xffb", "fine"},
+		{"stderr", "fine", "nul\x00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, encoding := encodeOutput(OutputEncodingEscape, tt.stdout, tt.stderr)
+			if encoding != OutputEncodingEscape {
+				t.Errorf("expected %q encoding, got %q", OutputEncodingEscape, encoding)
+			}
+			if !utf8.ValidString(stdout) || !utf8.ValidString(stderr) {
+				t.Errorf("expected escaped output to be valid UTF-8, got %q %q", stdout, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/jsonpath"
+)
+
+// resultMeta is the server-side metadata added to results in the full envelope
+type resultMeta struct {
+	ExecutionID        uuid.UUID `json:"executionId"`
+	EnvironmentID      uuid.UUID `json:"environmentId"`
+	EnvironmentVersion int       `json:"environmentVersion"`
+	Runtime            string    `json:"runtime"`
+	ExitCode           int       `json:"exitCode"`
+	DurationMs         int64     `json:"durationMs"`
+	CompletedAt        time.Time `json:"completedAt"`
+}
+
+// wrapResult builds the full envelope {result, meta}. A structured result is
+// embedded as JSON; raw output is embedded as a string.
+func wrapResult(stdout string, structured bool, meta resultMeta) (string, error) {
+	var result interface{}
+	if structured && json.Valid([]byte(stdout)) {
+		result = json.RawMessage(stdout)
+	} else if stdout != "" {
+		result = stdout
+	}
+
+	envelope, err := json.Marshal(struct {
+		Result interface{} `json:"result"`
+		Meta   resultMeta  `json:"meta"`
+	}{result, meta})
+	if err != nil {
+		return "", err
+	}
+	return string(envelope), nil
+}
+
+// selectResult applies a JSONPath projection to a JSON result. A path that
+// matches nothing yields null.
+func selectResult(expr, resultJSON string) (string, error) {
+	path, err := jsonpath.Parse(expr)
+	if err != nil {
+		return "", err
+	}
+	decoder := json.NewDecoder(strings.NewReader(resultJSON))
+	decoder.UseNumber()
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return "", fmt.Errorf("result is not JSON")
+	}
+	selected, _ := path.Select(result)
+	projected, err := json.Marshal(selected)
+	if err != nil {
+		return "", err
+	}
+	return string(projected), nil
+}
@@ -0,0 +1,64 @@
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWrapResult_Structured(t *testing.T) {
+	execID := uuid.New()
+	wrapped, err := wrapResult(`{"sum":8}`, true, resultMeta{ExecutionID: execID, Runtime: "deno", EnvironmentVersion: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var envelope struct {
+		Result map[string]int `json:"result"`
+		Meta   resultMeta     `json:"meta"`
+	}
+	if err := json.Unmarshal([]byte(wrapped), &envelope); err != nil {
+		t.Fatalf("envelope is not valid JSON: %v", err)
+	}
+	if envelope.Result["sum"] != 8 {
+		t.Errorf("expected embedded result, got %v", envelope.Result)
+	}
+	if envelope.Meta.ExecutionID != execID || envelope.Meta.Runtime != "deno" || envelope.Meta.EnvironmentVersion != 2 {
+		t.Errorf("unexpected meta: %+v", envelope.Meta)
+	}
+}
+
+func TestWrapResult_RawOutput(t *testing.T) {
+	wrapped, err := wrapResult("plain text", false, resultMeta{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var envelope struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(wrapped), &envelope); err != nil {
+		t.Fatalf("envelope is not valid JSON: %v", err)
+	}
+	if envelope.Result != "plain text" {
+		t.Errorf("expected raw output as a string, got %q", envelope.Result)
+	}
+}
+
+func TestSelectResult(t *testing.T) {
+	got, err := selectResult("$.items[*].id", `{"items":[{"id":12345678901234567890},{"id":2}],"total":2}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != `[12345678901234567890,2]` {
+		t.Errorf("expected the projected ids with precision kept, got %s", got)
+	}
+
+	if got, _ := selectResult("$.missing", `{"a":1}`); got != "null" {
+		t.Errorf("expected null for a path matching nothing, got %s", got)
+	}
+	if _, err := selectResult("$.a", "plain text"); err == nil {
+		t.Error("expected an error for a result that is not JSON")
+	}
+}
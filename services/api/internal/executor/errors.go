@@ -0,0 +1,29 @@
+package executor
+
+import "time"
+
+// Error is an executor failure that carries a machine-readable code,
+// allowing handlers to map it to a specific HTTP status.
+type Error struct {
+	Code    string
+	Message string
+
+	// RetryAfter and QueueDepth are set on "busy" errors to help clients back off;
+	// "environment_rate_limited" errors set RetryAfter only.
+	RetryAfter time.Duration
+	QueueDepth int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrEnvironmentNotFound is returned when the requested environment does not exist.
+var ErrEnvironmentNotFound = &Error{Code: "not_found", Message: "environment not found"}
+
+// ErrEnvironmentDraining is returned when executing in an environment that is
+// being drained for deletion.
+var ErrEnvironmentDraining = &Error{Code: "draining", Message: "environment is draining and accepts no new executions"}
+
+// ErrExecutionNotRunning is returned when cancelling an execution that is not running.
+var ErrExecutionNotRunning = &Error{Code: "not_found", Message: "execution not running"}
@@ -16,12 +16,32 @@ type Executor interface {
 	// ExecuteInEnvironment runs code in an existing environment and returns the result.
 	ExecuteInEnvironment(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error)
 
+	// UpdateEnvironment rewrites an environment's modules and/or dependencies in place,
+	// keeping its ID and execution history and bumping its version.
+	UpdateEnvironment(ctx context.Context, envID uuid.UUID, req *models.UpdateRequest) (*models.Environment, error)
+
+	// GetEnvironment returns the stored environment, or ErrEnvironmentNotFound.
+	GetEnvironment(ctx context.Context, envID uuid.UUID) (*models.Environment, error)
+
+	// ExportEnvironment returns a bundle that recreates the environment elsewhere.
+	ExportEnvironment(ctx context.Context, envID uuid.UUID) (*models.EnvironmentExport, error)
+
+	// CheckReadiness runs the environment's health check, or returns
+	// ErrEnvironmentNotFound.
+	CheckReadiness(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error)
+
+	// CancelExecution stops a running execution, or returns ErrExecutionNotRunning.
+	CancelExecution(ctx context.Context, envID, execID uuid.UUID) error
+
 	// DeleteEnvironment removes an environment and cleans up its resources.
 	DeleteEnvironment(ctx context.Context, envID uuid.UUID) error
 }
 
 // DockerExecutor implements Executor using Docker containers.
-type DockerExecutor struct{}
+type DockerExecutor struct {
+	inFlight   inFlightRegistry
+	rateLimits envRateLimiter
+}
 
 // NewDockerExecutor creates a new DockerExecutor instance.
 func NewDockerExecutor() *DockerExecutor {
@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// ExportEnvironment rebuilds the setup request for an environment from its stored
+// settings and the module files in its volume. Dependencies are exported as
+// specs and reinstalled on import rather than copied.
+func (e *DockerExecutor) ExportEnvironment(ctx context.Context, envID uuid.UUID) (*models.EnvironmentExport, error) {
+	log := logger.FromContext(ctx)
+
+	env, err := e.GetEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+	if env.Status != "ready" {
+		return nil, &Error{Code: "conflict", Message: "environment is " + env.Status + " and cannot be exported"}
+	}
+
+	names := metadataStrings(env.Metadata, "modules")
+	if len(names) == 0 {
+		names = []string{env.MainModule}
+	}
+	modules, err := readModules(ctx, env.VolumeName, names)
+	if err != nil {
+		log.Error("failed to read modules for export",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+
+	setup, err := setupRequestFromEnvironment(env, modules)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("environment exported",
+		slog.String("environment_id", envID.String()),
+		slog.Int("module_count", len(modules)),
+	)
+
+	return &models.EnvironmentExport{
+		FormatVersion:       models.EnvironmentExportVersion,
+		SourceEnvironmentID: envID,
+		ExportedAt:          time.Now().UTC(),
+		Setup:               *setup,
+	}, nil
+}
+
+// setupRequestFromEnvironment reconstructs the setup request an environment was
+// created from. The template name is left out because its settings are already
+// part of the stored ones and the template may not exist where it is imported.
+func setupRequestFromEnvironment(env *models.Environment, modules map[string]string) (*models.SetupRequest, error) {
+	// Exporting malformed metadata would drop the environment's permissions
+	if env.Metadata == nil {
+		return nil, &Error{Code: "conflict", Message: "environment metadata is malformed and cannot be exported"}
+	}
+	metadataJSON, err := json.Marshal(env.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	metadata, permissions, err := parseEnvironmentMetadata(metadataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored struct {
+		Dependencies           *models.Dependencies `json:"dependencies"`
+		PersistResults         *bool                `json:"persistResults"`
+		MaxExecutionsPerMinute int                  `json:"maxExecutionsPerMinute"`
+	}
+	if err := json.Unmarshal(metadataJSON, &stored); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+
+	return &models.SetupRequest{
+		MainModule:             env.MainModule,
+		Modules:                modules,
+		Dependencies:           stored.Dependencies,
+		Permissions:            permissions,
+		TTLSeconds:             env.TTLSeconds,
+		IdleTimeoutSeconds:     env.IdleTimeoutSeconds,
+		KeepAliveOnActivity:    env.KeepAliveOnActivity,
+		RequiredEnv:            metadataStrings(metadata, "requiredEnv"),
+		PersistResults:         stored.PersistResults,
+		MaxExecutionsPerMinute: stored.MaxExecutionsPerMinute,
+		Proxy:                  environmentProxy(metadata),
+		DefaultData:            metadata["defaultData"],
+		Timezone:               metadataDefault(metadata, "timezone", ""),
+		Locale:                 metadataDefault(metadata, "locale", ""),
+		RuntimeVersion:         metadataDefault(metadata, "runtimeVersion", ""),
+		Runsc:                  environmentRunscOptions(metadata),
+		HealthCheck:            environmentHealthCheck(metadata),
+		PreHook:                metadataDefault(metadata, "preHook", ""),
+		PostHook:               metadataDefault(metadata, "postHook", ""),
+		HandlerName:            metadataDefault(metadata, "handlerName", ""),
+		Handlers:               metadataStrings(metadata, "handlers"),
+		GroupID:                env.GroupID,
+	}, nil
+}
+
+// readModules reads the named module files out of a volume.
+func readModules(ctx context.Context, volumeName string, names []string) (map[string]string, error) {
+	args := []string{"run", "--rm",
+		"-v", fmt.Sprintf("%s:/workspace:ro", volumeName),
+		"busybox:latest",
+		"tar", "-cf", "-", "-C", "/workspace", "--",
+	}
+	cmd := DockerCommand(ctx, append(args, names...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if isImagePullFailure(stderr.String()) {
+			return nil, imagePullError("busybox:latest", stderr.String())
+		}
+		return nil, fmt.Errorf("failed to read modules: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return modulesFromTar(&stdout)
+}
+
+// modulesFromTar collects the regular files of a tar stream. writeModules ends
+// every file with a newline the module did not have, so one is trimmed.
+func modulesFromTar(r io.Reader) (map[string]string, error) {
+	modules := make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read modules: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", hdr.Name, err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		modules[name] = strings.TrimSuffix(string(content), "\n")
+	}
+	return modules, nil
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestModulesFromTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range map[string]string{"./main.ts": "export {}\n", "./lib/util.ts": "x\n\n"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.WriteHeader(&tar.Header{Name: "./lib/", Mode: 0755, Typeflag: tar.TypeDir})
+	tw.Close()
+
+	got, err := modulesFromTar(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"main.ts": "export {}", "lib/util.ts": "x\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestSetupRequestFromEnvironment(t *testing.T) {
+	idle := 600
+	original := models.SetupRequest{
+		MainModule:             "main.ts",
+		Modules:                map[string]string{"main.ts": "export {}"},
+		Dependencies:           &models.Dependencies{NPM: []string{"lodash@4.17.21"}},
+		Permissions:            &models.Permissions{AllowNet: []string{"api.example.com"}},
+		TTLSeconds:             7200,
+		IdleTimeoutSeconds:     &idle,
+		RequiredEnv:            []string{"API_KEY"},
+		MaxExecutionsPerMinute: 30,
+		Timezone:               "Europe/Berlin",
+	}
+
+	// Store metadata the way SetupEnvironment does and read it back as the database would
+	metadataJSON, _ := json.Marshal(map[string]interface{}{
+		"permissions":            original.Permissions,
+		"modules":                moduleNames(original.Modules),
+		"dependencies":           original.Dependencies,
+		"requiredEnv":            original.RequiredEnv,
+		"maxExecutionsPerMinute": original.MaxExecutionsPerMinute,
+		"timezone":               original.Timezone,
+		"template":               "base",
+	})
+	var metadata map[string]interface{}
+	json.Unmarshal(metadataJSON, &metadata)
+
+	env := &models.Environment{
+		ID:                 uuid.New(),
+		MainModule:         "main.ts",
+		Metadata:           metadata,
+		TTLSeconds:         7200,
+		IdleTimeoutSeconds: &idle,
+	}
+	got, err := setupRequestFromEnvironment(env, original.Modules)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, &original) {
+		t.Errorf("expected %+v, got %+v", original, *got)
+	}
+
+	env.Metadata = nil
+	if _, err := setupRequestFromEnvironment(env, original.Modules); err == nil {
+		t.Error("expected malformed metadata to be refused")
+	}
+}
@@ -0,0 +1,172 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// Fault kinds that can be injected when ENABLE_FAULT_INJECTION is set
+const (
+	FaultTimeout    = "timeout"     // execution reports a timeout (exit 124)
+	FaultOOM        = "oom"         // execution reports an out-of-memory kill (exit 137)
+	FaultInfraError = "infra_error" // setup or execution fails with an infrastructure error
+)
+
+// maxFaultDelay bounds an injected delay so a test cannot tie up a request forever
+const maxFaultDelay = 5 * time.Minute
+
+// errInjectedInfra is returned for FaultInfraError
+var errInjectedInfra = errors.New("injected infrastructure failure")
+
+// FaultInjectionEnabled reports whether ENABLE_FAULT_INJECTION is set. Faults are
+// never injected without it, whatever a request asks for.
+func FaultInjectionEnabled() bool {
+	return getEnvBool("ENABLE_FAULT_INJECTION", false)
+}
+
+// Fault is a failure a test client asked to have simulated.
+type Fault struct {
+	Kind  string        // FaultTimeout, FaultOOM, FaultInfraError or empty for a delay only
+	Delay time.Duration // wait before running (or failing) the operation
+}
+
+// ParseFault parses a comma-separated fault spec such as "oom" or
+// "delay=2s,infra_error".
+func ParseFault(spec string) (*Fault, error) {
+	fault := &Fault{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == FaultTimeout || part == FaultOOM || part == FaultInfraError:
+			if fault.Kind != "" {
+				return nil, fmt.Errorf("only one fault kind may be injected, got %s and %s", fault.Kind, part)
+			}
+			fault.Kind = part
+		case strings.HasPrefix(part, "delay="):
+			delay, err := time.ParseDuration(strings.TrimPrefix(part, "delay="))
+			if err != nil || delay < 0 || delay > maxFaultDelay {
+				return nil, fmt.Errorf("invalid fault delay %q: must be a duration up to %s", part, maxFaultDelay)
+			}
+			fault.Delay = delay
+		default:
+			return nil, fmt.Errorf("unknown fault %q", part)
+		}
+	}
+	return fault, nil
+}
+
+type faultKey struct{}
+
+// WithFault returns a context asking FaultInjector to simulate fault.
+func WithFault(ctx context.Context, fault *Fault) context.Context {
+	return context.WithValue(ctx, faultKey{}, fault)
+}
+
+func faultFromContext(ctx context.Context) *Fault {
+	fault, _ := ctx.Value(faultKey{}).(*Fault)
+	return fault
+}
+
+// FaultInjector wraps an Executor and simulates the fault carried by a request's
+// context instead of (or before) running it. Requests without a fault are passed
+// through unchanged. Only installed when FaultInjectionEnabled.
+type FaultInjector struct {
+	Executor
+}
+
+// NewFaultInjector wraps inner with fault injection.
+func NewFaultInjector(inner Executor) *FaultInjector {
+	return &FaultInjector{Executor: inner}
+}
+
+// SetupEnvironment applies an injected delay or infrastructure error. Timeout
+// and OOM faults only apply to executions.
+func (f *FaultInjector) SetupEnvironment(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
+	fault := faultFromContext(ctx)
+	if fault == nil {
+		return f.Executor.SetupEnvironment(ctx, req)
+	}
+	if err := injectDelay(ctx, fault, "setup"); err != nil {
+		return nil, err
+	}
+	if fault.Kind == FaultInfraError {
+		return nil, errInjectedInfra
+	}
+	return f.Executor.SetupEnvironment(ctx, req)
+}
+
+// ExecuteInEnvironment applies an injected delay, then returns a simulated
+// timeout, OOM kill or infrastructure error without running any container.
+func (f *FaultInjector) ExecuteInEnvironment(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+	fault := faultFromContext(ctx)
+	if fault == nil {
+		return f.Executor.ExecuteInEnvironment(ctx, envID, req)
+	}
+	if err := injectDelay(ctx, fault, "execute"); err != nil {
+		return nil, err
+	}
+
+	timeoutMs, memoryMb := RuntimeDefaultLimits(defaultRuntime)
+	if req.Limits != nil {
+		if req.Limits.TimeoutMs > 0 {
+			timeoutMs = req.Limits.TimeoutMs
+		}
+		if req.Limits.MemoryMb > 0 {
+			memoryMb = req.Limits.MemoryMb
+		}
+	}
+	warnings := []string{"fault injected: " + fault.Kind}
+
+	switch fault.Kind {
+	case FaultInfraError:
+		return nil, errInjectedInfra
+	case FaultTimeout:
+		return &models.ExecutionResponse{
+			ID:         uuid.New(),
+			ExitCode:   124,
+			Stderr:     "Execution timeout exceeded",
+			DurationMs: int64(timeoutMs),
+			Signal:     "SIGTERM",
+			Reason:     fmt.Sprintf("killed by timeout after %d ms", timeoutMs),
+			Warnings:   warnings,
+		}, nil
+	case FaultOOM:
+		signal, reason := describeExit(137, memoryMb)
+		return &models.ExecutionResponse{
+			ID:       uuid.New(),
+			ExitCode: 137,
+			Signal:   signal,
+			Reason:   reason,
+			Warnings: warnings,
+		}, nil
+	}
+	return f.Executor.ExecuteInEnvironment(ctx, envID, req)
+}
+
+// injectDelay waits out the fault's delay, returning early if ctx is done.
+func injectDelay(ctx context.Context, fault *Fault, operation string) error {
+	logger.FromContext(ctx).Warn("injecting fault",
+		slog.String("operation", operation),
+		slog.String("kind", fault.Kind),
+		slog.Duration("delay", fault.Delay),
+	)
+	if fault.Delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(fault.Delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
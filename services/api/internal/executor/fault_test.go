@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestParseFault(t *testing.T) {
+	fault, err := ParseFault("delay=250ms, oom")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fault.Kind != FaultOOM || fault.Delay != 250*time.Millisecond {
+		t.Errorf("expected an OOM after 250ms, got %+v", fault)
+	}
+
+	for _, spec := range []string{"", "crash", "oom,timeout", "delay=forever", "delay=-1s", "delay=1h"} {
+		if _, err := ParseFault(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestFaultInjector(t *testing.T) {
+	logger.Init(nil)
+	mock := NewMockExecutor()
+	injector := NewFaultInjector(mock)
+	envID := uuid.New()
+
+	// Requests without a fault reach the wrapped executor
+	if _, err := injector.ExecuteInEnvironment(context.Background(), envID, &models.ExecuteRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mock.ExecuteCalls) != 1 {
+		t.Fatalf("expected the execution to be passed through, got %d calls", len(mock.ExecuteCalls))
+	}
+
+	ctx := WithFault(context.Background(), &Fault{Kind: FaultOOM})
+	resp, err := injector.ExecuteInEnvironment(ctx, envID, &models.ExecuteRequest{Limits: &models.ResourceLimits{MemoryMb: 64}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ExitCode != 137 || resp.Signal != "SIGKILL" || resp.Reason != "killed by SIGKILL, most likely out of memory (limit 64 MB)" {
+		t.Errorf("expected a simulated OOM kill, got %+v", resp)
+	}
+
+	ctx = WithFault(context.Background(), &Fault{Kind: FaultTimeout})
+	if resp, _ := injector.ExecuteInEnvironment(ctx, envID, &models.ExecuteRequest{Limits: &models.ResourceLimits{TimeoutMs: 1000}}); resp.ExitCode != 124 || resp.Reason != "killed by timeout after 1000 ms" {
+		t.Errorf("expected a simulated timeout, got %+v", resp)
+	}
+
+	ctx = WithFault(context.Background(), &Fault{Kind: FaultInfraError})
+	if _, err := injector.SetupEnvironment(ctx, &models.SetupRequest{}); !errors.Is(err, errInjectedInfra) {
+		t.Errorf("expected an injected infrastructure error, got %v", err)
+	}
+	if len(mock.ExecuteCalls) != 1 || len(mock.SetupCalls) != 0 {
+		t.Error("simulated failures should not reach the wrapped executor")
+	}
+
+	// A delay honours cancellation
+	cancelled, cancel := context.WithCancel(WithFault(context.Background(), &Fault{Delay: time.Minute}))
+	cancel()
+	if _, err := injector.ExecuteInEnvironment(cancelled, envID, &models.ExecuteRequest{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the delay to stop on cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package executor
+
+import "github.com/jsfour/assist-tee/internal/models"
+
+// withGroupDefaults returns req with its group's defaults filled in: limits the
+// request leaves unset come from the group, and the group's env vars are
+// merged under the request's. req itself is not modified.
+func withGroupDefaults(req *models.ExecuteRequest, defaults *models.GroupDefaults) *models.ExecuteRequest {
+	if defaults.Limits == nil && len(defaults.Env) == 0 {
+		return req
+	}
+	merged := *req
+
+	if defaults.Limits != nil {
+		limits := models.ResourceLimits{}
+		if req.Limits != nil {
+			limits = *req.Limits
+		}
+		if limits.TimeoutMs == 0 {
+			limits.TimeoutMs = defaults.Limits.TimeoutMs
+		}
+		if limits.MemoryMb == 0 {
+			limits.MemoryMb = defaults.Limits.MemoryMb
+		}
+		// The group's swap limit is sized for the group's memory limit, so a
+		// request raising memory above it runs with swap equal to memory instead
+		if limits.MemorySwapMb == 0 && defaults.Limits.MemorySwapMb >= limits.MemoryMb {
+			limits.MemorySwapMb = defaults.Limits.MemorySwapMb
+		}
+		merged.Limits = &limits
+	}
+
+	if len(defaults.Env) > 0 {
+		env := make(map[string]string, len(defaults.Env)+len(req.Env))
+		for name, value := range defaults.Env {
+			env[name] = value
+		}
+		for name, value := range req.Env {
+			env[name] = value
+		}
+		merged.Env = env
+	}
+	return &merged
+}
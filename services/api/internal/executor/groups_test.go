@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestWithGroupDefaults(t *testing.T) {
+	defaults := &models.GroupDefaults{
+		Limits: &models.ResourceLimits{TimeoutMs: 10000, MemoryMb: 256},
+		Env:    map[string]string{"API_TOKEN": "shared", "REGION": "eu"},
+	}
+	req := &models.ExecuteRequest{
+		Limits: &models.ResourceLimits{MemoryMb: 512},
+		Env:    map[string]string{"REGION": "us"},
+	}
+
+	merged := withGroupDefaults(req, defaults)
+
+	if *merged.Limits != (models.ResourceLimits{TimeoutMs: 10000, MemoryMb: 512}) {
+		t.Errorf("expected request limits over group limits, got %+v", *merged.Limits)
+	}
+	if want := map[string]string{"API_TOKEN": "shared", "REGION": "us"}; !reflect.DeepEqual(merged.Env, want) {
+		t.Errorf("expected %v, got %v", want, merged.Env)
+	}
+	if req.Limits.TimeoutMs != 0 || len(req.Env) != 1 {
+		t.Errorf("expected the request to be left unchanged, got %+v", req)
+	}
+
+	if got := withGroupDefaults(req, &models.GroupDefaults{}); got != req {
+		t.Error("expected empty defaults to return the request as is")
+	}
+}
+
+func TestWithGroupDefaults_SwapBelowRequestMemory(t *testing.T) {
+	defaults := &models.GroupDefaults{
+		Limits: &models.ResourceLimits{MemoryMb: 256, MemorySwapMb: 512},
+	}
+
+	merged := withGroupDefaults(&models.ExecuteRequest{}, defaults)
+	if merged.Limits.MemorySwapMb != 512 {
+		t.Errorf("expected the group's swap limit, got %d", merged.Limits.MemorySwapMb)
+	}
+
+	merged = withGroupDefaults(&models.ExecuteRequest{Limits: &models.ResourceLimits{MemoryMb: 1024}}, defaults)
+	if merged.Limits.MemorySwapMb != 0 {
+		t.Errorf("expected a swap limit below the request's memory to be dropped, got %d", merged.Limits.MemorySwapMb)
+	}
+}
@@ -0,0 +1,42 @@
+package executor
+
+import "fmt"
+
+// defaultHandlerName is the export the runner invokes unless setup names another
+const defaultHandlerName = "handler"
+
+// addHookContext tells the runner which modules to call before and after the
+// handler. Empty hook names are left out.
+func addHookContext(extraContext map[string]interface{}, preHook, postHook string) {
+	if preHook != "" {
+		extraContext["preHook"] = preHook
+	}
+	if postHook != "" {
+		extraContext["postHook"] = postHook
+	}
+}
+
+// selectHandler returns the export an execution invokes: the requested one,
+// which must be the environment's handlerName or one of its handlers, or
+// handlerName when none is requested.
+func selectHandler(metadata map[string]interface{}, requested string) (string, error) {
+	handlerName := metadataDefault(metadata, "handlerName", "")
+	if handlerName == "" {
+		handlerName = defaultHandlerName
+	}
+	switch {
+	case requested == "":
+		return handlerName, nil
+	case requested == handlerName, containsString(metadataStrings(metadata, "handlers"), requested):
+		return requested, nil
+	}
+	return "", fmt.Errorf("handler %q is not one of the environment's handlers", requested)
+}
+
+// addHandlerNameContext tells the runner which export of the main module to
+// invoke. The default "handler" is left out.
+func addHandlerNameContext(extraContext map[string]interface{}, handlerName string) {
+	if handlerName != "" && handlerName != defaultHandlerName {
+		extraContext["handlerName"] = handlerName
+	}
+}
@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAddHookContext(t *testing.T) {
+	extra := map[string]interface{}{"dataPresent": true}
+	addHookContext(extra, "auth.ts", "")
+
+	if extra["preHook"] != "auth.ts" {
+		t.Errorf("expected preHook auth.ts, got %v", extra["preHook"])
+	}
+	if _, ok := extra["postHook"]; ok {
+		t.Errorf("expected no postHook, got %v", extra["postHook"])
+	}
+
+	input, err := buildExecutionInput(uuid.New(), uuid.New(), "main.ts", nil, nil, extra)
+	if err != nil {
+		t.Fatalf("buildExecutionInput: %v", err)
+	}
+	if !strings.Contains(string(input), `"preHook":"auth.ts"`) {
+		t.Errorf("expected preHook in the execution context, got %s", input)
+	}
+}
+
+func TestAddHandlerNameContext(t *testing.T) {
+	extra := map[string]interface{}{}
+	addHandlerNameContext(extra, defaultHandlerName)
+	if _, ok := extra["handlerName"]; ok {
+		t.Errorf("expected the default handler name to be left out, got %v", extra["handlerName"])
+	}
+
+	addHandlerNameContext(extra, "summarize")
+	if extra["handlerName"] != "summarize" {
+		t.Errorf("expected handlerName summarize, got %v", extra["handlerName"])
+	}
+}
+
+func TestSelectHandler(t *testing.T) {
+	metadata := map[string]interface{}{
+		"handlerName": "main",
+		"handlers":    []interface{}{"processOrder", "refund"},
+	}
+	tests := []struct {
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{"", "main", false},
+		{"main", "main", false},
+		{"refund", "refund", false},
+		{"handler", "", true},
+		{"deleteEverything", "", true},
+	}
+	for _, tt := range tests {
+		got, err := selectHandler(metadata, tt.requested)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("selectHandler(%q) = %q, %v; want %q, error %v", tt.requested, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// Environments that never named a handler serve only the default
+	if got, err := selectHandler(map[string]interface{}{}, ""); err != nil || got != defaultHandlerName {
+		t.Errorf("expected the default handler, got %q, %v", got, err)
+	}
+	if _, err := selectHandler(map[string]interface{}{}, "processOrder"); err == nil {
+		t.Error("expected an undeclared handler to be rejected")
+	}
+}
+
+func TestCheckReferencedModules(t *testing.T) {
+	metadata := map[string]interface{}{
+		"preHook":     "auth.ts",
+		"healthCheck": map[string]interface{}{"module": "health.ts", "timeoutMs": float64(2000)},
+	}
+
+	if err := checkReferencedModules(metadata, []string{"main.ts", "auth.ts", "health.ts"}); err != nil {
+		t.Errorf("expected modules keeping the hook and health check to pass, got %v", err)
+	}
+	if err := checkReferencedModules(metadata, []string{"main.ts", "health.ts"}); err == nil || !strings.Contains(err.Error(), "preHook module auth.ts") {
+		t.Errorf("expected dropping the preHook module to be rejected, got %v", err)
+	}
+	if err := checkReferencedModules(metadata, []string{"main.ts", "auth.ts"}); err == nil || !strings.Contains(err.Error(), "healthCheck module health.ts") {
+		t.Errorf("expected dropping the healthCheck module to be rejected, got %v", err)
+	}
+
+	// A health check of the main module is covered by the mainModule check
+	if err := checkReferencedModules(map[string]interface{}{"healthCheck": map[string]interface{}{}}, []string{"main.ts"}); err != nil {
+		t.Errorf("expected a main-module health check to pass, got %v", err)
+	}
+}
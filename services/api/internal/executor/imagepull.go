@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// imagePullMarkers are fragments of docker's stderr that indicate the daemon
+// could not pull an image, as opposed to the container itself failing
+var imagePullMarkers = []string{
+	"pull access denied",
+	"manifest unknown",
+	"manifest for",
+	"toomanyrequests",
+	"unauthorized: authentication required",
+	"repository does not exist",
+	"error pulling image",
+	"failed to resolve reference",
+	"no such host",
+	"tls handshake timeout",
+}
+
+// isImagePullFailure reports whether docker's stderr describes an image pull failure
+func isImagePullFailure(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	for _, marker := range imagePullMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// imagePullError builds an image_pull_failed error carrying the registry's
+// message from docker's stderr
+func imagePullError(image, stderr string) *Error {
+	return &Error{
+		Code:    "image_pull_failed",
+		Message: fmt.Sprintf("failed to pull image %s: %s", image, registryMessage(stderr)),
+	}
+}
+
+// registryMessage picks the daemon's error line out of docker's stderr,
+// falling back to the last non-empty line
+func registryMessage(stderr string) string {
+	var last string
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if msg, ok := strings.CutPrefix(line, "docker: "); ok {
+			line = msg
+		}
+		if msg, ok := strings.CutPrefix(line, "Error response from daemon: "); ok {
+			return msg
+		}
+		last = line
+	}
+	return last
+}
@@ -0,0 +1,39 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsImagePullFailure(t *testing.T) {
+	failures := []string{
+		"Unable to find image 'example/rt:latest' locally\ndocker: Error response from daemon: pull access denied for example/rt, repository does not exist or may require 'docker login'.",
+		"Error response from daemon: manifest for example/rt:v9 not found: manifest unknown: manifest unknown",
+		"Error response from daemon: toomanyrequests: You have reached your pull rate limit.",
+	}
+	for _, stderr := range failures {
+		if !isImagePullFailure(stderr) {
+			t.Errorf("expected pull failure for %q", stderr)
+		}
+	}
+
+	for _, stderr := range []string{"", "error: Uncaught TypeError: x is not a function", "Killed"} {
+		if isImagePullFailure(stderr) {
+			t.Errorf("expected %q not to be a pull failure", stderr)
+		}
+	}
+}
+
+func TestImagePullError_UsesRegistryMessage(t *testing.T) {
+	stderr := "Unable to find image 'example/rt:latest' locally\n" +
+		"docker: Error response from daemon: toomanyrequests: You have reached your pull rate limit.\n" +
+		"See 'docker run --help'.\n"
+
+	err := imagePullError("example/rt:latest", stderr)
+	if err.Code != "image_pull_failed" {
+		t.Errorf("expected code 'image_pull_failed', got %q", err.Code)
+	}
+	if !strings.Contains(err.Message, "example/rt:latest") || !strings.HasSuffix(err.Message, "toomanyrequests: You have reached your pull rate limit.") {
+		t.Errorf("unexpected message %q", err.Message)
+	}
+}
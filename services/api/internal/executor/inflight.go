@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errExecutionCancelled is the cancellation cause for executions stopped via CancelExecution.
+var errExecutionCancelled = errors.New("execution cancelled")
+
+// inFlightRegistry tracks running executions per environment so that operations
+// which rewrite an environment can refuse to run underneath an execution, and so
+// individual executions can be cancelled.
+type inFlightRegistry struct {
+	mu         sync.Mutex
+	counts     map[uuid.UUID]int
+	executions map[uuid.UUID]runningExecution
+}
+
+// runningExecution is a cancellable execution registered with track.
+type runningExecution struct {
+	envID  uuid.UUID
+	cancel context.CancelCauseFunc
+}
+
+// add registers a running execution and returns a function that unregisters it.
+func (r *inFlightRegistry) add(envID uuid.UUID) func() {
+	r.mu.Lock()
+	if r.counts == nil {
+		r.counts = make(map[uuid.UUID]int)
+	}
+	r.counts[envID]++
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.counts[envID]--
+		if r.counts[envID] <= 0 {
+			delete(r.counts, envID)
+		}
+	}
+}
+
+// count returns the number of executions currently running in the environment.
+func (r *inFlightRegistry) count(envID uuid.UUID) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[envID]
+}
+
+// track makes an execution cancellable and returns a function that stops tracking it.
+// It returns false if an execution with the same ID is already running.
+func (r *inFlightRegistry) track(envID, execID uuid.UUID, cancel context.CancelCauseFunc) (func(), bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.executions == nil {
+		r.executions = make(map[uuid.UUID]runningExecution)
+	}
+	if _, exists := r.executions[execID]; exists {
+		return nil, false
+	}
+	r.executions[execID] = runningExecution{envID: envID, cancel: cancel}
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.executions, execID)
+	}, true
+}
+
+// cancel cancels a running execution in the environment, reporting whether one was found.
+func (r *inFlightRegistry) cancel(envID, execID uuid.UUID) bool {
+	r.mu.Lock()
+	running, ok := r.executions[execID]
+	r.mu.Unlock()
+	if !ok || running.envID != envID {
+		return false
+	}
+	running.cancel(errExecutionCancelled)
+	return true
+}
+
+// drainPollInterval is how often wait checks whether an environment has drained.
+const drainPollInterval = 100 * time.Millisecond
+
+// wait blocks until no executions are running in the environment, reporting
+// false if ctx ends first.
+func (r *inFlightRegistry) wait(ctx context.Context, envID uuid.UUID) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for r.count(envID) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// cancelAll cancels every tracked execution in the environment and returns how many.
+func (r *inFlightRegistry) cancelAll(envID uuid.UUID) int {
+	r.mu.Lock()
+	var cancels []context.CancelCauseFunc
+	for _, running := range r.executions {
+		if running.envID == envID {
+			cancels = append(cancels, running.cancel)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel(errExecutionCancelled)
+	}
+	return len(cancels)
+}
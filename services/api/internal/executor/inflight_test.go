@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestInFlightRegistry_Cancel(t *testing.T) {
+	var r inFlightRegistry
+	envID, execID := uuid.New(), uuid.New()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	untrack, ok := r.track(envID, execID, cancel)
+	if !ok {
+		t.Fatal("expected execution to be tracked")
+	}
+
+	if _, ok := r.track(envID, execID, cancel); ok {
+		t.Error("expected duplicate execution ID to be rejected")
+	}
+	if r.cancel(uuid.New(), execID) {
+		t.Error("cancel should not match an execution in another environment")
+	}
+	if !r.cancel(envID, execID) {
+		t.Fatal("expected running execution to be cancelled")
+	}
+	if context.Cause(ctx) != errExecutionCancelled {
+		t.Errorf("expected cancellation cause %v, got %v", errExecutionCancelled, context.Cause(ctx))
+	}
+
+	untrack()
+	if r.cancel(envID, execID) {
+		t.Error("cancel should fail once the execution is untracked")
+	}
+}
+
+func TestInFlightRegistry_WaitAndCancelAll(t *testing.T) {
+	var r inFlightRegistry
+	envID, otherEnv := uuid.New(), uuid.New()
+
+	if !r.wait(context.Background(), envID) {
+		t.Fatal("an environment with nothing running should drain immediately")
+	}
+
+	done := r.add(envID)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	untrack, _ := r.track(envID, uuid.New(), cancel)
+	otherCtx, otherCancel := context.WithCancelCause(context.Background())
+	r.track(otherEnv, uuid.New(), otherCancel)
+
+	waitCtx, stop := context.WithTimeout(context.Background(), 3*drainPollInterval)
+	defer stop()
+	if r.wait(waitCtx, envID) {
+		t.Fatal("expected wait to time out while an execution is running")
+	}
+
+	if n := r.cancelAll(envID); n != 1 {
+		t.Errorf("expected 1 execution cancelled, got %d", n)
+	}
+	if context.Cause(ctx) != errExecutionCancelled {
+		t.Errorf("expected cancellation cause %v, got %v", errExecutionCancelled, context.Cause(ctx))
+	}
+	if otherCtx.Err() != nil {
+		t.Error("cancelAll should not touch other environments")
+	}
+
+	untrack()
+	done()
+	if !r.wait(context.Background(), envID) {
+		t.Error("expected the environment to drain once its execution finished")
+	}
+}
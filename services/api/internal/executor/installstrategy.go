@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// installStrategy builds the shell commands that cache an environment's
+// dependencies, so how a runtime installs packages can change with its version
+// without touching installDependencies. Commands must cover npm, jsr and URL
+// dependencies in that order, one command per dependency, to line up with
+// dependencySpecs for progress reporting. With cachedOnly, the commands must fail
+// instead of downloading anything missing from the cache.
+type installStrategy interface {
+	cacheCommands(deps *models.Dependencies, cachedOnly bool) []string
+}
+
+// installStrategies maps a runtime, optionally suffixed with "@" and a major
+// version, to its install strategy. installStrategyFor prefers the versioned
+// entry.
+var installStrategies = map[string]installStrategy{
+	// Deno 1 takes --node-modules-dir as a boolean flag
+	"deno": denoCacheStrategy{nodeModulesDir: "--node-modules-dir"},
+	// Deno 2 takes a mode, and "auto" matches Deno 1's behaviour
+	"deno@2": denoCacheStrategy{nodeModulesDir: "--node-modules-dir=auto"},
+}
+
+// installStrategyFor returns the install strategy for a runtime and its pinned
+// version, falling back to the runtime's unversioned strategy and then to the
+// default runtime's.
+func installStrategyFor(runtime, version string) installStrategy {
+	if runtime == "" {
+		runtime = defaultRuntime
+	}
+	if major := majorVersion(version); major != "" {
+		if strategy, ok := installStrategies[runtime+"@"+major]; ok {
+			return strategy
+		}
+	}
+	if strategy, ok := installStrategies[runtime]; ok {
+		return strategy
+	}
+	return installStrategies[defaultRuntime]
+}
+
+// environmentInstallStrategy returns the install strategy for the runtime and
+// version recorded in environment metadata.
+func environmentInstallStrategy(metadata map[string]interface{}) installStrategy {
+	return installStrategyFor(environmentRuntime(metadata), metadataDefault(metadata, "runtimeVersion", ""))
+}
+
+// majorVersion returns the major component of a version tag such as "1.41" or
+// "v2.0.3", or "" if it does not start with a number.
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	if major == "" || strings.Trim(major, "0123456789") != "" {
+		return ""
+	}
+	return major
+}
+
+// denoCacheStrategy installs dependencies with `deno cache`.
+type denoCacheStrategy struct {
+	// nodeModulesDir is the flag that makes npm packages land in node_modules
+	nodeModulesDir string
+}
+
+func (s denoCacheStrategy) cacheCommands(deps *models.Dependencies, cachedOnly bool) []string {
+	cache := "deno cache"
+	if cachedOnly {
+		cache += " --cached-only"
+	}
+	var commands []string
+	for _, pkg := range deps.NPM {
+		commands = append(commands, fmt.Sprintf("%s %s npm:%s", cache, s.nodeModulesDir, pkg))
+	}
+	for _, pkg := range deps.JSR {
+		commands = append(commands, fmt.Sprintf("%s %s", cache, jsrSpecifier(pkg)))
+	}
+	for _, url := range deps.Deno {
+		commands = append(commands, fmt.Sprintf("%s %s", cache, url))
+	}
+	return commands
+}
+
+// jsrSpecifier returns a jsr dependency as a jsr: specifier; the prefix is
+// optional in setup requests.
+func jsrSpecifier(pkg string) string {
+	return "jsr:" + strings.TrimPrefix(pkg, "jsr:")
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestInstallStrategyFor(t *testing.T) {
+	deps := &models.Dependencies{
+		NPM:  []string{"zod@3.22.4"},
+		JSR:  []string{"@std/path@1.0.0", "jsr:@std/assert"},
+		Deno: []string{"https://deno.land/std@0.224.0/async/delay.ts"},
+	}
+
+	tests := []struct {
+		runtime, version string
+		want             []string
+	}{
+		{"deno", "", []string{
+			"deno cache --node-modules-dir npm:zod@3.22.4",
+			"deno cache jsr:@std/path@1.0.0",
+			"deno cache jsr:@std/assert",
+			"deno cache https://deno.land/std@0.224.0/async/delay.ts",
+		}},
+		{"deno", "1.41", []string{
+			"deno cache --node-modules-dir npm:zod@3.22.4",
+			"deno cache jsr:@std/path@1.0.0",
+			"deno cache jsr:@std/assert",
+			"deno cache https://deno.land/std@0.224.0/async/delay.ts",
+		}},
+		{"deno", "v2.0.3", []string{
+			"deno cache --node-modules-dir=auto npm:zod@3.22.4",
+			"deno cache jsr:@std/path@1.0.0",
+			"deno cache jsr:@std/assert",
+			"deno cache https://deno.land/std@0.224.0/async/delay.ts",
+		}},
+		// Unknown runtimes and unparseable versions fall back to the default
+		{"unknown", "2", []string{
+			"deno cache --node-modules-dir npm:zod@3.22.4",
+			"deno cache jsr:@std/path@1.0.0",
+			"deno cache jsr:@std/assert",
+			"deno cache https://deno.land/std@0.224.0/async/delay.ts",
+		}},
+		{"deno", "latest", []string{
+			"deno cache --node-modules-dir npm:zod@3.22.4",
+			"deno cache jsr:@std/path@1.0.0",
+			"deno cache jsr:@std/assert",
+			"deno cache https://deno.land/std@0.224.0/async/delay.ts",
+		}},
+	}
+	for _, tt := range tests {
+		got := installStrategyFor(tt.runtime, tt.version).cacheCommands(deps, false)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("installStrategyFor(%q, %q) commands = %q, want %q", tt.runtime, tt.version, got, tt.want)
+		}
+	}
+
+	specs := dependencySpecs(deps)
+	want := []string{"npm:zod@3.22.4", "jsr:@std/path@1.0.0", "jsr:@std/assert", "https://deno.land/std@0.224.0/async/delay.ts"}
+	if !reflect.DeepEqual(specs, want) {
+		t.Errorf("dependencySpecs = %q, want %q", specs, want)
+	}
+}
+
+func TestEnvironmentInstallStrategy(t *testing.T) {
+	metadata := map[string]interface{}{"runtime": "deno", "runtimeVersion": "2.1"}
+	got := environmentInstallStrategy(metadata).cacheCommands(&models.Dependencies{NPM: []string{"zod"}}, true)
+	want := []string{"deno cache --cached-only --node-modules-dir=auto npm:zod"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
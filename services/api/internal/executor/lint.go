@@ -0,0 +1,189 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LintRule is a forbidden pattern that setup rejects modules for.
+type LintRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// builtinLintRules can be enabled by name in MODULE_LINT_RULES.
+var builtinLintRules = map[string]LintRule{
+	"no-eval": {
+		Name:    "no-eval",
+		Pattern: regexp.MustCompile(`\beval\s*\(`),
+		Message: "eval is not allowed",
+	},
+	"no-function-constructor": {
+		Name:    "no-function-constructor",
+		Pattern: regexp.MustCompile(`\bnew\s+Function\s*\(`),
+		Message: "the Function constructor is not allowed",
+	},
+	"no-dynamic-import": {
+		Name:    "no-dynamic-import",
+		Pattern: regexp.MustCompile(`\bimport\s*\(`),
+		Message: "dynamic import() is not allowed",
+	},
+	"no-deno-run": {
+		Name:    "no-deno-run",
+		Pattern: regexp.MustCompile(`\bDeno\s*\.\s*(run|Command)\b`),
+		Message: "spawning subprocesses is not allowed",
+	},
+}
+
+// maxLintViolations caps how many violations a rejected setup reports.
+const maxLintViolations = 100
+
+// maxLintTextLength caps the offending line quoted in a violation.
+const maxLintTextLength = 200
+
+// LintViolation is one line of a module that matched a lint rule.
+type LintViolation struct {
+	Module  string `json:"module"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	Text    string `json:"text"`
+}
+
+// LintRules returns the rules setup enforces: the built-in rules named in the
+// comma-separated MODULE_LINT_RULES, followed by the custom rules in the JSON
+// file at MODULE_LINT_RULES_FILE, an array of {"name", "pattern", "message"}.
+// Linting is off when neither is set.
+func LintRules() ([]LintRule, error) {
+	var rules []LintRule
+	for _, name := range strings.Split(os.Getenv("MODULE_LINT_RULES"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		rule, ok := builtinLintRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+
+	path := os.Getenv("MODULE_LINT_RULES_FILE")
+	if path == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lint rules: %w", err)
+	}
+	var custom []struct {
+		Name    string `json:"name"`
+		Pattern string `json:"pattern"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid lint rules file %s: %w", path, err)
+	}
+	for i, c := range custom {
+		if c.Name == "" || c.Pattern == "" {
+			return nil, fmt.Errorf("lint rule %d needs a name and a pattern", i)
+		}
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %q has an invalid pattern: %w", c.Name, err)
+		}
+		message := c.Message
+		if message == "" {
+			message = "forbidden by lint rule " + c.Name
+		}
+		rules = append(rules, LintRule{Name: c.Name, Pattern: pattern, Message: message})
+	}
+	return rules, nil
+}
+
+// lintRules caches the rules loaded by ValidateLintConfig, so setup doesn't
+// re-read the rules file and recompile its patterns on every request.
+var (
+	lintRulesMu     sync.RWMutex
+	lintRulesLoaded bool
+	lintRules       []LintRule
+)
+
+// ValidateLintConfig loads and compiles MODULE_LINT_RULES and
+// MODULE_LINT_RULES_FILE, so a bad ruleset fails at startup rather than on
+// every setup. The rules are kept for LoadedLintRules; a bad ruleset leaves
+// the previously loaded rules in place.
+func ValidateLintConfig() error {
+	rules, err := LintRules()
+	if err != nil {
+		return err
+	}
+	lintRulesMu.Lock()
+	lintRules, lintRulesLoaded = rules, true
+	lintRulesMu.Unlock()
+	return nil
+}
+
+// LoadedLintRules returns the rules loaded by ValidateLintConfig, loading them
+// on first use if it hasn't run.
+func LoadedLintRules() ([]LintRule, error) {
+	lintRulesMu.RLock()
+	rules, loaded := lintRules, lintRulesLoaded
+	lintRulesMu.RUnlock()
+	if loaded {
+		return rules, nil
+	}
+
+	if err := ValidateLintConfig(); err != nil {
+		return nil, err
+	}
+	lintRulesMu.RLock()
+	defer lintRulesMu.RUnlock()
+	return lintRules, nil
+}
+
+// LintModules checks every line of the modules against rules, returning the
+// violations ordered by module and line, at most maxLintViolations of them.
+// Matching is textual, so patterns inside comments and strings count too.
+func LintModules(modules map[string]string, rules []LintRule) []LintViolation {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []LintViolation
+	for _, name := range names {
+		for i, line := range strings.Split(modules[name], "\n") {
+			for _, rule := range rules {
+				if !rule.Pattern.MatchString(line) {
+					continue
+				}
+				text := strings.TrimSpace(line)
+				if len(text) > maxLintTextLength {
+					text = strings.ToValidUTF8(text[:maxLintTextLength], "")
+				}
+				violations = append(violations, LintViolation{
+					Module:  name,
+					Line:    i + 1,
+					Rule:    rule.Name,
+					Message: rule.Message,
+					Text:    text,
+				})
+				if len(violations) == maxLintViolations {
+					return violations
+				}
+			}
+		}
+	}
+	return violations
+}
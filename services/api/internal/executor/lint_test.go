@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	if rules, err := LintRules(); err != nil || len(rules) != 0 {
+		t.Fatalf("expected no rules by default, got %d (%v)", len(rules), err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`[{"name": "no-fetch", "pattern": "\\bfetch\\s*\\(", "message": "use the HTTP client module"}]`), 0o644)
+	t.Setenv("MODULE_LINT_RULES", "no-eval, no-dynamic-import")
+	t.Setenv("MODULE_LINT_RULES_FILE", path)
+
+	rules, err := LintRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var names []string
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	if got := strings.Join(names, ","); got != "no-eval,no-dynamic-import,no-fetch" {
+		t.Errorf("expected built-in then custom rules, got %s", got)
+	}
+}
+
+func TestLintRules_Invalid(t *testing.T) {
+	t.Setenv("MODULE_LINT_RULES", "no-such-rule")
+	if err := ValidateLintConfig(); err == nil {
+		t.Error("expected an unknown built-in rule to be rejected")
+	}
+
+	t.Setenv("MODULE_LINT_RULES", "")
+	path := filepath.Join(t.TempDir(), "rules.json")
+	t.Setenv("MODULE_LINT_RULES_FILE", path)
+	for _, content := range []string{
+		`not json`,
+		`[{"name": "broken", "pattern": "("}]`,
+		`[{"pattern": "x"}]`,
+	} {
+		os.WriteFile(path, []byte(content), 0o644)
+		if err := ValidateLintConfig(); err == nil {
+			t.Errorf("expected rules file %s to be rejected", content)
+		}
+	}
+}
+
+func TestLoadedLintRules(t *testing.T) {
+	t.Cleanup(func() { ValidateLintConfig() })
+	path := filepath.Join(t.TempDir(), "rules.json")
+	os.WriteFile(path, []byte(`[{"name": "no-fetch", "pattern": "\\bfetch\\s*\\("}]`), 0o644)
+	t.Setenv("MODULE_LINT_RULES_FILE", path)
+	if err := ValidateLintConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The rules file is only read at load time
+	os.WriteFile(path, []byte(`not json`), 0o644)
+	rules, err := LoadedLintRules()
+	if err != nil {
+		t.Fatalf("expected the loaded rules to be reused, got %v", err)
+	}
+	if len(rules) != 1 || rules[0].Name != "no-fetch" {
+		t.Errorf("expected the loaded no-fetch rule, got %v", rules)
+	}
+
+	// A bad reload keeps the previous rules
+	if err := ValidateLintConfig(); err == nil {
+		t.Fatal("expected the invalid rules file to be rejected")
+	}
+	if rules, _ := LoadedLintRules(); len(rules) != 1 {
+		t.Errorf("expected the previous rules to be kept, got %d", len(rules))
+	}
+}
+
+func TestLintModules(t *testing.T) {
+	rules := []LintRule{builtinLintRules["no-dynamic-import"], builtinLintRules["no-function-constructor"]}
+	modules := map[string]string{
+		"b.ts": "const f = new Function(\"return 1\");\nconst m = await import(\"./a.ts\");",
+		"a.ts": "import { x } from \"./b.ts\";\nexport const meta = import.meta.url;",
+	}
+
+	violations := LintModules(modules, rules)
+	if len(violations) != 2 {
+		t.Fatalf("expected 2 violations, got %+v", violations)
+	}
+	if v := violations[0]; v.Module != "b.ts" || v.Line != 1 || v.Rule != "no-function-constructor" {
+		t.Errorf("unexpected first violation: %+v", v)
+	}
+	if v := violations[1]; v.Module != "b.ts" || v.Line != 2 || v.Rule != "no-dynamic-import" {
+		t.Errorf("unexpected second violation: %+v", v)
+	}
+
+	if violations := LintModules(modules, nil); violations != nil {
+		t.Errorf("expected no violations without rules, got %+v", violations)
+	}
+}
+
+func TestLintModules_Capped(t *testing.T) {
+	modules := map[string]string{"main.ts": strings.Repeat("eval(x)\n", maxLintViolations+10)}
+	if got := len(LintModules(modules, []LintRule{builtinLintRules["no-eval"]})); got != maxLintViolations {
+		t.Errorf("expected violations capped at %d, got %d", maxLintViolations, got)
+	}
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// execDurations keeps a moving average of execution durations for Retry-After hints.
+var execDurations struct {
+	mu  sync.Mutex
+	avg time.Duration
+}
+
+// recordExecutionDuration folds a completed execution into the moving average.
+func recordExecutionDuration(d time.Duration) {
+	execDurations.mu.Lock()
+	defer execDurations.mu.Unlock()
+	if execDurations.avg == 0 {
+		execDurations.avg = d
+		return
+	}
+	// Exponentially weighted, favouring recent executions
+	execDurations.avg = (execDurations.avg*4 + d) / 5
+}
+
+// retryAfter estimates how long until a slot frees up for a new execution, given
+// the queue ahead of it, rounded up to whole seconds with a minimum of one second.
+func retryAfter(queueDepth int64) time.Duration {
+	execDurations.mu.Lock()
+	avg := execDurations.avg
+	execDurations.mu.Unlock()
+	if avg == 0 {
+		avg = time.Duration(defaultTimeoutMs) * time.Millisecond
+	}
+
+	slots := float64(execSlots.slots)
+	estimate := avg.Seconds() * float64(queueDepth+1) / slots
+	return time.Duration(math.Max(1, math.Ceil(estimate))) * time.Second
+}
+
+// acquireExecSlot takes an execution slot, waiting at most ExecQueueWait.
+// Normal-priority executions never use the slots reserved for high priority,
+// and queued executions age so neither class starves (see execScheduler). When
+// no slot frees up in time it returns a "busy" Error with a Retry-After hint.
+func acquireExecSlot(ctx context.Context, highPriority bool) (func(), error) {
+	release, depth, err := execSlots.acquire(ctx, highPriority, time.Now().Add(ExecQueueWait()))
+	if errors.Is(err, errQueueTimeout) {
+		return nil, &Error{
+			Code:       "busy",
+			Message:    "execution capacity exhausted, retry later",
+			RetryAfter: retryAfter(int64(depth)),
+			QueueDepth: depth,
+		}
+	}
+	return release, err
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAcquireExecSlot_BusyWhenFull(t *testing.T) {
+	t.Setenv("EXEC_QUEUE_WAIT_MS", "10")
+
+	saved := execSlots
+	execSlots = newExecScheduler(2, 0)
+	defer func() { execSlots = saved }()
+
+	// Fill every slot
+	for i := 0; i < execSlots.slots; i++ {
+		if _, err := acquireExecSlot(context.Background(), false); err != nil {
+			t.Fatalf("unexpected error acquiring slot %d: %v", i, err)
+		}
+	}
+
+	release, err := acquireExecSlot(context.Background(), false)
+	if release != nil {
+		t.Fatal("expected no slot to be acquired")
+	}
+
+	var execErr *Error
+	if !errors.As(err, &execErr) || execErr.Code != "busy" {
+		t.Fatalf("expected busy error, got %v", err)
+	}
+	if execErr.RetryAfter < time.Second {
+		t.Errorf("expected Retry-After of at least 1s, got %v", execErr.RetryAfter)
+	}
+	if execErr.QueueDepth != 1 {
+		t.Errorf("expected queue depth 1, got %d", execErr.QueueDepth)
+	}
+}
+
+func TestAcquireExecSlot_FreeSlot(t *testing.T) {
+	release, err := acquireExecSlot(context.Background(), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+}
+
+func TestAcquireExecSlot_ReservedForHighPriority(t *testing.T) {
+	t.Setenv("EXEC_QUEUE_WAIT_MS", "10")
+
+	saved := execSlots
+	execSlots = newExecScheduler(50, 20)
+	defer func() { execSlots = saved }()
+
+	// Use up every normal-priority slot
+	var releases []func()
+	for i := 0; i < execSlots.normalSlots; i++ {
+		release, err := acquireExecSlot(context.Background(), false)
+		if err != nil {
+			t.Fatalf("unexpected error acquiring slot %d: %v", i, err)
+		}
+		releases = append(releases, release)
+	}
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+
+	if _, err := acquireExecSlot(context.Background(), false); err == nil {
+		t.Fatal("expected normal priority to be refused a reserved slot")
+	}
+
+	release, err := acquireExecSlot(context.Background(), true)
+	if err != nil {
+		t.Fatalf("expected high priority to get a reserved slot, got %v", err)
+	}
+	release()
+}
+
+func TestNewExecScheduler(t *testing.T) {
+	if got := newExecScheduler(50, 0).normalSlots; got != 50 {
+		t.Errorf("expected every slot open to normal priority without a reservation, got %d", got)
+	}
+	if got := newExecScheduler(50, 20).normalSlots; got != 40 {
+		t.Errorf("expected 40 normal slots, got %d", got)
+	}
+	if got := newExecScheduler(50, 100).normalSlots; got != 1 {
+		t.Errorf("expected normal work to keep one slot, got %d", got)
+	}
+}
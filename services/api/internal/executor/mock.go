@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,13 +19,40 @@ type MockExecutor struct {
 	// If nil, returns a default successful response.
 	ExecuteFunc func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error)
 
+	// UpdateFunc is called when UpdateEnvironment is invoked.
+	// If nil, returns a ready environment with the version bumped to 2.
+	UpdateFunc func(ctx context.Context, envID uuid.UUID, req *models.UpdateRequest) (*models.Environment, error)
+
+	// GetFunc is called when GetEnvironment is invoked.
+	// If nil, returns a default ready environment with the requested ID.
+	GetFunc func(ctx context.Context, envID uuid.UUID) (*models.Environment, error)
+
+	// ExportFunc is called when ExportEnvironment is invoked.
+	// If nil, returns a bundle with a single main.ts module.
+	ExportFunc func(ctx context.Context, envID uuid.UUID) (*models.EnvironmentExport, error)
+
+	// ReadyFunc is called when CheckReadiness is invoked.
+	// If nil, returns a ready response without a health check.
+	ReadyFunc func(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error)
+
+	// CancelFunc is called when CancelExecution is invoked.
+	// If nil, returns nil (success).
+	CancelFunc func(ctx context.Context, envID, execID uuid.UUID) error
+
 	// DeleteFunc is called when DeleteEnvironment is invoked.
 	// If nil, returns nil (success).
 	DeleteFunc func(ctx context.Context, envID uuid.UUID) error
 
-	// Call tracking
+	// Call tracking. mu guards ExecuteCalls, which batch executions append
+	// to concurrently.
+	mu           sync.Mutex
 	SetupCalls   []SetupCall
 	ExecuteCalls []ExecuteCall
+	UpdateCalls  []UpdateCall
+	GetCalls     []GetCall
+	ExportCalls  []ExportCall
+	ReadyCalls   []ReadyCall
+	CancelCalls  []CancelCall
 	DeleteCalls  []DeleteCall
 }
 
@@ -41,6 +69,38 @@ type ExecuteCall struct {
 	Req   *models.ExecuteRequest
 }
 
+// UpdateCall records a call to UpdateEnvironment.
+type UpdateCall struct {
+	Ctx   context.Context
+	EnvID uuid.UUID
+	Req   *models.UpdateRequest
+}
+
+// GetCall records a call to GetEnvironment.
+type GetCall struct {
+	Ctx   context.Context
+	EnvID uuid.UUID
+}
+
+// ExportCall records a call to ExportEnvironment.
+type ExportCall struct {
+	Ctx   context.Context
+	EnvID uuid.UUID
+}
+
+// ReadyCall records a call to CheckReadiness.
+type ReadyCall struct {
+	Ctx   context.Context
+	EnvID uuid.UUID
+}
+
+// CancelCall records a call to CancelExecution.
+type CancelCall struct {
+	Ctx    context.Context
+	EnvID  uuid.UUID
+	ExecID uuid.UUID
+}
+
 // DeleteCall records a call to DeleteEnvironment.
 type DeleteCall struct {
 	Ctx   context.Context
@@ -69,12 +129,16 @@ func (m *MockExecutor) SetupEnvironment(ctx context.Context, req *models.SetupRe
 		ExecutionCount: 0,
 		Status:         "ready",
 		TTLSeconds:     req.TTLSeconds,
+		Version:        1,
+		CreatedBy:      req.CreatedBy,
 	}, nil
 }
 
 // ExecuteInEnvironment implements Executor.
 func (m *MockExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+	m.mu.Lock()
 	m.ExecuteCalls = append(m.ExecuteCalls, ExecuteCall{Ctx: ctx, EnvID: envID, Req: req})
+	m.mu.Unlock()
 
 	if m.ExecuteFunc != nil {
 		return m.ExecuteFunc(ctx, envID, req)
@@ -90,7 +154,96 @@ func (m *MockExecutor) ExecuteInEnvironment(ctx context.Context, envID uuid.UUID
 	}, nil
 }
 
+// UpdateEnvironment implements Executor.
+func (m *MockExecutor) UpdateEnvironment(ctx context.Context, envID uuid.UUID, req *models.UpdateRequest) (*models.Environment, error) {
+	m.UpdateCalls = append(m.UpdateCalls, UpdateCall{Ctx: ctx, EnvID: envID, Req: req})
+
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(ctx, envID, req)
+	}
+
+	// Default: return the updated environment
+	mainModule := req.MainModule
+	if mainModule == "" {
+		mainModule = "main.ts"
+	}
+	return &models.Environment{
+		ID:         envID,
+		VolumeName: "tee-env-" + envID.String(),
+		MainModule: mainModule,
+		CreatedAt:  time.Now(),
+		Status:     "ready",
+		TTLSeconds: 3600,
+		Version:    2,
+	}, nil
+}
+
+// GetEnvironment implements Executor.
+func (m *MockExecutor) GetEnvironment(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+	m.GetCalls = append(m.GetCalls, GetCall{Ctx: ctx, EnvID: envID})
+
+	if m.GetFunc != nil {
+		return m.GetFunc(ctx, envID)
+	}
+
+	// Default: return a ready environment
+	return &models.Environment{
+		ID:             envID,
+		VolumeName:     "tee-env-" + envID.String(),
+		MainModule:     "main.ts",
+		CreatedAt:      time.Now(),
+		ExecutionCount: 0,
+		Status:         "ready",
+		TTLSeconds:     3600,
+		Version:        1,
+	}, nil
+}
+
+// ExportEnvironment implements Executor.
+func (m *MockExecutor) ExportEnvironment(ctx context.Context, envID uuid.UUID) (*models.EnvironmentExport, error) {
+	m.ExportCalls = append(m.ExportCalls, ExportCall{Ctx: ctx, EnvID: envID})
+
+	if m.ExportFunc != nil {
+		return m.ExportFunc(ctx, envID)
+	}
+
+	// Default: return a minimal bundle
+	return &models.EnvironmentExport{
+		FormatVersion:       models.EnvironmentExportVersion,
+		SourceEnvironmentID: envID,
+		ExportedAt:          time.Now(),
+		Setup: models.SetupRequest{
+			MainModule: "main.ts",
+			Modules:    map[string]string{"main.ts": "export function handler() {}"},
+			TTLSeconds: 3600,
+		},
+	}, nil
+}
+
+// CheckReadiness implements Executor.
+func (m *MockExecutor) CheckReadiness(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error) {
+	m.ReadyCalls = append(m.ReadyCalls, ReadyCall{Ctx: ctx, EnvID: envID})
+
+	if m.ReadyFunc != nil {
+		return m.ReadyFunc(ctx, envID)
+	}
+
+	// Default: ready, with no health check declared
+	return &models.ReadinessResponse{EnvironmentID: envID, Ready: true}, nil
+}
+
 // DeleteEnvironment implements Executor.
+func (m *MockExecutor) CancelExecution(ctx context.Context, envID, execID uuid.UUID) error {
+	m.CancelCalls = append(m.CancelCalls, CancelCall{Ctx: ctx, EnvID: envID, ExecID: execID})
+
+	if m.CancelFunc != nil {
+		return m.CancelFunc(ctx, envID, execID)
+	}
+
+	// Default: return success
+	return nil
+}
+
 func (m *MockExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID) error {
 	m.DeleteCalls = append(m.DeleteCalls, DeleteCall{Ctx: ctx, EnvID: envID})
 
@@ -106,6 +259,11 @@ func (m *MockExecutor) DeleteEnvironment(ctx context.Context, envID uuid.UUID) e
 func (m *MockExecutor) Reset() {
 	m.SetupCalls = nil
 	m.ExecuteCalls = nil
+	m.UpdateCalls = nil
+	m.GetCalls = nil
+	m.ExportCalls = nil
+	m.ReadyCalls = nil
+	m.CancelCalls = nil
 	m.DeleteCalls = nil
 }
 
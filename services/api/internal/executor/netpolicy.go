@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"net"
+	"strings"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// NetHostAllowed reports whether an allowNet entry (host or host:port) falls
+// within the global allowlist. A global entry matches the same host with any
+// port unless it names a port itself, and "*.example.com" matches subdomains.
+func NetHostAllowed(entry string, allowlist []string) bool {
+	host, port := splitNetEntry(entry)
+	for _, allowed := range allowlist {
+		allowedHost, allowedPort := splitNetEntry(allowed)
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+		if allowedHost == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowedHost, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisallowedNetHosts returns the allowNet entries outside the global allowlist.
+// With no global allowlist configured every entry is allowed.
+func DisallowedNetHosts(allowNet []string) []string {
+	allowlist := GlobalNetAllowlist()
+	if len(allowlist) == 0 {
+		return nil
+	}
+	var disallowed []string
+	for _, entry := range allowNet {
+		if !NetHostAllowed(entry, allowlist) {
+			disallowed = append(disallowed, entry)
+		}
+	}
+	return disallowed
+}
+
+// restrictAllowNet returns permissions with AllowNet narrowed to the global
+// allowlist, so environments created before the policy changed cannot exceed it
+func restrictAllowNet(permissions *models.Permissions) *models.Permissions {
+	if permissions == nil || len(permissions.AllowNet) == 0 {
+		return permissions
+	}
+	allowlist := GlobalNetAllowlist()
+	if len(allowlist) == 0 {
+		return permissions
+	}
+
+	restricted := *permissions
+	restricted.AllowNet = nil
+	for _, entry := range permissions.AllowNet {
+		if NetHostAllowed(entry, allowlist) {
+			restricted.AllowNet = append(restricted.AllowNet, entry)
+		}
+	}
+	return &restricted
+}
+
+// splitNetEntry splits "host[:port]" into a lowercase host and optional port
+func splitNetEntry(entry string) (host, port string) {
+	entry = strings.ToLower(strings.TrimSpace(entry))
+	if h, p, err := net.SplitHostPort(entry); err == nil {
+		return h, p
+	}
+	return entry, ""
+}
@@ -0,0 +1,51 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestNetHostAllowed(t *testing.T) {
+	allowlist := []string{"api.example.com", "cdn.example.com:443", "*.internal.example.com"}
+
+	cases := map[string]bool{
+		"api.example.com":           true,
+		"API.example.com:8443":      true,
+		"cdn.example.com:443":       true,
+		"cdn.example.com":           false,
+		"cdn.example.com:80":        false,
+		"db.internal.example.com":   true,
+		"internal.example.com":      false,
+		"evil.com":                  false,
+		"api.example.com.evil.com":  false,
+		"x.db.internal.example.com": true,
+		"notinternal.example.com":   false,
+	}
+	for entry, want := range cases {
+		if got := NetHostAllowed(entry, allowlist); got != want {
+			t.Errorf("NetHostAllowed(%q) = %v, want %v", entry, got, want)
+		}
+	}
+}
+
+func TestRestrictAllowNet(t *testing.T) {
+	permissions := &models.Permissions{AllowNet: []string{"api.example.com", "evil.com"}, AllowEnv: []string{"KEY"}}
+
+	if got := restrictAllowNet(permissions); got != permissions {
+		t.Error("expected permissions to be unchanged without a global allowlist")
+	}
+
+	t.Setenv("GLOBAL_NET_ALLOWLIST", "api.example.com")
+	got := restrictAllowNet(permissions)
+	if !reflect.DeepEqual(got.AllowNet, []string{"api.example.com"}) {
+		t.Errorf("expected allowNet narrowed to [api.example.com], got %v", got.AllowNet)
+	}
+	if len(permissions.AllowNet) != 2 {
+		t.Error("the environment's stored permissions must not be modified")
+	}
+	if !reflect.DeepEqual(DisallowedNetHosts(permissions.AllowNet), []string{"evil.com"}) {
+		t.Errorf("expected evil.com to be disallowed, got %v", DisallowedNetHosts(permissions.AllowNet))
+	}
+}
@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+)
+
+// OfflineDeps describes how dependencies are installed when OFFLINE_DEPS is set:
+// without the public internet, from a pre-populated cache and/or an internal
+// registry mirror.
+type OfflineDeps struct {
+	// Network is the docker network install containers join. "none" (the
+	// default) allows no traffic at all, so only cached packages can be used.
+	Network string
+
+	// NPMRegistry is the mirror npm packages are fetched from, if any
+	NPMRegistry string
+
+	// CacheVolume is a docker volume holding a pre-populated DENO_DIR that is
+	// copied into each environment before its dependencies are cached
+	CacheVolume string
+}
+
+// OfflineDepsConfig returns the offline install settings from OFFLINE_DEPS,
+// OFFLINE_DEPS_NETWORK, OFFLINE_DEPS_NPM_REGISTRY and OFFLINE_DEPS_CACHE_VOLUME,
+// or nil when dependencies are installed from the internet as usual.
+func OfflineDepsConfig() *OfflineDeps {
+	if !getEnvBool("OFFLINE_DEPS", false) {
+		return nil
+	}
+	offline := &OfflineDeps{
+		Network:     os.Getenv("OFFLINE_DEPS_NETWORK"),
+		NPMRegistry: os.Getenv("OFFLINE_DEPS_NPM_REGISTRY"),
+		CacheVolume: os.Getenv("OFFLINE_DEPS_CACHE_VOLUME"),
+	}
+	if offline.Network == "" {
+		offline.Network = "none"
+	}
+	return offline
+}
+
+// cachedOnly reports whether installs must be served entirely from the cache,
+// because the install container has no network to reach a mirror with.
+func (o *OfflineDeps) cachedOnly() bool {
+	return o.Network == "none"
+}
+
+// dockerArgs returns the docker run flags for an offline install container.
+func (o *OfflineDeps) dockerArgs() []string {
+	args := []string{"--network=" + o.Network}
+	if o.NPMRegistry != "" {
+		args = append(args, "-e", "NPM_CONFIG_REGISTRY="+o.NPMRegistry)
+	}
+	if o.CacheVolume != "" {
+		args = append(args, "-v", fmt.Sprintf("%s:/offline-cache:ro", o.CacheVolume))
+	}
+	return args
+}
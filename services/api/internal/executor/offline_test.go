@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestOfflineDepsConfig(t *testing.T) {
+	if OfflineDepsConfig() != nil {
+		t.Fatal("expected online installs by default")
+	}
+
+	t.Setenv("OFFLINE_DEPS", "true")
+	offline := OfflineDepsConfig()
+	if offline == nil || !offline.cachedOnly() {
+		t.Fatalf("expected cache-only installs without a mirror network, got %+v", offline)
+	}
+
+	t.Setenv("OFFLINE_DEPS_NETWORK", "mirror-net")
+	t.Setenv("OFFLINE_DEPS_NPM_REGISTRY", "http://npm-mirror:4873")
+	offline = OfflineDepsConfig()
+	if offline.cachedOnly() {
+		t.Error("expected installs through the mirror network to be allowed to download")
+	}
+	want := []string{"--network=mirror-net", "-e", "NPM_CONFIG_REGISTRY=http://npm-mirror:4873"}
+	if got := offline.dockerArgs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDependencyCacheCommands(t *testing.T) {
+	deps := &models.Dependencies{NPM: []string{"zod@3.22.4"}, Deno: []string{"jsr:@std/path"}}
+	want := []string{
+		"deno cache --cached-only --node-modules-dir npm:zod@3.22.4",
+		"deno cache --cached-only jsr:@std/path",
+	}
+	if got := installStrategyFor("deno", "").cacheCommands(deps, true); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,23 @@
+package executor
+
+// oomExitCode is the exit code of a container killed by SIGKILL (128+9), which
+// describeExit reports as running out of memory.
+const oomExitCode = 137
+
+// MaxMemoryMb returns the most memory an out-of-memory retry may give an
+// execution, from MAX_MEMORY_MB (default 1024).
+func MaxMemoryMb() int {
+	return getEnvInt("MAX_MEMORY_MB", 1024)
+}
+
+// oomRetryMemory returns the memory and memory+swap limits for retrying an
+// execution that ran out of memory: double the memory, capped at MaxMemoryMb,
+// keeping the same swap allowance. ok is false when memory is already at the cap.
+func oomRetryMemory(memoryMb, memorySwapMb int) (retryMemoryMb, retrySwapMb int, ok bool) {
+	maxMemoryMb := MaxMemoryMb()
+	if memoryMb >= maxMemoryMb {
+		return 0, 0, false
+	}
+	retryMemoryMb = min(2*memoryMb, maxMemoryMb)
+	return retryMemoryMb, max(memorySwapMb, memoryMb) + retryMemoryMb - memoryMb, true
+}
@@ -0,0 +1,26 @@
+package executor
+
+import "testing"
+
+func TestOOMRetryMemory(t *testing.T) {
+	t.Setenv("MAX_MEMORY_MB", "512")
+
+	cases := []struct {
+		memoryMb, swapMb     int
+		wantMemory, wantSwap int
+		wantOK               bool
+	}{
+		{128, 128, 256, 256, true},
+		{128, 192, 256, 320, true}, // the swap allowance is kept
+		{384, 384, 512, 512, true}, // capped at MAX_MEMORY_MB
+		{512, 512, 0, 0, false},
+		{1024, 1024, 0, 0, false},
+	}
+	for _, c := range cases {
+		memory, swap, ok := oomRetryMemory(c.memoryMb, c.swapMb)
+		if memory != c.wantMemory || swap != c.wantSwap || ok != c.wantOK {
+			t.Errorf("oomRetryMemory(%d, %d) = %d, %d, %v; expected %d, %d, %v",
+				c.memoryMb, c.swapMb, memory, swap, ok, c.wantMemory, c.wantSwap, c.wantOK)
+		}
+	}
+}
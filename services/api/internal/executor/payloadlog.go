@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// LogExecutionPayloads reports whether execution input and results are logged
+// at debug level (LOG_EXECUTION_PAYLOADS, default false). Payloads are redacted
+// before they are logged.
+func LogExecutionPayloads() bool {
+	return getEnvBool("LOG_EXECUTION_PAYLOADS", false)
+}
+
+// logExecutionPayload logs an execution's input data or result at debug level
+// when LOG_EXECUTION_PAYLOADS is on. The execution's secret env var values are
+// scrubbed along with secret-looking keys, since handlers often echo them.
+func logExecutionPayload(ctx context.Context, msg string, execID uuid.UUID, key string, payload interface{}, env map[string]string) {
+	log := logger.FromContext(ctx)
+	if !LogExecutionPayloads() || !log.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	log.Debug(msg,
+		slog.String("execution_id", execID.String()),
+		slog.Any(key, logger.Redact(payload, logger.EnvSecrets(env))),
+	)
+}
+
+// decodedResult returns a JSON result decoded so it can be redacted field by
+// field, or the raw string when it is not JSON.
+func decodedResult(resultJSON string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(resultJSON), &decoded); err != nil {
+		return resultJSON
+	}
+	return decoded
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+func TestLogExecutionPayload(t *testing.T) {
+	var buf bytes.Buffer
+	previous := logger.Log
+	logger.Log = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	defer func() { logger.Log = previous }()
+
+	result := decodedResult(`{"greeting":"hi","token":"t-1","echo":"key=s3cret"}`)
+	env := map[string]string{"API_KEY": "s3cret"}
+
+	logExecutionPayload(context.Background(), "execution result", uuid.New(), "result", result, env)
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing logged by default, got %s", buf.String())
+	}
+
+	t.Setenv("LOG_EXECUTION_PAYLOADS", "true")
+	logExecutionPayload(context.Background(), "execution result", uuid.New(), "result", result, env)
+
+	logged := buf.String()
+	if !strings.Contains(logged, `"greeting":"hi"`) {
+		t.Errorf("expected the result to be logged, got %s", logged)
+	}
+	if strings.Contains(logged, "t-1") || strings.Contains(logged, "s3cret") {
+		t.Errorf("expected secrets to be redacted, got %s", logged)
+	}
+}
+
+func TestDecodedResult(t *testing.T) {
+	if got := decodedResult("not json"); got != "not json" {
+		t.Errorf("expected non-JSON output as is, got %v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// prepullDone is set once PrepullImages has made every image available.
+var prepullDone atomic.Bool
+
+// PrepullEnabled reports whether runtime images should be pulled at startup
+func PrepullEnabled() bool {
+	return getEnvBool("PREPULL_IMAGES", false)
+}
+
+// PrepullComplete reports whether startup image pulls are finished. It is always
+// true when PREPULL_IMAGES is off.
+func PrepullComplete() bool {
+	return !PrepullEnabled() || prepullDone.Load()
+}
+
+// prepullImageList returns every image setup and execute may need: the default
+// runtime image, each pinnable runtime version, and the helper image used to
+// write modules.
+func prepullImageList() []string {
+	images := []string{RuntimeImage()}
+	for _, version := range RuntimeVersions() {
+		images = append(images, RuntimeImageForVersion(version))
+	}
+	return append(images, "busybox:latest")
+}
+
+// PrepullImages makes sure every image from prepullImageList is present, pulling
+// missing ones in turn, and marks startup pulls complete once they all are.
+func PrepullImages(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	images := prepullImageList()
+
+	for i, image := range images {
+		log.Info("pre-pulling image",
+			slog.String("image", image),
+			slog.Int("index", i+1),
+			slog.Int("total", len(images)),
+		)
+		// No environment is involved, so pull under the nil environment ID
+		if err := ensureRuntimeImage(ctx, uuid.Nil, image); err != nil {
+			return err
+		}
+	}
+
+	prepullDone.Store(true)
+	log.Info("image pre-pull completed",
+		slog.Int("images", len(images)),
+	)
+	return nil
+}
@@ -0,0 +1,31 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrepullImageList(t *testing.T) {
+	t.Setenv("RUNTIME_IMAGE", "registry.local:5000/rt-deno:latest")
+	t.Setenv("RUNTIME_VERSIONS", "1.40, 1.41")
+
+	want := []string{
+		"registry.local:5000/rt-deno:latest",
+		"registry.local:5000/rt-deno:1.40",
+		"registry.local:5000/rt-deno:1.41",
+		"busybox:latest",
+	}
+	if got := prepullImageList(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestPrepullComplete(t *testing.T) {
+	if !PrepullComplete() {
+		t.Error("expected readiness when PREPULL_IMAGES is off")
+	}
+	t.Setenv("PREPULL_IMAGES", "true")
+	if PrepullComplete() {
+		t.Error("expected not ready until images are pulled")
+	}
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// dependencyProgressMarker starts the line the install script echoes after each
+// dependency is cached, followed by the dependency's index.
+const dependencyProgressMarker = "tee-progress: dependency "
+
+// reportProgress calls progress with p, if a progress sink is set.
+func reportProgress(progress func(models.SetupProgress), p models.SetupProgress) {
+	if progress != nil {
+		progress(p)
+	}
+}
+
+// dependencySpecs returns the dependencies in the order install strategies
+// cache them: npm, then jsr, then deno URLs.
+func dependencySpecs(deps *models.Dependencies) []string {
+	var specs []string
+	for _, pkg := range deps.NPM {
+		specs = append(specs, "npm:"+pkg)
+	}
+	for _, pkg := range deps.JSR {
+		specs = append(specs, jsrSpecifier(pkg))
+	}
+	specs = append(specs, deps.Deno...)
+	return specs
+}
+
+// withProgressMarkers follows each cache command with an echo of its marker line.
+// Only the index is echoed, so dependency names never reach the shell twice.
+func withProgressMarkers(commands []string) []string {
+	marked := make([]string, 0, 2*len(commands))
+	for i, command := range commands {
+		marked = append(marked, command, fmt.Sprintf("echo '%s%d'", dependencyProgressMarker, i))
+	}
+	return marked
+}
+
+// dependencyProgress returns a line hook for the install output that reports
+// each cached dependency to progress.
+func dependencyProgress(progress func(models.SetupProgress), specs []string) func(string) {
+	return func(line string) {
+		rest, ok := strings.CutPrefix(line, dependencyProgressMarker)
+		if !ok {
+			return
+		}
+		i, err := strconv.Atoi(rest)
+		if err != nil || i < 0 || i >= len(specs) {
+			return
+		}
+		reportProgress(progress, models.SetupProgress{
+			Stage:      models.SetupStageDependencyCached,
+			Dependency: specs[i],
+			Completed:  i + 1,
+			Total:      len(specs),
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package executor
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestDependencyProgress(t *testing.T) {
+	deps := &models.Dependencies{NPM: []string{"zod@3.22.4"}, Deno: []string{"https://deno.land/std/path/mod.ts"}}
+	specs := dependencySpecs(deps)
+
+	commands := withProgressMarkers(installStrategyFor("deno", "").cacheCommands(deps, false))
+	if len(commands) != 4 || commands[1] != "echo 'tee-progress: dependency 0'" {
+		t.Fatalf("expected a marker after each cache command, got %v", commands)
+	}
+
+	var reported []models.SetupProgress
+	writer := &streamingWriter{log: slog.New(slog.NewTextHandler(io.Discard, nil)), stream: "stdout", prefix: "dependency install",
+		onLine: dependencyProgress(func(p models.SetupProgress) { reported = append(reported, p) }, specs)}
+	writer.Write([]byte("Download https://registry.npmjs.org/zod\ntee-progress: dependency 0\ntee-progress: dep"))
+	writer.Write([]byte("endency 1\ntee-progress: dependency 7\n"))
+
+	want := []models.SetupProgress{
+		{Stage: models.SetupStageDependencyCached, Dependency: "npm:zod@3.22.4", Completed: 1, Total: 2},
+		{Stage: models.SetupStageDependencyCached, Dependency: "https://deno.land/std/path/mod.ts", Completed: 2, Total: 2},
+	}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("expected %+v, got %+v", want, reported)
+	}
+}
@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// ServerProxy returns the outbound proxy settings for networked containers, from
+// EXEC_HTTP_PROXY, EXEC_HTTPS_PROXY and EXEC_NO_PROXY. Nil when none are set.
+func ServerProxy() *models.ProxyConfig {
+	proxy := &models.ProxyConfig{
+		HTTPProxy:  os.Getenv("EXEC_HTTP_PROXY"),
+		HTTPSProxy: os.Getenv("EXEC_HTTPS_PROXY"),
+		NoProxy:    os.Getenv("EXEC_NO_PROXY"),
+	}
+	if *proxy == (models.ProxyConfig{}) {
+		return nil
+	}
+	return proxy
+}
+
+// ValidateProxy checks that the proxy URLs are absolute http(s) URLs with a host.
+func ValidateProxy(proxy *models.ProxyConfig) error {
+	if proxy == nil {
+		return nil
+	}
+	for name, value := range map[string]string{"httpProxy": proxy.HTTPProxy, "httpsProxy": proxy.HTTPSProxy} {
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL with a host, got %q", name, value)
+		}
+	}
+	return nil
+}
+
+// DisallowedProxyHosts returns the hosts of an environment's proxy URLs that
+// fall outside the global allowlist, since a proxy is a way out to the network
+// like any allowNet host. With no global allowlist configured every proxy is
+// allowed.
+func DisallowedProxyHosts(proxy *models.ProxyConfig) []string {
+	if proxy == nil {
+		return nil
+	}
+	allowlist := GlobalNetAllowlist()
+	if len(allowlist) == 0 {
+		return nil
+	}
+	var disallowed []string
+	for _, value := range []string{proxy.HTTPProxy, proxy.HTTPSProxy} {
+		if value == "" {
+			continue
+		}
+		u, err := url.Parse(value)
+		if err != nil || u.Host == "" {
+			disallowed = append(disallowed, value)
+			continue
+		}
+		if !NetHostAllowed(u.Host, allowlist) {
+			disallowed = append(disallowed, u.Host)
+		}
+	}
+	return disallowed
+}
+
+// proxyHostAllowed reports whether a proxy URL's host is within the allowlist.
+func proxyHostAllowed(value string, allowlist []string) bool {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return NetHostAllowed(u.Host, allowlist)
+}
+
+// resolveProxy overlays an environment's proxy settings on the server's, field
+// by field, so an environment can override just one of them. Overrides outside
+// the global allowlist are ignored, so environments created before the policy
+// changed cannot route around it.
+func resolveProxy(envProxy *models.ProxyConfig) *models.ProxyConfig {
+	resolved := ServerProxy()
+	if envProxy == nil {
+		return resolved
+	}
+	if resolved == nil {
+		resolved = &models.ProxyConfig{}
+	}
+	allowlist := GlobalNetAllowlist()
+	allowed := func(value string) bool {
+		return len(allowlist) == 0 || proxyHostAllowed(value, allowlist)
+	}
+	if envProxy.HTTPProxy != "" && allowed(envProxy.HTTPProxy) {
+		resolved.HTTPProxy = envProxy.HTTPProxy
+	}
+	if envProxy.HTTPSProxy != "" && allowed(envProxy.HTTPSProxy) {
+		resolved.HTTPSProxy = envProxy.HTTPSProxy
+	}
+	if envProxy.NoProxy != "" {
+		resolved.NoProxy = envProxy.NoProxy
+	}
+	return resolved
+}
+
+// environmentProxy reads the proxy override stored in environment metadata.
+func environmentProxy(metadata map[string]interface{}) *models.ProxyConfig {
+	data, ok := metadata["proxy"]
+	if !ok || data == nil {
+		return nil
+	}
+	proxyJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	proxy := &models.ProxyConfig{}
+	if err := json.Unmarshal(proxyJSON, proxy); err != nil {
+		return nil
+	}
+	return proxy
+}
+
+// proxyEnvArgs returns docker -e flags setting the proxy variables, in both the
+// upper and lower case spellings different tools look for.
+func proxyEnvArgs(proxy *models.ProxyConfig) []string {
+	if proxy == nil {
+		return nil
+	}
+	var args []string
+	for _, v := range []struct{ name, value string }{
+		{"HTTP_PROXY", proxy.HTTPProxy},
+		{"HTTPS_PROXY", proxy.HTTPSProxy},
+		{"NO_PROXY", proxy.NoProxy},
+		{"http_proxy", proxy.HTTPProxy},
+		{"https_proxy", proxy.HTTPSProxy},
+		{"no_proxy", proxy.NoProxy},
+	} {
+		if v.value != "" {
+			args = append(args, "-e", v.name+"="+v.value)
+		}
+	}
+	return args
+}
@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestResolveProxy(t *testing.T) {
+	if got := resolveProxy(nil); got != nil {
+		t.Errorf("expected no proxy without configuration, got %+v", got)
+	}
+
+	t.Setenv("EXEC_HTTP_PROXY", "http://proxy.corp:3128")
+	t.Setenv("EXEC_HTTPS_PROXY", "http://proxy.corp:3128")
+	t.Setenv("EXEC_NO_PROXY", "localhost")
+
+	metadata := map[string]interface{}{"proxy": map[string]interface{}{"httpsProxy": "http://team-proxy:8080"}}
+	got := resolveProxy(environmentProxy(metadata))
+	want := &models.ProxyConfig{HTTPProxy: "http://proxy.corp:3128", HTTPSProxy: "http://team-proxy:8080", NoProxy: "localhost"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	args := proxyEnvArgs(&models.ProxyConfig{HTTPSProxy: "http://p:1"})
+	if !reflect.DeepEqual(args, []string{"-e", "HTTPS_PROXY=http://p:1", "-e", "https_proxy=http://p:1"}) {
+		t.Errorf("unexpected proxy env args %v", args)
+	}
+}
+
+func TestValidateProxy(t *testing.T) {
+	if err := ValidateProxy(&models.ProxyConfig{HTTPProxy: "http://proxy.corp:3128", NoProxy: "*.internal"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{"proxy.corp:3128", "ftp://proxy.corp", "http://"} {
+		if err := ValidateProxy(&models.ProxyConfig{HTTPSProxy: bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestProxyOutsideGlobalAllowlist(t *testing.T) {
+	proxy := &models.ProxyConfig{HTTPProxy: "http://proxy.corp:3128", HTTPSProxy: "http://exfil.example.net:8080"}
+	if got := DisallowedProxyHosts(proxy); got != nil {
+		t.Errorf("expected every proxy to be allowed without a global allowlist, got %v", got)
+	}
+
+	t.Setenv("GLOBAL_NET_ALLOWLIST", "proxy.corp")
+	if got := DisallowedProxyHosts(proxy); !reflect.DeepEqual(got, []string{"exfil.example.net:8080"}) {
+		t.Errorf("expected the exfil proxy to be disallowed, got %v", got)
+	}
+
+	t.Setenv("EXEC_HTTPS_PROXY", "http://proxy.corp:3128")
+	got := resolveProxy(proxy)
+	want := &models.ProxyConfig{HTTPProxy: "http://proxy.corp:3128", HTTPSProxy: "http://proxy.corp:3128"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the disallowed override to be ignored, got %+v", got)
+	}
+}
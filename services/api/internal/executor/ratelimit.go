@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// envRateLimiter keeps a token bucket per environment for environments that set
+// maxExecutionsPerMinute. Buckets hold up to a minute's worth of executions and
+// refill continuously.
+type envRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[uuid.UUID]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the environment's bucket. When the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *envRateLimiter) allow(envID uuid.UUID, perMinute int, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[uuid.UUID]*tokenBucket)
+	}
+
+	capacity := float64(perMinute)
+	rate := capacity / 60 // tokens per second
+
+	bucket, ok := l.buckets[envID]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[envID] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := (1 - bucket.tokens) / rate
+	return false, time.Duration(math.Max(1, math.Ceil(wait))) * time.Second
+}
+
+// forget drops an environment's bucket once the environment is deleted.
+func (l *envRateLimiter) forget(envID uuid.UUID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, envID)
+}
+
+// maxExecutionsPerMinute reads the environment's execution rate limit from its
+// metadata, returning 0 when it has none.
+func maxExecutionsPerMinute(metadata map[string]interface{}) int {
+	limit, ok := metadata["maxExecutionsPerMinute"].(float64)
+	if !ok || limit < 1 {
+		return 0
+	}
+	return int(limit)
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEnvRateLimiter(t *testing.T) {
+	var l envRateLimiter
+	envID, other := uuid.New(), uuid.New()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow(envID, 2, now); !ok {
+			t.Fatalf("execution %d should be within the limit", i+1)
+		}
+	}
+	ok, wait := l.allow(envID, 2, now)
+	if ok {
+		t.Fatal("expected third execution in the same minute to be limited")
+	}
+	if wait != 30*time.Second {
+		t.Errorf("expected a 30s retry hint, got %v", wait)
+	}
+
+	if ok, _ := l.allow(other, 2, now); !ok {
+		t.Error("limits should be tracked per environment")
+	}
+	if ok, _ := l.allow(envID, 2, now.Add(30*time.Second)); !ok {
+		t.Error("expected a token to refill after 30s")
+	}
+
+	l.forget(envID)
+	if ok, _ := l.allow(envID, 2, now.Add(30*time.Second)); !ok {
+		t.Error("expected a fresh bucket after forget")
+	}
+}
+
+func TestMaxExecutionsPerMinute(t *testing.T) {
+	if got := maxExecutionsPerMinute(map[string]interface{}{"maxExecutionsPerMinute": float64(30)}); got != 30 {
+		t.Errorf("expected 30, got %d", got)
+	}
+	if got := maxExecutionsPerMinute(nil); got != 0 {
+		t.Errorf("expected no limit, got %d", got)
+	}
+}
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// CheckReadiness reports whether an environment can serve executions. The
+// environment must be ready and its volume must exist; when it declares a
+// health check, the check must also exit cleanly. A failed check is reported
+// in the response rather than as an error.
+func (e *DockerExecutor) CheckReadiness(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error) {
+	log := logger.FromContext(ctx)
+
+	var volumeName, mainModule, status string
+	var metadataJSON, groupLimitsJSON, groupEnvJSON []byte
+	err := database.WithRetry(ctx, func() error {
+		return database.DB.QueryRowContext(ctx, `
+			SELECT e.volume_name, e.main_module, e.metadata, e.status, g.limits, g.env
+			FROM environments e
+			LEFT JOIN environment_groups g ON g.id = e.group_id
+			WHERE e.id = $1
+		`, envID).Scan(&volumeName, &mainModule, &metadataJSON, &status, &groupLimitsJSON, &groupEnvJSON)
+	})
+	if err == sql.ErrNoRows {
+		return nil, ErrEnvironmentNotFound
+	} else if err != nil {
+		log.Error("database query failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		return nil, err
+	}
+
+	resp := &models.ReadinessResponse{EnvironmentID: envID}
+	if status != "ready" {
+		resp.Error = "environment is " + status
+		return resp, nil
+	}
+	if err := checkVolumeExists(ctx, volumeName); err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	metadata, permissions, err := parseEnvironmentMetadata(metadataJSON)
+	if err != nil {
+		log.Error("environment metadata is malformed, denying all permissions",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+	check := environmentHealthCheck(metadata)
+	if check == nil {
+		resp.Ready = true
+		return resp, nil
+	}
+	resp.Checked = true
+
+	// The check runs with the group's limits and env vars, like an execution
+	groupDefaults, err := database.DecodeGroupDefaults(groupLimitsJSON, groupEnvJSON)
+	if err != nil {
+		log.Error("environment group defaults are malformed, ignoring them",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		groupDefaults = &models.GroupDefaults{}
+	}
+	run, err := healthCheckRun(envID, volumeName, mainModule, metadata, permissions, check, groupDefaults)
+	if err != nil {
+		return nil, err
+	}
+
+	// The check is an execution like any other and waits for a slot
+	release, err := acquireExecSlot(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	done := e.inFlight.add(envID)
+	defer done()
+
+	result, err := runContainer(ctx, run, nil)
+	if err != nil {
+		return nil, fmt.Errorf("health check failed: %w", err)
+	}
+	resp.DurationMs = result.duration.Milliseconds()
+	resp.Error = healthCheckError(result)
+	resp.Ready = resp.Error == ""
+
+	log.Info("environment health check completed",
+		slog.String("environment_id", envID.String()),
+		slog.String("execution_id", run.execID.String()),
+		slog.Bool("ready", resp.Ready),
+		slog.Int64("duration_ms", resp.DurationMs),
+	)
+	return resp, nil
+}
+
+// healthCheckRun builds the container run for an environment's health check.
+// The check's timeout comes first; otherwise the check gets the limits and env
+// vars of the environment's group, as an execution would.
+func healthCheckRun(envID uuid.UUID, volumeName, mainModule string, metadata map[string]interface{}, permissions *models.Permissions, check *models.HealthCheckConfig, groupDefaults *models.GroupDefaults) (*containerRun, error) {
+	execID := uuid.New()
+	module := check.Module
+	if module == "" {
+		module = mainModule
+	}
+
+	req := &models.ExecuteRequest{}
+	if check.TimeoutMs > 0 {
+		req.Limits = &models.ResourceLimits{TimeoutMs: check.TimeoutMs}
+	}
+	req = withGroupDefaults(req, groupDefaults)
+	env := executionEnv(permissions, req.Env)
+
+	extraContext := map[string]interface{}{"healthCheck": true, "dataPresent": check.Data != nil}
+	// A separate check module keeps the conventional export
+	if module == mainModule {
+		addHandlerNameContext(extraContext, metadataDefault(metadata, "handlerName", ""))
+	}
+	inputJSON, err := buildExecutionInput(envID, execID, module, check.Data, env, extraContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build health check input: %w", err)
+	}
+
+	timeoutMs, memoryMb := RuntimeDefaultLimits(environmentRuntime(metadata))
+	memorySwapMb := 0
+	if req.Limits != nil {
+		if req.Limits.TimeoutMs > 0 {
+			timeoutMs = req.Limits.TimeoutMs
+		}
+		if req.Limits.MemoryMb > 0 {
+			memoryMb = req.Limits.MemoryMb
+		}
+		memorySwapMb = req.Limits.MemorySwapMb
+	}
+	return &containerRun{
+		envID:        envID,
+		execID:       execID,
+		volumeName:   volumeName,
+		image:        environmentImage(metadata),
+		mainModule:   module,
+		permissions:  permissions,
+		env:          env,
+		proxy:        resolveProxy(environmentProxy(metadata)),
+		timezone:     metadataDefault(metadata, "timezone", ""),
+		locale:       metadataDefault(metadata, "locale", ""),
+		runtime:      runscRuntime(resolveRunscOptions(environmentRunscOptions(metadata))),
+		input:        inputJSON,
+		timeoutMs:    timeoutMs,
+		memoryMb:     memoryMb,
+		memorySwapMb: memorySwapMb,
+	}, nil
+}
+
+// healthCheckError describes why a health check run failed, or returns "" if
+// it passed.
+func healthCheckError(result *containerResult) string {
+	switch {
+	case result.timedOut:
+		return "health check timed out"
+	case result.stalled:
+		return "health check stalled without output"
+	}
+	_, stderr, exitCode, _ := parseRunnerOutput(result.stdout, result.stderr, result.exitCode)
+	if exitCode != 0 {
+		return fmt.Sprintf("health check failed with exit code %d: %s", exitCode, strings.TrimSpace(stderr))
+	}
+	return ""
+}
+
+// environmentHealthCheck reads the health check stored in environment metadata.
+func environmentHealthCheck(metadata map[string]interface{}) *models.HealthCheckConfig {
+	data, ok := metadata["healthCheck"]
+	if !ok || data == nil {
+		return nil
+	}
+	checkJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	check := &models.HealthCheckConfig{}
+	if err := json.Unmarshal(checkJSON, check); err != nil {
+		return nil
+	}
+	return check
+}
+
+// checkVolumeExists returns an error when the environment's volume is gone.
+func checkVolumeExists(ctx context.Context, volumeName string) error {
+	cmd := DockerCommand(ctx, "volume", "inspect", volumeName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if isNoSuchVolume(stderr.String()) {
+			return fmt.Errorf("volume %s does not exist", volumeName)
+		}
+		return fmt.Errorf("failed to inspect volume: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestHealthCheckError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *containerResult
+		want   string
+	}{
+		{"passed", &containerResult{stdout: `{"success":true,"result":"ok"}`}, ""},
+		{"timed out", &containerResult{timedOut: true}, "health check timed out"},
+		{"stalled", &containerResult{stalled: true}, "health check stalled without output"},
+		{"handler threw", &containerResult{stdout: `{"success":false,"error":"connection refused"}`, exitCode: 1}, "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := healthCheckError(tt.result)
+			if tt.want == "" && got != "" {
+				t.Errorf("expected no error, got %q", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected error containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEnvironmentHealthCheck(t *testing.T) {
+	if check := environmentHealthCheck(map[string]interface{}{}); check != nil {
+		t.Errorf("expected no health check, got %+v", check)
+	}
+
+	check := environmentHealthCheck(map[string]interface{}{
+		"healthCheck": map[string]interface{}{"module": "health.ts", "timeoutMs": float64(2000)},
+	})
+	if check == nil || check.Module != "health.ts" || check.TimeoutMs != 2000 {
+		t.Errorf("unexpected health check: %+v", check)
+	}
+}
+
+func TestHealthCheckRun_AppliesGroupDefaults(t *testing.T) {
+	permissions := &models.Permissions{AllowEnv: []string{"API_URL"}}
+	defaults := &models.GroupDefaults{
+		Limits: &models.ResourceLimits{TimeoutMs: 9000, MemoryMb: 512, MemorySwapMb: 1024},
+		Env:    map[string]string{"API_URL": "https://internal.example", "UNLISTED": "x"},
+	}
+
+	run, err := healthCheckRun(uuid.New(), "vol", "main.ts", map[string]interface{}{}, permissions, &models.HealthCheckConfig{}, defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if run.timeoutMs != 9000 || run.memoryMb != 512 || run.memorySwapMb != 1024 {
+		t.Errorf("expected the group's limits, got timeout %d memory %d swap %d", run.timeoutMs, run.memoryMb, run.memorySwapMb)
+	}
+	if !reflect.DeepEqual(run.env, map[string]string{"API_URL": "https://internal.example"}) {
+		t.Errorf("expected the group's allowed env vars, got %v", run.env)
+	}
+	if !strings.Contains(string(run.input), `"API_URL":"https://internal.example"`) {
+		t.Errorf("expected the env vars in the runner input, got %s", run.input)
+	}
+
+	// The check's own timeout wins over the group's
+	run, err = healthCheckRun(uuid.New(), "vol", "main.ts", map[string]interface{}{}, permissions, &models.HealthCheckConfig{TimeoutMs: 2000}, defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if run.timeoutMs != 2000 || run.memoryMb != 512 {
+		t.Errorf("expected the check's timeout with the group's memory, got timeout %d memory %d", run.timeoutMs, run.memoryMb)
+	}
+}
@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// recordSplitter separates a streaming handler's stdout. Each line the runner
+// writes as {"type":"record","value":...} is forwarded to out as soon as it is
+// complete; everything else (the final result) is appended to rest.
+type recordSplitter struct {
+	out    io.Writer
+	rest   io.Writer
+	buffer []byte
+
+	// outErr is set once out fails (e.g. the client went away); later records are dropped
+	outErr error
+}
+
+func (s *recordSplitter) Write(p []byte) (int, error) {
+	s.buffer = append(s.buffer, p...)
+
+	for {
+		idx := bytes.IndexByte(s.buffer, '\n')
+		if idx == -1 {
+			break
+		}
+		line := s.buffer[:idx+1]
+		s.buffer = s.buffer[idx+1:]
+
+		if isRecordLine(line) {
+			if s.outErr == nil {
+				_, s.outErr = s.out.Write(line)
+			}
+			continue
+		}
+		if _, err := s.rest.Write(line); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush hands any unterminated trailing output, normally the final result, to rest
+func (s *recordSplitter) Flush() {
+	if len(s.buffer) > 0 {
+		s.rest.Write(s.buffer)
+		s.buffer = nil
+	}
+}
+
+func isRecordLine(line []byte) bool {
+	var record struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return false
+	}
+	return record.Type == "record"
+}
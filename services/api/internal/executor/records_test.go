@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRecordSplitter(t *testing.T) {
+	var out, rest bytes.Buffer
+	s := &recordSplitter{out: &out, rest: &rest}
+
+	s.Write([]byte(`{"type":"record","value":1}` + "\n" + `{"type":"rec`))
+	if out.String() != `{"type":"record","value":1}`+"\n" {
+		t.Errorf("expected first record forwarded immediately, got %q", out.String())
+	}
+
+	s.Write([]byte(`ord","value":{"row":2}}` + "\n" + `{"success":true,"result":"done"}`))
+	s.Flush()
+
+	wantOut := `{"type":"record","value":1}` + "\n" + `{"type":"record","value":{"row":2}}` + "\n"
+	if out.String() != wantOut {
+		t.Errorf("expected records %q, got %q", wantOut, out.String())
+	}
+	if rest.String() != `{"success":true,"result":"done"}` {
+		t.Errorf("expected final result in rest, got %q", rest.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("client gone") }
+
+func TestRecordSplitter_ContinuesAfterOutputFailure(t *testing.T) {
+	var rest bytes.Buffer
+	s := &recordSplitter{out: failingWriter{}, rest: &rest}
+
+	n, err := s.Write([]byte(`{"type":"record","value":1}` + "\n" + `{"success":true}`))
+	s.Flush()
+
+	if err != nil || n == 0 {
+		t.Fatalf("expected the container's output to keep flowing, got n=%d err=%v", n, err)
+	}
+	if rest.String() != `{"success":true}` {
+		t.Errorf("expected final result in rest, got %q", rest.String())
+	}
+}
@@ -0,0 +1,23 @@
+package executor
+
+import (
+	"strconv"
+)
+
+// reproducibleEnvArgs returns the docker -e flags for a reproducible execution.
+// SOURCE_DATE_EPOCH is the convention build tools read for a fixed timestamp;
+// TEE_FROZEN_TIME tells the runner to freeze Date at the same instant.
+func reproducibleEnvArgs(epoch int64) []string {
+	value := strconv.FormatInt(epoch, 10)
+	return []string{
+		"-e", "SOURCE_DATE_EPOCH=" + value,
+		"-e", "TEE_FROZEN_TIME=" + value,
+	}
+}
+
+// reproducibleDenoFlags returns the deno run flags for a reproducible execution.
+// --seed seeds Math.random and Deno's Web Crypto random source, so "random"
+// values repeat from run to run.
+func reproducibleDenoFlags(epoch int64) []string {
+	return []string{"--seed=" + strconv.FormatInt(epoch, 10)}
+}
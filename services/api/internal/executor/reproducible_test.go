@@ -0,0 +1,18 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReproducibleArgs(t *testing.T) {
+	env := reproducibleEnvArgs(1700000000)
+	want := []string{"-e", "SOURCE_DATE_EPOCH=1700000000", "-e", "TEE_FROZEN_TIME=1700000000"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+
+	if flags := reproducibleDenoFlags(0); !reflect.DeepEqual(flags, []string{"--seed=0"}) {
+		t.Errorf("expected a fixed seed, got %v", flags)
+	}
+}
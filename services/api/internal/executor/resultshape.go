@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// resultShape describes the top-level JSON type of a handler's result and, for
+// objects, its key names. It returns nil when the result is not JSON.
+func resultShape(resultJSON string) *models.ResultShape {
+	var result interface{}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return nil
+	}
+
+	switch value := result.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return &models.ResultShape{Type: "object", Keys: keys}
+	case []interface{}:
+		return &models.ResultShape{Type: "array"}
+	case string:
+		return &models.ResultShape{Type: "string"}
+	case float64:
+		return &models.ResultShape{Type: "number"}
+	case bool:
+		return &models.ResultShape{Type: "boolean"}
+	default:
+		return &models.ResultShape{Type: "null"}
+	}
+}
+
+// storedResultShape reads the result shape recorded in environment metadata.
+func storedResultShape(metadata map[string]interface{}) *models.ResultShape {
+	data, ok := metadata["resultShape"]
+	if !ok || data == nil {
+		return nil
+	}
+	shapeJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	shape := &models.ResultShape{}
+	if err := json.Unmarshal(shapeJSON, shape); err != nil {
+		return nil
+	}
+	return shape
+}
+
+// recordResultShape stores the shape of the latest successful result in the
+// environment's metadata. Nothing is written when the shape is unchanged, so
+// steady handlers cost no extra update.
+func recordResultShape(ctx context.Context, envID uuid.UUID, metadata map[string]interface{}, shape *models.ResultShape) {
+	if shape == nil || reflect.DeepEqual(storedResultShape(metadata), shape) {
+		return
+	}
+	shapeJSON, _ := json.Marshal(shape)
+	err := database.WithRetry(ctx, func() error {
+		_, err := database.DB.ExecContext(ctx, `
+			UPDATE environments
+			SET metadata = jsonb_set(COALESCE(metadata, '{}'::jsonb), '{resultShape}', $2::jsonb)
+			WHERE id = $1
+		`, envID, string(shapeJSON))
+		return err
+	})
+	if err != nil {
+		logger.FromContext(ctx).Warn("failed to record result shape",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestResultShape(t *testing.T) {
+	tests := []struct {
+		result string
+		want   *models.ResultShape
+	}{
+		{`{"total":3,"items":[]}`, &models.ResultShape{Type: "object", Keys: []string{"items", "total"}}},
+		{`[1,2]`, &models.ResultShape{Type: "array"}},
+		{`"ok"`, &models.ResultShape{Type: "string"}},
+		{`4.5`, &models.ResultShape{Type: "number"}},
+		{`true`, &models.ResultShape{Type: "boolean"}},
+		{`null`, &models.ResultShape{Type: "null"}},
+		{`not json`, nil},
+	}
+	for _, tt := range tests {
+		if got := resultShape(tt.result); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("resultShape(%s) = %+v, want %+v", tt.result, got, tt.want)
+		}
+	}
+}
+
+func TestStoredResultShape(t *testing.T) {
+	metadata := map[string]interface{}{
+		"resultShape": map[string]interface{}{"type": "object", "keys": []interface{}{"a", "b"}},
+	}
+	want := &models.ResultShape{Type: "object", Keys: []string{"a", "b"}}
+	if got := storedResultShape(metadata); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
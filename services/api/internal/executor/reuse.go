@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// setupContentHash identifies a setup request by everything that shapes the
+// environment it creates. Maps marshal with sorted keys, so the hash does not
+// depend on module order.
+func setupContentHash(req *models.SetupRequest) string {
+	hashed := *req
+	hashed.Reuse = false
+	data, _ := json.Marshal(hashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// reuseEnvironment takes a reference on a ready, unexpired environment created
+// with the same content hash. It returns nil when there is none to reuse.
+func reuseEnvironment(ctx context.Context, contentHash string) (*models.Environment, error) {
+	var env models.Environment
+	row := database.DB.QueryRowContext(ctx, `
+		UPDATE environments SET ref_count = ref_count + 1
+		WHERE id = (
+			SELECT id FROM environments
+			WHERE content_hash = $1 AND status = 'ready'
+			  AND created_at + (ttl_seconds || ' seconds')::interval > NOW()
+			ORDER BY created_at DESC
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING `+database.EnvironmentColumns,
+		contentHash)
+	err := database.ScanEnvironment(row, &env)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	database.MarkWritten(env.ID)
+	env.Reused = true
+	return &env, nil
+}
+
+// releaseSharedEnvironment drops one reference to an environment. It reports
+// true when other references remain, in which case the environment must be kept.
+func releaseSharedEnvironment(ctx context.Context, envID uuid.UUID) (bool, error) {
+	var remaining int
+	err := database.DB.QueryRowContext(ctx, `
+		UPDATE environments SET ref_count = ref_count - 1
+		WHERE id = $1 AND ref_count > 1
+		RETURNING ref_count
+	`, envID).Scan(&remaining)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	database.MarkWritten(envID)
+
+	logger.FromContext(ctx).Info("released reference to shared environment",
+		slog.String("environment_id", envID.String()),
+		slog.Int("ref_count", remaining),
+	)
+	return true, nil
+}
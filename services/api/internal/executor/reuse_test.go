@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestSetupContentHash(t *testing.T) {
+	req := &models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}", "util.ts": "export const a = 1"},
+		Reuse:      true,
+	}
+	same := &models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"util.ts": "export const a = 1", "main.ts": "export function handler() {}"},
+	}
+	if setupContentHash(req) != setupContentHash(same) {
+		t.Error("expected identical setups to hash the same regardless of the reuse flag")
+	}
+
+	different := *same
+	different.Permissions = &models.Permissions{AllowNet: []string{"api.example.com"}}
+	if setupContentHash(req) == setupContentHash(&different) {
+		t.Error("expected different permissions to change the hash")
+	}
+}
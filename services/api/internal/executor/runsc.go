@@ -0,0 +1,190 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultRunscRuntime is the docker runtime name gVisor registers by default
+const defaultRunscRuntime = "runsc"
+
+// knownRunscOptions lists the runsc flags that can be tuned and the values each
+// accepts. Anything else is rejected rather than passed to the sandbox.
+var knownRunscOptions = map[string][]string{
+	"platform":    {"ptrace", "systrap", "kvm"},
+	"network":     {"sandbox", "host"},
+	"file-access": {"exclusive", "shared"},
+}
+
+// ParseRunscOptions parses comma-separated runsc options such as
+// "platform=kvm,network=host" and checks them against knownRunscOptions.
+func ParseRunscOptions(spec string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("runsc option %q must be name=value", pair)
+		}
+		if _, dup := options[name]; dup {
+			return nil, fmt.Errorf("runsc option %q is set twice", name)
+		}
+		options[name] = value
+	}
+	if err := ValidateRunscOptions(options); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// ValidateRunscOptions checks that every option is a known runsc flag with one of
+// its accepted values.
+func ValidateRunscOptions(options map[string]string) error {
+	for _, name := range sortedKeys(options) {
+		values, ok := knownRunscOptions[name]
+		if !ok {
+			return fmt.Errorf("unknown runsc option %q (known: %s)", name, strings.Join(sortedKeys(knownRunscOptions), ", "))
+		}
+		if !containsString(values, options[name]) {
+			return fmt.Errorf("runsc option %s must be one of %s, got %q", name, strings.Join(values, ", "), options[name])
+		}
+	}
+	return nil
+}
+
+// RunscDefaultOptions returns the runsc options every environment runs with,
+// from RUNSC_DEFAULT_OPTIONS. Empty keeps gVisor's own defaults (ptrace/systrap).
+func RunscDefaultOptions() (map[string]string, error) {
+	options, err := ParseRunscOptions(os.Getenv("RUNSC_DEFAULT_OPTIONS"))
+	if err != nil {
+		return nil, fmt.Errorf("RUNSC_DEFAULT_OPTIONS: %w", err)
+	}
+	return options, nil
+}
+
+// RunscAllowedOptions returns the name=value pairs environments may choose for
+// themselves, from the comma-separated RUNSC_ALLOWED_OPTIONS (e.g.
+// "platform=kvm,platform=ptrace"). Empty allows no per-environment options.
+func RunscAllowedOptions() ([]string, error) {
+	var allowed []string
+	for _, pair := range strings.Split(os.Getenv("RUNSC_ALLOWED_OPTIONS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		if _, err := ParseRunscOptions(pair); err != nil {
+			return nil, fmt.Errorf("RUNSC_ALLOWED_OPTIONS: %w", err)
+		}
+		allowed = append(allowed, pair)
+	}
+	return allowed, nil
+}
+
+// ValidateRunscConfig checks RUNSC_DEFAULT_OPTIONS and RUNSC_ALLOWED_OPTIONS, so
+// a typo fails at startup rather than on the first setup.
+func ValidateRunscConfig() error {
+	if _, err := RunscDefaultOptions(); err != nil {
+		return err
+	}
+	_, err := RunscAllowedOptions()
+	return err
+}
+
+// DisallowedRunscOptions returns the sorted name=value pairs in options that
+// RUNSC_ALLOWED_OPTIONS does not list.
+func DisallowedRunscOptions(options map[string]string) []string {
+	allowed, _ := RunscAllowedOptions()
+	var disallowed []string
+	for _, name := range sortedKeys(options) {
+		if pair := name + "=" + options[name]; !containsString(allowed, pair) {
+			disallowed = append(disallowed, pair)
+		}
+	}
+	return disallowed
+}
+
+// resolveRunscOptions overlays an environment's runsc options on the server's.
+func resolveRunscOptions(envOptions map[string]string) map[string]string {
+	resolved, _ := RunscDefaultOptions()
+	if resolved == nil {
+		resolved = make(map[string]string)
+	}
+	for name, value := range envOptions {
+		resolved[name] = value
+	}
+	return resolved
+}
+
+// runscRuntime returns the docker runtime that runs containers with the given
+// runsc options. Docker cannot pass runsc flags per container, so each option
+// set is a runtime registered in daemon.json, named after its sorted options:
+// {"platform": "kvm"} runs as "runsc-platform-kvm", with runtimeArgs
+// ["--platform=kvm"]. No options means the plain "runsc" runtime.
+func runscRuntime(options map[string]string) string {
+	name := defaultRunscRuntime
+	for _, key := range sortedKeys(options) {
+		name += "-" + key + "-" + options[key]
+	}
+	return name
+}
+
+// RunscRuntimeArgs returns the runsc flags a runtime for options is registered
+// with, in the order runscRuntime names them.
+func RunscRuntimeArgs(options map[string]string) []string {
+	args := make([]string, 0, len(options))
+	for _, key := range sortedKeys(options) {
+		args = append(args, "--"+key+"="+options[key])
+	}
+	return args
+}
+
+// environmentRunscOptions reads the runsc options stored in environment metadata.
+func environmentRunscOptions(metadata map[string]interface{}) map[string]string {
+	stored, ok := metadata["runsc"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	options := make(map[string]string, len(stored))
+	for name, value := range stored {
+		if str, ok := value.(string); ok {
+			options[name] = str
+		}
+	}
+	return options
+}
+
+// checkRuntimeRegistered returns a validation error when the docker daemon has
+// no runtime with the given name.
+func checkRuntimeRegistered(ctx context.Context, runtime string) error {
+	output, err := DockerCommand(ctx, "info", "--format", "{{json .Runtimes}}").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list docker runtimes: %w", err)
+	}
+	var runtimes map[string]json.RawMessage
+	if err := json.Unmarshal(output, &runtimes); err != nil {
+		return fmt.Errorf("failed to parse docker runtimes: %w", err)
+	}
+	if _, ok := runtimes[runtime]; !ok {
+		return &Error{
+			Code:    "validation_error",
+			Message: fmt.Sprintf("docker runtime %q is not registered on this server; add it to daemon.json with runtimeArgs", runtime),
+		}
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRunscOptions(t *testing.T) {
+	options, err := ParseRunscOptions("platform=kvm, network=host")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(options, map[string]string{"platform": "kvm", "network": "host"}) {
+		t.Errorf("unexpected options: %v", options)
+	}
+
+	for _, spec := range []string{"platform", "platform=qemu", "debug=true", "platform=kvm,platform=ptrace"} {
+		if _, err := ParseRunscOptions(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestRunscRuntime(t *testing.T) {
+	if got := runscRuntime(nil); got != "runsc" {
+		t.Errorf("expected the plain runsc runtime without options, got %q", got)
+	}
+
+	t.Setenv("RUNSC_DEFAULT_OPTIONS", "platform=ptrace,network=sandbox")
+	options := resolveRunscOptions(map[string]string{"platform": "kvm"})
+	if got := runscRuntime(options); got != "runsc-network-sandbox-platform-kvm" {
+		t.Errorf("expected the environment's platform over the default, got %q", got)
+	}
+	if args := RunscRuntimeArgs(options); !reflect.DeepEqual(args, []string{"--network=sandbox", "--platform=kvm"}) {
+		t.Errorf("unexpected runtime args: %v", args)
+	}
+}
+
+func TestDisallowedRunscOptions(t *testing.T) {
+	t.Setenv("RUNSC_ALLOWED_OPTIONS", "platform=kvm,platform=ptrace")
+	if err := ValidateRunscConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := DisallowedRunscOptions(map[string]string{"platform": "kvm", "network": "host"})
+	if !reflect.DeepEqual(got, []string{"network=host"}) {
+		t.Errorf("expected only network=host to be disallowed, got %v", got)
+	}
+
+	t.Setenv("RUNSC_ALLOWED_OPTIONS", "platform=warp")
+	if err := ValidateRunscConfig(); err == nil {
+		t.Error("expected an unknown allowed option to fail validation")
+	}
+}
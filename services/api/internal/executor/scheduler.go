@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errQueueTimeout is returned when no slot frees up before the deadline.
+var errQueueTimeout = errors.New("timed out waiting for an execution slot")
+
+// execWaiter is an execution queued for a slot. granted is closed once it
+// holds one.
+type execWaiter struct {
+	high     bool
+	enqueued time.Time
+	granted  chan struct{}
+}
+
+// execScheduler hands out execution slots, queueing executions by priority
+// when none are free.
+//
+// Waiting executions age: a waiter's effective priority is its class's base
+// priority plus the time it has waited, with high priority starting
+// EXEC_PRIORITY_AGING_MS ahead of normal. A normal-priority execution that has
+// waited that long is therefore served before any high-priority execution that
+// arrived after it, so sustained high-priority load can't starve it. Within a
+// class waiters age alike, so each class is served first-come first-served.
+type execScheduler struct {
+	mu sync.Mutex
+
+	slots int
+	// normalSlots caps the slots normal priority may hold, keeping the rest
+	// for high priority. Equal to slots when none are reserved.
+	normalSlots int
+	inUse       int
+	normalInUse int
+
+	// Waiters per class, in arrival order
+	high, normal []*execWaiter
+
+	// maxWait is the longest any execution has waited for a slot
+	maxWait time.Duration
+}
+
+// newExecScheduler creates a scheduler with the given number of slots,
+// reserving reservedPercent of them for high priority while always leaving
+// normal priority at least one.
+func newExecScheduler(slots, reservedPercent int) *execScheduler {
+	reserved := slots * reservedPercent / 100
+	if reserved < 0 {
+		reserved = 0
+	}
+	if reserved >= slots {
+		reserved = slots - 1
+	}
+	return &execScheduler{slots: slots, normalSlots: slots - reserved}
+}
+
+// acquire waits until deadline for a slot, returning a func that releases it.
+// The returned depth is the number of executions waiting when this one joined
+// the queue, itself included, or 0 when a slot was free straight away.
+func (s *execScheduler) acquire(ctx context.Context, high bool, deadline time.Time) (release func(), depth int, err error) {
+	release = func() { s.release(high) }
+	w := &execWaiter{high: high, enqueued: time.Now(), granted: make(chan struct{})}
+
+	s.mu.Lock()
+	if high {
+		s.high = append(s.high, w)
+	} else {
+		s.normal = append(s.normal, w)
+	}
+	s.dispatchLocked()
+	depth = len(s.high) + len(s.normal)
+	s.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return release, 0, nil
+	default:
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case <-w.granted:
+		return release, depth, nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.removeLocked(w) {
+		// Granted a slot as we gave up; keep it
+		return release, depth, nil
+	}
+	return nil, depth, err
+}
+
+// release frees a slot and hands it to the next waiter, if any.
+func (s *execScheduler) release(high bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inUse--
+	if !high {
+		s.normalInUse--
+	}
+	s.dispatchLocked()
+}
+
+// dispatchLocked grants free slots to waiters in effective priority order.
+// Normal-priority waiters are passed over while normal priority holds all of
+// its slots, leaving the reserved ones to high priority.
+func (s *execScheduler) dispatchLocked() {
+	now := time.Now()
+	aging := PriorityAging()
+	for s.inUse < s.slots {
+		normalOK := len(s.normal) > 0 && s.normalInUse < s.normalSlots
+
+		var w *execWaiter
+		switch {
+		case len(s.high) > 0 && (!normalOK || !s.normal[0].enqueued.Add(aging).Before(s.high[0].enqueued)):
+			w, s.high = s.high[0], s.high[1:]
+		case normalOK:
+			w, s.normal = s.normal[0], s.normal[1:]
+			s.normalInUse++
+		default:
+			return
+		}
+
+		s.inUse++
+		if wait := now.Sub(w.enqueued); wait > s.maxWait {
+			s.maxWait = wait
+		}
+		close(w.granted)
+	}
+}
+
+// removeLocked takes a waiter out of its queue, reporting false if it was no
+// longer queued because it had been granted a slot.
+func (s *execScheduler) removeLocked(w *execWaiter) bool {
+	queue := &s.normal
+	if w.high {
+		queue = &s.high
+	}
+	for i, queued := range *queue {
+		if queued == w {
+			*queue = append((*queue)[:i], (*queue)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SchedulerStatus is a snapshot of execution slot usage and queueing.
+type SchedulerStatus struct {
+	Slots        int   `json:"slots"`
+	InUse        int   `json:"inUse"`
+	Waiting      int   `json:"waiting"`
+	OldestWaitMs int64 `json:"oldestWaitMs"`
+	MaxWaitMs    int64 `json:"maxWaitMs"`
+}
+
+// status reports slot usage, how long the longest-waiting execution has been
+// queued, and the longest wait for a slot since startup.
+func (s *execScheduler) status() SchedulerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	status := SchedulerStatus{
+		Slots:     s.slots,
+		InUse:     s.inUse,
+		Waiting:   len(s.high) + len(s.normal),
+		MaxWaitMs: s.maxWait.Milliseconds(),
+	}
+	for _, queue := range [][]*execWaiter{s.high, s.normal} {
+		if len(queue) > 0 {
+			if wait := now.Sub(queue[0].enqueued).Milliseconds(); wait > status.OldestWaitMs {
+				status.OldestWaitMs = wait
+			}
+		}
+	}
+	return status
+}
+
+// GetSchedulerStatus reports execution slot usage and queue wait times.
+func GetSchedulerStatus() SchedulerStatus {
+	return execSlots.status()
+}
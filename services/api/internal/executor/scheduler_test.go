@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// queueBehind acquires a slot from s in the background, sending the waiter's
+// priority on granted once it holds one
+func queueBehind(t *testing.T, s *execScheduler, high bool, granted chan<- bool) {
+	t.Helper()
+	go func() {
+		release, _, err := s.acquire(context.Background(), high, time.Now().Add(5*time.Second))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		granted <- high
+		release()
+	}()
+}
+
+// waitForQueued waits until s has n executions waiting
+func waitForQueued(t *testing.T, s *execScheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if s.status().Waiting == n {
+			return
+		}
+	}
+	t.Fatalf("expected %d waiting executions", n)
+}
+
+func TestExecScheduler_HighPriorityFirst(t *testing.T) {
+	t.Setenv("EXEC_PRIORITY_AGING_MS", "60000")
+	s := newExecScheduler(1, 0)
+	release, _, err := s.acquire(context.Background(), false, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	granted := make(chan bool, 2)
+	queueBehind(t, s, false, granted)
+	waitForQueued(t, s, 1)
+	queueBehind(t, s, true, granted)
+	waitForQueued(t, s, 2)
+	release()
+
+	if !<-granted {
+		t.Error("expected the high-priority execution to be served first")
+	}
+	<-granted
+}
+
+func TestExecScheduler_AgingPreventsStarvation(t *testing.T) {
+	t.Setenv("EXEC_PRIORITY_AGING_MS", "20")
+	s := newExecScheduler(1, 0)
+	release, _, err := s.acquire(context.Background(), false, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	granted := make(chan bool, 2)
+	queueBehind(t, s, false, granted)
+	waitForQueued(t, s, 1)
+	time.Sleep(40 * time.Millisecond)
+	queueBehind(t, s, true, granted)
+	waitForQueued(t, s, 2)
+	release()
+
+	if <-granted {
+		t.Error("expected the long-waiting normal-priority execution to be served first")
+	}
+	<-granted
+
+	if status := s.status(); status.MaxWaitMs < 40 {
+		t.Errorf("expected max wait of at least 40ms, got %dms", status.MaxWaitMs)
+	}
+}
+
+func TestExecScheduler_TimeoutLeavesQueue(t *testing.T) {
+	s := newExecScheduler(1, 0)
+	release, _, err := s.acquire(context.Background(), false, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, depth, err := s.acquire(context.Background(), true, time.Now().Add(10*time.Millisecond)); err != errQueueTimeout || depth != 1 {
+		t.Fatalf("expected a queue timeout at depth 1, got %v at depth %d", err, depth)
+	}
+	if status := s.status(); status.Waiting != 0 || status.InUse != 1 {
+		t.Errorf("expected the timed-out waiter removed, got %+v", status)
+	}
+	release()
+	if status := s.status(); status.InUse != 0 {
+		t.Errorf("expected the slot freed, got %+v", status)
+	}
+}
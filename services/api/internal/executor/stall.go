@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// errExecutionStalled is the cancellation cause for executions stopped for
+// producing no output for ExecStallTimeout.
+var errExecutionStalled = errors.New("execution stalled")
+
+// ExecStallTimeout returns how long a running execution may go without writing
+// to stdout or stderr before it is killed, from EXEC_STALL_TIMEOUT_MS. 0 (the
+// default) disables stall detection, since some handlers are legitimately silent.
+func ExecStallTimeout() time.Duration {
+	return time.Duration(getEnvInt("EXEC_STALL_TIMEOUT_MS", 0)) * time.Millisecond
+}
+
+// activityWriter records when output was last written through it.
+type activityWriter struct {
+	last atomic.Int64 // unix nanoseconds
+}
+
+func newActivityWriter(now time.Time) *activityWriter {
+	w := &activityWriter{}
+	w.last.Store(now.UnixNano())
+	return w
+}
+
+func (w *activityWriter) Write(p []byte) (int, error) {
+	w.last.Store(time.Now().UnixNano())
+	return len(p), nil
+}
+
+// idle returns how long it has been since the last write.
+func (w *activityWriter) idle(now time.Time) time.Duration {
+	return now.Sub(time.Unix(0, w.last.Load()))
+}
+
+// watchStall calls stop with errExecutionStalled once activity has been idle
+// for timeout. It returns when ctx is done.
+func watchStall(ctx context.Context, activity *activityWriter, timeout time.Duration, stop context.CancelCauseFunc) {
+	interval := timeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if activity.idle(now) >= timeout {
+				stop(errExecutionStalled)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatchStall_StopsSilentExecution(t *testing.T) {
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+
+	activity := newActivityWriter(time.Now())
+	go watchStall(ctx, activity, 40*time.Millisecond, stop)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected a silent execution to be stopped")
+	}
+	if cause := context.Cause(ctx); cause != errExecutionStalled {
+		t.Errorf("expected cause %v, got %v", errExecutionStalled, cause)
+	}
+}
+
+func TestWatchStall_OutputKeepsExecutionAlive(t *testing.T) {
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+
+	activity := newActivityWriter(time.Now())
+	go watchStall(ctx, activity, 60*time.Millisecond, stop)
+
+	for i := 0; i < 10; i++ {
+		time.Sleep(15 * time.Millisecond)
+		activity.Write([]byte("."))
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected an execution writing output to keep running, got %v", context.Cause(ctx))
+	}
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// statsSampleInterval is the pause between docker stats samples. Each
+// `docker stats --no-stream` call itself takes about a second.
+const statsSampleInterval = 200 * time.Millisecond
+
+// statsSampler polls docker stats for a running container and keeps the peak
+// memory and an estimate of CPU time.
+type statsSampler struct {
+	mu           sync.Mutex
+	peakMemBytes float64
+	cpuMs        float64
+	samples      int
+	lastSample   time.Time
+	done         chan struct{}
+}
+
+// startStatsSampler samples the named container until ctx is done. The container
+// may not exist yet or may already be gone; failed samples are skipped.
+func startStatsSampler(ctx context.Context, name string) *statsSampler {
+	s := &statsSampler{done: make(chan struct{}), lastSample: time.Now()}
+	go func() {
+		defer close(s.done)
+		for {
+			output, err := DockerCommand(ctx, "stats", "--no-stream", "--format", "{{.MemUsage}}|{{.CPUPerc}}", name).Output()
+			if err == nil {
+				s.record(string(output), time.Now())
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(statsSampleInterval):
+			}
+		}
+	}()
+	return s
+}
+
+// record folds one "used / limit|cpu%" sample into the totals. CPU time is
+// estimated by integrating the CPU percentage over the time since the last sample.
+func (s *statsSampler) record(line string, now time.Time) {
+	memPart, cpuPart, ok := strings.Cut(strings.TrimSpace(line), "|")
+	if !ok {
+		return
+	}
+	used, _, _ := strings.Cut(memPart, "/")
+	memBytes, err := parseByteSize(strings.TrimSpace(used))
+	if err != nil {
+		return
+	}
+	cpuPercent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(cpuPart), "%"), 64)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if memBytes > s.peakMemBytes {
+		s.peakMemBytes = memBytes
+	}
+	s.cpuMs += cpuPercent / 100 * float64(now.Sub(s.lastSample).Milliseconds())
+	s.lastSample = now
+	s.samples++
+}
+
+// stop waits for the sampler to finish and returns the collected usage, or nil
+// if the container exited before any sample was taken.
+func (s *statsSampler) stop() *models.ResourceUsage {
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.samples == 0 {
+		return nil
+	}
+	return &models.ResourceUsage{
+		PeakMemoryMb: s.peakMemBytes / (1024 * 1024),
+		CPUTimeMs:    int64(s.cpuMs),
+		Samples:      s.samples,
+	}
+}
+
+// byteUnits maps docker's size suffixes to multipliers, longest suffix first.
+var byteUnits = []struct {
+	suffix     string
+	multiplier float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseByteSize parses a docker size such as "12.5MiB" into bytes.
+func parseByteSize(value string) (float64, error) {
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64)
+			if err != nil {
+				return 0, err
+			}
+			return n * unit.multiplier, nil
+		}
+	}
+	return 0, fmt.Errorf("unrecognized size %q", value)
+}
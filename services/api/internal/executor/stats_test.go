@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]float64{
+		"512B":    512,
+		"1.5KiB":  1536,
+		"12MiB":   12 << 20,
+		"2GiB":    2 << 30,
+		"100kB":   100e3,
+		"3.2MB":   3.2e6,
+		"0B":      0,
+		"64.5MiB": 64.5 * (1 << 20),
+	}
+	for input, want := range cases {
+		got, err := parseByteSize(input)
+		if err != nil {
+			t.Errorf("parseByteSize(%q) returned error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseByteSize(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	if _, err := parseByteSize("lots"); err == nil {
+		t.Error("expected error for unrecognized size")
+	}
+}
+
+func TestStatsSampler_Record(t *testing.T) {
+	start := time.Now()
+	s := &statsSampler{done: make(chan struct{}), lastSample: start}
+
+	s.record("10MiB / 128MiB|50.00%\n", start.Add(time.Second))
+	s.record("32MiB / 128MiB|100.00%", start.Add(2*time.Second))
+	s.record("20MiB / 128MiB|0.00%", start.Add(3*time.Second))
+	s.record("garbage", start.Add(4*time.Second))
+	close(s.done)
+
+	usage := s.stop()
+	if usage == nil {
+		t.Fatal("expected usage after samples")
+	}
+	if usage.PeakMemoryMb != 32 {
+		t.Errorf("expected peak memory 32MB, got %v", usage.PeakMemoryMb)
+	}
+	if usage.CPUTimeMs != 1500 {
+		t.Errorf("expected 1500ms CPU time, got %d", usage.CPUTimeMs)
+	}
+	if usage.Samples != 3 {
+		t.Errorf("expected 3 samples, got %d", usage.Samples)
+	}
+}
+
+func TestStatsSampler_NoSamples(t *testing.T) {
+	s := &statsSampler{done: make(chan struct{})}
+	close(s.done)
+	if usage := s.stop(); usage != nil {
+		t.Errorf("expected nil usage when the container exited before sampling, got %+v", usage)
+	}
+}
@@ -0,0 +1,35 @@
+package executor
+
+import (
+	"errors"
+	"io"
+)
+
+// errInputTooLarge is returned by limitedInput once the stream exceeds its limit
+var errInputTooLarge = errors.New("streamed input exceeds maximum size")
+
+// limitedInput passes through at most limit bytes and then fails, recording that
+// the limit was hit so the caller can report it after the container exits.
+type limitedInput struct {
+	r        io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (l *limitedInput) Read(p []byte) (int, error) {
+	if l.read > l.limit {
+		l.exceeded = true
+		return 0, errInputTooLarge
+	}
+	if remaining := l.limit - l.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		l.exceeded = true
+		return n - int(l.read-l.limit), errInputTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLimitedInput_WithinLimit(t *testing.T) {
+	in := &limitedInput{r: strings.NewReader("hello"), limit: 5}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected 'hello', got %q", data)
+	}
+	if in.exceeded {
+		t.Error("expected exceeded to be false")
+	}
+}
+
+func TestLimitedInput_ExceedsLimit(t *testing.T) {
+	in := &limitedInput{r: strings.NewReader("hello world"), limit: 5}
+
+	data, err := io.ReadAll(in)
+	if !errors.Is(err, errInputTooLarge) {
+		t.Fatalf("expected errInputTooLarge, got %v", err)
+	}
+	if len(data) > 5 {
+		t.Errorf("expected at most 5 bytes to pass through, got %d", len(data))
+	}
+	if !in.exceeded {
+		t.Error("expected exceeded to be true")
+	}
+}
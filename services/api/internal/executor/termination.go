@@ -0,0 +1,35 @@
+package executor
+
+import "fmt"
+
+// signalNames names the signals a handler's container is commonly terminated by.
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	6:  "SIGABRT",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	15: "SIGTERM",
+}
+
+// describeExit explains a container's exit code. Codes above 128 follow the
+// shell convention of 128+signal. The only thing that SIGKILLs a container we
+// did not stop ourselves is the kernel's OOM killer, so SIGKILL is reported as
+// out of memory.
+func describeExit(exitCode, memoryMb int) (signal, reason string) {
+	if exitCode <= 128 || exitCode > 128+64 {
+		return "", fmt.Sprintf("exited with code %d", exitCode)
+	}
+
+	sig := exitCode - 128
+	signal, ok := signalNames[sig]
+	if !ok {
+		signal = fmt.Sprintf("SIG%d", sig)
+	}
+	if sig == 9 {
+		return signal, fmt.Sprintf("killed by SIGKILL, most likely out of memory (limit %d MB)", memoryMb)
+	}
+	return signal, "killed by " + signal
+}
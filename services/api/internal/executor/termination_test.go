@@ -0,0 +1,25 @@
+package executor
+
+import "testing"
+
+func TestDescribeExit(t *testing.T) {
+	cases := []struct {
+		exitCode int
+		signal   string
+		reason   string
+	}{
+		{0, "", "exited with code 0"},
+		{2, "", "exited with code 2"},
+		{137, "SIGKILL", "killed by SIGKILL, most likely out of memory (limit 128 MB)"},
+		{139, "SIGSEGV", "killed by SIGSEGV"},
+		{143, "SIGTERM", "killed by SIGTERM"},
+		{162, "SIG34", "killed by SIG34"},
+		{255, "", "exited with code 255"},
+	}
+	for _, c := range cases {
+		signal, reason := describeExit(c.exitCode, 128)
+		if signal != c.signal || reason != c.reason {
+			t.Errorf("exit %d: expected %q/%q, got %q/%q", c.exitCode, c.signal, c.reason, signal, reason)
+		}
+	}
+}
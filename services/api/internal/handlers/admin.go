@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/reaper"
+)
+
+// AdminHealthResponse reports the health of background processes and the
+// execution queue
+type AdminHealthResponse struct {
+	Status     string                   `json:"status"`
+	Reaper     reaper.Status            `json:"reaper"`
+	Executions executor.SchedulerStatus `json:"executions"`
+}
+
+// HandleAdminHealth reports background process health, returning 503 when the
+// reaper has stalled so monitoring can alert on it
+func (s *Server) HandleAdminHealth(w http.ResponseWriter, r *http.Request) {
+	resp := AdminHealthResponse{
+		Status:     "ok",
+		Reaper:     reaper.GetStatus(),
+		Executions: executor.GetSchedulerStatus(),
+	}
+
+	status := http.StatusOK
+	if resp.Reaper.Stalled {
+		resp.Status = "degraded"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/middleware"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// AuditRecorder appends entries to the audit log
+type AuditRecorder interface {
+	RecordAudit(ctx context.Context, entry *models.AuditEntry) error
+}
+
+// auditTargetKey is the context key for the environment an audited handler acted on
+type auditTargetKey struct{}
+
+// setAuditEnvironment records the environment an audited request acted on, for
+// handlers like setup whose target is not in the URL
+func setAuditEnvironment(ctx context.Context, envID uuid.UUID) {
+	if target, ok := ctx.Value(auditTargetKey{}).(*uuid.UUID); ok {
+		*target = envID
+	}
+}
+
+// auditFailedKey is the context key for a handler's override of the audited outcome
+type auditFailedKey struct{}
+
+// setAuditFailed marks an audited request as failed, for streaming handlers that
+// report errors in-band after a 200 status has been sent
+func setAuditFailed(ctx context.Context) {
+	if failed, ok := ctx.Value(auditFailedKey{}).(*bool); ok {
+		*failed = true
+	}
+}
+
+// auditStatusWriter captures the status code written by an audited handler
+type auditStatusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *auditStatusWriter) WriteHeader(code int) {
+	w.statusCode = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (w *auditStatusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *auditStatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Audited wraps a mutating handler so every call is recorded in the audit log
+// with the caller, target environment and outcome. It is a no-op when the
+// server has no AuditRecorder.
+func (s *Server) Audited(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Audit == nil {
+			next(w, r)
+			return
+		}
+
+		var target uuid.UUID
+		if id, err := uuid.Parse(mux.Vars(r)["id"]); err == nil {
+			target = id
+		}
+		var failed bool
+		ctx := context.WithValue(r.Context(), auditTargetKey{}, &target)
+		ctx = context.WithValue(ctx, auditFailedKey{}, &failed)
+
+		sw := &auditStatusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next(sw, r.WithContext(ctx))
+
+		entry := &models.AuditEntry{
+			Actor:      middleware.Principal(ctx),
+			Action:     action,
+			Outcome:    "success",
+			StatusCode: sw.statusCode,
+			RequestID:  logger.GetRequestID(ctx),
+		}
+		if target != uuid.Nil {
+			entry.EnvironmentID = &target
+		}
+		if sw.statusCode >= 400 || failed {
+			entry.Outcome = "failure"
+		}
+
+		// The audit record must be written even if the client has gone away
+		if err := s.Audit.RecordAudit(context.WithoutCancel(ctx), entry); err != nil {
+			logger.FromContext(ctx).Error("failed to write audit log entry",
+				slog.String("action", action),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+}
+
+func (s *Server) HandleListAudit(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+	query := r.URL.Query()
+
+	filter := database.AuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Limit:  100,
+	}
+
+	if raw := query.Get("environmentId"); raw != "" {
+		envID, err := uuid.Parse(raw)
+		if err != nil {
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "environmentId must be a UUID")
+			return
+		}
+		filter.EnvironmentID = &envID
+	}
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeErrorWithCode(w, http.StatusBadRequest, "validation_error", name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*dest = t
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "limit must be between 1 and 1000")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := database.ListAudit(ctx, filter)
+	if err != nil {
+		log.Error("failed to query audit log",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/middleware"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+type recordingAudit struct {
+	entries []*models.AuditEntry
+}
+
+func (a *recordingAudit) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	a.entries = append(a.entries, entry)
+	return nil
+}
+
+func TestAudited_RecordsDelete(t *testing.T) {
+	audit := &recordingAudit{}
+	server := NewServer(executor.NewMockExecutor())
+	server.Audit = audit
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodDelete, "/environments/"+envID.String(), nil)
+	req = req.WithContext(middleware.WithPrincipal(req.Context(), "ops"))
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.Audited("delete", server.HandleDelete)(rec, req)
+
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+	}
+	entry := audit.entries[0]
+	if entry.Actor != "ops" || entry.Action != "delete" || entry.Outcome != "success" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+	if entry.EnvironmentID == nil || *entry.EnvironmentID != envID {
+		t.Errorf("expected environment ID %s, got %v", envID, entry.EnvironmentID)
+	}
+}
+
+func TestAudited_RecordsFailure(t *testing.T) {
+	audit := &recordingAudit{}
+	server := NewServer(executor.NewMockExecutor())
+	server.Audit = audit
+
+	req := httptest.NewRequest(http.MethodDelete, "/environments/not-a-uuid", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "not-a-uuid"})
+
+	server.Audited("delete", server.HandleDelete)(httptest.NewRecorder(), req)
+
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+	}
+	if audit.entries[0].Outcome != "failure" || audit.entries[0].StatusCode != http.StatusBadRequest {
+		t.Errorf("expected failure with status 400, got %+v", audit.entries[0])
+	}
+	if audit.entries[0].EnvironmentID != nil {
+		t.Error("expected no environment ID for an invalid ID")
+	}
+}
+
+func TestAudited_RecordsStreamedSetupFailure(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.SetupFunc = func(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
+		req.Progress(models.SetupProgress{Stage: models.SetupStageVolumeCreated})
+		return nil, &executor.Error{Code: "image_pull_failed", Message: "failed to pull busybox:latest"}
+	}
+	audit := &recordingAudit{}
+	server := NewServer(mock)
+	server.Audit = audit
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup?stream=true", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.Audited("setup", server.HandleSetup)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the stream to have started with status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(audit.entries))
+	}
+	if audit.entries[0].Outcome != "failure" {
+		t.Errorf("expected a failed streamed setup to be audited as a failure, got %+v", audit.entries[0])
+	}
+}
+
+func TestAudited_StreamsThroughCompress(t *testing.T) {
+	server := NewServer(executor.NewMockExecutor())
+	server.Audit = &recordingAudit{}
+
+	rec := httptest.NewRecorder()
+	streamed := false
+	handler := middleware.Compress(server.Audited("execute", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"type\":\"record\"}\n"))
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected an audited writer to be flushable")
+		}
+		flusher.Flush()
+		streamed = rec.Flushed && rec.Body.Len() > 0
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+uuid.New().String()+"/execute", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, req)
+
+	if !streamed {
+		t.Error("expected the line to reach the client before the handler returned")
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected a streamed response to be left uncompressed, got %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// defaultBatchConcurrency is how many batch items run at once when the request
+// does not say
+const defaultBatchConcurrency = 4
+
+// batchItemLine is one NDJSON line of a batch response, written as the item
+// completes. Exactly one of the embedded response or Error is set.
+type batchItemLine struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	*models.ExecutionResponse
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// batchSummaryLine is the final NDJSON line of a batch response. An item
+// succeeded when it ran and exited 0.
+type batchSummaryLine struct {
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+}
+
+// HandleBatchExecute fans a batch of executions out against one environment and
+// streams each item's result as an NDJSON line, tagged with its index, as soon
+// as it completes. A summary line with success and failure counts ends the
+// stream.
+func (s *Server) HandleBatchExecute(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	if s.maintenance.blocks(envID) {
+		log.Warn("batch refused: maintenance mode",
+			slog.String("environment_id", envID.String()),
+		)
+		writeErrorWithCode(w, http.StatusServiceUnavailable, "maintenance", "Executions are paused for maintenance")
+		return
+	}
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var batch models.BatchExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		log.Warn("failed to decode batch request",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err := validateBatch(ctx, &batch); err != nil {
+		log.Warn("validation failed: invalid batch",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	concurrency := batch.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency > len(batch.Items) {
+		concurrency = len(batch.Items)
+	}
+
+	log.Info("batch request received",
+		slog.String("environment_id", envID.String()),
+		slog.Int("items", len(batch.Items)),
+		slog.Int("concurrency", concurrency),
+	)
+
+	out := &ndjsonWriter{w: w}
+	var mu sync.Mutex
+	summary := batchSummaryLine{Type: "summary", Total: len(batch.Items)}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				line := batchItemLine{Type: "result", Index: i}
+				resp, err := s.Executor.ExecuteInEnvironment(ctx, envID, &batch.Items[i])
+				if err != nil {
+					line.Type = "error"
+					line.Error = err.Error()
+					line.Code = executorErrorCode(err, "execution_failed")
+				} else {
+					line.ExecutionResponse = resp
+				}
+
+				mu.Lock()
+				if err == nil && resp.ExitCode == 0 {
+					summary.Succeeded++
+				} else {
+					summary.Failed++
+				}
+				out.writeLine(line)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range batch.Items {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	log.Info("batch completed",
+		slog.String("environment_id", envID.String()),
+		slog.Int("succeeded", summary.Succeeded),
+		slog.Int("failed", summary.Failed),
+	)
+	out.writeLine(summary)
+}
+
+// validateBatch checks a batch's size and concurrency, and each item the way
+// HandleExecute checks a single request
+func validateBatch(ctx context.Context, batch *models.BatchExecuteRequest) error {
+	if len(batch.Items) == 0 {
+		return fmt.Errorf("items cannot be empty")
+	}
+	if max := maxBatchItems(); len(batch.Items) > max {
+		return fmt.Errorf("a batch may hold at most %d items (MAX_BATCH_ITEMS)", max)
+	}
+	if batch.Concurrency < 0 {
+		return fmt.Errorf("concurrency cannot be negative")
+	}
+	if max := maxBatchConcurrency(); batch.Concurrency > max {
+		return fmt.Errorf("concurrency may be at most %d (MAX_BATCH_CONCURRENCY)", max)
+	}
+
+	for i := range batch.Items {
+		if err := validateBatchItem(ctx, &batch.Items[i]); err != nil {
+			return fmt.Errorf("items[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validateBatchItem checks an item like a single execute request. Its result
+// becomes an NDJSON line, so the raw envelope is not available.
+func validateBatchItem(ctx context.Context, item *models.ExecuteRequest) error {
+	if item.Envelope == models.EnvelopeRaw {
+		return fmt.Errorf("envelope 'raw' is not supported in a batch")
+	}
+	if item.CallbackURL != "" {
+		return fmt.Errorf("callbackUrl is not supported in a batch")
+	}
+	return validateExecuteRequest(ctx, item)
+}
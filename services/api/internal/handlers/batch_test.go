@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func batchRequest(server *Server, envID uuid.UUID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/batch", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+	server.HandleBatchExecute(rec, req)
+	return rec
+}
+
+func TestHandleBatchExecute(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		switch req.Args[0] {
+		case "fail":
+			return &models.ExecutionResponse{ID: uuid.New(), ExitCode: 1}, nil
+		case "error":
+			return nil, &executor.Error{Code: "environment_busy", Message: "busy"}
+		}
+		return &models.ExecutionResponse{ID: uuid.New(), Stdout: req.Args[0]}, nil
+	}
+	server := NewServer(mock)
+
+	rec := batchRequest(server, uuid.New(), `{"concurrency":2,"items":[
+		{"args":["a"]},{"args":["fail"]},{"args":["error"]},{"args":["b"]}]}`)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected NDJSON content type, got %q", ct)
+	}
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 5 {
+		t.Fatalf("expected 4 item lines and a summary, got %d", len(lines))
+	}
+
+	seen := map[int]map[string]interface{}{}
+	for _, line := range lines[:4] {
+		seen[int(line["index"].(float64))] = line
+	}
+	if seen[0]["type"] != "result" || seen[0]["stdout"] != "a" {
+		t.Errorf("unexpected line for item 0: %v", seen[0])
+	}
+	if seen[1]["type"] != "result" || seen[1]["exitCode"] != float64(1) {
+		t.Errorf("unexpected line for item 1: %v", seen[1])
+	}
+	if seen[2]["type"] != "error" || seen[2]["code"] != "environment_busy" {
+		t.Errorf("unexpected line for item 2: %v", seen[2])
+	}
+
+	summary := lines[4]
+	if summary["type"] != "summary" || summary["total"] != float64(4) ||
+		summary["succeeded"] != float64(2) || summary["failed"] != float64(2) {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+	if len(mock.ExecuteCalls) != 4 {
+		t.Errorf("expected 4 execute calls, got %d", len(mock.ExecuteCalls))
+	}
+}
+
+func TestHandleBatchExecute_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", `{"items":[]}`, "items cannot be empty"},
+		{"negative concurrency", `{"items":[{}],"concurrency":-1}`, "concurrency"},
+		{"concurrency above max", `{"items":[{}],"concurrency":1000}`, "MAX_BATCH_CONCURRENCY"},
+		{"invalid item", `{"items":[{},{"envelope":"bogus"}]}`, "items[1]"},
+		{"negative sourceDateEpoch", `{"items":[{"reproducible":true,"sourceDateEpoch":-1}]}`, "sourceDateEpoch cannot be negative"},
+		{"raw envelope", `{"items":[{"envelope":"raw"}]}`, "not supported in a batch"},
+		{"callback url", `{"items":[{"callbackUrl":"https://93.184.216.34/cb"}]}`, "callbackUrl is not supported in a batch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := executor.NewMockExecutor()
+			server := NewServer(mock)
+
+			rec := batchRequest(server, uuid.New(), tt.body)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			var resp ErrorResponse
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if resp.Code != "validation_error" || !strings.Contains(resp.Error, tt.want) {
+				t.Errorf("expected validation error mentioning %q, got %+v", tt.want, resp)
+			}
+			if len(mock.ExecuteCalls) != 0 {
+				t.Errorf("expected no executions, got %d", len(mock.ExecuteCalls))
+			}
+		})
+	}
+}
+
+func TestHandleBatchExecute_TooManyItems(t *testing.T) {
+	t.Setenv("MAX_BATCH_ITEMS", "2")
+	server := NewServer(executor.NewMockExecutor())
+
+	rec := batchRequest(server, uuid.New(), `{"items":[{},{},{}]}`)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+func (s *Server) HandleCancelExecution(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+	execID, err := uuid.Parse(vars["execId"])
+	if err != nil {
+		log.Warn("invalid execution ID",
+			slog.String("id", vars["execId"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid execution ID")
+		return
+	}
+
+	log.Info("cancel execution request received",
+		slog.String("environment_id", envID.String()),
+		slog.String("execution_id", execID.String()),
+	)
+
+	if err := s.Executor.CancelExecution(ctx, envID, execID); err != nil {
+		log.Warn("execution cancellation failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("execution_id", execID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeExecutorError(w, err, "cancel_failed")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+)
+
+func TestHandleCancelExecution_Success(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID, execID := uuid.New(), uuid.New()
+	req := httptest.NewRequest(http.MethodDelete, "/environments/"+envID.String()+"/executions/"+execID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String(), "execId": execID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleCancelExecution(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if len(mock.CancelCalls) != 1 || mock.CancelCalls[0].ExecID != execID || mock.CancelCalls[0].EnvID != envID {
+		t.Errorf("expected cancel call for %s/%s, got %+v", envID, execID, mock.CancelCalls)
+	}
+}
+
+func TestHandleCancelExecution_NotRunning(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.CancelFunc = func(ctx context.Context, envID, execID uuid.UUID) error {
+		return executor.ErrExecutionNotRunning
+	}
+	server := NewServer(mock)
+
+	envID, execID := uuid.New(), uuid.New()
+	req := httptest.NewRequest(http.MethodDelete, "/environments/"+envID.String()+"/executions/"+execID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String(), "execId": execID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleCancelExecution(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Code != "not_found" {
+		t.Errorf("expected code 'not_found', got '%s'", resp.Code)
+	}
+}
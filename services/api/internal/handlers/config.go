@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// getEnvInt returns the positive integer value of an environment variable, or defaultValue
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// maxModulesPerEnv returns the maximum number of modules a single environment may hold
+func maxModulesPerEnv() int {
+	return getEnvInt("MAX_MODULES_PER_ENV", 500)
+}
+
+// maxDependencies returns the maximum number of npm and deno dependencies a
+// single environment may install
+func maxDependencies() int {
+	return getEnvInt("MAX_DEPENDENCIES", 100)
+}
+
+// maxEnvValueBytes returns the largest value an execute-time env var may have
+func maxEnvValueBytes() int {
+	return getEnvInt("MAX_ENV_VALUE_BYTES", 32<<10)
+}
+
+// maxEnvTotalBytes returns the largest combined size of an execute request's env
+// var names and values
+func maxEnvTotalBytes() int {
+	return getEnvInt("MAX_ENV_TOTAL_BYTES", 256<<10)
+}
+
+// maxBatchItems returns the maximum number of executions in one batch request
+func maxBatchItems() int {
+	return getEnvInt("MAX_BATCH_ITEMS", 100)
+}
+
+// maxBatchConcurrency returns how many of a batch's executions may run at once
+func maxBatchConcurrency() int {
+	return getEnvInt("MAX_BATCH_CONCURRENCY", 16)
+}
+
+// highPriorityAllowed reports whether the caller may submit high-priority
+// executions. HIGH_PRIORITY_PRINCIPALS lists the bearer token labels allowed to;
+// when unset, any authenticated caller may.
+func highPriorityAllowed(principal string) bool {
+	if principal == "" || principal == "anonymous" {
+		return false
+	}
+	allowed := os.Getenv("HIGH_PRIORITY_PRINCIPALS")
+	if allowed == "" {
+		return true
+	}
+	for _, p := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(p) == principal {
+			return true
+		}
+	}
+	return false
+}
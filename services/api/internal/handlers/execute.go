@@ -1,13 +1,25 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/jsonpath"
 	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/middleware"
 	"github.com/jsfour/assist-tee/internal/models"
 )
 
@@ -26,8 +38,30 @@ func (s *Server) HandleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.maintenance.blocks(envID) {
+		log.Warn("execution refused: maintenance mode",
+			slog.String("environment_id", envID.String()),
+		)
+		writeErrorWithCode(w, http.StatusServiceUnavailable, "maintenance", "Executions are paused for maintenance")
+		return
+	}
+
+	if !requireContentType(w, r, "application/json", "application/octet-stream") {
+		return
+	}
+
 	var req models.ExecuteRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if isStreamedInput(r) {
+		// Streamed input: a single-line JSON header followed by the raw data
+		if err := decodeStreamedRequest(r.Body, &req); err != nil {
+			log.Warn("failed to decode streamed execute request",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+			return
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Warn("failed to decode execute request",
 			slog.String("environment_id", envID.String()),
 			slog.String("error", err.Error()),
@@ -36,6 +70,99 @@ func (s *Server) HandleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Query options override body fields
+	if persistParam := r.URL.Query().Get("persist"); persistParam != "" {
+		persist, err := strconv.ParseBool(persistParam)
+		if err != nil {
+			log.Warn("validation failed: invalid persist parameter",
+				slog.String("persist", persistParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "persist must be a boolean")
+			return
+		}
+		req.Persist = &persist
+	}
+	if statsParam := r.URL.Query().Get("stats"); statsParam != "" {
+		includeStats, err := strconv.ParseBool(statsParam)
+		if err != nil {
+			log.Warn("validation failed: invalid stats parameter",
+				slog.String("stats", statsParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "stats must be a boolean")
+			return
+		}
+		req.IncludeStats = includeStats
+	}
+	if retryParam := r.URL.Query().Get("retryWithMoreMemory"); retryParam != "" {
+		retry, err := strconv.ParseBool(retryParam)
+		if err != nil {
+			log.Warn("validation failed: invalid retryWithMoreMemory parameter",
+				slog.String("retryWithMoreMemory", retryParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "retryWithMoreMemory must be a boolean")
+			return
+		}
+		req.RetryWithMoreMemory = retry
+	}
+	if reproducibleParam := r.URL.Query().Get("reproducible"); reproducibleParam != "" {
+		reproducible, err := strconv.ParseBool(reproducibleParam)
+		if err != nil {
+			log.Warn("validation failed: invalid reproducible parameter",
+				slog.String("reproducible", reproducibleParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "reproducible must be a boolean")
+			return
+		}
+		req.Reproducible = reproducible
+	}
+	if deterministicParam := r.URL.Query().Get("deterministicId"); deterministicParam != "" {
+		deterministic, err := strconv.ParseBool(deterministicParam)
+		if err != nil {
+			log.Warn("validation failed: invalid deterministicId parameter",
+				slog.String("deterministicId", deterministicParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "deterministicId must be a boolean")
+			return
+		}
+		req.DeterministicID = deterministic
+	}
+	if envelope := r.URL.Query().Get("envelope"); envelope != "" {
+		req.Envelope = envelope
+	}
+	if sel := r.URL.Query().Get("select"); sel != "" {
+		req.Select = sel
+	}
+	if handler := r.URL.Query().Get("handler"); handler != "" {
+		req.Handler = handler
+	}
+
+	if err := validateExecuteRequest(ctx, &req); err != nil {
+		if errors.Is(err, errHighPriorityForbidden) {
+			log.Warn("high priority execution not allowed for caller",
+				slog.String("principal", middleware.Principal(ctx)),
+			)
+			writeErrorWithCode(w, http.StatusForbidden, "forbidden", err.Error())
+			return
+		}
+		log.Warn("validation failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if req.Envelope == models.EnvelopeRaw && acceptsNDJSON(r) {
+		log.Warn("validation failed: raw envelope with NDJSON streaming")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "envelope 'raw' cannot be combined with an NDJSON response")
+		return
+	}
+
+	if req.CallbackURL != "" && acceptsNDJSON(r) {
+		log.Warn("validation failed: callback with NDJSON streaming")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "callbackUrl cannot be combined with an NDJSON response")
+		return
+	}
+
 	// Log request details
 	timeoutMs := 5000
 	memoryMb := 128
@@ -54,6 +181,19 @@ func (s *Server) HandleExecute(w http.ResponseWriter, r *http.Request) {
 		slog.Int("memory_mb", memoryMb),
 	)
 
+	if req.CallbackURL != "" {
+		s.executeWithCallback(ctx, envID, &req)
+		writeJSON(w, http.StatusAccepted, callbackAcceptedResponse{ExecutionID: *req.ExecutionID})
+		return
+	}
+
+	// Clients that accept NDJSON get generator records as they are produced
+	var records *ndjsonWriter
+	if acceptsNDJSON(r) {
+		records = &ndjsonWriter{w: w}
+		req.Records = records
+	}
+
 	done := logger.LogOperation(ctx, "execute_in_environment",
 		slog.String("environment_id", envID.String()),
 	)
@@ -66,12 +206,274 @@ func (s *Server) HandleExecute(w http.ResponseWriter, r *http.Request) {
 			slog.String("environment_id", envID.String()),
 			slog.String("error", err.Error()),
 		)
-		writeErrorWithCode(w, http.StatusInternalServerError, "execution_failed", err.Error())
+		if records != nil && records.started {
+			// Headers are already sent; report the failure in-band
+			setAuditFailed(ctx)
+			records.writeLine(ndjsonError{Type: "error", Error: err.Error(), Code: executorErrorCode(err, "execution_failed")})
+			return
+		}
+		writeExecutorError(w, err, "execution_failed")
 		return
 	}
 
 	// Log execution result
 	logger.LogExecutionResult(ctx, envID.String(), resp.ID.String(), resp.ExitCode, resp.DurationMs, nil)
 
+	if records != nil {
+		records.writeLine(ndjsonResult{Type: "result", ExecutionResponse: resp})
+		return
+	}
+	// Failed executions keep the JSON response so stderr and the exit code show
+	if req.Envelope == models.EnvelopeRaw && resp.ExitCode == 0 {
+		writeRawResult(w, resp, req.RawStdin)
+		return
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
+
+// callbackAcceptedResponse is the 202 body of an execution whose result will be
+// delivered to its callback URL
+type callbackAcceptedResponse struct {
+	ExecutionID uuid.UUID `json:"executionId"`
+}
+
+// deliverCallback posts a callback payload; a variable so tests can capture
+// deliveries without the SSRF-guarded client refusing local servers.
+var deliverCallback = executor.DeliverCallback
+
+// executeWithCallback runs an execution in the background and delivers its
+// result to req.CallbackURL. The execution gets an ID up front, so the caller
+// can match the callback and cancel the execution while it runs. It outlives
+// the request, so it runs without the request's cancellation.
+func (s *Server) executeWithCallback(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) {
+	if req.ExecutionID == nil {
+		id := uuid.New()
+		req.ExecutionID = &id
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		log := logger.FromContext(ctx)
+		payload := &models.CallbackPayload{ExecutionID: *req.ExecutionID, EnvironmentID: envID}
+
+		done := logger.LogOperation(ctx, "execute_in_environment",
+			slog.String("environment_id", envID.String()),
+		)
+		resp, err := s.Executor.ExecuteInEnvironment(ctx, envID, req)
+		done(err)
+		if err != nil {
+			log.Error("execution failed",
+				slog.String("environment_id", envID.String()),
+				slog.String("error", err.Error()),
+			)
+			payload.Error, payload.Code = err.Error(), executorErrorCode(err, "execution_failed")
+		} else {
+			logger.LogExecutionResult(ctx, envID.String(), resp.ID.String(), resp.ExitCode, resp.DurationMs, nil)
+			payload.Execution = resp
+		}
+
+		if err := deliverCallback(ctx, req.CallbackURL, payload); err != nil {
+			log.Warn("callback delivery failed",
+				slog.String("environment_id", envID.String()),
+				slog.String("execution_id", payload.ExecutionID.String()),
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+}
+
+// writeRawResult writes an execution's result as the response body itself. A
+// string result is written as the string and any other result as its JSON; raw
+// stdin filters' output is written as is. The Content-Type is the one the
+// handler declared, else application/json for non-string results, else sniffed.
+func writeRawResult(w http.ResponseWriter, resp *models.ExecutionResponse, rawStdin bool) {
+	body := []byte(resp.Stdout)
+	if resp.Encoding == executor.OutputEncodingBase64 {
+		if decoded, err := base64.StdEncoding.DecodeString(resp.Stdout); err == nil {
+			body = decoded
+		}
+	}
+
+	contentType := resp.ContentType
+	if !rawStdin {
+		var text string
+		if err := json.Unmarshal(body, &text); err == nil {
+			body = []byte(text)
+		} else if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Execution-Id", resp.ID.String())
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// acceptsNDJSON reports whether the client asked for a streamed NDJSON response
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept))
+		if mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonResult is the final line of an NDJSON execute response
+type ndjsonResult struct {
+	Type string `json:"type"`
+	*models.ExecutionResponse
+}
+
+// ndjsonError is the final line of an NDJSON execute response that failed
+// after records were already sent
+type ndjsonError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// errHighPriorityForbidden is returned by validateExecuteRequest when the caller
+// may not submit high priority executions
+var errHighPriorityForbidden = errors.New("caller may not submit high priority executions")
+
+// validateExecuteRequest checks an execute request once query options have been
+// applied. HandleExecute and batch items share it so they accept the same
+// requests.
+func validateExecuteRequest(ctx context.Context, req *models.ExecuteRequest) error {
+	if req.DeterministicID && req.ExecutionID != nil {
+		return fmt.Errorf("deterministicId cannot be combined with executionId")
+	}
+	if req.DeterministicID && req.DataStream != nil {
+		return fmt.Errorf("deterministicId is not supported for streamed input")
+	}
+	if req.SourceDateEpoch != 0 && !req.Reproducible {
+		return fmt.Errorf("sourceDateEpoch requires reproducible")
+	}
+	if req.SourceDateEpoch < 0 {
+		return fmt.Errorf("sourceDateEpoch cannot be negative")
+	}
+	if req.Envelope != "" && req.Envelope != models.EnvelopeBare && req.Envelope != models.EnvelopeFull && req.Envelope != models.EnvelopeRaw {
+		return fmt.Errorf("envelope must be 'bare', 'full' or 'raw'")
+	}
+	if req.InputEncoding != "" && req.InputEncoding != models.InputEncodingBase64 {
+		return fmt.Errorf("inputEncoding must be 'base64'")
+	}
+	if req.InputEncoding != "" && !req.RawStdin {
+		return fmt.Errorf("inputEncoding requires rawStdin")
+	}
+	if req.Select != "" {
+		if _, err := jsonpath.Parse(req.Select); err != nil {
+			return fmt.Errorf("select: %w", err)
+		}
+	}
+	if req.CallbackURL != "" {
+		switch {
+		case req.DeterministicID:
+			return fmt.Errorf("callbackUrl cannot be combined with deterministicId")
+		case req.DataStream != nil:
+			return fmt.Errorf("callbackUrl is not supported for streamed input")
+		case req.Envelope == models.EnvelopeRaw:
+			return fmt.Errorf("callbackUrl cannot be combined with envelope 'raw'")
+		}
+		if err := executor.ValidateCallbackURL(ctx, req.CallbackURL); err != nil {
+			return err
+		}
+	}
+	if err := validateHandlerName(req.Handler); err != nil {
+		return fmt.Errorf("handler must be a JavaScript identifier of at most 128 characters")
+	}
+	if req.Priority != "" && req.Priority != models.PriorityHigh && req.Priority != models.PriorityNormal {
+		return fmt.Errorf("priority must be 'high' or 'normal'")
+	}
+	if req.Priority == models.PriorityHigh && !highPriorityAllowed(middleware.Principal(ctx)) {
+		return errHighPriorityForbidden
+	}
+	if err := validateArgs(req.Args); err != nil {
+		return err
+	}
+	if err := validateLabels(req.Labels); err != nil {
+		return err
+	}
+	if err := validateEnvSize(req.Env); err != nil {
+		return err
+	}
+	if err := validateLocale(req.Timezone, req.Locale); err != nil {
+		return err
+	}
+	if err := validateLimits(req.Limits); err != nil {
+		return err
+	}
+	return validateWorkingDir(req.WorkingDir)
+}
+
+// ndjsonWriter streams record lines to the client, sending the 200 status and
+// content type with the first line and flushing after each write
+type ndjsonWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (n *ndjsonWriter) Write(p []byte) (int, error) {
+	if !n.started {
+		n.w.Header().Set("Content-Type", "application/x-ndjson")
+		n.w.WriteHeader(http.StatusOK)
+		n.started = true
+	}
+	written, err := n.w.Write(p)
+	if f, ok := n.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return written, err
+}
+
+func (n *ndjsonWriter) writeLine(v interface{}) {
+	line, _ := json.Marshal(v)
+	n.Write(append(line, '\n'))
+}
+
+// maxStreamHeaderBytes bounds the JSON header line of a streamed execute request
+const maxStreamHeaderBytes = 1 << 20
+
+// isStreamedInput reports whether the execute body uses the streamed input protocol
+func isStreamedInput(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/octet-stream"
+}
+
+// decodeStreamedRequest reads the header line into req and leaves the remainder
+// of the body as req.DataStream, so the data is never buffered by the API
+func decodeStreamedRequest(body io.Reader, req *models.ExecuteRequest) error {
+	reader := bufio.NewReaderSize(body, 64*1024)
+
+	var header []byte
+	for {
+		line, err := reader.ReadSlice('\n')
+		header = append(header, line...)
+		if err == nil || err == io.EOF {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return err
+		}
+		if len(header) > maxStreamHeaderBytes {
+			return fmt.Errorf("stream header exceeds %d bytes", maxStreamHeaderBytes)
+		}
+	}
+
+	if err := json.Unmarshal(header, req); err != nil {
+		return fmt.Errorf("invalid stream header: %w", err)
+	}
+	if req.Data != nil {
+		return fmt.Errorf("stream header must not include data")
+	}
+
+	req.DataStream = reader
+	return nil
+}
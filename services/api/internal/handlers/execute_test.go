@@ -5,13 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/middleware"
 	"github.com/jsfour/assist-tee/internal/models"
 )
 
@@ -237,3 +243,989 @@ func TestHandleExecute_NonZeroExitCode(t *testing.T) {
 		t.Errorf("expected stderr 'Error: something went wrong', got '%s'", resp.Stderr)
 	}
 }
+
+func TestHandleExecute_PersistQueryParam(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{Data: map[string]interface{}{}})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?persist=false", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	persist := mock.ExecuteCalls[0].Req.Persist
+	if persist == nil || *persist {
+		t.Errorf("expected Persist=false to be passed to executor, got %v", persist)
+	}
+}
+
+func TestHandleExecute_InvalidPersistQueryParam(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{Data: map[string]interface{}{}})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?persist=maybe", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if len(mock.ExecuteCalls) != 0 {
+		t.Errorf("expected 0 execute calls, got %d", len(mock.ExecuteCalls))
+	}
+}
+
+func TestHandleExecute_MissingRequiredEnv(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return nil, &executor.Error{Code: "validation_error", Message: "missing required env vars: API_KEY"}
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{Data: map[string]interface{}{}})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	if resp.Code != "validation_error" {
+		t.Errorf("expected code 'validation_error', got '%s'", resp.Code)
+	}
+
+	if resp.Error != "missing required env vars: API_KEY" {
+		t.Errorf("unexpected error message '%s'", resp.Error)
+	}
+}
+
+func TestHandleExecute_StreamedInput(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	var streamed string
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		if req.DataStream == nil {
+			t.Fatal("expected DataStream to be set")
+		}
+		data, err := io.ReadAll(req.DataStream)
+		if err != nil {
+			t.Fatalf("failed to read stream: %v", err)
+		}
+		streamed = string(data)
+		return &models.ExecutionResponse{ID: uuid.New(), ExitCode: 0}, nil
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body := "{\"env\":{\"MODE\":\"fast\"}}\nline one\nline two\n"
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if streamed != "line one\nline two\n" {
+		t.Errorf("unexpected streamed data: %q", streamed)
+	}
+	if got := mock.ExecuteCalls[0].Req.Env["MODE"]; got != "fast" {
+		t.Errorf("expected header env to be decoded, got %q", got)
+	}
+}
+
+func TestHandleExecute_StreamedInputRejectsInlineData(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body := "{\"data\":{\"a\":1}}\nraw"
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.ExecuteCalls) != 0 {
+		t.Error("executor should not be called for an invalid stream header")
+	}
+}
+
+func TestHandleExecute_EnvelopeQueryParam(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?envelope=full", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if got := mock.ExecuteCalls[0].Req.Envelope; got != models.EnvelopeFull {
+		t.Errorf("expected envelope 'full' to be passed to executor, got %q", got)
+	}
+}
+
+func TestHandleExecute_InvalidEnvelope(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?envelope=fancy", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.ExecuteCalls) != 0 {
+		t.Error("executor should not be called for an invalid envelope")
+	}
+}
+
+func TestHandleExecute_RawEnvelope(t *testing.T) {
+	cases := []struct {
+		name        string
+		resp        models.ExecutionResponse
+		contentType string
+		body        string
+	}{
+		{"declared", models.ExecutionResponse{Stdout: `"<p>hi</p>"`, ContentType: "text/html; charset=utf-8"}, "text/html; charset=utf-8", "<p>hi</p>"},
+		{"sniffed string", models.ExecutionResponse{Stdout: `"a,b\n1,2\n"`}, "text/plain; charset=utf-8", "a,b\n1,2\n"},
+		{"json result", models.ExecutionResponse{Stdout: `{"ok":true}`}, "application/json", `{"ok":true}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := executor.NewMockExecutor()
+			mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+				resp := tc.resp
+				resp.ID = uuid.New()
+				return &resp, nil
+			}
+			server := NewServer(mock)
+
+			envID := uuid.New()
+			body, _ := json.Marshal(models.ExecuteRequest{})
+			req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?envelope=raw", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+			rec := httptest.NewRecorder()
+			server.HandleExecute(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("expected Content-Type %q, got %q", tc.contentType, got)
+			}
+			if got := rec.Body.String(); got != tc.body {
+				t.Errorf("expected body %q, got %q", tc.body, got)
+			}
+		})
+	}
+}
+
+func TestHandleExecute_RawEnvelopeFailureKeepsJSON(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return &models.ExecutionResponse{ID: uuid.New(), ExitCode: 1, Stderr: "boom", ContentType: "text/html"}, nil
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{Envelope: models.EnvelopeRaw})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected a JSON response for a failed execution, got %q", got)
+	}
+	var resp models.ExecutionResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Stderr != "boom" {
+		t.Errorf("expected the execution response with stderr, got %s", rec.Body.String())
+	}
+}
+
+func TestHandleExecute_BusyBackpressure(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return nil, &executor.Error{Code: "busy", Message: "execution capacity exhausted, retry later", RetryAfter: 3 * time.Second, QueueDepth: 7}
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("expected Retry-After 3, got %q", got)
+	}
+	if got := rec.Header().Get("X-Queue-Depth"); got != "7" {
+		t.Errorf("expected X-Queue-Depth 7, got %q", got)
+	}
+}
+
+func TestHandleExecute_ImagePullFailed(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return nil, &executor.Error{Code: "image_pull_failed", Message: "failed to pull image example/rt:latest: toomanyrequests"}
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("expected status %d, got %d", http.StatusBadGateway, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "image_pull_failed" {
+		t.Errorf("expected code 'image_pull_failed', got '%s'", resp.Code)
+	}
+}
+
+func TestHandleExecute_EnvironmentRateLimited(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return nil, &executor.Error{Code: "environment_rate_limited", Message: "environment allows at most 10 executions per minute", RetryAfter: 6 * time.Second}
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "6" {
+		t.Errorf("expected Retry-After 6, got %q", got)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "environment_rate_limited" {
+		t.Errorf("expected code 'environment_rate_limited', got '%s'", resp.Code)
+	}
+}
+
+func TestHandleExecute_Draining(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		return nil, executor.ErrEnvironmentDraining
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "draining" {
+		t.Errorf("expected code 'draining', got '%s'", resp.Code)
+	}
+}
+
+func TestHandleExecute_TimezoneAndLocale(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	cases := []struct {
+		timezone, locale string
+		wantStatus       int
+	}{
+		{"Europe/Berlin", "de_DE.UTF-8", http.StatusOK},
+		{"America/Sao_Paulo", "pt_BR", http.StatusOK},
+		{"Mars/Olympus_Mons", "", http.StatusBadRequest},
+		{"Local", "", http.StatusBadRequest},
+		{"", "en_US; rm -rf /", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(models.ExecuteRequest{Timezone: c.timezone, Locale: c.locale})
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("timezone %q locale %q: expected status %d, got %d", c.timezone, c.locale, c.wantStatus, rec.Code)
+		}
+	}
+	if len(mock.ExecuteCalls) != 2 {
+		t.Errorf("expected 2 execute calls, got %d", len(mock.ExecuteCalls))
+	}
+}
+
+func TestHandleExecute_NDJSONRecords(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		if req.Records == nil {
+			t.Fatal("expected a record writer for an NDJSON request")
+		}
+		req.Records.Write([]byte(`{"type":"record","value":1}` + "\n"))
+		req.Records.Write([]byte(`{"type":"record","value":2}` + "\n"))
+		return &models.ExecutionResponse{ID: uuid.New(), Stdout: `"done"`}, nil
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected Content-Type application/x-ndjson, got %s", ct)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 records and a result line, got %q", rec.Body.String())
+	}
+	var final struct {
+		Type   string `json:"type"`
+		Stdout string `json:"stdout"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &final); err != nil {
+		t.Fatalf("failed to unmarshal result line: %v", err)
+	}
+	if final.Type != "result" || final.Stdout != `"done"` {
+		t.Errorf("unexpected result line %q", lines[2])
+	}
+}
+
+func TestHandleExecute_NDJSONStreamsThroughMiddleware(t *testing.T) {
+	t.Setenv("BEARER_TOKEN", "stream-token")
+	if err := middleware.InitAuth(); err != nil {
+		t.Fatalf("InitAuth: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		req.Records.Write([]byte(`{"type":"record","value":1}` + "\n"))
+		if !rec.Flushed || !strings.Contains(rec.Body.String(), `"value":1`) {
+			t.Error("expected the record to be flushed to the client before the execution finished")
+		}
+		return &models.ExecutionResponse{ID: uuid.New(), Stdout: `"done"`}, nil
+	}
+	server := NewServer(mock)
+	server.Audit = &recordingAudit{}
+
+	// The same chain main wires up around the execute route
+	r := mux.NewRouter()
+	r.HandleFunc("/environments/{id}/execute", server.Audited("execute", server.HandleExecute)).Methods("POST")
+	handler := middleware.Recovery(middleware.RequestLogging(middleware.Compress(middleware.CORS(middleware.BearerAuth(r)))))
+
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+uuid.New().String()+"/execute", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Authorization", "Bearer stream-token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 2 {
+		t.Errorf("expected a record and a result line, got %q", rec.Body.String())
+	}
+}
+
+func TestHandleExecute_NDJSONErrorAfterRecords(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExecuteFunc = func(ctx context.Context, envID uuid.UUID, req *models.ExecuteRequest) (*models.ExecutionResponse, error) {
+		req.Records.Write([]byte(`{"type":"record","value":1}` + "\n"))
+		return nil, errors.New("container vanished")
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a record and an error line, got %q", rec.Body.String())
+	}
+	var final ndjsonError
+	json.Unmarshal([]byte(lines[1]), &final)
+	if final.Type != "error" || final.Code != "execution_failed" || final.Error != "container vanished" {
+		t.Errorf("unexpected error line %q", lines[1])
+	}
+}
+
+func TestHandleExecute_ArgsAndWorkingDir(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{WorkingDir: "jobs/nightly", Args: []string{"--verbose", "report.csv"}})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if len(mock.ExecuteCalls) != 1 {
+		t.Fatalf("expected 1 execute call, got %d", len(mock.ExecuteCalls))
+	}
+	got := mock.ExecuteCalls[0].Req
+	if !reflect.DeepEqual(got.Args, []string{"--verbose", "report.csv"}) || got.WorkingDir != "jobs/nightly" {
+		t.Errorf("expected args and workingDir to reach the executor, got %v %q", got.Args, got.WorkingDir)
+	}
+}
+
+func TestHandleExecute_InvalidWorkingDir(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{WorkingDir: "../etc", Args: []string{"--verbose"}})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.ExecuteCalls) != 0 {
+		t.Error("executor should not be called for an invalid workingDir")
+	}
+}
+
+func TestHandleExecute_HighPriority(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  string
+		allowed    string
+		wantStatus int
+	}{
+		{"authenticated caller", "ci", "", http.StatusOK},
+		{"listed caller", "ci", "ops, ci", http.StatusOK},
+		{"unlisted caller", "ci", "ops", http.StatusForbidden},
+		{"anonymous caller", "anonymous", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HIGH_PRIORITY_PRINCIPALS", tt.allowed)
+			mock := executor.NewMockExecutor()
+			server := NewServer(mock)
+
+			envID := uuid.New()
+			body, _ := json.Marshal(models.ExecuteRequest{Priority: models.PriorityHigh})
+			req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+			req = req.WithContext(middleware.WithPrincipal(req.Context(), tt.principal))
+
+			rec := httptest.NewRecorder()
+			server.HandleExecute(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandleExecute_InvalidPriority(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{Priority: "urgent"})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleExecute_SwapBelowMemory(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{
+		Limits: &models.ResourceLimits{MemoryMb: 256, MemorySwapMb: 128},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.ExecuteCalls) != 0 {
+		t.Error("executor should not be called with a swap limit below the memory limit")
+	}
+}
+
+func TestHandleExecute_InvalidSelect(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute?select=items.id", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.ExecuteCalls) != 0 {
+		t.Error("executor should not be called for an invalid select expression")
+	}
+}
+
+func TestHandleExecute_Reproducible(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	cases := []struct {
+		query      string
+		request    models.ExecuteRequest
+		wantStatus int
+	}{
+		{"?reproducible=true", models.ExecuteRequest{SourceDateEpoch: 1700000000}, http.StatusOK},
+		{"", models.ExecuteRequest{Reproducible: true}, http.StatusOK},
+		{"?reproducible=maybe", models.ExecuteRequest{}, http.StatusBadRequest},
+		{"", models.ExecuteRequest{SourceDateEpoch: 1700000000}, http.StatusBadRequest},
+		{"", models.ExecuteRequest{Reproducible: true, SourceDateEpoch: -1}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(c.request)
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute"+c.query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("query %q request %+v: expected status %d, got %d", c.query, c.request, c.wantStatus, rec.Code)
+		}
+	}
+	if len(mock.ExecuteCalls) != 2 {
+		t.Fatalf("expected 2 execute calls, got %d", len(mock.ExecuteCalls))
+	}
+	if call := mock.ExecuteCalls[0].Req; !call.Reproducible || call.SourceDateEpoch != 1700000000 {
+		t.Errorf("expected the reproducible settings to reach the executor, got %+v", call)
+	}
+}
+
+func TestHandleExecute_InputEncoding(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	cases := []struct {
+		request    models.ExecuteRequest
+		wantStatus int
+	}{
+		{models.ExecuteRequest{RawStdin: true, InputEncoding: "base64", Data: "AAEC"}, http.StatusOK},
+		{models.ExecuteRequest{RawStdin: true, InputEncoding: "hex", Data: "0001"}, http.StatusBadRequest},
+		{models.ExecuteRequest{InputEncoding: "base64", Data: "AAEC"}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(c.request)
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("request %+v: expected status %d, got %d", c.request, c.wantStatus, rec.Code)
+		}
+	}
+	if len(mock.ExecuteCalls) != 1 || mock.ExecuteCalls[0].Req.InputEncoding != models.InputEncodingBase64 {
+		t.Errorf("expected one base64 execute call, got %+v", mock.ExecuteCalls)
+	}
+}
+
+func TestHandleExecute_DeterministicID(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+	execID := uuid.New()
+
+	cases := []struct {
+		query      string
+		request    models.ExecuteRequest
+		wantStatus int
+	}{
+		{"?deterministicId=true", models.ExecuteRequest{Data: "x"}, http.StatusOK},
+		{"?deterministicId=sometimes", models.ExecuteRequest{}, http.StatusBadRequest},
+		{"", models.ExecuteRequest{DeterministicID: true, ExecutionID: &execID}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(c.request)
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute"+c.query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("query %q request %+v: expected status %d, got %d", c.query, c.request, c.wantStatus, rec.Code)
+		}
+	}
+	if len(mock.ExecuteCalls) != 1 || !mock.ExecuteCalls[0].Req.DeterministicID {
+		t.Errorf("expected one deterministic execute call, got %+v", mock.ExecuteCalls)
+	}
+}
+
+func TestHandleExecute_DataNullVersusAbsent(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	for _, body := range []string{`{}`, `{"data": null}`} {
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", body, http.StatusOK, rec.Code)
+		}
+	}
+
+	if len(mock.ExecuteCalls) != 2 {
+		t.Fatalf("expected 2 execute calls, got %d", len(mock.ExecuteCalls))
+	}
+	if absent := mock.ExecuteCalls[0].Req; absent.DataPresent || absent.Data != nil {
+		t.Errorf("expected no data for an empty body, got %+v", absent)
+	}
+	if null := mock.ExecuteCalls[1].Req; !null.DataPresent || null.Data != nil {
+		t.Errorf("expected present null data, got %+v", null)
+	}
+}
+
+func TestHandleExecute_RetryWithMoreMemoryParam(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	for query, want := range map[string]int{"?retryWithMoreMemory=true": http.StatusOK, "?retryWithMoreMemory=twice": http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute"+query, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("%s: expected status %d, got %d", query, want, rec.Code)
+		}
+	}
+	if len(mock.ExecuteCalls) != 1 || !mock.ExecuteCalls[0].Req.RetryWithMoreMemory {
+		t.Errorf("expected one execution asking to retry with more memory, got %+v", mock.ExecuteCalls)
+	}
+}
+
+func TestHandleExecute_HandlerQueryParam(t *testing.T) {
+	cases := []struct {
+		query      string
+		wantStatus int
+		want       string
+	}{
+		{"?handler=processOrder", http.StatusOK, "processOrder"},
+		{"?handler=process.order", http.StatusBadRequest, ""},
+		{"?handler=" + url.QueryEscape("x;Deno.exit()"), http.StatusBadRequest, ""},
+	}
+	for _, c := range cases {
+		mock := executor.NewMockExecutor()
+		server := NewServer(mock)
+
+		envID := uuid.New()
+		body, _ := json.Marshal(models.ExecuteRequest{})
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute"+c.query, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("%s: expected status %d, got %d", c.query, c.wantStatus, rec.Code)
+			continue
+		}
+		if c.wantStatus == http.StatusOK && mock.ExecuteCalls[0].Req.Handler != c.want {
+			t.Errorf("%s: expected handler %q passed to the executor, got %q", c.query, c.want, mock.ExecuteCalls[0].Req.Handler)
+		}
+		if c.wantStatus != http.StatusOK && len(mock.ExecuteCalls) != 0 {
+			t.Errorf("%s: executor should not be called for an invalid handler", c.query)
+		}
+	}
+}
+
+func TestHandleExecute_EnvSizeLimits(t *testing.T) {
+	t.Setenv("MAX_ENV_VALUE_BYTES", "8")
+	t.Setenv("MAX_ENV_TOTAL_BYTES", "20")
+
+	cases := []struct {
+		env        map[string]string
+		wantStatus int
+		wantError  string
+	}{
+		{map[string]string{"A": "12345678", "B": "1234"}, http.StatusOK, ""},
+		{map[string]string{"TOKEN": "123456789"}, http.StatusBadRequest, "env var TOKEN exceeds 8 bytes (MAX_ENV_VALUE_BYTES)"},
+		{map[string]string{"AAAA": "12345678", "BBBB": "12345678"}, http.StatusBadRequest, "env vars total 24 bytes, exceeding 20 (MAX_ENV_TOTAL_BYTES)"},
+	}
+	for _, c := range cases {
+		mock := executor.NewMockExecutor()
+		server := NewServer(mock)
+
+		envID := uuid.New()
+		body, _ := json.Marshal(models.ExecuteRequest{Env: c.env})
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: expected status %d, got %d", c.env, c.wantStatus, rec.Code)
+			continue
+		}
+		if c.wantStatus == http.StatusOK {
+			continue
+		}
+		var resp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Code != "validation_error" || resp.Error != c.wantError {
+			t.Errorf("%v: unexpected error %+v", c.env, resp)
+		}
+		if len(mock.ExecuteCalls) != 0 {
+			t.Errorf("%v: executor should not be called for oversized env", c.env)
+		}
+	}
+}
+
+func TestHandleExecute_Labels(t *testing.T) {
+	cases := []struct {
+		labels     map[string]string
+		wantStatus int
+	}{
+		{map[string]string{"orderId": "42", "job.name": "nightly-sync"}, http.StatusOK},
+		{map[string]string{"order id": "42"}, http.StatusBadRequest},
+		{map[string]string{"note": strings.Repeat("x", 257)}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		mock := executor.NewMockExecutor()
+		server := NewServer(mock)
+
+		envID := uuid.New()
+		body, _ := json.Marshal(models.ExecuteRequest{Labels: c.labels})
+		req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+		rec := httptest.NewRecorder()
+		server.HandleExecute(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("%v: expected status %d, got %d", c.labels, c.wantStatus, rec.Code)
+			continue
+		}
+		if c.wantStatus == http.StatusOK && !reflect.DeepEqual(mock.ExecuteCalls[0].Req.Labels, c.labels) {
+			t.Errorf("expected labels %v passed to the executor, got %v", c.labels, mock.ExecuteCalls[0].Req.Labels)
+		}
+	}
+}
+
+func TestHandleExecute_CallbackURL(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	delivered := make(chan *models.CallbackPayload, 1)
+	orig := deliverCallback
+	deliverCallback = func(ctx context.Context, rawURL string, payload *models.CallbackPayload) error {
+		if rawURL != "https://93.184.216.34/cb" {
+			t.Errorf("expected delivery to the callback URL, got %q", rawURL)
+		}
+		delivered <- payload
+		return nil
+	}
+	defer func() { deliverCallback = orig }()
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.ExecuteRequest{CallbackURL: "https://93.184.216.34/cb"})
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var accepted struct {
+		ExecutionID uuid.UUID `json:"executionId"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &accepted); err != nil || accepted.ExecutionID == uuid.Nil {
+		t.Fatalf("expected an executionId in the 202 body, got %s", rec.Body.String())
+	}
+
+	select {
+	case payload := <-delivered:
+		if payload.ExecutionID != accepted.ExecutionID || payload.EnvironmentID != envID {
+			t.Errorf("expected payload for execution %s in %s, got %+v", accepted.ExecutionID, envID, payload)
+		}
+		if payload.Execution == nil || payload.Error != "" {
+			t.Errorf("expected the execution result in the payload, got %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+	if got := mock.ExecuteCalls[0].Req.ExecutionID; got == nil || *got != accepted.ExecutionID {
+		t.Errorf("expected the executor to run with the returned execution ID, got %v", got)
+	}
+}
+
+func TestHandleExecute_CallbackURLRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		req  models.ExecuteRequest
+	}{
+		{"loopback", models.ExecuteRequest{CallbackURL: "https://127.0.0.1/cb"}},
+		{"plain http", models.ExecuteRequest{CallbackURL: "http://93.184.216.34/cb"}},
+		{"deterministic id", models.ExecuteRequest{CallbackURL: "https://93.184.216.34/cb", DeterministicID: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := executor.NewMockExecutor()
+			server := NewServer(mock)
+
+			envID := uuid.New()
+			body, _ := json.Marshal(tt.req)
+			req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+			rec := httptest.NewRecorder()
+			server.HandleExecute(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+			if len(mock.ExecuteCalls) != 0 {
+				t.Errorf("expected 0 execute calls, got %d", len(mock.ExecuteCalls))
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+const (
+	defaultExecutionsLimit = 50
+	maxExecutionsLimit     = 500
+)
+
+// HandleListExecutions lists an environment's stored executions, newest first.
+// Each ?label=key:value narrows the list to executions carrying that label, and
+// ?limit caps how many are returned.
+func (s *Server) HandleListExecutions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	limit := defaultExecutionsLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxExecutionsLimit {
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "limit must be between 1 and "+strconv.Itoa(maxExecutionsLimit))
+			return
+		}
+	}
+
+	labels := map[string]string{}
+	for _, filter := range r.URL.Query()["label"] {
+		key, value, ok := strings.Cut(filter, ":")
+		if !ok {
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "label filters must be key:value")
+			return
+		}
+		labels[key] = value
+	}
+	if err := validateLabels(labels); err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	if !s.environmentExists(w, r, envID) {
+		return
+	}
+
+	records, err := database.ListExecutions(ctx, envID, labels, limit)
+	if err != nil {
+		log.Error("failed to list executions",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, records)
+}
+
+// latestExecution looks up an environment's newest execution; a variable so
+// tests can stand in for the database.
+var latestExecution = database.LatestExecution
+
+// HandleLatestExecution returns an environment's most recently started
+// execution, with its output, or 404 when it has none yet.
+func (s *Server) HandleLatestExecution(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	if !s.environmentExists(w, r, envID) {
+		return
+	}
+
+	record, err := latestExecution(ctx, envID)
+	if errors.Is(err, database.ErrNoExecutions) {
+		writeErrorWithCode(w, http.StatusNotFound, "not_found", "No executions found")
+		return
+	}
+	if err != nil {
+		log.Error("failed to get latest execution",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+// environmentExists checks that the environment exists, writing a 404 (or a
+// 500 when the lookup fails) and returning false when it doesn't
+func (s *Server) environmentExists(w http.ResponseWriter, r *http.Request, envID uuid.UUID) bool {
+	_, err := s.Executor.GetEnvironment(r.Context(), envID)
+	if errors.Is(err, executor.ErrEnvironmentNotFound) {
+		writeErrorWithCode(w, http.StatusNotFound, "not_found", "Environment not found")
+		return false
+	}
+	if err != nil {
+		logger.FromContext(r.Context()).Error("failed to get environment",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return false
+	}
+	return true
+}
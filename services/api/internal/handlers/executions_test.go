@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestHandleListExecutions_InvalidQuery(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+	envID := uuid.New()
+
+	for _, query := range []string{
+		"?limit=0",
+		"?limit=10000",
+		"?limit=ten",
+		"?label=orderId",
+		"?label=" + url.QueryEscape("order id:42"),
+		"?label=k:" + strings.Repeat("v", 257),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/executions"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+		rec := httptest.NewRecorder()
+
+		server.HandleListExecutions(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+		var resp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Code != "validation_error" {
+			t.Errorf("%s: expected code 'validation_error', got '%s'", query, resp.Code)
+		}
+	}
+	if len(mock.GetCalls) != 0 {
+		t.Errorf("expected no environment lookups for invalid queries, got %d", len(mock.GetCalls))
+	}
+}
+
+func TestHandleListExecutions_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.GetFunc = func(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/executions?label=orderId:42", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleListExecutions(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleLatestExecution_InvalidID(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	req := httptest.NewRequest(http.MethodGet, "/environments/not-a-uuid/executions/latest", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "not-a-uuid"})
+	rec := httptest.NewRecorder()
+
+	server.HandleLatestExecution(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleLatestExecution_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.GetFunc = func(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/executions/latest", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleLatestExecution(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if len(mock.GetCalls) != 1 {
+		t.Errorf("expected one environment lookup, got %d", len(mock.GetCalls))
+	}
+}
+
+func TestHandleLatestExecution_Success(t *testing.T) {
+	envID, execID := uuid.New(), uuid.New()
+	latest := &models.ExecutionRecord{ID: execID, Status: "completed", Stdout: `{"ok":true}`}
+	original := latestExecution
+	latestExecution = func(ctx context.Context, id uuid.UUID) (*models.ExecutionRecord, error) {
+		if id != envID {
+			t.Errorf("expected a lookup for %s, got %s", envID, id)
+		}
+		return latest, nil
+	}
+	t.Cleanup(func() { latestExecution = original })
+
+	server := NewServer(executor.NewMockExecutor())
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/executions/latest", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleLatestExecution(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var got models.ExecutionRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if got.ID != execID || got.Stdout != latest.Stdout {
+		t.Errorf("expected the latest execution, got %+v", got)
+	}
+}
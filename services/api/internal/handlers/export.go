@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// HandleExport returns a portable bundle that POST /environments/import turns
+// back into an environment, for backups and moving environments between
+// deployments.
+func (s *Server) HandleExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	bundle, err := s.Executor.ExportEnvironment(ctx, envID)
+	if err != nil {
+		log.Error("environment export failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeExecutorError(w, err, "export_failed")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="environment-%s.json"`, envID))
+	writeJSON(w, http.StatusOK, bundle)
+}
+
+// HandleImport recreates an exported environment under a new ID. The bundle's
+// setup request goes through the same validation as POST /environments/setup.
+func (s *Server) HandleImport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var bundle models.EnvironmentExport
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		log.Warn("failed to decode import request",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if bundle.FormatVersion != models.EnvironmentExportVersion {
+		log.Warn("validation failed: unsupported export format",
+			slog.Int("format_version", bundle.FormatVersion),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error",
+			fmt.Sprintf("unsupported formatVersion %d (expected %d)", bundle.FormatVersion, models.EnvironmentExportVersion))
+		return
+	}
+	if bundle.Setup.Template != "" {
+		log.Warn("validation failed: import bundle names a template")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "setup.template is not supported on import")
+		return
+	}
+
+	log.Info("importing environment",
+		slog.String("source_environment_id", bundle.SourceEnvironmentID.String()),
+	)
+
+	s.createEnvironment(w, r, &bundle.Setup)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestHandleExport_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ExportFunc = func(ctx context.Context, envID uuid.UUID) (*models.EnvironmentExport, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/export", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleExport(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleExportImport_RoundTrip(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/export", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleExport(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/environments/import", bytes.NewReader(rec.Body.Bytes()))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+
+	server.HandleImport(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if len(mock.SetupCalls) != 1 {
+		t.Fatalf("expected 1 setup call, got %d", len(mock.SetupCalls))
+	}
+	if got := mock.SetupCalls[0].Req.MainModule; got != "main.ts" {
+		t.Errorf("expected imported mainModule 'main.ts', got %q", got)
+	}
+}
+
+func TestHandleImport_RejectsUnknownFormat(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.EnvironmentExport{
+		FormatVersion: 99,
+		Setup: models.SetupRequest{
+			MainModule: "main.ts",
+			Modules:    map[string]string{"main.ts": "export {}"},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleImport(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called for an unsupported bundle")
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// HandleGet serves GET and HEAD for a single environment. Both set metadata
+// headers so HEAD can be used as a cheap existence check; only GET writes a body.
+func (s *Server) HandleGet(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	env, err := s.Executor.GetEnvironment(ctx, envID)
+	if errors.Is(err, executor.ErrEnvironmentNotFound) {
+		log.Debug("environment not found",
+			slog.String("environment_id", envID.String()),
+		)
+		writeErrorWithCode(w, http.StatusNotFound, "not_found", "Environment not found")
+		return
+	} else if err != nil {
+		log.Error("failed to get environment",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	w.Header().Set("X-Environment-Status", env.Status)
+	w.Header().Set("X-Execution-Count", strconv.Itoa(env.ExecutionCount))
+	w.Header().Set("X-TTL-Seconds", strconv.Itoa(env.TTLSeconds))
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, env)
+}
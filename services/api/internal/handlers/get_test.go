@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestHandleGet_Success(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleGet(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp models.Environment
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp.ID != envID {
+		t.Errorf("expected ID %s, got %s", envID, resp.ID)
+	}
+}
+
+func TestHandleGet_Head(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.GetFunc = func(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+		return &models.Environment{ID: envID, Status: "ready", ExecutionCount: 7, TTLSeconds: 600}, nil
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodHead, "/environments/"+envID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleGet(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if rec.Body.Len() != 0 {
+		t.Errorf("expected empty body for HEAD, got %q", rec.Body.String())
+	}
+
+	if got := rec.Header().Get("X-Environment-Status"); got != "ready" {
+		t.Errorf("expected X-Environment-Status 'ready', got '%s'", got)
+	}
+
+	if got := rec.Header().Get("X-Execution-Count"); got != "7" {
+		t.Errorf("expected X-Execution-Count '7', got '%s'", got)
+	}
+
+	if got := rec.Header().Get("X-TTL-Seconds"); got != "600" {
+		t.Errorf("expected X-TTL-Seconds '600', got '%s'", got)
+	}
+}
+
+func TestHandleGet_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.GetFunc = func(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodHead, "/environments/"+envID.String(), nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+
+	rec := httptest.NewRecorder()
+	server.HandleGet(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleGet_InvalidID(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	req := httptest.NewRequest(http.MethodGet, "/environments/not-a-uuid", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "not-a-uuid"})
+
+	rec := httptest.NewRecorder()
+	server.HandleGet(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if len(mock.GetCalls) != 0 {
+		t.Errorf("expected 0 get calls, got %d", len(mock.GetCalls))
+	}
+}
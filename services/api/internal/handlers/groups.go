@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// groupIDPattern restricts group IDs to short, URL-safe identifiers
+var groupIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+// HandlePatchGroup updates the defaults shared by every environment in a group.
+// Members pick up the change on their next execution. Env var values are not
+// echoed back, since groups are used to rotate shared secrets.
+func (s *Server) HandlePatchGroup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	groupID := mux.Vars(r)["groupId"]
+	if !groupIDPattern.MatchString(groupID) {
+		log.Warn("invalid group ID",
+			slog.String("group_id", groupID),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "group ID must be 1-64 letters, digits, '.', '_' or '-'")
+		return
+	}
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var patch models.GroupDefaults
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		log.Warn("failed to decode group update",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if patch.Limits == nil && len(patch.Env) == 0 {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "at least one of limits or env is required")
+		return
+	}
+	if err := validateGroupDefaults(&patch); err != nil {
+		log.Warn("validation failed: invalid group defaults",
+			slog.String("group_id", groupID),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+
+	group, err := database.UpdateGroupDefaults(ctx, groupID, &patch)
+	if err != nil {
+		log.Error("failed to update group defaults",
+			slog.String("group_id", groupID),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "update_failed", err.Error())
+		return
+	}
+
+	log.Info("group defaults updated",
+		slog.String("group_id", groupID),
+		slog.Int("member_count", group.MemberCount),
+	)
+
+	for name := range group.Defaults.Env {
+		group.Defaults.Env[name] = logger.RedactedValue
+	}
+	writeJSON(w, http.StatusOK, group)
+}
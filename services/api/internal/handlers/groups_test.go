@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+)
+
+func TestHandlePatchGroup_Validation(t *testing.T) {
+	server := NewServer(executor.NewMockExecutor())
+
+	cases := []struct {
+		name    string
+		groupID string
+		body    string
+	}{
+		{"invalid group ID", "bad/id", `{"env":{"A":"b"}}`},
+		{"empty patch", "payments", `{}`},
+		{"negative memory", "payments", `{"limits":{"memoryMb":-1}}`},
+		{"swap below memory", "payments", `{"limits":{"memoryMb":256,"memorySwapMb":128}}`},
+		{"invalid env name", "payments", `{"env":{"1BAD":"x"}}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/groups/"+c.groupID, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"groupId": c.groupID})
+			rec := httptest.NewRecorder()
+
+			server.HandlePatchGroup(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
-	"encoding/json"
+	"database/sql"
 	"log/slog"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/jsfour/assist-tee/internal/database"
 	"github.com/jsfour/assist-tee/internal/logger"
 	"github.com/jsfour/assist-tee/internal/models"
@@ -16,12 +17,17 @@ func (s *Server) HandleList(w http.ResponseWriter, r *http.Request) {
 
 	log.Debug("list environments request received")
 
-	rows, err := database.DB.QueryContext(ctx, `
-		SELECT id, volume_name, main_module, created_at, last_executed_at,
-		       execution_count, status, metadata, ttl_seconds
-		FROM environments
-		ORDER BY created_at DESC
-	`)
+	var rows *sql.Rows
+	// uuid.Nil reads from the primary while any environment was just written
+	err := database.WithReader(ctx, uuid.Nil, func(db *sql.DB) error {
+		var err error
+		rows, err = db.QueryContext(ctx, `
+			SELECT `+database.EnvironmentColumns+`
+			FROM environments
+			ORDER BY created_at DESC
+		`)
+		return err
+	})
 	if err != nil {
 		log.Error("failed to query environments",
 			slog.String("error", err.Error()),
@@ -34,21 +40,12 @@ func (s *Server) HandleList(w http.ResponseWriter, r *http.Request) {
 	envs := []models.Environment{}
 	for rows.Next() {
 		var env models.Environment
-		var metadataJSON []byte
-		err := rows.Scan(
-			&env.ID, &env.VolumeName, &env.MainModule, &env.CreatedAt,
-			&env.LastExecutedAt, &env.ExecutionCount, &env.Status,
-			&metadataJSON, &env.TTLSeconds,
-		)
-		if err != nil {
+		if err := database.ScanEnvironment(rows, &env); err != nil {
 			log.Warn("failed to scan environment row",
 				slog.String("error", err.Error()),
 			)
 			continue
 		}
-		if metadataJSON != nil {
-			json.Unmarshal(metadataJSON, &env.Metadata)
-		}
 		envs = append(envs, env)
 	}
 
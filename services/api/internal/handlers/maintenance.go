@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// Maintenance describes whether executions are frozen and which environments
+// may still execute while they are
+type Maintenance struct {
+	Enabled   bool        `json:"enabled"`
+	Allowlist []uuid.UUID `json:"allowlist"`
+}
+
+// maintenanceMode is the server's current maintenance setting, shared by the
+// execute handler and the admin endpoint
+type maintenanceMode struct {
+	mu        sync.RWMutex
+	enabled   bool
+	allowlist map[uuid.UUID]bool
+}
+
+// newMaintenanceMode loads the initial setting from MAINTENANCE_MODE and the
+// comma-separated MAINTENANCE_ALLOWLIST of environment IDs
+func newMaintenanceMode() *maintenanceMode {
+	m := &maintenanceMode{allowlist: make(map[uuid.UUID]bool)}
+	m.enabled, _ = strconv.ParseBool(os.Getenv("MAINTENANCE_MODE"))
+	for _, raw := range strings.Split(os.Getenv("MAINTENANCE_ALLOWLIST"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			logger.Log.Warn("ignoring invalid environment ID in MAINTENANCE_ALLOWLIST",
+				slog.String("id", raw),
+			)
+			continue
+		}
+		m.allowlist[id] = true
+	}
+	return m
+}
+
+// blocks reports whether executions in envID are currently refused
+func (m *maintenanceMode) blocks(envID uuid.UUID) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled && !m.allowlist[envID]
+}
+
+func (m *maintenanceMode) get() Maintenance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	status := Maintenance{Enabled: m.enabled, Allowlist: make([]uuid.UUID, 0, len(m.allowlist))}
+	for id := range m.allowlist {
+		status.Allowlist = append(status.Allowlist, id)
+	}
+	return status
+}
+
+func (m *maintenanceMode) set(status Maintenance) {
+	allowlist := make(map[uuid.UUID]bool, len(status.Allowlist))
+	for _, id := range status.Allowlist {
+		allowlist[id] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = status.Enabled
+	m.allowlist = allowlist
+}
+
+// HandleGetMaintenance reports the current maintenance setting
+func (s *Server) HandleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.maintenance.get())
+}
+
+// HandleSetMaintenance replaces the maintenance setting. The allowlist in the
+// request replaces the current one; it does not take effect across restarts.
+func (s *Server) HandleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var status Maintenance
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		log.Warn("failed to decode maintenance request",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	s.maintenance.set(status)
+
+	log.Warn("maintenance mode updated",
+		slog.Bool("enabled", status.Enabled),
+		slog.Int("allowlist_size", len(status.Allowlist)),
+	)
+
+	writeJSON(w, http.StatusOK, s.maintenance.get())
+}
+
+// ReadyResponse reports whether the server is ready to take traffic
+type ReadyResponse struct {
+	Status      string `json:"status"`
+	Maintenance bool   `json:"maintenance"`
+}
+
+// HandleReady reports readiness and whether maintenance mode is on. The server
+// stays ready during maintenance since setup and delete keep working, but not
+// while PREPULL_IMAGES pulls are still running. The allowlist is left out
+// because this endpoint is served without auth.
+func (s *Server) HandleReady(w http.ResponseWriter, r *http.Request) {
+	s.maintenance.mu.RLock()
+	enabled := s.maintenance.enabled
+	s.maintenance.mu.RUnlock()
+
+	if !executor.PrepullComplete() {
+		writeJSON(w, http.StatusServiceUnavailable, ReadyResponse{
+			Status:      "pulling_images",
+			Maintenance: enabled,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ReadyResponse{
+		Status:      "ready",
+		Maintenance: enabled,
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+)
+
+func executeIn(server *Server, envID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/environments/"+envID.String()+"/execute", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+	server.HandleExecute(rec, req)
+	return rec
+}
+
+func TestMaintenance_BlocksExecutionsOutsideAllowlist(t *testing.T) {
+	allowed := uuid.New()
+	t.Setenv("MAINTENANCE_MODE", "true")
+	t.Setenv("MAINTENANCE_ALLOWLIST", allowed.String()+", not-a-uuid")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	rec := executeIn(server, uuid.New())
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "maintenance" {
+		t.Errorf("expected code 'maintenance', got '%s'", resp.Code)
+	}
+
+	if rec := executeIn(server, allowed); rec.Code != http.StatusOK {
+		t.Errorf("expected allowlisted environment to execute, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(mock.ExecuteCalls) != 1 {
+		t.Errorf("expected 1 execute call, got %d", len(mock.ExecuteCalls))
+	}
+}
+
+func TestHandleSetMaintenance(t *testing.T) {
+	server := NewServer(executor.NewMockExecutor())
+	envID := uuid.New()
+
+	body, _ := json.Marshal(Maintenance{Enabled: true})
+	req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.HandleSetMaintenance(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if rec := executeIn(server, envID); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d while in maintenance, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var ready ReadyResponse
+	json.Unmarshal(rec.Body.Bytes(), &ready)
+	if rec.Code != http.StatusOK || ready.Status != "ready" || !ready.Maintenance {
+		t.Errorf("expected ready with maintenance on, got %d %+v", rec.Code, ready)
+	}
+
+	body, _ = json.Marshal(Maintenance{Enabled: false})
+	req = httptest.NewRequest(http.MethodPut, "/admin/maintenance", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	server.HandleSetMaintenance(httptest.NewRecorder(), req)
+
+	if rec := executeIn(server, envID); rec.Code != http.StatusOK {
+		t.Errorf("expected executions to resume, got %d", rec.Code)
+	}
+}
+
+func TestHandleReady_PullingImages(t *testing.T) {
+	t.Setenv("PREPULL_IMAGES", "true")
+	server := NewServer(executor.NewMockExecutor())
+
+	rec := httptest.NewRecorder()
+	server.HandleReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	var ready ReadyResponse
+	json.Unmarshal(rec.Body.Bytes(), &ready)
+	if rec.Code != http.StatusServiceUnavailable || ready.Status != "pulling_images" {
+		t.Errorf("expected 503 pulling_images before pre-pull completes, got %d %+v", rec.Code, ready)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func (s *Server) HandlePatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var req models.UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Warn("failed to decode update request",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	log.Info("update request received",
+		slog.String("environment_id", envID.String()),
+		slog.Int("module_count", len(req.Modules)),
+		slog.Bool("has_dependencies", req.Dependencies != nil),
+	)
+
+	// Validate request
+	if req.Modules == nil && req.Dependencies == nil && req.MainModule == "" {
+		log.Warn("validation failed: nothing to update")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "at least one of modules, dependencies or mainModule is required")
+		return
+	}
+	if req.Modules != nil && len(req.Modules) == 0 {
+		log.Warn("validation failed: modules cannot be empty")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "modules cannot be empty")
+		return
+	}
+	if err := validateModules(req.Modules); err != nil {
+		log.Warn("validation failed: invalid module name",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateDependencies(req.Dependencies); err != nil {
+		log.Warn("validation failed: dependency not allowed",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if req.Modules != nil && req.MainModule != "" {
+		if _, exists := req.Modules[req.MainModule]; !exists {
+			log.Warn("validation failed: mainModule must exist in modules map",
+				slog.String("main_module", req.MainModule),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "mainModule must exist in modules map")
+			return
+		}
+	}
+	if req.Modules != nil && !checkModulePolicy(w, r, req.Modules) {
+		return
+	}
+
+	done := logger.LogOperation(ctx, "update_environment",
+		slog.String("environment_id", envID.String()),
+	)
+
+	env, err := s.Executor.UpdateEnvironment(ctx, envID, &req)
+	done(err)
+
+	if err != nil {
+		log.Error("environment update failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeExecutorError(w, err, "update_failed")
+		return
+	}
+
+	log.Info("environment updated",
+		slog.String("environment_id", env.ID.String()),
+		slog.Int("version", env.Version),
+	)
+
+	writeJSON(w, http.StatusOK, env)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func newPatchRequest(envID string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/environments/"+envID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(req, map[string]string{"id": envID})
+}
+
+func TestHandlePatch_Success(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	body, _ := json.Marshal(models.UpdateRequest{
+		Modules: map[string]string{
+			"main.ts": "export function handler() { return 'v2'; }",
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	server.HandlePatch(rec, newPatchRequest(envID.String(), body))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp models.Environment
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp.ID != envID {
+		t.Errorf("expected ID %s, got %s", envID, resp.ID)
+	}
+
+	if resp.Version != 2 {
+		t.Errorf("expected Version 2, got %d", resp.Version)
+	}
+
+	if len(mock.UpdateCalls) != 1 {
+		t.Errorf("expected 1 update call, got %d", len(mock.UpdateCalls))
+	}
+}
+
+func TestHandlePatch_EmptyUpdate(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	rec := httptest.NewRecorder()
+	server.HandlePatch(rec, newPatchRequest(uuid.New().String(), []byte("{}")))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	if len(mock.UpdateCalls) != 0 {
+		t.Errorf("expected 0 update calls, got %d", len(mock.UpdateCalls))
+	}
+}
+
+func TestHandlePatch_InvalidModuleName(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.UpdateRequest{
+		Modules: map[string]string{
+			"../escape.ts": "export function handler() {}",
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	server.HandlePatch(rec, newPatchRequest(uuid.New().String(), body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	if resp.Code != "validation_error" {
+		t.Errorf("expected code 'validation_error', got '%s'", resp.Code)
+	}
+}
+
+func TestHandlePatch_ExecutionsInFlight(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.UpdateFunc = func(ctx context.Context, envID uuid.UUID, req *models.UpdateRequest) (*models.Environment, error) {
+		return nil, &executor.Error{Code: "conflict", Message: "environment has 1 execution(s) in flight"}
+	}
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.UpdateRequest{MainModule: "other.ts"})
+
+	rec := httptest.NewRecorder()
+	server.HandlePatch(rec, newPatchRequest(uuid.New().String(), body))
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, rec.Code)
+	}
+
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	if resp.Code != "conflict" {
+		t.Errorf("expected code 'conflict', got '%s'", resp.Code)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// HandleEnvironmentReady runs an environment's health check so orchestration can
+// confirm it is functional before routing traffic to it. It responds 200 when
+// the environment is ready and 503 when it is not.
+func (s *Server) HandleEnvironmentReady(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	resp, err := s.Executor.CheckReadiness(ctx, envID)
+	if errors.Is(err, executor.ErrEnvironmentNotFound) {
+		writeErrorWithCode(w, http.StatusNotFound, "not_found", "Environment not found")
+		return
+	} else if err != nil {
+		log.Error("readiness check failed",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeExecutorError(w, err, "readiness_check_failed")
+		return
+	}
+
+	if !resp.Ready {
+		log.Warn("environment not ready",
+			slog.String("environment_id", envID.String()),
+			slog.String("reason", resp.Error),
+		)
+		writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
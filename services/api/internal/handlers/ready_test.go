@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func readyRequest(server *Server, envID uuid.UUID) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/ready", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+	server.HandleEnvironmentReady(rec, req)
+	return rec
+}
+
+func TestHandleEnvironmentReady(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ReadyFunc = func(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error) {
+		return &models.ReadinessResponse{EnvironmentID: envID, Ready: true, Checked: true, DurationMs: 42}, nil
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	rec := readyRequest(server, envID)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp models.ReadinessResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp.Ready || !resp.Checked || resp.EnvironmentID != envID {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(mock.ReadyCalls) != 1 || mock.ReadyCalls[0].EnvID != envID {
+		t.Errorf("expected one readiness check for %s, got %+v", envID, mock.ReadyCalls)
+	}
+}
+
+func TestHandleEnvironmentReady_CheckFailed(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ReadyFunc = func(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error) {
+		return &models.ReadinessResponse{
+			EnvironmentID: envID,
+			Checked:       true,
+			Error:         "health check failed with exit code 1: connection refused",
+		}, nil
+	}
+	server := NewServer(mock)
+
+	rec := readyRequest(server, uuid.New())
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	var resp models.ReadinessResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Ready || resp.Error == "" {
+		t.Errorf("expected a not-ready response with an error, got %+v", resp)
+	}
+}
+
+func TestHandleEnvironmentReady_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.ReadyFunc = func(ctx context.Context, envID uuid.UUID) (*models.ReadinessResponse, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	if rec := readyRequest(server, uuid.New()); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleSetup_InvalidHealthCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check *models.HealthCheckConfig
+	}{
+		{"unknown module", &models.HealthCheckConfig{Module: "health.ts"}},
+		{"negative timeout", &models.HealthCheckConfig{TimeoutMs: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := executor.NewMockExecutor()
+			server := NewServer(mock)
+
+			body, _ := json.Marshal(models.SetupRequest{
+				MainModule:  "main.ts",
+				Modules:     map[string]string{"main.ts": "export function handler() {}"},
+				HealthCheck: tt.check,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			server.HandleSetup(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			if len(mock.SetupCalls) != 0 {
+				t.Error("expected setup not to be called")
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"github.com/jsfour/assist-tee/internal/executor"
 )
 
 // ErrorResponse represents a JSON error response
@@ -41,3 +45,47 @@ func writeErrorWithDetails(w http.ResponseWriter, status int, message, details s
 		Details: details,
 	})
 }
+
+// executorErrorStatus maps executor error codes to HTTP statuses
+var executorErrorStatus = map[string]int{
+	"not_found":                  http.StatusNotFound,
+	"validation_error":           http.StatusBadRequest,
+	"conflict":                   http.StatusConflict,
+	"input_too_large":            http.StatusRequestEntityTooLarge,
+	"busy":                       http.StatusServiceUnavailable,
+	"image_pull_failed":          http.StatusBadGateway,
+	"environment_rate_limited":   http.StatusTooManyRequests,
+	"draining":                   http.StatusConflict,
+	"dependency_install_timeout": http.StatusGatewayTimeout,
+	"resource_abuse":             http.StatusUnprocessableEntity,
+}
+
+// executorErrorCode returns the code writeExecutorError would report for err
+func executorErrorCode(err error, fallbackCode string) string {
+	var execErr *executor.Error
+	if errors.As(err, &execErr) {
+		return execErr.Code
+	}
+	return fallbackCode
+}
+
+// writeExecutorError writes an executor error, using the error's own code and
+// status when it carries one and falling back to a 500 with fallbackCode otherwise
+func writeExecutorError(w http.ResponseWriter, err error, fallbackCode string) {
+	var execErr *executor.Error
+	if errors.As(err, &execErr) {
+		status, ok := executorErrorStatus[execErr.Code]
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		if execErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(execErr.RetryAfter.Seconds())))
+		}
+		if execErr.QueueDepth > 0 {
+			w.Header().Set("X-Queue-Depth", strconv.Itoa(execErr.QueueDepth))
+		}
+		writeErrorWithCode(w, status, execErr.Code, execErr.Message)
+		return
+	}
+	writeErrorWithCode(w, http.StatusInternalServerError, fallbackCode, err.Error())
+}
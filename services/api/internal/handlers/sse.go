@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// sseWriter writes server-sent events, flushing after each one. Events may be
+// sent from the goroutines that read container output, so writes are serialized.
+type sseWriter struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+}
+
+// newSSEWriter sends the 200 status and event stream headers.
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	s := &sseWriter{w: w}
+	s.flush()
+	return s
+}
+
+// event writes one event with v as its JSON data.
+func (s *sseWriter) event(name string, v interface{}) {
+	data, _ := json.Marshal(v)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data)
+	s.flush()
+}
+
+func (s *sseWriter) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// HandleStats serves aggregate statistics over an environment's stored
+// executions, optionally limited to a since/until window
+func (s *Server) HandleStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	vars := mux.Vars(r)
+	envID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		log.Warn("invalid environment ID",
+			slog.String("id", vars["id"]),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_id", "Invalid environment ID")
+		return
+	}
+
+	var since, until time.Time
+	for name, dest := range map[string]*time.Time{"since": &since, "until": &until} {
+		if raw := r.URL.Query().Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeErrorWithCode(w, http.StatusBadRequest, "validation_error", name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*dest = t
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "since must be before until")
+		return
+	}
+
+	if _, err := s.Executor.GetEnvironment(ctx, envID); errors.Is(err, executor.ErrEnvironmentNotFound) {
+		writeErrorWithCode(w, http.StatusNotFound, "not_found", "Environment not found")
+		return
+	} else if err != nil {
+		log.Error("failed to get environment",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	stats, err := database.ExecutionStats(ctx, envID, since, until)
+	if err != nil {
+		log.Error("failed to compute execution stats",
+			slog.String("environment_id", envID.String()),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "query_failed", err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
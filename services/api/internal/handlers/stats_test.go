@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestHandleStats_InvalidWindow(t *testing.T) {
+	server := NewServer(executor.NewMockExecutor())
+	envID := uuid.New()
+
+	for _, query := range []string{
+		"?since=yesterday",
+		"?since=2024-02-01T00:00:00Z&until=2024-01-01T00:00:00Z",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/stats"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+		rec := httptest.NewRecorder()
+
+		server.HandleStats(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", query, http.StatusBadRequest, rec.Code)
+		}
+		var resp ErrorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if resp.Code != "validation_error" {
+			t.Errorf("%s: expected code 'validation_error', got '%s'", query, resp.Code)
+		}
+	}
+}
+
+func TestHandleStats_NotFound(t *testing.T) {
+	mock := executor.NewMockExecutor()
+	mock.GetFunc = func(ctx context.Context, envID uuid.UUID) (*models.Environment, error) {
+		return nil, executor.ErrEnvironmentNotFound
+	}
+	server := NewServer(mock)
+
+	envID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/environments/"+envID.String()+"/stats", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": envID.String()})
+	rec := httptest.NewRecorder()
+
+	server.HandleStats(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// templateNamePattern restricts template names to short, URL-safe identifiers
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,64}$`)
+
+func (s *Server) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.FromContext(ctx)
+
+	if !requireContentType(w, r, "application/json") {
+		return
+	}
+
+	var tmpl models.Template
+	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
+		log.Warn("failed to decode template request",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	if !templateNamePattern.MatchString(tmpl.Name) {
+		log.Warn("validation failed: invalid template name",
+			slog.String("name", tmpl.Name),
+		)
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "name must be 1-64 letters, digits, '.', '_' or '-'")
+		return
+	}
+	if err := validateDependencies(tmpl.Dependencies); err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if err := validatePermissions(tmpl.Permissions); err != nil {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", err.Error())
+		return
+	}
+	if tmpl.TTLSeconds < 0 {
+		writeErrorWithCode(w, http.StatusBadRequest, "validation_error", "ttlSeconds must be >= 0")
+		return
+	}
+
+	if err := database.CreateTemplate(ctx, &tmpl); err != nil {
+		if errors.Is(err, database.ErrTemplateExists) {
+			writeErrorWithCode(w, http.StatusConflict, "conflict", err.Error())
+			return
+		}
+		log.Error("failed to create template",
+			slog.String("name", tmpl.Name),
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "template_failed", err.Error())
+		return
+	}
+
+	log.Info("template created",
+		slog.String("name", tmpl.Name),
+	)
+
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
+// applyTemplate fills the settings a setup request leaves unset from tmpl
+func applyTemplate(req *models.SetupRequest, tmpl *models.Template) {
+	if req.Dependencies == nil {
+		req.Dependencies = tmpl.Dependencies
+	}
+	if req.Permissions == nil {
+		req.Permissions = tmpl.Permissions
+	}
+	if req.TTLSeconds == 0 {
+		req.TTLSeconds = tmpl.TTLSeconds
+	}
+}
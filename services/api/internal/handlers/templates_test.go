@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+func TestApplyTemplate_FillsUnsetFields(t *testing.T) {
+	tmpl := &models.Template{
+		Name:         "web",
+		Dependencies: &models.Dependencies{NPM: []string{"zod@3"}},
+		Permissions:  &models.Permissions{AllowNet: []string{"api.example.com"}},
+		TTLSeconds:   600,
+	}
+	req := &models.SetupRequest{MainModule: "main.ts"}
+
+	applyTemplate(req, tmpl)
+
+	if req.Dependencies != tmpl.Dependencies {
+		t.Error("expected dependencies from template")
+	}
+	if req.Permissions != tmpl.Permissions {
+		t.Error("expected permissions from template")
+	}
+	if req.TTLSeconds != 600 {
+		t.Errorf("expected ttlSeconds 600, got %d", req.TTLSeconds)
+	}
+}
+
+func TestApplyTemplate_RequestOverrides(t *testing.T) {
+	tmpl := &models.Template{
+		Name:        "web",
+		Permissions: &models.Permissions{AllowNet: []string{"api.example.com"}},
+		TTLSeconds:  600,
+	}
+	perms := &models.Permissions{}
+	req := &models.SetupRequest{Permissions: perms, TTLSeconds: 60}
+
+	applyTemplate(req, tmpl)
+
+	if req.Permissions != perms {
+		t.Error("request permissions should override template")
+	}
+	if req.TTLSeconds != 60 {
+		t.Errorf("request ttlSeconds should override template, got %d", req.TTLSeconds)
+	}
+}
+
+func TestHandleCreateTemplate_InvalidName(t *testing.T) {
+	server := NewServer(executor.NewMockExecutor())
+
+	req := httptest.NewRequest(http.MethodPost, "/templates", bytes.NewReader([]byte(`{"name":"bad name!"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	server.HandleCreateTemplate(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
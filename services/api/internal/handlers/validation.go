@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // validate timezones even where the host has no zoneinfo
+
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+	"github.com/jsfour/assist-tee/internal/models"
+)
+
+// moduleNamePattern restricts module file names to characters that are safe to
+// embed in the volume write commands
+var moduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-/]+$`)
+
+// envNamePattern matches POSIX-style environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnvNames checks that every entry is a valid environment variable name
+func validateEnvNames(names []string) error {
+	for _, name := range names {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid env var name %q", name)
+		}
+	}
+	return nil
+}
+
+// validateModuleName checks that a module file name is relative, stays inside
+// the workspace and only uses safe characters
+func validateModuleName(name string) error {
+	if name == "" {
+		return fmt.Errorf("module name cannot be empty")
+	}
+	if !moduleNamePattern.MatchString(name) {
+		return fmt.Errorf("module name %q contains invalid characters", name)
+	}
+	if strings.HasPrefix(name, "/") {
+		return fmt.Errorf("module name %q must be a relative path", name)
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return fmt.Errorf("module name %q escapes the workspace", name)
+	}
+	return nil
+}
+
+// Limits on execute-time command-line arguments
+const (
+	maxArgs      = 64
+	maxArgLength = 4096
+)
+
+// validateArgs checks execute-time arguments. They are passed to docker as
+// separate argv entries (never through a shell), so only size and NULs matter.
+func validateArgs(args []string) error {
+	if len(args) > maxArgs {
+		return fmt.Errorf("too many args: %d exceeds the maximum of %d", len(args), maxArgs)
+	}
+	for i, arg := range args {
+		if len(arg) > maxArgLength {
+			return fmt.Errorf("arg %d exceeds %d bytes", i, maxArgLength)
+		}
+		if strings.IndexByte(arg, 0) >= 0 {
+			return fmt.Errorf("arg %d contains a NUL byte", i)
+		}
+	}
+	return nil
+}
+
+// validateEnvSize checks execute-time env vars against MAX_ENV_VALUE_BYTES per
+// value and MAX_ENV_TOTAL_BYTES for all names and values together, before they
+// reach the docker command line or the runner's stdin
+func validateEnvSize(env map[string]string) error {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	maxValue, maxTotal := maxEnvValueBytes(), maxEnvTotalBytes()
+	total := 0
+	for _, name := range names {
+		if len(env[name]) > maxValue {
+			return fmt.Errorf("env var %s exceeds %d bytes (MAX_ENV_VALUE_BYTES)", name, maxValue)
+		}
+		total += len(name) + len(env[name])
+	}
+	if total > maxTotal {
+		return fmt.Errorf("env vars total %d bytes, exceeding %d (MAX_ENV_TOTAL_BYTES)", total, maxTotal)
+	}
+	return nil
+}
+
+const (
+	maxLabels          = 16
+	maxLabelValueBytes = 256
+)
+
+// labelKeyPattern matches an execution label key
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,63}$`)
+
+// validateLabels checks an execution's labels: at most 16, keys of letters,
+// digits, '_', '.' or '-', and values of at most 256 bytes
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("too many labels: %d exceeds the maximum of %d", len(labels), maxLabels)
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("label key %q must be 1-63 letters, digits, '_', '.' or '-'", key)
+		}
+		if len(labels[key]) > maxLabelValueBytes {
+			return fmt.Errorf("label %s exceeds %d bytes", key, maxLabelValueBytes)
+		}
+	}
+	return nil
+}
+
+// validateWorkingDir checks that a working directory is a safe relative path
+// inside the workspace, using the same rules as module names
+func validateWorkingDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := validateModuleName(dir); err != nil {
+		return fmt.Errorf("invalid workingDir: %w", err)
+	}
+	return nil
+}
+
+// validateRuntimeVersion checks that a pinned runtime version is one of the
+// tags configured in RUNTIME_VERSIONS
+func validateRuntimeVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+	available := executor.RuntimeVersions()
+	for _, v := range available {
+		if v == version {
+			return nil
+		}
+	}
+	if len(available) == 0 {
+		return fmt.Errorf("runtimeVersion %q is not available: no runtime versions are configured", version)
+	}
+	return fmt.Errorf("runtimeVersion %q is not available (available: %s)", version, strings.Join(available, ", "))
+}
+
+// localePattern matches POSIX locale names such as "en", "de_DE" and "pt_BR.UTF-8"
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?(\.(UTF-8|utf8))?$`)
+
+// validateLocale checks that timezone is a known IANA zone and locale a
+// well-formed locale name. Both are optional.
+func validateLocale(timezone, locale string) error {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+			return fmt.Errorf("unknown timezone %q", timezone)
+		}
+	}
+	if locale != "" && locale != "C" && locale != "C.UTF-8" && locale != "POSIX" && !localePattern.MatchString(locale) {
+		return fmt.Errorf("invalid locale %q", locale)
+	}
+	return nil
+}
+
+// dependencyPattern restricts dependency specs to characters that are safe in
+// the shell script that caches them
+var dependencyPattern = regexp.MustCompile(`^[A-Za-z0-9@/._:~^+=%-]+$`)
+
+// jsrPackagePattern matches a jsr package, with or without the jsr: prefix and
+// a version: "@scope/name", "jsr:@scope/name@^1.0.0". Versions are limited to
+// dependencyPattern's shell-safe characters, so ranges like ">=1.0.0" or "*"
+// are not accepted.
+var jsrPackagePattern = regexp.MustCompile(`^(jsr:)?@[a-z0-9-]+/[a-z0-9-]+(@[A-Za-z0-9._~^=+-]+)?$`)
+
+// validateDependencies checks the dependency count against MAX_DEPENDENCIES,
+// that every dependency spec is safe to pass to deno cache and that deno and
+// jsr dependencies come from DENO_ALLOWED_HOSTS when set
+func validateDependencies(deps *models.Dependencies) error {
+	if deps == nil {
+		return nil
+	}
+	if count, max := deps.Count(), maxDependencies(); count > max {
+		return fmt.Errorf("too many dependencies: %d exceeds the maximum of %d", count, max)
+	}
+	for _, spec := range append(append([]string{}, deps.NPM...), deps.Deno...) {
+		if !dependencyPattern.MatchString(spec) || strings.HasPrefix(spec, "-") {
+			return fmt.Errorf("dependency %q contains invalid characters", spec)
+		}
+	}
+	for _, spec := range deps.JSR {
+		if !jsrPackagePattern.MatchString(spec) {
+			return fmt.Errorf("jsr dependency %q must look like @scope/name or @scope/name@version", spec)
+		}
+	}
+
+	allowedHosts := executor.DenoAllowedHosts()
+	if len(allowedHosts) == 0 {
+		return nil
+	}
+	if len(deps.JSR) > 0 && !executor.NetHostAllowed("jsr.io", allowedHosts) {
+		return fmt.Errorf("jsr dependencies require jsr.io in DENO_ALLOWED_HOSTS")
+	}
+	for _, spec := range deps.Deno {
+		host := denoDependencyHost(spec)
+		if host == "" {
+			return fmt.Errorf("deno dependency %q must be an http(s) URL or jsr: specifier", spec)
+		}
+		if !executor.NetHostAllowed(host, allowedHosts) {
+			return fmt.Errorf("deno dependency %q: host %s is not in DENO_ALLOWED_HOSTS", spec, host)
+		}
+	}
+	return nil
+}
+
+// denoDependencyHost returns the host a deno dependency is fetched from, or ""
+// if it is not a URL or registry specifier
+func denoDependencyHost(spec string) string {
+	if strings.HasPrefix(spec, "jsr:") {
+		return "jsr.io"
+	}
+	u, err := url.Parse(spec)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// validateProxy checks an environment's proxy override, rejecting proxy hosts
+// outside GLOBAL_NET_ALLOWLIST
+func validateProxy(proxy *models.ProxyConfig) error {
+	if err := executor.ValidateProxy(proxy); err != nil {
+		return fmt.Errorf("invalid proxy: %w", err)
+	}
+	if disallowed := executor.DisallowedProxyHosts(proxy); len(disallowed) > 0 {
+		return fmt.Errorf("proxy hosts not permitted by server policy: %s", strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+// validateHealthCheck checks that a health check names one of the setup's
+// modules and has a usable timeout
+func validateHealthCheck(check *models.HealthCheckConfig, modules map[string]string) error {
+	if check == nil {
+		return nil
+	}
+	if check.Module != "" {
+		if _, exists := modules[check.Module]; !exists {
+			return fmt.Errorf("healthCheck.module must exist in modules map")
+		}
+	}
+	if check.TimeoutMs < 0 {
+		return fmt.Errorf("healthCheck.timeoutMs cannot be negative")
+	}
+	return nil
+}
+
+// validateHooks checks that the pre- and post-execution hooks name modules of
+// the setup request
+func validateHooks(preHook, postHook string, modules map[string]string) error {
+	for _, hook := range []struct{ name, module string }{{"preHook", preHook}, {"postHook", postHook}} {
+		if hook.module == "" {
+			continue
+		}
+		if _, exists := modules[hook.module]; !exists {
+			return fmt.Errorf("%s must exist in modules map", hook.name)
+		}
+	}
+	return nil
+}
+
+// handlerNamePattern matches a JavaScript identifier the runner can look up on
+// the main module
+var handlerNamePattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]{0,127}$`)
+
+// maxHandlers bounds the handlers an environment may declare
+const maxHandlers = 64
+
+// validateHandlerName checks that a handler name is a safe identifier
+func validateHandlerName(name string) error {
+	if name != "" && !handlerNamePattern.MatchString(name) {
+		return fmt.Errorf("handlerName must be a JavaScript identifier of at most 128 characters")
+	}
+	return nil
+}
+
+// validateHandlers checks the handler name and the handlers an execute request
+// may select
+func validateHandlers(handlerName string, handlers []string) error {
+	if err := validateHandlerName(handlerName); err != nil {
+		return err
+	}
+	if len(handlers) > maxHandlers {
+		return fmt.Errorf("too many handlers: %d exceeds the maximum of %d", len(handlers), maxHandlers)
+	}
+	for _, name := range handlers {
+		if name == "" || !handlerNamePattern.MatchString(name) {
+			return fmt.Errorf("handlers entry %q must be a JavaScript identifier of at most 128 characters", name)
+		}
+	}
+	return nil
+}
+
+// validateRunsc checks an environment's runsc options against the known flags
+// and RUNSC_ALLOWED_OPTIONS
+func validateRunsc(options map[string]string) error {
+	if len(options) == 0 {
+		return nil
+	}
+	if err := executor.ValidateRunscOptions(options); err != nil {
+		return err
+	}
+	if disallowed := executor.DisallowedRunscOptions(options); len(disallowed) > 0 {
+		return fmt.Errorf("runsc options not permitted by server policy: %s", strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+// validatePermissions rejects allowNet hosts outside GLOBAL_NET_ALLOWLIST
+func validatePermissions(permissions *models.Permissions) error {
+	if permissions == nil {
+		return nil
+	}
+	if disallowed := executor.DisallowedNetHosts(permissions.AllowNet); len(disallowed) > 0 {
+		return fmt.Errorf("allowNet hosts not permitted by server policy: %s", strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+// validateModules checks the module count against MAX_MODULES_PER_ENV and
+// validates every module name in a deterministic order. Names that resolve to
+// the same file (e.g. "./main.ts" and "main.ts") are rejected, since which one
+// is written last would depend on map iteration order.
+func validateModules(modules map[string]string) error {
+	if max := maxModulesPerEnv(); len(modules) > max {
+		return fmt.Errorf("too many modules: %d exceeds the maximum of %d", len(modules), max)
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]string, len(names))
+	for _, name := range names {
+		if err := validateModuleName(name); err != nil {
+			return err
+		}
+		cleaned := path.Clean(name)
+		if other, exists := seen[cleaned]; exists {
+			return fmt.Errorf("modules %q and %q resolve to the same file %q", other, name, cleaned)
+		}
+		seen[cleaned] = name
+	}
+	return nil
+}
+
+// policyViolationResponse is the error body for modules that break the lint
+// policy, listing the offending lines
+type policyViolationResponse struct {
+	ErrorResponse
+	Violations []executor.LintViolation `json:"violations"`
+}
+
+// checkModulePolicy lints modules against the configured rules, writing a 422
+// policy_violation listing the offending lines and returning false if any match
+func checkModulePolicy(w http.ResponseWriter, r *http.Request, modules map[string]string) bool {
+	log := logger.FromContext(r.Context())
+
+	rules, err := executor.LoadedLintRules()
+	if err != nil {
+		log.Error("failed to load lint rules",
+			slog.String("error", err.Error()),
+		)
+		writeErrorWithCode(w, http.StatusInternalServerError, "lint_failed", err.Error())
+		return false
+	}
+	violations := executor.LintModules(modules, rules)
+	if len(violations) == 0 {
+		return true
+	}
+
+	log.Warn("modules rejected by lint policy",
+		slog.Int("violation_count", len(violations)),
+		slog.String("first_rule", violations[0].Rule),
+	)
+	writeJSON(w, http.StatusUnprocessableEntity, policyViolationResponse{
+		ErrorResponse: ErrorResponse{
+			Error: fmt.Sprintf("modules violate the lint policy (%s at %s:%d)", violations[0].Rule, violations[0].Module, violations[0].Line),
+			Code:  "policy_violation",
+		},
+		Violations: violations,
+	})
+	return false
+}
+
+// requireContentType checks that a request body uses one of the allowed media
+// types, writing a 415 if it does not. Requests without a body are not checked.
+func requireContentType(w http.ResponseWriter, r *http.Request, allowed ...string) bool {
+	if r.ContentLength == 0 && r.Header.Get("Content-Type") == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && containsMediaType(allowed, mediaType) {
+		return true
+	}
+
+	logger.FromContext(r.Context()).Warn("unsupported content type",
+		slog.String("content_type", r.Header.Get("Content-Type")),
+	)
+	writeErrorWithCode(w, http.StatusUnsupportedMediaType, "unsupported_media_type",
+		fmt.Sprintf("Content-Type must be %s", strings.Join(allowed, " or ")))
+	return false
+}
+
+func containsMediaType(allowed []string, mediaType string) bool {
+	for _, a := range allowed {
+		if a == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGroupDefaults checks a group's shared limits and env var names
+func validateGroupDefaults(defaults *models.GroupDefaults) error {
+	if limits := defaults.Limits; limits != nil {
+		if limits.TimeoutMs < 0 || limits.MemoryMb < 0 {
+			return fmt.Errorf("limits cannot be negative")
+		}
+		if err := validateLimits(limits); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(defaults.Env))
+	for name := range defaults.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return validateEnvNames(names)
+}
+
+// validateLimits rejects a swap limit that is negative or below the memory limit.
+// A memorySwapMb below the runtime's default memory is caught by the executor.
+func validateLimits(limits *models.ResourceLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.MemorySwapMb < 0 {
+		return fmt.Errorf("limits.memorySwapMb cannot be negative")
+	}
+	if limits.MemorySwapMb > 0 && limits.MemorySwapMb < limits.MemoryMb {
+		return fmt.Errorf("limits.memorySwapMb (%d) must be at least limits.memoryMb (%d)", limits.MemorySwapMb, limits.MemoryMb)
+	}
+	return nil
+}
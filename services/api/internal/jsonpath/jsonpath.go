@@ -0,0 +1,169 @@
+// Package jsonpath implements the subset of JSONPath used to project execution
+// results: a root "$" followed by member (".name", "['name']"), index ("[0]",
+// "[-1]") and wildcard (".*", "[*]") steps.
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxLength bounds expressions so a query parameter cannot make parsing expensive
+const maxLength = 256
+
+type stepKind int
+
+const (
+	memberStep stepKind = iota
+	indexStep
+	wildcardStep
+)
+
+type step struct {
+	kind  stepKind
+	name  string
+	index int
+}
+
+// Path is a parsed JSONPath expression.
+type Path struct {
+	steps    []step
+	wildcard bool
+}
+
+// Parse parses a JSONPath expression, rejecting anything outside the supported subset.
+func Parse(expr string) (*Path, error) {
+	if len(expr) > maxLength {
+		return nil, fmt.Errorf("jsonpath longer than %d characters", maxLength)
+	}
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath must start with '$'")
+	}
+
+	p := &Path{}
+	rest := expr[1:]
+	for rest != "" {
+		var s step
+		var err error
+		switch rest[0] {
+		case '.':
+			s, rest, err = parseDot(rest[1:])
+		case '[':
+			s, rest, err = parseBracket(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonpath %q: %w", expr, err)
+		}
+		if s.kind == wildcardStep {
+			p.wildcard = true
+		}
+		p.steps = append(p.steps, s)
+	}
+	return p, nil
+}
+
+func parseDot(rest string) (step, string, error) {
+	if strings.HasPrefix(rest, "*") {
+		return step{kind: wildcardStep}, rest[1:], nil
+	}
+	end := 0
+	for end < len(rest) && isNameChar(rest[end]) {
+		end++
+	}
+	if end == 0 {
+		return step{}, "", fmt.Errorf("expected a member name after '.'")
+	}
+	return step{kind: memberStep, name: rest[:end]}, rest[end:], nil
+}
+
+func parseBracket(rest string) (step, string, error) {
+	end := strings.IndexByte(rest, ']')
+	if end < 0 {
+		return step{}, "", fmt.Errorf("unterminated '['")
+	}
+	inner, rest := rest[:end], rest[end+1:]
+
+	switch {
+	case inner == "*":
+		return step{kind: wildcardStep}, rest, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return step{kind: memberStep, name: inner[1 : len(inner)-1]}, rest, nil
+	}
+	index, err := strconv.Atoi(inner)
+	if err != nil {
+		return step{}, "", fmt.Errorf("expected an index, quoted name or '*' in brackets, got %q", inner)
+	}
+	return step{kind: indexStep, index: index}, rest, nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '$' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Select applies the path to a decoded JSON value. Paths with a wildcard return
+// every match as a slice; other paths return the single match. ok is false when
+// a path without a wildcard matches nothing.
+func (p *Path) Select(value interface{}) (result interface{}, ok bool) {
+	matches := []interface{}{value}
+	for _, s := range p.steps {
+		var next []interface{}
+		for _, m := range matches {
+			next = append(next, s.apply(m)...)
+		}
+		matches = next
+	}
+
+	if p.wildcard {
+		if matches == nil {
+			matches = []interface{}{}
+		}
+		return matches, true
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	return matches[0], true
+}
+
+func (s step) apply(value interface{}) []interface{} {
+	switch s.kind {
+	case memberStep:
+		if obj, ok := value.(map[string]interface{}); ok {
+			if v, ok := obj[s.name]; ok {
+				return []interface{}{v}
+			}
+		}
+	case indexStep:
+		if arr, ok := value.([]interface{}); ok {
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i >= 0 && i < len(arr) {
+				return []interface{}{arr[i]}
+			}
+		}
+	case wildcardStep:
+		switch v := value.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{
+		"user": {"name": "ada", "roles": ["admin", "dev"]},
+		"items": [{"id": 1}, {"id": 2}, {"id": 3}],
+		"odd key": true
+	}`), &doc)
+
+	tests := []struct {
+		expr   string
+		want   interface{}
+		wantOK bool
+	}{
+		{"$", doc, true},
+		{"$.user.name", "ada", true},
+		{"$['user']['roles'][1]", "dev", true},
+		{"$.items[-1].id", 3.0, true},
+		{"$.items[*].id", []interface{}{1.0, 2.0, 3.0}, true},
+		{"$[\"odd key\"]", true, true},
+		{"$.user.*", []interface{}{"ada", []interface{}{"admin", "dev"}}, true},
+		{"$.missing", nil, false},
+		{"$.missing[*]", []interface{}{}, true},
+	}
+	for _, tt := range tests {
+		path, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", tt.expr, err)
+		}
+		got, ok := path.Select(doc)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%q) = %v, %v; want %v, %v", tt.expr, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "user.name", "$.", "$[", "$[abc]", "$..name", "$.a b"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
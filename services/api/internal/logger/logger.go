@@ -13,6 +13,7 @@ type contextKey string
 const (
 	RequestIDKey contextKey = "request_id"
 	LogLevelEnv  string     = "LOG_LEVEL"
+	LogFormatEnv string     = "LOG_FORMAT"
 )
 
 var (
@@ -51,6 +52,14 @@ func Init(cfg *Config) {
 		}
 	}
 
+	// Check environment variable for log format override
+	switch os.Getenv(LogFormatEnv) {
+	case "text", "TEXT":
+		cfg.JSONFormat = false
+	case "json", "JSON":
+		cfg.JSONFormat = true
+	}
+
 	opts := &slog.HandlerOptions{
 		Level:     cfg.Level,
 		AddSource: cfg.AddSource,
@@ -72,12 +81,27 @@ func WithRequestID(ctx context.Context, requestID string) *slog.Logger {
 	return Log.With(slog.String("request_id", requestID))
 }
 
-// FromContext returns a logger from context, or the default logger
+// FromContext returns a logger from context, or the default logger, carrying
+// the request ID and any attributes added with WithAttrs
 func FromContext(ctx context.Context) *slog.Logger {
+	log := Log
 	if requestID, ok := ctx.Value(RequestIDKey).(string); ok {
-		return WithRequestID(ctx, requestID)
+		log = WithRequestID(ctx, requestID)
+	}
+	if attrs, ok := ctx.Value(attrsKey{}).([]any); ok {
+		log = log.With(attrs...)
 	}
-	return Log
+	return log
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose loggers include attrs on every line, after
+// any attributes already added
+func WithAttrs(ctx context.Context, attrs ...any) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]any)
+	combined := append(append([]any{}, existing...), attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
 }
 
 // WithContext adds request ID to context
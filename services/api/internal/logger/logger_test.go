@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestInit_LogFormatEnv(t *testing.T) {
+	t.Setenv(LogFormatEnv, "text")
+	Init(nil)
+	if _, ok := Log.Handler().(*slog.TextHandler); !ok {
+		t.Errorf("expected text handler with LOG_FORMAT=text, got %T", Log.Handler())
+	}
+
+	t.Setenv(LogFormatEnv, "json")
+	Init(&Config{Level: slog.LevelInfo, JSONFormat: false})
+	if _, ok := Log.Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("expected JSON handler with LOG_FORMAT=json, got %T", Log.Handler())
+	}
+
+	t.Setenv(LogFormatEnv, "")
+	Init(nil)
+	if _, ok := Log.Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("expected JSON handler by default, got %T", Log.Handler())
+	}
+}
+
+func TestWithAttrs(t *testing.T) {
+	original := Log
+	defer func() { Log = original }()
+	var buf bytes.Buffer
+	Log = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	ctx := WithContext(context.Background(), "req-1")
+	ctx = WithAttrs(ctx, slog.String("environment_id", "env-1"))
+	ctx = WithAttrs(ctx, slog.Any("labels", map[string]string{"orderId": "42"}))
+	FromContext(ctx).Info("execution completed")
+
+	line := buf.String()
+	for _, want := range []string{`"request_id":"req-1"`, `"environment_id":"env-1"`, `"labels":{"orderId":"42"}`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %s in log line, got %s", want, line)
+		}
+	}
+}
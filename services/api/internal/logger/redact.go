@@ -0,0 +1,78 @@
+package logger
+
+import "strings"
+
+// RedactedValue replaces content scrubbed from logged payloads
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeyParts mark object keys whose values are never logged
+var sensitiveKeyParts = []string{
+	"password", "passwd", "secret", "token", "apikey", "api_key", "api-key",
+	"authorization", "credential", "private_key", "privatekey", "cookie",
+}
+
+// Redact returns a copy of a decoded JSON value that is safe to log: values of
+// secret-looking object keys (password, token, apiKey, ...) and every
+// occurrence of the given secret strings are replaced with RedactedValue.
+func Redact(value interface{}, secrets []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				redacted[key] = RedactedValue
+				continue
+			}
+			redacted[key] = Redact(item, secrets)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = Redact(item, secrets)
+		}
+		return redacted
+	case string:
+		return RedactString(v, secrets)
+	default:
+		return value
+	}
+}
+
+// RedactString replaces every occurrence of the given secrets in s with
+// RedactedValue. Empty secrets are ignored.
+func RedactString(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, RedactedValue)
+		}
+	}
+	return s
+}
+
+// minEnvSecretLength is the shortest env var value scrubbed from logged payloads
+// when its name does not look secret. Shorter values like "us" or "true" would
+// mangle unrelated text without hiding anything.
+const minEnvSecretLength = 8
+
+// EnvSecrets returns the env var values to scrub from logged payloads: every
+// value of a secret-looking name, and other values of at least 8 bytes.
+func EnvSecrets(env map[string]string) []string {
+	secrets := make([]string, 0, len(env))
+	for name, value := range env {
+		if isSensitiveKey(name) || len(value) >= minEnvSecretLength {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+func isSensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	value := map[string]interface{}{
+		"user":     "alice",
+		"password": "hunter2",
+		"nested": map[string]interface{}{
+			"apiKey": "abc",
+			"items":  []interface{}{"uses sk-live-123 here", 42.0},
+		},
+	}
+
+	got := Redact(value, []string{"sk-live-123", ""})
+
+	want := map[string]interface{}{
+		"user":     "alice",
+		"password": RedactedValue,
+		"nested": map[string]interface{}{
+			"apiKey": RedactedValue,
+			"items":  []interface{}{"uses " + RedactedValue + " here", 42.0},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if value["password"] != "hunter2" {
+		t.Error("expected the original value to be left unchanged")
+	}
+}
+
+func TestEnvSecrets(t *testing.T) {
+	env := map[string]string{
+		"REGION":       "us",
+		"DEBUG":        "true",
+		"DB_PASSWORD":  "pw",
+		"DATABASE_URL": "postgres://u:p@db/app",
+	}
+
+	got := Redact("region=us debug=true pw=pw url=postgres://u:p@db/app", EnvSecrets(env))
+
+	want := "region=us debug=true " + RedactedValue + "=" + RedactedValue + " url=" + RedactedValue
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"crypto/subtle"
 	"log/slog"
 	"net/http"
@@ -11,10 +12,21 @@ import (
 )
 
 var bearerToken string
+var bearerTokenLabel string
 var authDisabled bool
 
+// principalKey is the context key for the authenticated caller's label
+type principalKey struct{}
+
+// anonymousPrincipal is recorded for requests when auth is disabled
+const anonymousPrincipal = "anonymous"
+
 func InitAuth() error {
 	bearerToken = os.Getenv("BEARER_TOKEN")
+	bearerTokenLabel = os.Getenv("BEARER_TOKEN_LABEL")
+	if bearerTokenLabel == "" {
+		bearerTokenLabel = "default"
+	}
 	authDisabled = os.Getenv("DISABLE_BEARER_TOKEN") == "true"
 
 	if !authDisabled && bearerToken == "" {
@@ -30,6 +42,12 @@ func InitAuth() error {
 	return nil
 }
 
+// IsAuthDisabled reports whether bearer token authentication was disabled with
+// DISABLE_BEARER_TOKEN. Only valid after InitAuth.
+func IsAuthDisabled() bool {
+	return authDisabled
+}
+
 type AuthConfigError struct {
 	Message string
 }
@@ -41,13 +59,13 @@ func (e *AuthConfigError) Error() string {
 func BearerAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for health checks (required for load balancers/k8s probes)
-		if r.URL.Path == "/health" {
+		if r.URL.Path == "/health" || r.URL.Path == "/health/ready" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		if authDisabled {
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), anonymousPrincipal)))
 			return
 		}
 
@@ -83,6 +101,17 @@ func BearerAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), bearerTokenLabel)))
 	})
 }
+
+// WithPrincipal returns a context carrying the label of the authenticated caller
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Principal returns the label of the authenticated caller, or "" if unknown
+func Principal(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey{}).(string)
+	return principal
+}
@@ -212,3 +212,26 @@ func TestBearerAuth_TimingAttackResistance(t *testing.T) {
 		}
 	}
 }
+
+func TestBearerAuth_SetsPrincipal(t *testing.T) {
+	os.Setenv("BEARER_TOKEN", "valid-token")
+	os.Setenv("BEARER_TOKEN_LABEL", "ci-pipeline")
+	os.Unsetenv("DISABLE_BEARER_TOKEN")
+	defer os.Unsetenv("BEARER_TOKEN")
+	defer os.Unsetenv("BEARER_TOKEN_LABEL")
+
+	InitAuth()
+
+	var principal string
+	handler := BearerAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = Principal(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer valid-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if principal != "ci-pipeline" {
+		t.Errorf("expected principal 'ci-pipeline', got %q", principal)
+	}
+}
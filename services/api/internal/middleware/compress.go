@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the smallest response body worth gzipping; anything
+// shorter is sent as-is
+const compressMinSize = 1024
+
+// uncompressedTypes are content types that are streamed to the client and must
+// not be held back by the compressor
+var uncompressedTypes = []string{
+	"text/event-stream",
+	"application/x-ndjson",
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether the
+// body is large enough to compress, then either switches to a gzip stream or
+// passes the buffered bytes through unchanged
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	buf        []byte
+	gz         *gzip.Writer
+	decided    bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.decided {
+		return
+	}
+	w.statusCode = code
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < compressMinSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends buffered output immediately. A handler that flushes is
+// streaming, so the response is left uncompressed if nothing has been sent yet.
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// decide writes the status line and buffered body, compressing when allowed
+func (w *gzipResponseWriter) decide(allowCompression bool) error {
+	w.decided = true
+
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if allowCompression && len(w.buf) >= compressMinSize && h.Get("Content-Encoding") == "" &&
+		bodyAllowed(w.statusCode) && !isStreamingType(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close finishes the response: small bodies are written uncompressed and an
+// open gzip stream is terminated
+func (w *gzipResponseWriter) close() error {
+	if !w.decided {
+		return w.decide(false)
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Compress returns middleware that gzips response bodies for clients that send
+// Accept-Encoding: gzip. Bodies under compressMinSize, HEAD requests, and
+// streaming responses (event streams, NDJSON, or handlers that flush) are sent
+// uncompressed.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(gw, r)
+		gw.close()
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isStreamingType(contentType string) bool {
+	for _, t := range uncompressedTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
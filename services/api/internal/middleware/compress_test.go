@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress_LargeBody(t *testing.T) {
+	body := strings.Repeat(`{"id":"env"},`, 500)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/environments", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected gzip Content-Encoding, got %q", enc)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Errorf("expected compressed body smaller than %d bytes, got %d", len(body), rec.Body.Len())
+	}
+
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("failed to open gzip body: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to read gzip body: %v", err)
+	}
+	if string(decoded) != body {
+		t.Error("decompressed body does not match original")
+	}
+}
+
+func TestCompress_SkipsSmallBodies(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not found"}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/environments/x", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected no Content-Encoding for a small body, got %q", enc)
+	}
+	if rec.Body.String() != `{"error":"not found"}` {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestCompress_RequiresAcceptEncoding(t *testing.T) {
+	body := strings.Repeat("x", 4096)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+
+	for _, accept := range []string{"", "identity", "gzip;q=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/environments", nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("Accept-Encoding %q: expected no compression, got %q", accept, enc)
+		}
+		if rec.Body.String() != body {
+			t.Errorf("Accept-Encoding %q: body was altered", accept)
+		}
+	}
+}
+
+func TestCompress_SkipsStreamingResponses(t *testing.T) {
+	body := strings.Repeat("data: tick\n\n", 200)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(body))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected event streams to be uncompressed, got %q", enc)
+	}
+
+	flushing := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(body))
+	}))
+	rec = httptest.NewRecorder()
+	flushing.ServeHTTP(rec, req)
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("expected flushed responses to be uncompressed, got %q", enc)
+	}
+	if !rec.Flushed {
+		t.Error("expected flush to reach the underlying writer")
+	}
+}
+
+func TestCompress_ComposesWithRequestLogging(t *testing.T) {
+	body := strings.Repeat("y", 8192)
+	handler := RequestLogging(Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(body))
+		w.(http.Flusher).Flush()
+	})))
+
+	req := httptest.NewRequest(http.MethodPost, "/environments/x/execute", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected status %d, got %d", http.StatusAccepted, rec.Code)
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Error("expected X-Request-ID header to be preserved")
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("expected gzip Content-Encoding, got %q", enc)
+	}
+	if !rec.Flushed {
+		t.Error("expected flush to pass through the logging writer")
+	}
+}
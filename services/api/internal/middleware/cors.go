@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	defaultCORSMethods = "GET, POST, PUT, PATCH, DELETE, HEAD, OPTIONS"
+	defaultCORSHeaders = "Authorization, Content-Type, Accept, X-Request-ID"
+
+	// corsExposedHeaders are the response headers browser clients may read
+	corsExposedHeaders = "X-Request-ID, Retry-After, X-Queue-Depth, X-Environment-Status, X-Execution-Count, X-TTL-Seconds, X-Execution-Id"
+)
+
+// corsConfig holds the CORS settings read from the environment
+type corsConfig struct {
+	origins map[string]bool
+	any     bool // "*" was listed
+	methods string
+	headers string
+}
+
+func loadCORSConfig() corsConfig {
+	cfg := corsConfig{
+		origins: make(map[string]bool),
+		methods: getEnvDefault("CORS_ALLOWED_METHODS", defaultCORSMethods),
+		headers: getEnvDefault("CORS_ALLOWED_HEADERS", defaultCORSHeaders),
+	}
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch origin {
+		case "":
+		case "*":
+			cfg.any = true
+		default:
+			cfg.origins[origin] = true
+		}
+	}
+	return cfg
+}
+
+func (c corsConfig) allows(origin string) bool {
+	return c.any || c.origins[origin]
+}
+
+// CORS returns middleware that adds CORS headers for the origins listed in
+// CORS_ALLOWED_ORIGINS and answers preflight requests before they reach auth.
+// With no origins configured (the default) it adds nothing, so browsers keep
+// blocking cross-origin calls.
+func CORS(next http.Handler) http.Handler {
+	cfg := loadCORSConfig()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !cfg.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Add("Vary", "Origin")
+
+		// Preflight: the browser asks before sending the real request
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", cfg.methods)
+			h.Set("Access-Control-Allow-Headers", cfg.headers)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getEnvDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_DisabledByDefault(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+
+	handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/environments", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected no CORS headers without configured origins, got %q", got)
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://admin.example.com/, https://other.example.com")
+
+	called := false
+	handler := CORS(BearerAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})))
+
+	req := httptest.NewRequest(http.MethodOptions, "/environments/setup", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if called {
+		t.Error("preflight requests should not reach the routes")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("expected origin to be echoed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != defaultCORSHeaders {
+		t.Errorf("expected default allowed headers, got %q", got)
+	}
+}
+
+func TestCORS_ActualRequest(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://admin.example.com")
+
+	handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/environments", nil)
+	req.Header.Set("Origin", "https://admin.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://admin.example.com" {
+		t.Errorf("expected origin to be echoed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got == "" {
+		t.Error("expected exposed headers on actual requests")
+	}
+
+	req.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("expected unlisted origin to get no CORS headers, got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/jsfour/assist-tee/internal/executor"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+// FaultHeader names the request header that asks for a fault to be simulated
+const FaultHeader = "X-Fault-Inject"
+
+// FaultInjection attaches the fault requested in the X-Fault-Inject header to the
+// request context for executor.FaultInjector. It is only installed when
+// ENABLE_FAULT_INJECTION is set; it also checks the flag itself, so the header
+// is ignored if the middleware is ever wired up without it.
+func FaultInjection(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		spec := r.Header.Get(FaultHeader)
+		if spec == "" || !executor.FaultInjectionEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fault, err := executor.ParseFault(spec)
+		if err != nil {
+			logger.Log.Warn("invalid fault injection header",
+				slog.String("request_id", logger.GetRequestID(r.Context())),
+				slog.String("error", err.Error()),
+			)
+			writeError(w, http.StatusBadRequest, errorResponse{
+				Error: "invalid " + FaultHeader + " header: " + err.Error(),
+				Code:  "validation_error",
+			})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(executor.WithFault(r.Context(), fault)))
+	})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/executor"
+)
+
+func TestFaultInjection(t *testing.T) {
+	var reached bool
+	handler := FaultInjection(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The header is ignored unless the server enables fault injection
+	t.Setenv("ENABLE_FAULT_INJECTION", "")
+	req := httptest.NewRequest(http.MethodPost, "/environments/setup", nil)
+	req.Header.Set(FaultHeader, "not-a-fault")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !reached {
+		t.Errorf("expected the request to pass through, got status %d", rec.Code)
+	}
+
+	t.Setenv("ENABLE_FAULT_INJECTION", "true")
+	reached = false
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || reached {
+		t.Errorf("expected an invalid fault to be rejected, got status %d", rec.Code)
+	}
+	var resp errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code != "validation_error" || resp.Error == "" {
+		t.Errorf("expected a JSON validation_error, got %q", rec.Body.String())
+	}
+
+	// A valid fault is simulated by the wrapped executor
+	handler = FaultInjection(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		injector := executor.NewFaultInjector(executor.NewMockExecutor())
+		if _, err := injector.SetupEnvironment(r.Context(), nil); err == nil {
+			t.Error("expected the fault to reach the executor")
+		}
+	}))
+	req.Header.Set(FaultHeader, "infra_error")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
@@ -1,8 +1,12 @@
 package middleware
 
 import (
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -31,13 +35,60 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// RequestLogging returns middleware that logs HTTP requests with timing and request IDs
+// Flush forwards to the underlying writer so streaming handlers keep working
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// requestIDPattern limits incoming request IDs to a safe length and character
+// set, since they are echoed into logs and response headers
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+
+// requestIDHeaders returns the headers to take an incoming request ID from, in
+// order of precedence, from the comma-separated REQUEST_ID_HEADERS
+func requestIDHeaders() []string {
+	var headers []string
+	for _, header := range strings.Split(getEnvDefault("REQUEST_ID_HEADERS", "X-Request-ID"), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, http.CanonicalHeaderKey(header))
+		}
+	}
+	return headers
+}
+
+// incomingRequestID returns the first usable request ID among headers and the
+// header it came from. For a W3C traceparent the trace ID is used.
+func incomingRequestID(r *http.Request, headers []string) (id, header string) {
+	for _, header := range headers {
+		value := strings.TrimSpace(r.Header.Get(header))
+		if header == "Traceparent" {
+			// version-traceid-parentid-flags
+			if parts := strings.Split(value, "-"); len(parts) == 4 {
+				value = parts[1]
+			} else {
+				value = ""
+			}
+		}
+		if requestIDPattern.MatchString(value) {
+			return value, header
+		}
+	}
+	return "", ""
+}
+
+// RequestLogging returns middleware that logs HTTP requests with timing and request IDs.
+// The request ID is taken from the first of REQUEST_ID_HEADERS the request carries
+// (X-Request-ID by default) or generated, and echoed back in X-Request-ID.
 func RequestLogging(next http.Handler) http.Handler {
+	idHeaders := requestIDHeaders()
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
 		// Generate or extract request ID
-		requestID := r.Header.Get("X-Request-ID")
+		requestID, source := incomingRequestID(r, idHeaders)
 		if requestID == "" {
 			requestID = uuid.New().String()
 		}
@@ -46,8 +97,11 @@ func RequestLogging(next http.Handler) http.Handler {
 		ctx := logger.WithContext(r.Context(), requestID)
 		r = r.WithContext(ctx)
 
-		// Add request ID to response header
+		// Add request ID to response header, and preserve it under the header it came in on
 		w.Header().Set("X-Request-ID", requestID)
+		if source != "" && source != "Traceparent" {
+			w.Header().Set(source, requestID)
+		}
 
 		// Wrap response writer to capture status
 		wrapped := newResponseWriter(w)
@@ -88,19 +142,50 @@ func RequestLogging(next http.Handler) http.Handler {
 	})
 }
 
-// Recovery returns middleware that recovers from panics and logs them
+// errorResponse mirrors the API's JSON error format (handlers.ErrorResponse)
+type errorResponse struct {
+	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// writeError writes resp as a JSON error response with the given status
+func writeError(w http.ResponseWriter, status int, resp errorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Recovery returns middleware that recovers from panics and logs them.
+// Clients receive a JSON error with code internal_error and the request ID in
+// details; the panic message is only included when debug logging is enabled.
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
+				// The request ID is attached by RequestLogging further down the chain,
+				// so fall back to the response header it sets.
 				requestID := logger.GetRequestID(r.Context())
+				if requestID == "" {
+					requestID = w.Header().Get("X-Request-ID")
+				}
 				logger.Log.Error("panic recovered",
 					slog.String("request_id", requestID),
 					slog.String("method", r.Method),
 					slog.String("path", r.URL.Path),
 					slog.Any("panic", err),
 				)
-				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+
+				message := "Internal Server Error"
+				if logger.Log.Enabled(r.Context(), slog.LevelDebug) {
+					message = fmt.Sprintf("Internal Server Error: %v", err)
+				}
+
+				writeError(w, http.StatusInternalServerError, errorResponse{
+					Error:   message,
+					Code:    "internal_error",
+					Details: requestID,
+				})
 			}
 		}()
 		next.ServeHTTP(w, r)
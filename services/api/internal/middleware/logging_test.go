@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+func TestRecovery_ReturnsJSONError(t *testing.T) {
+	handler := Recovery(RequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Request-ID", "req-123")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected Content-Type application/json, got %s", ct)
+	}
+
+	var resp errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if resp.Code != "internal_error" {
+		t.Errorf("expected code 'internal_error', got '%s'", resp.Code)
+	}
+
+	if resp.Details != "req-123" {
+		t.Errorf("expected details 'req-123', got '%s'", resp.Details)
+	}
+
+	if strings.Contains(resp.Error, "boom") {
+		t.Errorf("expected panic message to be hidden outside debug mode, got '%s'", resp.Error)
+	}
+}
+
+func TestRecovery_IncludesPanicInDebugMode(t *testing.T) {
+	logger.Init(&logger.Config{Level: slog.LevelDebug, JSONFormat: true})
+	defer logger.Init(nil)
+
+	handler := Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp errorResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	if !strings.Contains(resp.Error, "boom") {
+		t.Errorf("expected panic message in debug mode, got '%s'", resp.Error)
+	}
+}
+
+func TestRequestLogging_RequestIDHeaders(t *testing.T) {
+	t.Setenv("REQUEST_ID_HEADERS", "X-Correlation-ID, traceparent, X-Request-ID")
+
+	var seen string
+	handler := RequestLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logger.GetRequestID(r.Context())
+	}))
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"precedence", map[string]string{"X-Correlation-ID": "corr-1", "X-Request-ID": "req-1"}, "corr-1"},
+		{"traceparent", map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"skips unusable", map[string]string{"X-Correlation-ID": "bad id\n", "X-Request-ID": "req-2"}, "req-2"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if seen != c.want || rec.Header().Get("X-Request-ID") != c.want {
+			t.Errorf("%s: expected request ID %q, got %q (response %q)", c.name, c.want, seen, rec.Header().Get("X-Request-ID"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("X-Correlation-ID", "corr-2")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Correlation-ID"); got != "corr-2" {
+		t.Errorf("expected incoming X-Correlation-ID to be preserved, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Error("expected a generated request ID")
+	}
+}
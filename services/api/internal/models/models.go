@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,27 +16,396 @@ type Environment struct {
 	LastExecutedAt *time.Time             `json:"lastExecutedAt,omitempty"`
 	ExecutionCount int                    `json:"executionCount"`
 	Status         string                 `json:"status"`
-	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"` // nil only when the stored metadata is malformed
 	TTLSeconds     int                    `json:"ttlSeconds"`
+	WarmedUp       bool                   `json:"warmedUp"`
+	Version        int                    `json:"version"`
+
+	// IdleTimeoutSeconds and KeepAliveOnActivity override the server's reaper settings
+	IdleTimeoutSeconds  *int  `json:"idleTimeoutSeconds,omitempty"`
+	KeepAliveOnActivity *bool `json:"keepAliveOnActivity,omitempty"`
+
+	// RefCount is the number of setups sharing a reusable environment; deleting
+	// it only removes the volume once the last reference is released
+	RefCount int `json:"refCount"`
+
+	// DiskUsageBytes is the size of the environment's volume (modules and
+	// dependency cache), measured at setup and update
+	DiskUsageBytes *int64 `json:"diskUsageBytes,omitempty"`
+
+	// GroupID names the environment group whose defaults its executions inherit
+	GroupID string `json:"groupId,omitempty"`
+
+	// CreatedBy is the bearer token label of the caller that created the
+	// environment, or its remote IP when auth is disabled
+	CreatedBy string `json:"createdBy,omitempty"`
+
+	// Reused is set on a setup response that returned an existing environment
+	Reused bool `json:"reused,omitempty"`
+
+	// Warnings are set on a setup response when parts of the request were not
+	// applied as asked
+	Warnings []string `json:"warnings,omitempty"`
+
+	// SetupDurationMs and SetupSteps are set on a setup response that provisioned
+	// the environment, showing how long setup took and where the time went
+	SetupDurationMs int64       `json:"setupDurationMs,omitempty"`
+	SetupSteps      *SetupSteps `json:"setupSteps,omitempty"`
+}
+
+// SetupSteps breaks a setup's duration down by step, in milliseconds. Steps that
+// did not run are omitted.
+type SetupSteps struct {
+	QueueMs        int64 `json:"queueMs"`                  // waiting for a setup slot
+	VolumeMs       int64 `json:"volumeMs"`                 // creating the volume
+	ModulesMs      int64 `json:"modulesMs"`                // writing the modules
+	DependenciesMs int64 `json:"dependenciesMs,omitempty"` // installing dependencies
+	ImageMs        int64 `json:"imageMs"`                  // checking or pulling the runtime image
+	WarmupMs       int64 `json:"warmupMs,omitempty"`       // the warmup execution
+	StoreMs        int64 `json:"storeMs"`                  // measuring the volume and storing the environment
 }
 
 type Dependencies struct {
 	NPM  []string `json:"npm,omitempty"`  // npm packages: ["pkg@version"]
+	JSR  []string `json:"jsr,omitempty"`  // jsr packages: ["@scope/pkg@version"]
 	Deno []string `json:"deno,omitempty"` // deno URLs: ["https://..."]
 }
 
+// Count returns the number of dependencies of every kind.
+func (d *Dependencies) Count() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.NPM) + len(d.JSR) + len(d.Deno)
+}
+
 type SetupRequest struct {
 	MainModule   string            `json:"mainModule"`
 	Modules      map[string]string `json:"modules"`
 	Dependencies *Dependencies     `json:"dependencies,omitempty"`
 	Permissions  *Permissions      `json:"permissions,omitempty"`
 	TTLSeconds   int               `json:"ttlSeconds,omitempty"`
+	Warmup       *WarmupConfig     `json:"warmup,omitempty"`
+
+	// PreHook and PostHook name modules the runner calls around the handler in
+	// the same container: PreHook's `before(event, context)` runs first and may
+	// replace the event, PostHook's `after(result, event, context)` runs last and
+	// may replace the result. Either one throwing fails the execution.
+	PreHook  string `json:"preHook,omitempty"`
+	PostHook string `json:"postHook,omitempty"`
+
+	// HandlerName is the export of mainModule the runner invokes (default
+	// "handler"), so one module can hold several handlers.
+	HandlerName string `json:"handlerName,omitempty"`
+	// Handlers lists further exports of mainModule an execute request may
+	// select with `handler`. HandlerName is always selectable.
+	Handlers []string `json:"handlers,omitempty"`
+
+	// HealthCheck declares the check GET /environments/{id}/ready runs to confirm
+	// the handler's external dependencies are reachable.
+	HealthCheck *HealthCheckConfig `json:"healthCheck,omitempty"`
+
+	// IdleTimeoutSeconds reaps the environment once it has gone this long without an
+	// execution (0 disables idle reaping). Nil uses REAP_IDLE_SECONDS.
+	IdleTimeoutSeconds *int `json:"idleTimeoutSeconds,omitempty"`
+
+	// KeepAliveOnActivity measures the TTL from the last execution instead of creation,
+	// so actively used environments are not reaped. Nil uses REAP_KEEP_ALIVE_ON_ACTIVITY.
+	KeepAliveOnActivity *bool `json:"keepAliveOnActivity,omitempty"`
+
+	// RequiredEnv lists env var names every execute request must supply.
+	RequiredEnv []string `json:"requiredEnv,omitempty"`
+
+	// PersistResults sets the environment's default for storing execution records.
+	// Defaults to true; an execute request's Persist overrides it.
+	PersistResults *bool `json:"persistResults,omitempty"`
+
+	// Template names a registered template whose settings fill in any of
+	// Dependencies, Permissions and TTLSeconds the request leaves unset.
+	Template string `json:"template,omitempty"`
+
+	// MaxExecutionsPerMinute caps how often the environment can be executed, for
+	// handlers that call rate-limited downstream APIs. 0 means no limit.
+	MaxExecutionsPerMinute int `json:"maxExecutionsPerMinute,omitempty"`
+
+	// Proxy overrides the server's outbound proxy (EXEC_HTTP_PROXY etc.) for this
+	// environment. It only applies to executions with network access.
+	Proxy *ProxyConfig `json:"proxy,omitempty"`
+
+	// DefaultData is the data executions receive when the execute request has
+	// none. Execute requests with mergeDefaults deep-merge their data over it.
+	DefaultData interface{} `json:"defaultData,omitempty"`
+
+	// Timezone and Locale set the environment's default TZ and LANG for executions
+	// that don't specify their own.
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+
+	// Reuse returns an existing, unexpired environment created from an identical
+	// setup request (also with reuse) instead of creating a new one. Only honored
+	// when the server enables ENVIRONMENT_REUSE.
+	Reuse bool `json:"reuse,omitempty"`
+
+	// RuntimeVersion pins the environment to a runtime image tag from RUNTIME_VERSIONS,
+	// so later changes to the default image don't affect it. Empty uses the default.
+	RuntimeVersion string `json:"runtimeVersion,omitempty"`
+
+	// Runsc tunes the gVisor sandbox, e.g. {"platform": "kvm"}, overriding the
+	// server's RUNSC_DEFAULT_OPTIONS. Each option must be listed in
+	// RUNSC_ALLOWED_OPTIONS.
+	Runsc map[string]string `json:"runsc,omitempty"`
+
+	// GroupID adds the environment to a group, so its executions inherit the
+	// defaults set with PATCH /groups/{groupId}.
+	GroupID string `json:"groupId,omitempty"`
+
+	// Progress, when set, is called as each setup step completes. Used for
+	// streamed (?stream=true) setup requests.
+	Progress func(SetupProgress) `json:"-"`
+
+	// CreatedBy identifies the caller, recorded as the environment's createdBy.
+	// Set by the server, never from the request body.
+	CreatedBy string `json:"-"`
+}
+
+// Setup progress stages reported through SetupRequest.Progress
+const (
+	SetupStageVolumeCreated    = "volume_created"
+	SetupStageModuleWritten    = "module_written"
+	SetupStageDependencyCached = "dependency_cached"
+	SetupStageImageReady       = "image_ready"
+	SetupStageWarmedUp         = "warmed_up"
+)
+
+// SetupProgress is one step of an environment setup. Completed and Total count
+// the modules or dependencies of the step's kind.
+type SetupProgress struct {
+	Stage      string `json:"stage"`
+	Module     string `json:"module,omitempty"`
+	Dependency string `json:"dependency,omitempty"`
+	Completed  int    `json:"completed,omitempty"`
+	Total      int    `json:"total,omitempty"`
+}
+
+// EnvironmentExportVersion is the EnvironmentExport format this server writes
+// and the only one it imports.
+const EnvironmentExportVersion = 1
+
+// EnvironmentExport is a portable copy of an environment: the setup request that
+// recreates it, rebuilt from its stored settings and the module files in its
+// volume. Importing it creates a new environment with a new ID.
+type EnvironmentExport struct {
+	FormatVersion       int          `json:"formatVersion"`
+	SourceEnvironmentID uuid.UUID    `json:"sourceEnvironmentId"`
+	ExportedAt          time.Time    `json:"exportedAt"`
+	Setup               SetupRequest `json:"setup"`
+}
+
+// ResultShape describes what a handler returns: the top-level JSON type
+// ("object", "array", "string", "number", "boolean" or "null") and, for objects,
+// the sorted key names. Stored in environment metadata as resultShape.
+type ResultShape struct {
+	Type string   `json:"type"`
+	Keys []string `json:"keys,omitempty"`
+}
+
+// ProxyConfig sets the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables for
+// containers with network access
+type ProxyConfig struct {
+	HTTPProxy  string `json:"httpProxy,omitempty"`
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+}
+
+// GroupDefaults are the settings shared by the environments of a group. Limits
+// fill in the limits an execute request leaves unset, and Env is merged under
+// the request's env vars (the request wins). Env vars still have to pass the
+// environment's allowEnv.
+type GroupDefaults struct {
+	Limits *ResourceLimits   `json:"limits,omitempty"`
+	Env    map[string]string `json:"env,omitempty"`
+}
+
+// EnvironmentGroup is a named set of environments sharing GroupDefaults
+type EnvironmentGroup struct {
+	ID          string        `json:"id"`
+	Defaults    GroupDefaults `json:"defaults"`
+	MemberCount int           `json:"memberCount"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+// Template is a named, reusable environment configuration
+type Template struct {
+	Name         string        `json:"name"`
+	Dependencies *Dependencies `json:"dependencies,omitempty"`
+	Permissions  *Permissions  `json:"permissions,omitempty"`
+	TTLSeconds   int           `json:"ttlSeconds,omitempty"`
+	CreatedAt    time.Time     `json:"createdAt"`
+}
+
+// WarmupConfig enables a setup-time warmup execution. The handler is invoked once
+// with Data (or an empty input) so module-load and top-level init errors fail the setup.
+type WarmupConfig struct {
+	Data interface{} `json:"data,omitempty"`
+}
+
+// HealthCheckConfig declares an environment's readiness check. The check runs the
+// handler exported by Module (the main module when empty) with Data, and with
+// context.healthCheck set so a shared handler can tell it apart. The environment
+// is ready when the check exits cleanly within TimeoutMs.
+type HealthCheckConfig struct {
+	Module    string      `json:"module,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	TimeoutMs int         `json:"timeoutMs,omitempty"`
+}
+
+// ReadinessResponse is the result of GET /environments/{id}/ready. Checked is
+// false when the environment declares no health check, in which case Ready only
+// means its volume exists.
+type ReadinessResponse struct {
+	EnvironmentID uuid.UUID `json:"environmentId"`
+	Ready         bool      `json:"ready"`
+	Checked       bool      `json:"checked"`
+	DurationMs    int64     `json:"durationMs,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// UpdateRequest replaces an environment's code in place. Modules are written over
+// the existing files (modules missing from the map are removed); Dependencies, when
+// set, are re-installed. MainModule optionally switches the entry point.
+type UpdateRequest struct {
+	MainModule   string            `json:"mainModule,omitempty"`
+	Modules      map[string]string `json:"modules,omitempty"`
+	Dependencies *Dependencies     `json:"dependencies,omitempty"`
 }
 
 type ExecuteRequest struct {
-	Data   interface{}     `json:"data,omitempty"`
+	Data   interface{}       `json:"data,omitempty"`
 	Env    map[string]string `json:"env,omitempty"`
-	Limits *ResourceLimits `json:"limits,omitempty"`
+	Limits *ResourceLimits   `json:"limits,omitempty"`
+
+	// DataPresent is set when the request body has a "data" field, even if it is
+	// null. An absent field gets the environment's defaultData; an explicit null
+	// is passed to the handler as null.
+	DataPresent bool `json:"-"`
+
+	// Persist controls whether the execution record and environment stats are stored.
+	// Nil uses the environment default. Also settable via the ?persist= query parameter.
+	Persist *bool `json:"persist,omitempty"`
+
+	// ExecutionID optionally sets the execution's ID so the caller can cancel it
+	// while it runs. Generated when omitted.
+	ExecutionID *uuid.UUID `json:"executionId,omitempty"`
+
+	// CallbackURL, when set, runs the execution in the background: execute
+	// returns 202 with the execution ID, and a CallbackPayload is POSTed here
+	// once it finishes. The URL must pass the server's callback SSRF checks.
+	CallbackURL string `json:"callbackUrl,omitempty"`
+
+	// DeterministicID derives the execution ID from the environment ID and the
+	// request's data, env, args and workingDir instead of generating a random
+	// one, so identical executions update the same record. Cannot be combined
+	// with ExecutionID or streamed input. Also settable via ?deterministicId=true.
+	DeterministicID bool `json:"deterministicId,omitempty"`
+
+	// Args are passed to the runtime as command-line arguments (Deno.args /
+	// process.argv) and exposed as context.args.
+	Args []string `json:"args,omitempty"`
+
+	// WorkingDir is a directory inside the workspace to run from. Defaults to the
+	// workspace root.
+	WorkingDir string `json:"workingDir,omitempty"`
+
+	// IncludeStats samples the container's memory and CPU usage while it runs and
+	// returns them as ResourceUsage. Also settable via ?stats=true.
+	IncludeStats bool `json:"includeStats,omitempty"`
+
+	// Envelope selects the stdout format: EnvelopeBare (default) returns the handler's
+	// result as is, EnvelopeFull wraps it with server-side metadata, and EnvelopeRaw
+	// makes a successful result the response body itself. Also settable via the
+	// ?envelope= query parameter.
+	Envelope string `json:"envelope,omitempty"`
+
+	// DataStream, when set, is piped to the runner as the event data instead of Data.
+	// Used for streamed (application/octet-stream) execute requests.
+	DataStream io.Reader `json:"-"`
+
+	// Select is a JSONPath expression (e.g. "$.items[*].id") projecting the result
+	// before it is returned; the stored record keeps the full result. Ignored for
+	// output that is not JSON. Also settable via the ?select= query parameter.
+	Select string `json:"select,omitempty"`
+
+	// Handler selects which export of the main module to invoke, from the
+	// environment's handlerName and handlers. Empty uses handlerName.
+	Handler string `json:"handler,omitempty"`
+
+	// Labels tag the execution with the client's own correlation data (an order
+	// ID, a job name). They are stored on the execution record, added to the
+	// execution's log lines and can filter GET /environments/{id}/executions.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// MergeDefaults deep-merges object Data over the environment's defaultData
+	// instead of replacing it.
+	MergeDefaults bool `json:"mergeDefaults,omitempty"`
+
+	// RetryWithMoreMemory re-runs the execution once with double the memory (up
+	// to MAX_MEMORY_MB) if it is killed for running out of memory. Not supported
+	// for streamed executions. Also settable via ?retryWithMoreMemory=true.
+	RetryWithMoreMemory bool `json:"retryWithMoreMemory,omitempty"`
+
+	// Priority is PriorityHigh or PriorityNormal (the default). High-priority
+	// executions may use the slots reserved by EXEC_HIGH_PRIORITY_RESERVED_PERCENT.
+	Priority string `json:"priority,omitempty"`
+
+	// Timezone (an IANA name such as "Europe/Berlin") and Locale (such as
+	// "de_DE.UTF-8") are passed to the container as TZ and LANG. Empty uses the
+	// environment's defaults, then UTC and the runtime's default locale.
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+
+	// Reproducible runs the handler with a fixed clock and random seed for
+	// golden-file testing: SOURCE_DATE_EPOCH and a frozen Date at SourceDateEpoch
+	// (seconds, default 0), and a fixed Math.random seed. Also settable via
+	// ?reproducible=true.
+	Reproducible    bool  `json:"reproducible,omitempty"`
+	SourceDateEpoch int64 `json:"sourceDateEpoch,omitempty"`
+
+	// RawStdin runs the main module as a plain stdin filter: Data (which must be a
+	// string) or DataStream is piped to the container's stdin with no JSON envelope,
+	// and the module's raw stdout is returned. The module needs no handler export.
+	RawStdin bool `json:"rawStdin,omitempty"`
+
+	// InputEncoding, when InputEncodingBase64, marks Data as a base64 string that
+	// is decoded and piped to a RawStdin module as binary. The decoded size is
+	// capped by MAX_STREAM_INPUT_BYTES.
+	InputEncoding string `json:"inputEncoding,omitempty"`
+
+	// Records, when set, runs the handler in record-streaming mode: each value a
+	// generator handler yields is written to Records as one NDJSON line as it is
+	// produced. Used for execute requests that accept application/x-ndjson.
+	Records io.Writer `json:"-"`
+}
+
+// BatchExecuteRequest runs several executions against one environment, at most
+// Concurrency (default 4) at a time
+type BatchExecuteRequest struct {
+	Items       []ExecuteRequest `json:"items"`
+	Concurrency int              `json:"concurrency,omitempty"`
+}
+
+// UnmarshalJSON decodes an execute request, recording whether "data" was present
+// so that {"data": null} can be told apart from a request without data.
+func (r *ExecuteRequest) UnmarshalJSON(b []byte) error {
+	type plain ExecuteRequest
+	if err := json.Unmarshal(b, (*plain)(r)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	_, r.DataPresent = fields["data"]
+	return nil
 }
 
 type Permissions struct {
@@ -46,6 +417,10 @@ type Permissions struct {
 	// Only env vars in this list will be forwarded from ExecuteRequest.Env to the container
 	AllowEnv []string `json:"allowEnv,omitempty"`
 
+	// StrictEnv rejects execute requests that pass env vars not listed in AllowEnv
+	// or denied by EXEC_ENV_DENIED_PREFIXES, instead of silently dropping them
+	StrictEnv bool `json:"strictEnv,omitempty"`
+
 	// File permissions (reserved for future use)
 	AllowRead  []string `json:"allowRead,omitempty"`
 	AllowWrite []string `json:"allowWrite,omitempty"`
@@ -56,9 +431,55 @@ type Permissions struct {
 	AllowHrtime bool `json:"allowHrtime,omitempty"`
 }
 
+// ResourceUsage summarizes the resources an execution's container used, sampled
+// from docker stats while it ran
+type ResourceUsage struct {
+	PeakMemoryMb float64 `json:"peakMemoryMb"`
+	CPUTimeMs    int64   `json:"cpuTimeMs"` // estimated from sampled CPU percentage
+	Samples      int     `json:"samples"`
+}
+
+// Scheduling priorities for ExecuteRequest.Priority
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+)
+
+// InputEncodingBase64 is the ExecuteRequest.InputEncoding for base64 binary data
+const InputEncodingBase64 = "base64"
+
+// Result envelope formats for ExecuteRequest.Envelope
+const (
+	EnvelopeBare = "bare"
+	EnvelopeFull = "full"
+	EnvelopeRaw  = "raw"
+)
+
 type ResourceLimits struct {
 	TimeoutMs int `json:"timeoutMs"`
 	MemoryMb  int `json:"memoryMb"`
+
+	// MemorySwapMb is the memory plus swap the container may use. Defaults to
+	// MemoryMb, which disables swap so MemoryMb is a hard ceiling.
+	MemorySwapMb int `json:"memorySwapMb,omitempty"`
+}
+
+// ExecutionRecord is a stored execution, as listed by
+// GET /environments/{id}/executions
+type ExecutionRecord struct {
+	ID          uuid.UUID         `json:"id"`
+	Status      string            `json:"status"`
+	ExitCode    *int              `json:"exitCode,omitempty"`
+	DurationMs  *int64            `json:"durationMs,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+
+	// Stdout, Stderr and Outputs are only included by
+	// GET /environments/{id}/executions/latest
+	Stdout  string                     `json:"stdout,omitempty"`
+	Stderr  string                     `json:"stderr,omitempty"`
+	Outputs map[string]json.RawMessage `json:"outputs,omitempty"`
 }
 
 type ExecutionResponse struct {
@@ -67,4 +488,80 @@ type ExecutionResponse struct {
 	Stdout     string    `json:"stdout"`
 	Stderr     string    `json:"stderr"`
 	DurationMs int64     `json:"durationMs"`
+
+	// Signal names the signal that terminated the handler (e.g. "SIGKILL"), if any,
+	// and Reason explains how it ended: "exited with code 1", "killed by timeout
+	// after 5000 ms", "killed by SIGKILL, most likely out of memory (limit 128 MB)"...
+	Signal string `json:"signal,omitempty"`
+	Reason string `json:"reason,omitempty"`
+
+	// Outputs holds the named outputs the handler recorded with context.setOutput.
+	// Binary outputs are {"encoding": "base64", "data": "..."}.
+	Outputs map[string]json.RawMessage `json:"outputs,omitempty"`
+
+	// ResourceUsage is set when the request asked for stats and at least one
+	// sample was taken before the container exited.
+	ResourceUsage *ResourceUsage `json:"resourceUsage,omitempty"`
+
+	// ContentType is the media type the handler declared for its result with
+	// context.setContentType, once checked against RESULT_CONTENT_TYPES. Used as
+	// the Content-Type of envelope=raw responses.
+	ContentType string `json:"contentType,omitempty"`
+
+	// Encoding is "base64" when Stdout and Stderr were base64-encoded because the
+	// output was not valid UTF-8 (OUTPUT_ENCODING=base64), "escape" when invalid
+	// bytes were escaped instead (the default); empty when the output was valid.
+	Encoding string `json:"encoding,omitempty"`
+
+	// Warnings describe parts of the request that were not applied as asked,
+	// e.g. env vars dropped by the environment's permissions.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Attempts and MemoryMb are set when the request asked to retry with more
+	// memory: the attempt this result is from (2 if the handler was re-run
+	// after running out of memory) and that attempt's memory limit.
+	Attempts int `json:"attempts,omitempty"`
+	MemoryMb int `json:"memoryMb,omitempty"`
+}
+
+// CallbackPayload is POSTed to an execution's CallbackURL when it finishes:
+// Execution holds the result, or Error and Code say why none was produced.
+type CallbackPayload struct {
+	ExecutionID   uuid.UUID          `json:"executionId"`
+	EnvironmentID uuid.UUID          `json:"environmentId"`
+	Execution     *ExecutionResponse `json:"execution,omitempty"`
+	Error         string             `json:"error,omitempty"`
+	Code          string             `json:"code,omitempty"`
+}
+
+// AuditEntry is one record in the append-only audit log of mutating operations
+type AuditEntry struct {
+	ID            int64      `json:"id"`
+	Actor         string     `json:"actor"`
+	Action        string     `json:"action"`
+	EnvironmentID *uuid.UUID `json:"environmentId,omitempty"`
+	Outcome       string     `json:"outcome"`
+	StatusCode    int        `json:"statusCode"`
+	RequestID     string     `json:"requestId,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// ExecutionStats summarizes an environment's stored executions over an optional
+// time window
+type ExecutionStats struct {
+	EnvironmentID  uuid.UUID         `json:"environmentId"`
+	Since          *time.Time        `json:"since,omitempty"`
+	Until          *time.Time        `json:"until,omitempty"`
+	Count          int               `json:"count"`
+	SuccessRate    float64           `json:"successRate"`
+	DurationMs     DurationQuantiles `json:"durationMs"`
+	ExitCodes      map[string]int    `json:"exitCodes"`
+	LastExecutedAt *time.Time        `json:"lastExecutedAt,omitempty"`
+}
+
+// DurationQuantiles holds execution duration percentiles in milliseconds
+type DurationQuantiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
 }
@@ -0,0 +1,124 @@
+package reaper
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jsfour/assist-tee/internal/database"
+)
+
+// fakeDB is a database/sql driver that serves canned rows to queries and
+// records the statements executed, so the reaper can run without Postgres.
+type fakeDB struct {
+	mu sync.Mutex
+	// rows answers queries containing the key, with columns naming the values
+	rows    map[string]fakeRows
+	execs   []fakeExec
+	queries []string
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+type fakeExec struct {
+	query string
+	args  []driver.Value
+}
+
+var (
+	fakeDBOnce    sync.Once
+	fakeDBCurrent *fakeDB
+)
+
+// useFakeDB points database.DB at a fresh fakeDB for the test
+func useFakeDB(t *testing.T) *fakeDB {
+	fakeDBOnce.Do(func() { sql.Register("reaperfake", fakeDriver{}) })
+	fake := &fakeDB{rows: map[string]fakeRows{}}
+	fakeDBCurrent = fake
+
+	db, err := sql.Open("reaperfake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		db.Close()
+	})
+	return fake
+}
+
+// execed returns the args of each executed statement containing query
+func (f *fakeDB) execed(query string) [][]driver.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var args [][]driver.Value
+	for _, exec := range f.execs {
+		if strings.Contains(exec.query, query) {
+			args = append(args, exec.args)
+		}
+	}
+	return args
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{db: fakeDBCurrent}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.execs = append(c.db.execs, fakeExec{query: query, args: values(args)})
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.queries = append(c.db.queries, query)
+	for key, rows := range c.db.rows {
+		if strings.Contains(query, key) {
+			return &fakeRowsCursor{rows: rows}, nil
+		}
+	}
+	return &fakeRowsCursor{}, nil
+}
+
+func values(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, arg := range args {
+		vals[i] = arg.Value
+	}
+	return vals
+}
+
+type fakeRowsCursor struct {
+	rows fakeRows
+	next int
+}
+
+func (r *fakeRowsCursor) Columns() []string { return r.rows.columns }
+func (r *fakeRowsCursor) Close() error      { return nil }
+
+func (r *fakeRowsCursor) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.values) {
+		return io.EOF
+	}
+	copy(dest, r.rows.values[r.next])
+	r.next++
+	return nil
+}
@@ -3,84 +3,167 @@ package reaper
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"fmt"
 	"log/slog"
-	"os/exec"
-	"strings"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jsfour/assist-tee/internal/database"
+	"github.com/jsfour/assist-tee/internal/executor"
 	"github.com/jsfour/assist-tee/internal/logger"
 )
 
+// Interval is how often the reaper runs
+const Interval = 5 * time.Minute
+
+// Cycle timestamps, stored as Unix nanoseconds (0 = never). startedAt stands in
+// for the last success until the first cycle completes.
+var (
+	lastRunAt     atomic.Int64
+	lastSuccessAt atomic.Int64
+	startedAt     = time.Now()
+)
+
 // StartReaper starts the background process that cleans up expired environments
 func StartReaper() {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(Interval)
 	go func() {
 		logger.Log.Info("reaper service started",
-			slog.Duration("interval", 5*time.Minute),
+			slog.Duration("interval", Interval),
 		)
 		for range ticker.C {
-			reapExpiredEnvironments()
+			runCycle(reapExpiredEnvironments)
+		}
+	}()
+}
+
+// runCycle runs one reaper pass, recovering from panics so a single bad cycle
+// cannot stop the ticker loop, and records when the reaper last succeeded
+func runCycle(reap func() error) {
+	lastRunAt.Store(time.Now().UnixNano())
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			logger.Log.Error("reaper cycle panicked",
+				slog.String("panic", fmt.Sprint(rec)),
+				slog.String("stack", string(debug.Stack())),
+			)
 		}
 	}()
+
+	if err := reap(); err != nil {
+		return
+	}
+	lastSuccessAt.Store(time.Now().UnixNano())
+}
+
+// Status describes the reaper's recent activity
+type Status struct {
+	LastRunAt     *time.Time `json:"lastRunAt,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	Interval      string     `json:"interval"`
+	Stalled       bool       `json:"stalled"`
+}
+
+// GetStatus reports the reaper's last run and last successful run. The reaper is
+// considered stalled once it has gone three intervals since it started or last
+// succeeded.
+func GetStatus() Status {
+	status := Status{Interval: Interval.String()}
+	if ns := lastRunAt.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		status.LastRunAt = &t
+	}
+
+	since := startedAt
+	if ns := lastSuccessAt.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		status.LastSuccessAt = &t
+		since = t
+	}
+	status.Stalled = time.Since(since) > 3*Interval
+	return status
 }
 
-func reapExpiredEnvironments() {
+func reapExpiredEnvironments() error {
 	ctx := context.Background()
 	log := logger.Log
 
 	log.Debug("running environment reaper")
 
-	rows, err := database.DB.QueryContext(ctx, `
-		SELECT id, volume_name, created_at, ttl_seconds
+	recoverStuckUpdates(ctx)
+
+	keepAlive, idleSeconds := reapConfig()
+	maxAgeSeconds := maxEnvironmentAgeSeconds()
+
+	// The scan may legitimately outlast DB_STATEMENT_TIMEOUT_MS on a large table
+	conn, release, err := database.LongRunningConn(ctx)
+	if err != nil {
+		log.Error("reaper failed to acquire database connection",
+			slog.String("error", err.Error()),
+		)
+		return err
+	}
+	defer release()
+
+	// Every environment is read and reapReason decides which ones go
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, volume_name, created_at, last_executed_at, ttl_seconds,
+		       idle_timeout_seconds, keep_alive_on_activity
 		FROM environments
-		WHERE created_at + (ttl_seconds || ' seconds')::interval < NOW()
 	`)
 	if err != nil {
 		log.Error("reaper query failed",
 			slog.String("error", err.Error()),
 		)
-		return
+		return err
 	}
 	defer rows.Close()
 
 	var reaped int
 	var errors int
+	now := time.Now()
 	for rows.Next() {
-		var id uuid.UUID
-		var volumeName string
-		var createdAt time.Time
-		var ttl int
-
-		if err := rows.Scan(&id, &volumeName, &createdAt, &ttl); err != nil {
+		var env reapCandidate
+		err := rows.Scan(&env.id, &env.volumeName, &env.createdAt, &env.lastExecutedAt,
+			&env.ttlSeconds, &env.idleTimeoutSeconds, &env.keepAliveOnActivity)
+		if err != nil {
 			log.Warn("failed to scan environment row",
 				slog.String("error", err.Error()),
 			)
 			errors++
 			continue
 		}
+		reason := reapReason(env, now, keepAlive, idleSeconds, maxAgeSeconds)
+		if reason == "" {
+			continue
+		}
 
-		age := time.Since(createdAt)
 		log.Info("reaping expired environment",
-			slog.String("environment_id", id.String()),
-			slog.String("volume_name", volumeName),
-			slog.Duration("age", age),
-			slog.Int("ttl_seconds", ttl),
+			slog.String("environment_id", env.id.String()),
+			slog.String("volume_name", env.volumeName),
+			slog.Duration("age", now.Sub(env.createdAt)),
+			slog.Int("ttl_seconds", env.ttlSeconds),
+			slog.String("reason", reason),
 		)
 
 		// Remove volume
-		if err := exec.Command("docker", "volume", "rm", "-f", volumeName).Run(); err != nil {
+		if err := executor.DockerCommand(ctx, "volume", "rm", "-f", env.volumeName).Run(); err != nil {
 			log.Warn("failed to remove docker volume during reap",
-				slog.String("volume_name", volumeName),
+				slog.String("volume_name", env.volumeName),
 				slog.String("error", err.Error()),
 			)
 		}
 
 		// Delete from DB
-		if _, err := database.DB.ExecContext(ctx, "DELETE FROM environments WHERE id = $1", id); err != nil {
+		if _, err := database.DB.ExecContext(ctx, "DELETE FROM environments WHERE id = $1", env.id); err != nil {
 			log.Error("failed to delete environment during reap",
-				slog.String("environment_id", id.String()),
+				slog.String("environment_id", env.id.String()),
 				slog.String("error", err.Error()),
 			)
 			errors++
@@ -90,6 +173,10 @@ func reapExpiredEnvironments() {
 		reaped++
 	}
 
+	// Environments that predate disk usage tracking are measured here rather
+	// than when read
+	executor.BackfillDiskUsage(ctx)
+
 	if reaped > 0 || errors > 0 {
 		log.Info("reaper cycle completed",
 			slog.Int("reaped", reaped),
@@ -98,6 +185,110 @@ func reapExpiredEnvironments() {
 	} else {
 		log.Debug("reaper cycle completed - no expired environments")
 	}
+	return nil
+}
+
+// reapCandidate is an environment row as the reaper reads it. The nullable
+// settings fall back to the server defaults.
+type reapCandidate struct {
+	id                  uuid.UUID
+	volumeName          string
+	createdAt           time.Time
+	lastExecutedAt      sql.NullTime
+	ttlSeconds          int
+	idleTimeoutSeconds  sql.NullInt64
+	keepAliveOnActivity sql.NullBool
+}
+
+// reapReason returns why env should be reaped at now, or "" to keep it. An
+// environment is reaped when any of:
+//   - it is older than maxAgeSeconds (0 disables), whatever its TTL or activity
+//   - its TTL has elapsed, measured from creation or (with keep-alive) from its last execution
+//   - idle reaping is enabled and it has gone idleSeconds without an execution
+//
+// Per-environment settings override the server defaults keepAlive and idleSeconds.
+func reapReason(env reapCandidate, now time.Time, keepAlive bool, idleSeconds, maxAgeSeconds int) string {
+	if maxAgeSeconds > 0 && env.createdAt.Add(time.Duration(maxAgeSeconds)*time.Second).Before(now) {
+		return "max_age"
+	}
+
+	lastActive := env.createdAt
+	if env.lastExecutedAt.Valid {
+		lastActive = env.lastExecutedAt.Time
+	}
+	if env.keepAliveOnActivity.Valid {
+		keepAlive = env.keepAliveOnActivity.Bool
+	}
+	ttlFrom := env.createdAt
+	if keepAlive {
+		ttlFrom = lastActive
+	}
+	if ttlFrom.Add(time.Duration(env.ttlSeconds) * time.Second).Before(now) {
+		return "ttl_expired"
+	}
+
+	if env.idleTimeoutSeconds.Valid {
+		idleSeconds = int(env.idleTimeoutSeconds.Int64)
+	}
+	if idleSeconds > 0 && lastActive.Add(time.Duration(idleSeconds)*time.Second).Before(now) {
+		return "idle"
+	}
+	return ""
+}
+
+// recoverStuckUpdates marks environments 'failed' that have been 'updating' for
+// longer than any update takes, which happens when the server running the update
+// crashes or restarts partway through. Their volumes may hold a mix of old and
+// new modules, so they are taken out of service rather than put back; TTL and
+// idle reaping still remove them.
+func recoverStuckUpdates(ctx context.Context) {
+	log := logger.Log
+
+	rows, err := database.DB.QueryContext(ctx, `
+		UPDATE environments SET status = 'failed'
+		WHERE status = 'updating'
+		  AND (updating_since IS NULL OR updating_since + ($1 || ' seconds')::interval < NOW())
+		RETURNING id
+	`, int(executor.StuckUpdateTimeout().Seconds()))
+	if err != nil {
+		log.Error("failed to recover stuck updates",
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		log.Warn("environment stuck updating, marked failed",
+			slog.String("environment_id", id.String()),
+		)
+	}
+}
+
+// reapConfig returns the server-wide reaper defaults: whether the TTL is measured
+// from the last execution (REAP_KEEP_ALIVE_ON_ACTIVITY) and the idle timeout in
+// seconds (REAP_IDLE_SECONDS, 0 disables idle reaping)
+func reapConfig() (keepAlive bool, idleSeconds int) {
+	keepAlive, _ = strconv.ParseBool(os.Getenv("REAP_KEEP_ALIVE_ON_ACTIVITY"))
+	idleSeconds, err := strconv.Atoi(os.Getenv("REAP_IDLE_SECONDS"))
+	if err != nil || idleSeconds < 0 {
+		idleSeconds = 0
+	}
+	return keepAlive, idleSeconds
+}
+
+// maxEnvironmentAgeSeconds returns the hard cap on environment age from
+// MAX_ENVIRONMENT_AGE_SECONDS. 0 (the default) means no cap.
+func maxEnvironmentAgeSeconds() int {
+	seconds, err := strconv.Atoi(os.Getenv("MAX_ENVIRONMENT_AGE_SECONDS"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return seconds
 }
 
 // ReconcileEnvironments reconciles the database with actual Docker volumes
@@ -108,7 +299,7 @@ func ReconcileEnvironments() error {
 	log.Info("starting environment reconciliation")
 
 	// Get all volumes from Docker
-	cmd := exec.Command("docker", "volume", "ls", "--format", "{{.Name}}")
+	cmd := executor.DockerCommand(ctx, "volume", "ls", "--format", "{{.Name}}")
 	output, err := cmd.Output()
 	if err != nil {
 		log.Error("failed to list docker volumes",
@@ -180,11 +371,11 @@ func ReconcileEnvironments() error {
 
 	var removedOrphans int
 	for volumeName := range dockerVolumes {
-		if strings.HasPrefix(volumeName, "tee-env-") && !dbVolumes[volumeName] {
+		if executor.IsEnvironmentVolume(volumeName) && !dbVolumes[volumeName] {
 			log.Warn("removing orphaned volume",
 				slog.String("volume_name", volumeName),
 			)
-			if err := exec.Command("docker", "volume", "rm", "-f", volumeName).Run(); err != nil {
+			if err := executor.DockerCommand(ctx, "volume", "rm", "-f", volumeName).Run(); err != nil {
 				log.Error("failed to remove orphaned volume",
 					slog.String("volume_name", volumeName),
 					slog.String("error", err.Error()),
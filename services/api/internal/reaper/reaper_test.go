@@ -0,0 +1,146 @@
+package reaper
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jsfour/assist-tee/internal/logger"
+)
+
+func init() {
+	logger.Init(nil)
+}
+
+func TestRunCycle_RecoversFromPanic(t *testing.T) {
+	lastRunAt.Store(0)
+	lastSuccessAt.Store(0)
+
+	runCycle(func() error { panic("scan exploded") })
+
+	status := GetStatus()
+	if status.LastRunAt == nil {
+		t.Error("expected last run to be recorded")
+	}
+	if status.LastSuccessAt != nil {
+		t.Error("a panicking cycle must not count as a success")
+	}
+}
+
+func TestRunCycle_RecordsSuccess(t *testing.T) {
+	lastRunAt.Store(0)
+	lastSuccessAt.Store(0)
+
+	runCycle(func() error { return errors.New("query failed") })
+	if GetStatus().LastSuccessAt != nil {
+		t.Error("a failed cycle must not count as a success")
+	}
+
+	runCycle(func() error { return nil })
+	status := GetStatus()
+	if status.LastSuccessAt == nil {
+		t.Fatal("expected last success to be recorded")
+	}
+	if status.Stalled {
+		t.Error("reaper should not be stalled right after a success")
+	}
+}
+
+func TestMaxEnvironmentAgeSeconds(t *testing.T) {
+	cases := map[string]int{
+		"":      0,
+		"86400": 86400,
+		"-5":    0,
+		"bogus": 0,
+	}
+	for value, want := range cases {
+		t.Setenv("MAX_ENVIRONMENT_AGE_SECONDS", value)
+		if got := maxEnvironmentAgeSeconds(); got != want {
+			t.Errorf("MAX_ENVIRONMENT_AGE_SECONDS=%q: expected %d, got %d", value, want, got)
+		}
+	}
+}
+
+func TestReapReason(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	executed := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: ago(d), Valid: true} }
+
+	tests := []struct {
+		name        string
+		env         reapCandidate
+		keepAlive   bool
+		idleSeconds int
+		maxAge      int
+		want        string
+	}{
+		{"within ttl", reapCandidate{createdAt: ago(time.Minute), ttlSeconds: 3600}, false, 0, 0, ""},
+		{"ttl expired", reapCandidate{createdAt: ago(2 * time.Hour), ttlSeconds: 3600}, false, 0, 0, "ttl_expired"},
+		{"ttl from creation despite activity", reapCandidate{createdAt: ago(2 * time.Hour), lastExecutedAt: executed(time.Minute), ttlSeconds: 3600}, false, 0, 0, "ttl_expired"},
+		{"keep-alive default", reapCandidate{createdAt: ago(2 * time.Hour), lastExecutedAt: executed(time.Minute), ttlSeconds: 3600}, true, 0, 0, ""},
+		{"keep-alive override", reapCandidate{createdAt: ago(2 * time.Hour), lastExecutedAt: executed(time.Minute), ttlSeconds: 3600, keepAliveOnActivity: sql.NullBool{Bool: true, Valid: true}}, false, 0, 0, ""},
+		{"keep-alive override off", reapCandidate{createdAt: ago(2 * time.Hour), lastExecutedAt: executed(time.Minute), ttlSeconds: 3600, keepAliveOnActivity: sql.NullBool{Valid: true}}, true, 0, 0, "ttl_expired"},
+		{"idle default", reapCandidate{createdAt: ago(10 * time.Minute), lastExecutedAt: executed(5 * time.Minute), ttlSeconds: 3600}, false, 60, 0, "idle"},
+		{"idle override", reapCandidate{createdAt: ago(10 * time.Minute), ttlSeconds: 3600, idleTimeoutSeconds: sql.NullInt64{Int64: 60, Valid: true}}, false, 0, 0, "idle"},
+		{"idle override disables", reapCandidate{createdAt: ago(10 * time.Minute), ttlSeconds: 3600, idleTimeoutSeconds: sql.NullInt64{Valid: true}}, false, 60, 0, ""},
+		{"recently active", reapCandidate{createdAt: ago(10 * time.Minute), lastExecutedAt: executed(time.Second), ttlSeconds: 3600}, false, 60, 0, ""},
+		{"max age", reapCandidate{createdAt: ago(48 * time.Hour), lastExecutedAt: executed(time.Second), ttlSeconds: 3600}, true, 0, 86400, "max_age"},
+	}
+	for _, tt := range tests {
+		if got := reapReason(tt.env, now, tt.keepAlive, tt.idleSeconds, tt.maxAge); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestReapExpiredEnvironments(t *testing.T) {
+	fake := useFakeDB(t)
+	t.Setenv("REAP_IDLE_SECONDS", "")
+	t.Setenv("REAP_KEEP_ALIVE_ON_ACTIVITY", "")
+	t.Setenv("MAX_ENVIRONMENT_AGE_SECONDS", "")
+
+	// docker volume rm records the volumes it is asked to remove
+	bin, removed := t.TempDir(), filepath.Join(t.TempDir(), "removed")
+	script := fmt.Sprintf("#!/bin/sh\n[ \"$1 $2\" = \"volume rm\" ] && echo \"$4\" >> %s\nexit 0\n", removed)
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	now := time.Now()
+	fresh, expired, kept, idle := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	fake.rows["idle_timeout_seconds, keep_alive_on_activity"] = fakeRows{
+		columns: []string{"id", "volume_name", "created_at", "last_executed_at", "ttl_seconds", "idle_timeout_seconds", "keep_alive_on_activity"},
+		values: [][]driver.Value{
+			{fresh.String(), "tee-env-fresh", now.Add(-time.Minute), nil, int64(3600), nil, nil},
+			{expired.String(), "tee-env-expired", now.Add(-2 * time.Hour), nil, int64(3600), nil, nil},
+			{kept.String(), "tee-env-kept", now.Add(-2 * time.Hour), now.Add(-time.Minute), int64(3600), nil, true},
+			{idle.String(), "tee-env-idle", now.Add(-10 * time.Minute), now.Add(-5 * time.Minute), int64(3600), int64(60), nil},
+		},
+	}
+
+	if err := reapExpiredEnvironments(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var deleted []string
+	for _, args := range fake.execed("DELETE FROM environments") {
+		deleted = append(deleted, args[0].(string))
+	}
+	if want := []string{expired.String(), idle.String()}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("expected %v to be deleted, got %v", want, deleted)
+	}
+
+	volumes, _ := os.ReadFile(removed)
+	if got := strings.Fields(string(volumes)); !reflect.DeepEqual(got, []string{"tee-env-expired", "tee-env-idle"}) {
+		t.Errorf("expected the reaped volumes to be removed, got %v", got)
+	}
+}
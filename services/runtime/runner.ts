@@ -9,19 +9,33 @@
  * This script runs inside the execution container and:
  * 1. Reads execution parameters from stdin (JSON)
  * 2. Loads the user's module from /workspace
- * 3. Calls the user's exported `handler(event, context)` function
+ * 3. Calls the user's exported `handler(event, context)` function (or the export
+ *    named by `context.handlerName`)
  * 4. Writes the result to stdout as JSON
  */
 
 interface ExecutionEvent {
   env?: Record<string, string>;
   data?: unknown;
+  stream?: ReadableStream<Uint8Array>; // raw input bytes for streamed executions
 }
 
 interface ExecutionContext {
   executionId: string;
   environmentId: string;
   requestId: string;
+  warmup?: boolean; // true for the setup-time warmup invocation
+  healthCheck?: boolean; // true for GET /environments/{id}/ready checks
+  preHook?: string; // module whose before(event, context) runs ahead of the handler
+  postHook?: string; // module whose after(result, event, context) runs after the handler
+  handlerName?: string; // export of the main module to invoke (default "handler")
+  streamedData?: boolean; // true when raw data follows the JSON header on stdin
+  dataPresent?: boolean; // false when the request sent no data (event.data is then null or the default)
+  args?: string[]; // command-line args, also available as Deno.args
+  workingDir?: string; // directory within /workspace to run from
+  streamRecords?: boolean; // true when yielded records are streamed back as NDJSON
+  setOutput?: (name: string, value: unknown) => void; // record a named output
+  setContentType?: (type: string) => void; // declare the result's media type for raw responses
 }
 
 interface ExecutionInput {
@@ -31,8 +45,11 @@ interface ExecutionInput {
 }
 
 interface ExecutionOutput {
+  teeEnvelope: 1; // marks this as the runner's envelope rather than handler output
   success: boolean;
   result?: unknown;
+  outputs?: Record<string, unknown>;
+  contentType?: string;
   error?: string;
   stack?: string;
   logs?: LogEntry[];
@@ -52,9 +69,36 @@ interface TimingInfo {
   totalMs: number;
 }
 
+/**
+ * Import a hook module and return its named export, failing the execution if
+ * the module does not provide it.
+ */
+async function loadHook(
+  moduleName: string,
+  exportName: "before" | "after",
+): Promise<(...args: unknown[]) => unknown> {
+  const hookModule = await import(`/workspace/${moduleName}`);
+  if (typeof hookModule[exportName] !== "function") {
+    throw new Error(
+      `Hook module '${moduleName}' does not export a '${exportName}' function.`
+    );
+  }
+  return hookModule[exportName];
+}
+
 // Captured logs from user code
 const capturedLogs: LogEntry[] = [];
 
+// Named outputs recorded with context.setOutput
+const namedOutputs: Record<string, unknown> = {};
+const MAX_OUTPUTS = 64;
+const MAX_OUTPUT_NAME_LENGTH = 128;
+
+// Media type declared with context.setContentType; the server checks it
+// against its allowlist
+let declaredContentType: string | undefined;
+const MAX_CONTENT_TYPE_LENGTH = 255;
+
 // Timing information
 const timings: Record<string, number> = {};
 const startTime = performance.now();
@@ -126,31 +170,169 @@ function recordTiming(phase: string, startMs: number): void {
   timings[phase] = performance.now() - startMs;
 }
 
-async function readStdin(): Promise<string> {
+/**
+ * Freeze Date at TEE_FROZEN_TIME (seconds since the epoch) for reproducible
+ * executions: Date.now() and `new Date()` return that instant. Dates built from
+ * explicit arguments are unaffected, and performance.now() keeps running.
+ */
+function freezeClock(): void {
+  const frozen = Deno.env.get("TEE_FROZEN_TIME");
+  if (!frozen) return;
+
+  const frozenMs = Number(frozen) * 1000;
+  const RealDate = Date;
+  class FrozenDate extends RealDate {
+    constructor(...args: unknown[]) {
+      if (args.length === 0) {
+        super(frozenMs);
+      } else {
+        // @ts-ignore: forward the caller's arguments to Date unchanged
+        super(...args);
+      }
+    }
+
+    static override now(): number {
+      return frozenMs;
+    }
+  }
+  globalThis.Date = FrozenDate as DateConstructor;
+  debugLog("clock frozen", { frozenAt: new RealDate(frozenMs).toISOString() });
+}
+
+interface StdinInput {
+  header: string;
+  reader: ReadableStreamDefaultReader<Uint8Array>;
+  remainder: Uint8Array; // bytes read past the header line
+}
+
+/**
+ * Read the JSON header from stdin. The header is terminated by a newline or EOF;
+ * anything after the newline is left unread for streamed executions.
+ */
+async function readStdin(): Promise<StdinInput> {
   const phaseStart = performance.now();
   debugLog("reading stdin");
 
-  const chunks: Uint8Array[] = [];
-  for await (const chunk of Deno.stdin.readable) {
-    chunks.push(chunk);
-  }
+  const reader = Deno.stdin.readable.getReader();
+  let buffered = new Uint8Array(0);
+  let newlineIndex = -1;
 
-  const totalLength = chunks.reduce((acc, chunk) => acc + chunk.length, 0);
-  const combined = new Uint8Array(totalLength);
+  while (newlineIndex < 0) {
+    const { value, done } = await reader.read();
+    if (done) break;
 
-  let offset = 0;
-  for (const chunk of chunks) {
-    combined.set(chunk, offset);
-    offset += chunk.length;
+    const combined = new Uint8Array(buffered.length + value.length);
+    combined.set(buffered, 0);
+    combined.set(value, buffered.length);
+    newlineIndex = combined.indexOf(0x0a, buffered.length);
+    buffered = combined;
   }
 
-  const decoder = new TextDecoder();
-  const content = decoder.decode(combined);
+  const headerEnd = newlineIndex < 0 ? buffered.length : newlineIndex;
+  const header = new TextDecoder().decode(buffered.subarray(0, headerEnd));
+  const remainder = newlineIndex < 0 ? new Uint8Array(0) : buffered.subarray(newlineIndex + 1);
 
   recordTiming("stdinReadMs", phaseStart);
-  debugLog("stdin read complete", { bytes: totalLength });
+  debugLog("stdin header read", { bytes: headerEnd });
+
+  return { header, reader, remainder };
+}
 
-  return content;
+/**
+ * Expose the rest of stdin, starting with any bytes already buffered, as a stream
+ */
+function remainingStdin(stdin: StdinInput): ReadableStream<Uint8Array> {
+  let pending: Uint8Array | null = stdin.remainder.length > 0 ? stdin.remainder : null;
+  return new ReadableStream<Uint8Array>({
+    async pull(controller) {
+      if (pending) {
+        controller.enqueue(pending);
+        pending = null;
+        return;
+      }
+      const { value, done } = await stdin.reader.read();
+      if (done) {
+        controller.close();
+      } else {
+        controller.enqueue(value);
+      }
+    },
+    cancel(reason) {
+      return stdin.reader.cancel(reason);
+    },
+  });
+}
+
+/**
+ * Record a named output. Binary values (Uint8Array/ArrayBuffer) are sent as
+ * `{"encoding":"base64","data":"..."}`; anything else must be JSON-serializable.
+ */
+function setOutput(name: string, value: unknown): void {
+  if (typeof name !== "string" || name.length === 0 || name.length > MAX_OUTPUT_NAME_LENGTH) {
+    throw new Error(`Output name must be 1-${MAX_OUTPUT_NAME_LENGTH} characters`);
+  }
+  if (!(name in namedOutputs) && Object.keys(namedOutputs).length >= MAX_OUTPUTS) {
+    throw new Error(`Too many outputs: at most ${MAX_OUTPUTS} are allowed`);
+  }
+
+  if (value instanceof ArrayBuffer) {
+    value = new Uint8Array(value);
+  }
+  if (value instanceof Uint8Array) {
+    let binary = "";
+    for (const byte of value) binary += String.fromCharCode(byte);
+    value = { encoding: "base64", data: btoa(binary) };
+  }
+  namedOutputs[name] = value ?? null;
+}
+
+/**
+ * Declare the media type of the result (e.g. "text/html; charset=utf-8"), used
+ * as the Content-Type when the caller asks for the raw result.
+ */
+function setContentType(type: string): void {
+  if (typeof type !== "string" || type.length === 0 || type.length > MAX_CONTENT_TYPE_LENGTH) {
+    throw new Error(`Content type must be 1-${MAX_CONTENT_TYPE_LENGTH} characters`);
+  }
+  declaredContentType = type;
+}
+
+/**
+ * Write one record yielded by a streaming handler to stdout as an NDJSON line.
+ * Protocol: each record is `{"type":"record","value":<value>}` followed by a
+ * newline; the final ExecutionOutput is written last without a type.
+ */
+async function writeRecord(value: unknown): Promise<void> {
+  const line = JSON.stringify({ type: "record", value: value ?? null }) + "\n";
+  await Deno.stdout.write(new TextEncoder().encode(line));
+}
+
+/**
+ * Whether a handler's return value is a (sync or async) generator/iterator
+ */
+function isRecordIterator(value: unknown): value is Iterator<unknown> | AsyncIterator<unknown> {
+  if (value === null || typeof value !== "object") return false;
+  const candidate = value as Record<PropertyKey, unknown>;
+  return typeof candidate.next === "function" &&
+    (Symbol.asyncIterator in candidate || Symbol.iterator in candidate);
+}
+
+/**
+ * Stream every record from a generator handler, returning its return value
+ */
+async function drainRecords(
+  iterator: Iterator<unknown> | AsyncIterator<unknown>,
+): Promise<unknown> {
+  let count = 0;
+  while (true) {
+    const { value, done } = await iterator.next();
+    if (done) {
+      debugLog("record stream finished", { records: count });
+      return value;
+    }
+    await writeRecord(value);
+    count++;
+  }
 }
 
 async function main() {
@@ -165,13 +347,18 @@ async function main() {
 
   try {
     // 1. Read stdin as JSON
-    const stdinContent = await readStdin();
+    const stdin = await readStdin();
 
-    if (!stdinContent.trim()) {
+    if (!stdin.header.trim()) {
       throw new Error("No input provided via stdin");
     }
 
-    const input: ExecutionInput = JSON.parse(stdinContent);
+    const input: ExecutionInput = JSON.parse(stdin.header);
+
+    // Streamed data is handed to the handler unread rather than buffered
+    if (input.context.streamedData) {
+      input.event.stream = remainingStdin(stdin);
+    }
 
     debugLog("input parsed", {
       executionId: input.context.executionId,
@@ -191,6 +378,16 @@ async function main() {
       }
     }
 
+    // Run from the requested directory inside the workspace
+    if (input.context.workingDir) {
+      const workingDir = `/workspace/${input.context.workingDir}`;
+      debugLog("changing working directory", { workingDir });
+      Deno.chdir(workingDir);
+    }
+
+    // Reproducible executions see a fixed clock from module load onwards
+    freezeClock();
+
     // 3. Load user module
     const moduleLoadStart = performance.now();
     const modulePath = `/workspace/${input.mainModule}`;
@@ -200,25 +397,55 @@ async function main() {
     const module = await import(modulePath);
 
     recordTiming("moduleLoadMs", moduleLoadStart);
+    const handlerName = input.context.handlerName ?? "handler";
+    const handler = module[handlerName];
     debugLog("module loaded", {
       exports: Object.keys(module),
-      hasHandler: typeof module.handler === "function",
+      handlerName,
+      hasHandler: typeof handler === "function",
     });
 
-    if (typeof module.handler !== "function") {
+    if (typeof handler !== "function") {
       throw new Error(
-        `Module '${input.mainModule}' does not export a 'handler' function.\n` +
-        `Expected: export async function handler(event, context) { ... }`
+        `Module '${input.mainModule}' does not export a '${handlerName}' function.\n` +
+        `Expected: export async function ${handlerName}(event, context) { ... }`
       );
     }
 
-    // 4. Call user's handler
+    const before = input.context.preHook ? await loadHook(input.context.preHook, "before") : undefined;
+    const after = input.context.postHook ? await loadHook(input.context.postHook, "after") : undefined;
+
+    // 4. Call user's handler, between the pre- and post-execution hooks
+    input.context.setOutput = setOutput;
+    input.context.setContentType = setContentType;
     const handlerStart = performance.now();
+
+    if (before) {
+      debugLog("calling pre-execution hook", { module: input.context.preHook });
+      const event = await before(input.event, input.context);
+      if (event !== undefined) {
+        input.event = event as ExecutionEvent;
+      }
+    }
+
     debugLog("calling handler", {
       executionId: input.context.executionId,
     });
 
-    const result = await module.handler(input.event, input.context);
+    let result = await handler(input.event, input.context);
+
+    // Generator handlers stream their records; the generator's return value is the result
+    if (input.context.streamRecords && isRecordIterator(result)) {
+      result = await drainRecords(result);
+    }
+
+    if (after) {
+      debugLog("calling post-execution hook", { module: input.context.postHook });
+      const hookResult = await after(result, input.event, input.context);
+      if (hookResult !== undefined) {
+        result = hookResult;
+      }
+    }
 
     recordTiming("handlerExecutionMs", handlerStart);
     debugLog("handler completed", {
@@ -236,8 +463,11 @@ async function main() {
 
     // 6. Write success result to stdout
     const output: ExecutionOutput = {
+      teeEnvelope: 1,
       success: true,
       result: result,
+      outputs: Object.keys(namedOutputs).length > 0 ? namedOutputs : undefined,
+      contentType: declaredContentType,
       logs: capturedLogs.length > 0 ? capturedLogs : undefined,
       timing: DEBUG ? timing : undefined,
     };
@@ -283,6 +513,7 @@ async function main() {
 
     // Write error to stdout as structured JSON
     const output: ExecutionOutput = {
+      teeEnvelope: 1,
       success: false,
       error: errorMessage,
       stack: errorStack,